@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+type attachTool struct{ mockTool }
+
+func (t *attachTool) Execute(ctx context.Context, _ map[string]any) (string, error) {
+	if !tools.Attach(ctx, llm.ImagePath("/tmp/cat.png")) {
+		return "", nil
+	}
+	return "attached", nil
+}
+
+func TestToolAttachmentsReachNextRequest(t *testing.T) {
+	client := newMockClient(toolCallResponse("look"), finalResponse("a cat"))
+	reg := baseRegistry()
+	reg.Register(&attachTool{mockTool{name: "look"}})
+
+	e := New(client, reg, baseCfg(), DefaultPromptSpec())
+	if _, _, err := e.Run(context.Background(), "what is it?", RunOptions{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	calls := client.allCalls()
+	if len(calls) != 2 {
+		t.Fatalf("calls = %d, want 2", len(calls))
+	}
+	msgs := calls[1].Messages
+	last := msgs[len(msgs)-1]
+	if last.Role != "user" || len(last.Parts) != 1 || last.Parts[0].Path != "/tmp/cat.png" {
+		t.Fatalf("last message = %+v, want the attached image", last)
+	}
+}
+
+func TestRunOptionsPartsOnTaskMessage(t *testing.T) {
+	client := newMockClient(finalResponse("ok"))
+	e := New(client, baseRegistry(), baseCfg(), DefaultPromptSpec())
+	parts := []llm.ContentPart{llm.ImageURL("https://example.com/a.png")}
+	if _, _, err := e.Run(context.Background(), "describe", RunOptions{Parts: parts}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	found := false
+	for _, m := range client.allCalls()[0].Messages {
+		if m.Role == "user" && m.HasImages() {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("task message lost its image parts")
+	}
+}
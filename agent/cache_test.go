@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+func TestEngineMarksCacheablePrefix(t *testing.T) {
+	client := newMockClient(finalResponse("done"))
+	e := New(client, baseRegistry(), baseCfg(), DefaultPromptSpec())
+	history := []llm.Message{
+		{Role: "user", Content: "hi", Cache: true},
+		{Role: "assistant", Content: "hello"},
+	}
+	if _, _, err := e.Run(context.Background(), "task", RunOptions{History: history}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	msgs := client.allCalls()[0].Messages
+	var marked []string
+	for _, m := range msgs {
+		if m.Cache {
+			marked = append(marked, m.Role+":"+m.Content)
+		}
+	}
+	if len(marked) != 3 || msgs[0].Role != "system" || !msgs[0].Cache ||
+		marked[1] != "assistant:hello" || marked[2] != "user:task" {
+		t.Fatalf("cache-marked messages = %q", marked)
+	}
+}
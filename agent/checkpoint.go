@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/secrets"
+)
+
+// CheckpointStore persists the loop state of a run, keyed by run id, so the
+// run can continue after a crash or restart (see Engine.ResumeCheckpoint).
+type CheckpointStore interface {
+	Save(ctx context.Context, runID string, state []byte) error
+	Load(ctx context.Context, runID string) ([]byte, bool, error)
+	Delete(ctx context.Context, runID string) error
+}
+
+// WithCheckpointStore makes the engine checkpoint the full loop state before
+// every step. The checkpoint is deleted once the run returns a final answer.
+func WithCheckpointStore(s CheckpointStore) Option {
+	return func(e *Engine) {
+		if s != nil {
+			e.checkpoints = s
+		}
+	}
+}
+
+func (e *Engine) saveCheckpoint(ctx context.Context, st *engineLoopState, step int) {
+	if e.checkpoints == nil || st.pendingTool != nil || strings.TrimSpace(st.runID) == "" {
+		return
+	}
+	b, err := marshalResumeState(resumeStateV1{
+		RunID:             st.runID,
+		Model:             st.model,
+		Step:              step,
+		PlanRequired:      st.planRequired,
+		ParseFailures:     st.parseFailures,
+		SkillAuthProfiles: append([]string{}, st.skillAuthProfiles...),
+		EnforceSkillAuth:  st.enforceSkillAuth,
+		Messages:          st.messages,
+		ExtraParams:       st.extraParams,
+		AgentCtx:          snapshotFromContext(st.agentCtx),
+	})
+	if err == nil {
+		err = e.checkpoints.Save(ctx, st.runID, b)
+	}
+	if err != nil {
+		st.log.Warn("checkpoint_save_error", "step", step, "error", err.Error())
+		return
+	}
+	st.log.Debug("checkpoint_saved", "step", step, "bytes", len(b))
+}
+
+func (e *Engine) deleteCheckpoint(ctx context.Context, st *engineLoopState) {
+	if e.checkpoints == nil || strings.TrimSpace(st.runID) == "" {
+		return
+	}
+	if err := e.checkpoints.Delete(context.WithoutCancel(ctx), st.runID); err != nil {
+		st.log.Warn("checkpoint_delete_error", "error", err.Error())
+	}
+}
+
+// ResumeCheckpoint continues a run from the last checkpoint saved for runID.
+func (e *Engine) ResumeCheckpoint(ctx context.Context, runID string) (*Final, *Context, error) {
+	if e == nil || e.checkpoints == nil {
+		return nil, nil, fmt.Errorf("checkpoint store is not configured")
+	}
+	id := strings.TrimSpace(runID)
+	if id == "" {
+		return nil, nil, fmt.Errorf("missing run_id")
+	}
+
+	b, ok, err := e.checkpoints.Load(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("checkpoint not found: %s", id)
+	}
+	rs, err := unmarshalResumeState(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rs.Version != 1 {
+		return nil, nil, fmt.Errorf("unsupported checkpoint version: %d", rs.Version)
+	}
+
+	ctx = secrets.WithSkillAuthProfilePolicy(ctx, rs.SkillAuthProfiles, rs.EnforceSkillAuth)
+
+	agentCtx := contextFromSnapshot(rs.AgentCtx)
+	log := e.log.With("run_id", rs.RunID, "model", rs.Model)
+	log.Info("run_resume_checkpoint", "step", rs.Step, "messages", len(rs.Messages))
+
+	return e.runLoop(ctx, &engineLoopState{
+		runID:           rs.RunID,
+		model:           rs.Model,
+		log:             log,
+		messages:        rs.Messages,
+		agentCtx:        agentCtx,
+		extraParams:     rs.ExtraParams,
+		tools:           buildLLMTools(e.registry),
+		planRequired:    rs.PlanRequired,
+		parseFailures:   rs.ParseFailures,
+		requestedWrites: ExtractFileWritePaths(agentCtx.Task),
+		nextStep:        rs.Step,
+
+		skillAuthProfiles: rs.SkillAuthProfiles,
+		enforceSkillAuth:  rs.EnforceSkillAuth,
+	})
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/internal/fsstore"
+)
+
+// FileCheckpointStore stores one JSON file per run under dir.
+type FileCheckpointStore struct {
+	dir string
+}
+
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("missing checkpoint dir")
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+func (s *FileCheckpointStore) Save(_ context.Context, runID string, state []byte) error {
+	path, err := s.path(runID)
+	if err != nil {
+		return err
+	}
+	return fsstore.WriteTextAtomic(path, string(state), fsstore.FileOptions{})
+}
+
+func (s *FileCheckpointStore) Load(_ context.Context, runID string) ([]byte, bool, error) {
+	path, err := s.path(runID)
+	if err != nil {
+		return nil, false, err
+	}
+	text, ok, err := fsstore.ReadText(path)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	return []byte(text), true, nil
+}
+
+func (s *FileCheckpointStore) Delete(_ context.Context, runID string) error {
+	path, err := s.path(runID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileCheckpointStore) path(runID string) (string, error) {
+	if s == nil {
+		return "", fmt.Errorf("nil checkpoint store")
+	}
+	runID = strings.TrimSpace(runID)
+	if runID == "" {
+		return "", fmt.Errorf("missing run_id")
+	}
+	for _, r := range runID {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			continue
+		}
+		return "", fmt.Errorf("invalid run_id: %q", runID)
+	}
+	return filepath.Join(s.dir, runID+".json"), nil
+}
@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/secrets"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+type memCheckpointStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemCheckpointStore() *memCheckpointStore {
+	return &memCheckpointStore{data: map[string][]byte{}}
+}
+
+func (s *memCheckpointStore) Save(_ context.Context, runID string, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[runID] = append([]byte{}, state...)
+	return nil
+}
+
+func (s *memCheckpointStore) Load(_ context.Context, runID string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.data[runID]
+	return b, ok, nil
+}
+
+func (s *memCheckpointStore) Delete(_ context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, runID)
+	return nil
+}
+
+func TestCheckpoint_ResumeAfterFailure(t *testing.T) {
+	store := newMemCheckpointStore()
+	reg := tools.NewRegistry()
+	reg.Register(&bigOutputTool{size: 10})
+
+	// The client runs out of responses after one tool call, failing step 1.
+	first := newMockClient(bigCall(0))
+	e := New(first, reg, baseCfg(), DefaultPromptSpec(), WithCheckpointStore(store))
+	if _, _, err := e.Run(context.Background(), "the task", RunOptions{RunID: "run-1"}); err == nil {
+		t.Fatalf("expected first run to fail")
+	}
+	b, ok, _ := store.Load(context.Background(), "run-1")
+	if !ok {
+		t.Fatalf("expected a checkpoint for run-1")
+	}
+	rs, err := unmarshalResumeState(b)
+	if err != nil {
+		t.Fatalf("unmarshal checkpoint: %v", err)
+	}
+	if rs.Step != 1 || len(rs.AgentCtx.Steps) != 1 {
+		t.Fatalf("checkpoint step=%d steps=%d, want 1/1", rs.Step, len(rs.AgentCtx.Steps))
+	}
+
+	second := newMockClient(finalResponse("done"))
+	e = New(second, reg, baseCfg(), DefaultPromptSpec(), WithCheckpointStore(store))
+	final, runCtx, err := e.ResumeCheckpoint(context.Background(), "run-1")
+	if err != nil {
+		t.Fatalf("ResumeCheckpoint() error = %v", err)
+	}
+	if final == nil || final.Output != "done" {
+		t.Fatalf("unexpected final: %#v", final)
+	}
+	if runCtx.Task != "the task" || len(runCtx.Steps) != 1 {
+		t.Fatalf("restored context task=%q steps=%d", runCtx.Task, len(runCtx.Steps))
+	}
+
+	calls := second.allCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 LLM call after resume, got %d", len(calls))
+	}
+	if len(calls[0].Tools) == 0 {
+		t.Fatalf("resumed request should include native tools")
+	}
+	var sawObservation bool
+	for _, m := range calls[0].Messages {
+		if strings.HasPrefix(m.Content, "Tool Result (big):") {
+			sawObservation = true
+		}
+	}
+	if !sawObservation {
+		t.Fatalf("resumed request should contain the earlier tool observation")
+	}
+
+	if _, ok, _ := store.Load(context.Background(), "run-1"); ok {
+		t.Fatalf("checkpoint should be deleted after the run finishes")
+	}
+}
+
+// policyTool records the auth_profile policy its calls run under.
+type policyTool struct {
+	bigOutputTool
+	mu       sync.Mutex
+	policies []secrets.SkillAuthProfilePolicy
+}
+
+func (t *policyTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	p, _ := secrets.SkillAuthProfilePolicyFromContext(ctx)
+	t.mu.Lock()
+	t.policies = append(t.policies, p)
+	t.mu.Unlock()
+	return t.bigOutputTool.Execute(ctx, params)
+}
+
+func TestCheckpoint_ResumeKeepsSkillAuthProfiles(t *testing.T) {
+	store := newMemCheckpointStore()
+	tool := &policyTool{bigOutputTool: bigOutputTool{size: 10}}
+	reg := tools.NewRegistry()
+	reg.Register(tool)
+
+	e := New(newMockClient(bigCall(0)), reg, baseCfg(), DefaultPromptSpec(),
+		WithCheckpointStore(store), WithSkillAuthProfiles([]string{"p1"}, true))
+	if _, _, err := e.Run(context.Background(), "the task", RunOptions{RunID: "run-1"}); err == nil {
+		t.Fatalf("expected first run to fail")
+	}
+
+	// Resume twice with engines that have no profiles configured (as run
+	// --resume and the daemon build them); the checkpointed policy must
+	// survive both.
+	for i := 0; i < 2; i++ {
+		e = New(newMockClient(bigCall(i+1)), reg, baseCfg(), DefaultPromptSpec(), WithCheckpointStore(store))
+		if _, _, err := e.ResumeCheckpoint(context.Background(), "run-1"); err == nil {
+			t.Fatalf("resume %d: expected failure", i)
+		}
+		b, ok, _ := store.Load(context.Background(), "run-1")
+		if !ok {
+			t.Fatalf("resume %d: checkpoint missing", i)
+		}
+		rs, err := unmarshalResumeState(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rs.SkillAuthProfiles) != 1 || rs.SkillAuthProfiles[0] != "p1" || !rs.EnforceSkillAuth {
+			t.Fatalf("resume %d: checkpoint profiles=%v enforce=%v", i, rs.SkillAuthProfiles, rs.EnforceSkillAuth)
+		}
+	}
+	if len(tool.policies) != 3 {
+		t.Fatalf("tool calls = %d, want 3", len(tool.policies))
+	}
+	for i, p := range tool.policies {
+		if !p.Enforce || !p.Allowed["p1"] {
+			t.Fatalf("call %d ran under policy %+v", i, p)
+		}
+	}
+}
+
+func TestCheckpoint_ResumeUnknownRun(t *testing.T) {
+	e := New(newMockClient(), baseRegistry(), baseCfg(), DefaultPromptSpec(), WithCheckpointStore(newMemCheckpointStore()))
+	if _, _, err := e.ResumeCheckpoint(context.Background(), "missing"); err == nil {
+		t.Fatalf("expected error for missing checkpoint")
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileCheckpointStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileCheckpointStore() error = %v", err)
+	}
+	if err := s.Save(ctx, "abc123", []byte(`{"v":1}`)); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	b, ok, err := s.Load(ctx, "abc123")
+	if err != nil || !ok || string(b) != `{"v":1}` {
+		t.Fatalf("Load() = %q, %v, %v", b, ok, err)
+	}
+	if err := s.Delete(ctx, "abc123"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, _ := s.Load(ctx, "abc123"); ok {
+		t.Fatalf("Load() after Delete() should report missing")
+	}
+	if err := s.Save(ctx, "../escape", nil); err == nil {
+		t.Fatalf("expected invalid run_id error")
+	}
+}
@@ -15,6 +15,11 @@ type Metrics struct {
 	ElapsedMs    int64
 	ToolCalls    int
 	ParseRetries int
+	// Backends counts LLM rounds per serving backend; StepBackends maps a
+	// step number to the backend that answered it. Both stay empty unless
+	// the client reports Result.Backend.
+	Backends     map[string]int
+	StepBackends map[int]string
 }
 
 type Context struct {
@@ -50,3 +55,18 @@ func (c *Context) AddUsage(usage llm.Usage, dur time.Duration) {
 	c.Metrics.ElapsedMs = time.Since(c.Metrics.StartTime).Milliseconds()
 	_ = dur
 }
+
+// RecordBackend notes which backend served the LLM call for step.
+func (c *Context) RecordBackend(step int, backend string) {
+	if backend == "" {
+		return
+	}
+	if c.Metrics.Backends == nil {
+		c.Metrics.Backends = make(map[string]int)
+	}
+	if c.Metrics.StepBackends == nil {
+		c.Metrics.StepBackends = make(map[int]string)
+	}
+	c.Metrics.Backends[backend]++
+	c.Metrics.StepBackends[step] = backend
+}
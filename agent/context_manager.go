@@ -0,0 +1,313 @@
+package agent
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/quailyquaily/mistermorph/internal/jsonutil"
+	"github.com/quailyquaily/mistermorph/internal/prompttmpl"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/llm/tokens"
+)
+
+const (
+	CompactionTruncate  = "truncate"
+	CompactionSummarize = "summarize"
+)
+
+const (
+	defaultCompactionKeepRecent = 4
+	defaultCompactionMaxChars   = 800
+
+	// conclusionMaxChars bounds the observations kept for the forced
+	// conclusion after a token budget stop.
+	conclusionMaxChars = 200
+
+	compactedMarker = "[compacted:"
+	toolResultLabel = "Tool Result ("
+)
+
+//go:embed prompts/compaction_system.tmpl
+var compactionSystemPromptTemplateSource string
+
+//go:embed prompts/compaction_user.tmpl
+var compactionUserPromptTemplateSource string
+
+var compactionSystemPromptTemplate = prompttmpl.MustParse("agent_compaction_system_prompt", compactionSystemPromptTemplateSource, nil)
+var compactionUserPromptTemplate = prompttmpl.MustParse("agent_compaction_user_prompt", compactionUserPromptTemplateSource, template.FuncMap{
+	"toJSON": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	},
+})
+
+type compactionUserPromptTemplateData struct {
+	Task         string
+	Observations []string
+	MaxChars     int
+}
+
+// contextManager keeps the loop's message history under a token estimate by
+// compacting older tool observations. The system prompt, everything up to and
+// including the task message, and all assistant messages (including the plan)
+// are never modified.
+type contextManager struct {
+	maxTokens  int
+	keepRecent int
+	strategy   string
+	maxChars   int
+}
+
+func newContextManager(cfg Config) *contextManager {
+	if cfg.CompactionMaxTokens <= 0 {
+		return nil
+	}
+	m := &contextManager{
+		maxTokens:  cfg.CompactionMaxTokens,
+		keepRecent: cfg.CompactionKeepRecent,
+		strategy:   strings.ToLower(strings.TrimSpace(cfg.CompactionStrategy)),
+		maxChars:   cfg.CompactionMaxChars,
+	}
+	if m.keepRecent <= 0 {
+		m.keepRecent = defaultCompactionKeepRecent
+	}
+	if m.maxChars <= 0 {
+		m.maxChars = defaultCompactionMaxChars
+	}
+	if m.strategy != CompactionSummarize {
+		m.strategy = CompactionTruncate
+	}
+	return m
+}
+
+// estimateMessagesTokens returns the llm/tokens estimate of messages for
+// model, in total and per message.
+func estimateMessagesTokens(model string, messages []llm.Message) (int, []int) {
+	per := make([]int, len(messages))
+	total := 0
+	for i, m := range messages {
+		per[i] = tokens.Message(model, m)
+		total += per[i]
+	}
+	return total, per
+}
+
+// shrinkForConclusion returns a copy of messages with tool observations
+// truncated, oldest first, until their estimate for model fits in limit
+// tokens or nothing is left to cut. The forced conclusion after a token
+// budget stop uses it instead of resending the history that did not fit.
+func shrinkForConclusion(messages []llm.Message, model, task string, limit int) []llm.Message {
+	out := append([]llm.Message(nil), messages...)
+	total, per := estimateMessagesTokens(model, out)
+	m := &contextManager{maxChars: conclusionMaxChars}
+	for _, i := range m.candidates(out, task) {
+		if total <= limit {
+			break
+		}
+		body := rewriteObservation(observationBody(out[i]), func(obs string) string { return truncateObservation(obs, conclusionMaxChars) })
+		out[i] = withObservationBody(out[i], body)
+		n := tokens.Message(model, out[i])
+		total += n - per[i]
+		per[i] = n
+	}
+	return out
+}
+
+// candidates returns indexes of tool observations that may be compacted,
+// oldest first, excluding the most recent keepRecent observations.
+func (m *contextManager) candidates(messages []llm.Message, task string) []int {
+	start := 0
+	for i, msg := range messages {
+		if msg.Role == "user" && msg.Content == task {
+			start = i + 1
+			break
+		}
+	}
+	var obs []int
+	for i := start; i < len(messages); i++ {
+		if isToolObservation(messages[i]) {
+			obs = append(obs, i)
+		}
+	}
+	if len(obs) <= m.keepRecent {
+		return nil
+	}
+	obs = obs[:len(obs)-m.keepRecent]
+	out := obs[:0]
+	for _, i := range obs {
+		body := observationBody(messages[i])
+		if strings.HasPrefix(body, compactedMarker) || len(body) <= m.maxChars {
+			continue
+		}
+		out = append(out, i)
+	}
+	return out
+}
+
+func isToolObservation(m llm.Message) bool {
+	switch m.Role {
+	case "tool":
+		return true
+	case "user":
+		return strings.HasPrefix(m.Content, toolResultLabel)
+	default:
+		return false
+	}
+}
+
+// observationBody strips the "Tool Result (name):" header used for tool
+// results that are not tied to a native tool call id.
+func observationBody(m llm.Message) string {
+	if m.Role == "user" && strings.HasPrefix(m.Content, toolResultLabel) {
+		if i := strings.IndexByte(m.Content, '\n'); i >= 0 {
+			return m.Content[i+1:]
+		}
+	}
+	return m.Content
+}
+
+func withObservationBody(m llm.Message, body string) llm.Message {
+	if m.Role == "user" && strings.HasPrefix(m.Content, toolResultLabel) {
+		if i := strings.IndexByte(m.Content, '\n'); i >= 0 {
+			m.Content = m.Content[:i+1] + body
+			return m
+		}
+	}
+	m.Content = body
+	return m
+}
+
+// rewriteObservation applies fn to an observation body. Untrusted tool
+// output stays inside its wrapper, so compacting it never turns it into
+// trusted text.
+func rewriteObservation(body string, fn func(string) string) string {
+	if toolName, obs, ok := unwrapUntrustedToolObservation(body); ok {
+		return wrapUntrustedToolObservation(toolName, fn(obs))
+	}
+	return fn(body)
+}
+
+func truncateObservation(body string, maxChars int) string {
+	head := body
+	if len(head) > maxChars {
+		head = head[:maxChars]
+		for len(head) > 0 && !utf8.ValidString(head) {
+			head = head[:len(head)-1]
+		}
+	}
+	return fmt.Sprintf("%s truncated %d of %d bytes]\n%s", compactedMarker, len(body)-len(head), len(body), head)
+}
+
+// compactContext compacts st.messages in place when the estimate exceeds the
+// configured budget.
+func (e *Engine) compactContext(ctx context.Context, st *engineLoopState, step int) {
+	m := e.contextMgr
+	if m == nil {
+		return
+	}
+	before, per := estimateMessagesTokens(st.model, st.messages)
+	if before <= m.maxTokens {
+		return
+	}
+	idxs := m.candidates(st.messages, st.agentCtx.Task)
+	if len(idxs) == 0 {
+		st.log.Warn("context_over_budget", "step", step, "estimated_tokens", before, "budget", m.maxTokens)
+		return
+	}
+
+	total := before
+	if m.strategy == CompactionSummarize {
+		summaries, err := e.summarizeObservations(ctx, st, idxs)
+		if err != nil {
+			st.log.Warn("context_summarize_error", "step", step, "error", err.Error())
+		} else {
+			for j, i := range idxs {
+				summary := strings.TrimSpace(summaries[j])
+				body := rewriteObservation(observationBody(st.messages[i]), func(obs string) string {
+					return fmt.Sprintf("%s summarized from %d bytes]\n%s", compactedMarker, len(obs), summary)
+				})
+				st.messages[i] = withObservationBody(st.messages[i], body)
+				n := tokens.Message(st.model, st.messages[i])
+				total += n - per[i]
+				per[i] = n
+			}
+		}
+	}
+
+	// Truncate oldest-first until the estimate fits (also the summarize fallback).
+	for _, i := range idxs {
+		if total <= m.maxTokens {
+			break
+		}
+		body := observationBody(st.messages[i])
+		if _, obs, _ := unwrapUntrustedToolObservation(body); strings.HasPrefix(obs, compactedMarker) {
+			continue
+		}
+		body = rewriteObservation(body, func(obs string) string { return truncateObservation(obs, m.maxChars) })
+		st.messages[i] = withObservationBody(st.messages[i], body)
+		n := tokens.Message(st.model, st.messages[i])
+		total += n - per[i]
+		per[i] = n
+	}
+
+	st.log.Info("context_compacted",
+		"step", step,
+		"strategy", m.strategy,
+		"estimated_tokens_before", before,
+		"estimated_tokens_after", total,
+		"budget", m.maxTokens,
+	)
+}
+
+func (e *Engine) summarizeObservations(ctx context.Context, st *engineLoopState, idxs []int) ([]string, error) {
+	observations := make([]string, 0, len(idxs))
+	for _, i := range idxs {
+		observations = append(observations, observationBody(st.messages[i]))
+	}
+	sys, err := prompttmpl.Render(compactionSystemPromptTemplate, struct{}{})
+	if err != nil {
+		return nil, fmt.Errorf("render compaction prompts: %w", err)
+	}
+	user, err := prompttmpl.Render(compactionUserPromptTemplate, compactionUserPromptTemplateData{
+		Task:         st.agentCtx.Task,
+		Observations: observations,
+		MaxChars:     e.contextMgr.maxChars,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render compaction prompts: %w", err)
+	}
+
+	client, model := e.auxModel(e.compactionClient, e.compactionModel, st.model)
+	res, err := client.Chat(ctx, llm.Request{
+		Model:     model,
+		ForceJSON: true,
+		Messages: []llm.Message{
+			{Role: "system", Content: sys},
+			{Role: "user", Content: user},
+		},
+		Parameters: map[string]any{"temperature": 0},
+	})
+	if err != nil {
+		return nil, err
+	}
+	st.agentCtx.AddUsage(res.Usage, res.Duration)
+
+	var out struct {
+		Summaries []string `json:"summaries"`
+	}
+	if err := jsonutil.DecodeWithFallback(res.Text, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Summaries) != len(idxs) {
+		return nil, fmt.Errorf("expected %d summaries, got %d", len(idxs), len(out.Summaries))
+	}
+	return out.Summaries, nil
+}
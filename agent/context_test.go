@@ -61,3 +61,17 @@ func TestAddUsageZeroCostNoChange(t *testing.T) {
 		t.Errorf("expected TotalCost=0, got %f", ctx.Metrics.TotalCost)
 	}
 }
+
+func TestRecordBackend(t *testing.T) {
+	ctx := NewContext("t", 5)
+	ctx.RecordBackend(1, "openai/gpt")
+	ctx.RecordBackend(2, "anthropic/claude")
+	ctx.RecordBackend(3, "anthropic/claude")
+	ctx.RecordBackend(4, "")
+	if ctx.Metrics.Backends["anthropic/claude"] != 2 || ctx.Metrics.Backends["openai/gpt"] != 1 {
+		t.Fatalf("Backends = %v", ctx.Metrics.Backends)
+	}
+	if ctx.Metrics.StepBackends[2] != "anthropic/claude" || len(ctx.Metrics.StepBackends) != 3 {
+		t.Fatalf("StepBackends = %v", ctx.Metrics.StepBackends)
+	}
+}
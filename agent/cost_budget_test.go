@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/llm/tokens"
+)
+
+func TestMaxCostUSDForcesConclusion(t *testing.T) {
+	expensive := toolCallResponse("search")
+	expensive.Usage = llm.Usage{TotalTokens: 10, Cost: 0.6}
+	client := newMockClient(expensive, finalResponse("wrapped up"))
+	reg := baseRegistry()
+	tool := &mockTool{name: "search", result: "found it"}
+	reg.Register(tool)
+
+	cfg := baseCfg()
+	cfg.MaxCostUSD = 0.5
+	e := New(client, reg, cfg, DefaultPromptSpec())
+	final, runCtx, err := e.Run(context.Background(), "task", RunOptions{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if final == nil || final.Output != "wrapped up" {
+		t.Fatalf("final = %+v", final)
+	}
+	if len(client.allCalls()) != 2 || len(runCtx.Steps) != 0 {
+		t.Fatalf("calls = %d, steps = %d; want the tool call skipped", len(client.allCalls()), len(runCtx.Steps))
+	}
+	if !almostEqual(runCtx.Metrics.TotalCost, 0.6) {
+		t.Fatalf("TotalCost = %v", runCtx.Metrics.TotalCost)
+	}
+}
+
+func TestMaxTokenBudgetPrecheck(t *testing.T) {
+	t.Run("first request over budget", func(t *testing.T) {
+		client := newMockClient(finalResponse("done"))
+		cfg := baseCfg()
+		cfg.MaxTokenBudget = 10
+		final, _, err := New(client, baseRegistry(), cfg, DefaultPromptSpec()).Run(context.Background(), "task", RunOptions{})
+		if err != nil || final == nil || final.Output != "done" {
+			t.Fatalf("Run() = %+v, %v; want the request sent anyway", final, err)
+		}
+
+		client = newMockClient(finalResponse("done"))
+		cfg.StrictBudget = true
+		_, _, err = New(client, baseRegistry(), cfg, DefaultPromptSpec()).Run(context.Background(), "task", RunOptions{})
+		if !errors.Is(err, ErrTokenBudget) {
+			t.Fatalf("Run() error = %v, want ErrTokenBudget", err)
+		}
+		if n := len(client.allCalls()); n != 0 {
+			t.Fatalf("calls = %d, want none", n)
+		}
+	})
+
+	t.Run("next step would cross budget", func(t *testing.T) {
+		reg := baseRegistry()
+		observation := strings.Repeat("found it ", 2000)
+		reg.Register(&mockTool{name: "search", result: observation})
+		probe := newMockClient(finalResponse("done"))
+		if _, _, err := New(probe, reg, baseCfg(), DefaultPromptSpec()).Run(context.Background(), "task", RunOptions{}); err != nil {
+			t.Fatalf("probe Run() error = %v", err)
+		}
+
+		first := toolCallResponse("search")
+		first.Usage = llm.Usage{TotalTokens: 100}
+		client := newMockClient(first, finalResponse("wrapped up"))
+		cfg := baseCfg()
+		// Room for the first request but not for the larger second one.
+		cfg.MaxTokenBudget = 100 + tokens.Request(probe.allCalls()[0])
+		e := New(client, reg, cfg, DefaultPromptSpec())
+		final, _, err := e.Run(context.Background(), "task", RunOptions{})
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if final == nil || final.Output != "wrapped up" {
+			t.Fatalf("final = %+v", final)
+		}
+		calls := client.allCalls()
+		if n := len(calls); n != 2 {
+			t.Fatalf("calls = %d, want the tool step and the forced conclusion", n)
+		}
+		// The conclusion must not resend the observation that did not fit.
+		if got, raw := tokens.Request(calls[1]), tokens.Count(calls[1].Model, observation); got >= raw {
+			t.Fatalf("conclusion request ~%d tokens, observation alone is ~%d", got, raw)
+		}
+	})
+}
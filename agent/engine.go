@@ -68,6 +68,22 @@ func WithPlanStepUpdate(fn func(*Context, PlanStepUpdate)) Option {
 	}
 }
 
+func WithPlanCreated(fn func(*Context, *Plan)) Option {
+	return func(e *Engine) {
+		if fn != nil {
+			e.onPlanCreated = fn
+		}
+	}
+}
+
+func WithToolEvent(fn func(*Context, ToolEvent)) Option {
+	return func(e *Engine) {
+		if fn != nil {
+			e.onToolEvent = fn
+		}
+	}
+}
+
 func WithFallbackFinal(fn func() *Final) Option {
 	return func(e *Engine) {
 		if fn != nil {
@@ -83,6 +99,25 @@ func WithSkillAuthProfiles(authProfiles []string, enforce bool) Option {
 	}
 }
 
+// WithIntentModel routes intent inference to client and model instead of
+// the engine's client and the run's model. A nil client or empty model keeps
+// the default.
+func WithIntentModel(client llm.Client, model string) Option {
+	return func(e *Engine) {
+		e.intentClient = client
+		e.intentModel = strings.TrimSpace(model)
+	}
+}
+
+// WithCompactionModel routes context compaction summaries like
+// WithIntentModel does for intent inference.
+func WithCompactionModel(client llm.Client, model string) Option {
+	return func(e *Engine) {
+		e.compactionClient = client
+		e.compactionModel = strings.TrimSpace(model)
+	}
+}
+
 type Config struct {
 	MaxSteps         int
 	MaxTokenBudget   int
@@ -90,6 +125,25 @@ type Config struct {
 	IntentEnabled    bool
 	IntentTimeout    time.Duration
 	IntentMaxHistory int
+	// ToolConcurrency bounds how many read-only tool calls from one step may
+	// run at the same time. Values <= 1 execute tool calls sequentially.
+	ToolConcurrency int
+	// MaxCostUSD stops the loop (like MaxTokenBudget) once the accumulated
+	// Usage.Cost exceeds it. 0 disables it.
+	MaxCostUSD float64
+	// StrictBudget fails the run with ErrTokenBudget when its first request
+	// is estimated above MaxTokenBudget. By default that request is sent
+	// anyway, since the estimate may be off.
+	StrictBudget bool
+
+	// Context compaction. When CompactionMaxTokens > 0 and the estimated size
+	// of the message history exceeds it, older tool observations (all but the
+	// CompactionKeepRecent most recent) are truncated or summarized to about
+	// CompactionMaxChars each. CompactionStrategy is "truncate" or "summarize".
+	CompactionMaxTokens  int
+	CompactionKeepRecent int
+	CompactionMaxChars   int
+	CompactionStrategy   string
 }
 
 type Engine struct {
@@ -105,12 +159,23 @@ type Engine struct {
 	paramsBuilder    func(opts RunOptions) map[string]any
 	onToolSuccess    func(ctx *Context, toolName string)
 	onPlanStepUpdate func(ctx *Context, update PlanStepUpdate)
+	onPlanCreated    func(ctx *Context, plan *Plan)
+	onToolEvent      func(ctx *Context, event ToolEvent)
+	onStreamDelta    func(ctx *Context, delta StreamDelta)
 	fallbackFinal    func() *Final
 
 	skillAuthProfiles []string
 	enforceSkillAuth  bool
 
 	guard *guard.Guard
+
+	intentClient     llm.Client
+	intentModel      string
+	compactionClient llm.Client
+	compactionModel  string
+
+	contextMgr  *contextManager
+	checkpoints CheckpointStore
 }
 
 func New(client llm.Client, registry *tools.Registry, cfg Config, spec PromptSpec, opts ...Option) *Engine {
@@ -136,6 +201,8 @@ func New(client llm.Client, registry *tools.Registry, cfg Config, spec PromptSpe
 		spec:     spec,
 		log:      slog.Default(),
 		logOpts:  DefaultLogOptions(),
+
+		contextMgr: newContextManager(cfg),
 	}
 	for _, opt := range opts {
 		if opt != nil {
@@ -154,7 +221,10 @@ func (e *Engine) Run(ctx context.Context, task string, opts RunOptions) (*Final,
 		model = "gpt-5.2"
 	}
 
-	runID := newRunID()
+	runID := strings.TrimSpace(opts.RunID)
+	if runID == "" {
+		runID = newRunID()
+	}
 	log := e.log.With("run_id", runID, "model", model)
 	log.Info("run_start", "task_len", len(task))
 
@@ -169,7 +239,8 @@ func (e *Engine) Run(ctx context.Context, task string, opts RunOptions) (*Final,
 		if cancel != nil {
 			defer cancel()
 		}
-		inferred, err := InferIntent(intentCtx, e.client, model, task, opts.History, e.config.IntentMaxHistory)
+		intentClient, intentModel := e.auxModel(e.intentClient, e.intentModel, model)
+		inferred, err := InferIntent(intentCtx, intentClient, intentModel, task, opts.History, e.config.IntentMaxHistory)
 		if err != nil {
 			log.Warn("intent_infer_error", "error", err.Error())
 		} else if !inferred.Empty() {
@@ -197,26 +268,33 @@ func (e *Engine) Run(ctx context.Context, task string, opts RunOptions) (*Final,
 		systemPrompt = BuildSystemPrompt(e.registry, spec)
 	}
 
-	messages := []llm.Message{{Role: "system", Content: systemPrompt}}
+	// Cache hints: the system prompt and the history are the same on every
+	// step, and later steps only append after the task message.
+	messages := []llm.Message{{Role: "system", Content: systemPrompt, Cache: true}}
 	if hasIntent && e.promptBuilder != nil {
 		messages = append(messages, llm.Message{Role: "system", Content: IntentSystemMessage(intent)})
 	}
+	historyStart := len(messages)
 	for _, m := range opts.History {
 		if strings.TrimSpace(strings.ToLower(m.Role)) == "system" {
 			continue
 		}
-		if strings.TrimSpace(m.Content) == "" {
+		if strings.TrimSpace(m.Content) == "" && len(m.Parts) == 0 {
 			continue
 		}
+		m.Cache = false
 		messages = append(messages, m)
 	}
+	if len(messages) > historyStart {
+		messages[len(messages)-1].Cache = true
+	}
 
 	if metaMsg, ok := buildInjectedMetaMessage(opts.Meta); ok {
 		messages = append(messages, llm.Message{Role: "user", Content: metaMsg})
 		log.Debug("run_meta_injected", "meta_bytes", len(metaMsg))
 	}
 
-	messages = append(messages, llm.Message{Role: "user", Content: task})
+	messages = append(messages, llm.Message{Role: "user", Content: task, Parts: opts.Parts, Cache: true})
 
 	requestedWrites := ExtractFileWritePaths(task)
 
@@ -238,6 +316,9 @@ func (e *Engine) Run(ctx context.Context, task string, opts RunOptions) (*Final,
 		planRequired:    planRequired,
 		requestedWrites: requestedWrites,
 		nextStep:        0,
+
+		skillAuthProfiles: append([]string{}, e.skillAuthProfiles...),
+		enforceSkillAuth:  e.enforceSkillAuth,
 	})
 }
 
@@ -312,3 +393,15 @@ func isHeartbeatMeta(meta map[string]any) bool {
 	}
 	return false
 }
+
+// auxModel picks the client and model for an auxiliary call, falling back to
+// the engine's client and the run's model.
+func (e *Engine) auxModel(client llm.Client, model string, runModel string) (llm.Client, string) {
+	if client == nil {
+		client = e.client
+	}
+	if model == "" {
+		model = runModel
+	}
+	return client, model
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/quailyquaily/mistermorph/llm"
 )
 
+const forceConclusionPrompt = "You have reached the maximum number of steps or token budget. Provide your final output NOW as a JSON final response."
+
 func (e *Engine) forceConclusion(ctx context.Context, messages []llm.Message, model string, agentCtx *Context, extraParams map[string]any, log *slog.Logger) (*Final, *Context, error) {
 	if log == nil {
 		log = e.log.With("model", model)
@@ -17,13 +19,14 @@ func (e *Engine) forceConclusion(ctx context.Context, messages []llm.Message, mo
 	log.Warn("force_conclusion", "steps", len(agentCtx.Steps), "messages", len(messages))
 	messages = append(messages, llm.Message{
 		Role:    "user",
-		Content: "You have reached the maximum number of steps or token budget. Provide your final output NOW as a JSON final response.",
+		Content: forceConclusionPrompt,
 	})
 
-	result, err := e.client.Chat(ctx, llm.Request{
+	result, err := e.chat(ctx, agentCtx.MaxSteps, agentCtx, llm.Request{
 		Model:      model,
 		Messages:   messages,
 		ForceJSON:  true,
+		Schema:     ResponseSchema,
 		Parameters: extraParams,
 	})
 	if err != nil {
@@ -84,7 +87,7 @@ func toolArgsSummary(toolName string, params map[string]any, opts LogOptions) ma
 		if v, ok := params["include_body"].(bool); ok {
 			out["include_body"] = v
 		}
-	case "read_file":
+	case "read_file", "edit_file", "list_dir", "search_files":
 		if v, ok := params["path"].(string); ok && strings.TrimSpace(v) != "" {
 			out["path"] = truncateString(strings.TrimSpace(v), opts.MaxStringValueChars)
 		}
@@ -165,7 +168,7 @@ func toolArgsSummary(toolName string, params map[string]any, opts LogOptions) ma
 		if v, ok := params["value"].(string); ok && strings.TrimSpace(v) != "" {
 			out["value"] = truncateString(strings.TrimSpace(v), 200)
 		}
-	case "bash":
+	case "bash", "process_start":
 		if opts.IncludeToolParams {
 			if v, ok := params["cmd"].(string); ok && strings.TrimSpace(v) != "" {
 				out["cmd"] = truncateString(strings.TrimSpace(v), 500)
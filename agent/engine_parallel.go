@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/guard"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+// toolOutcome is the result of a prefetched call. A call that needs guard
+// approval is not run, since approvals pause the run; pre keeps its guard
+// decision for the sequential path, so it is evaluated and audited once.
+type toolOutcome struct {
+	observation string
+	err         error
+	duration    time.Duration
+	pre         *guard.Result
+}
+
+// prefetchReadOnlyToolCalls executes the run of consecutive read-only tool
+// calls starting at start concurrently (bounded by Config.ToolConcurrency)
+// and records their outcomes by index. Calls after a non-read-only call are
+// never started early, so they still observe its side effects.
+func (e *Engine) prefetchReadOnlyToolCalls(ctx context.Context, st *engineLoopState, step int, calls []ToolCall, start int, out map[int]toolOutcome) {
+	end := start
+	for end < len(calls) && e.isReadOnlyToolCall(calls[end]) {
+		end++
+	}
+	if end-start < 2 {
+		return
+	}
+
+	if st.log != nil {
+		st.log.Debug("tool_calls_parallel", "step", step, "from_index", start, "count", end-start, "concurrency", e.config.ToolConcurrency)
+	}
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, e.config.ToolConcurrency)
+	)
+	for i := start; i < end; i++ {
+		wg.Add(1)
+		go func(i int, tc ToolCall) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			started := time.Now()
+			var pre *guard.Result
+			if e.guard != nil && e.guard.Enabled() {
+				gr := e.evaluateToolCallPre(ctx, st, step, &tc)
+				pre = &gr
+			}
+			var res toolOutcome
+			if pre != nil && pre.Decision == guard.DecisionRequireApproval && !st.approvedPendingTool {
+				res.pre = pre
+			} else {
+				observation, err, _, _ := e.executeToolWithGuard(ctx, st, step, "", &tc, nil, true, pre)
+				res = toolOutcome{observation: observation, err: err, duration: time.Since(started)}
+			}
+			mu.Lock()
+			out[i] = res
+			mu.Unlock()
+		}(i, calls[i])
+	}
+	wg.Wait()
+}
+
+func (e *Engine) isReadOnlyToolCall(tc ToolCall) bool {
+	if e.registry == nil {
+		return false
+	}
+	t, ok := e.registry.Get(tc.Name)
+	if !ok {
+		return false
+	}
+	return tools.IsReadOnlyCall(t, tc.Params)
+}
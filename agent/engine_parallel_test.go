@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/guard"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+type barrierTool struct {
+	name     string
+	readOnly bool
+	wg       *sync.WaitGroup
+	active   *int32
+	peak     *int32
+}
+
+func (t *barrierTool) Name() string                 { return t.name }
+func (t *barrierTool) Description() string          { return "barrier tool" }
+func (t *barrierTool) ParameterSchema() string      { return "{}" }
+func (t *barrierTool) ReadOnly(map[string]any) bool { return t.readOnly }
+func (t *barrierTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	n := atomic.AddInt32(t.active, 1)
+	defer atomic.AddInt32(t.active, -1)
+	for {
+		p := atomic.LoadInt32(t.peak)
+		if n <= p || atomic.CompareAndSwapInt32(t.peak, p, n) {
+			break
+		}
+	}
+	if t.wg != nil {
+		t.wg.Done()
+		done := make(chan struct{})
+		go func() { t.wg.Wait(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			return "", fmt.Errorf("timed out waiting for concurrent calls")
+		}
+	}
+	return fmt.Sprintf("%s:%v", t.name, params["i"]), nil
+}
+
+func multiToolCallResponse(names ...string) llm.Result {
+	calls := make([]llm.ToolCall, 0, len(names))
+	for i, name := range names {
+		calls = append(calls, llm.ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      name,
+			Arguments: map[string]any{"i": i},
+		})
+	}
+	return llm.Result{ToolCalls: calls}
+}
+
+func toolMessages(req llm.Request) []string {
+	var out []string
+	for _, m := range req.Messages {
+		if m.Role == "tool" {
+			out = append(out, m.ToolCallID+"="+m.Content)
+		}
+	}
+	return out
+}
+
+func TestToolConcurrency_RunsReadOnlyCallsInParallel(t *testing.T) {
+	var active, peak int32
+	wg := &sync.WaitGroup{}
+	wg.Add(3)
+	reg := tools.NewRegistry()
+	reg.Register(&barrierTool{name: "ro", readOnly: true, wg: wg, active: &active, peak: &peak})
+
+	client := newMockClient(
+		multiToolCallResponse("ro", "ro", "ro"),
+		finalResponse("done"),
+	)
+	cfg := baseCfg()
+	cfg.ToolConcurrency = 3
+	e := New(client, reg, cfg, DefaultPromptSpec())
+	if _, _, err := e.Run(context.Background(), "task", RunOptions{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if peak != 3 {
+		t.Fatalf("expected 3 concurrent calls, peak=%d", peak)
+	}
+
+	calls := client.allCalls()
+	got := toolMessages(calls[len(calls)-1])
+	want := []string{"call_0=ro:0", "call_1=ro:1", "call_2=ro:2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("tool observations out of order: got %v want %v", got, want)
+	}
+}
+
+func TestToolConcurrency_DoesNotReorderAroundWrites(t *testing.T) {
+	var active, peak int32
+	reg := tools.NewRegistry()
+	reg.Register(&barrierTool{name: "ro", readOnly: true, active: &active, peak: &peak})
+	reg.Register(&barrierTool{name: "rw", readOnly: false, active: &active, peak: &peak})
+
+	client := newMockClient(
+		multiToolCallResponse("ro", "rw", "ro"),
+		finalResponse("done"),
+	)
+	cfg := baseCfg()
+	cfg.ToolConcurrency = 4
+	e := New(client, reg, cfg, DefaultPromptSpec())
+	if _, _, err := e.Run(context.Background(), "task", RunOptions{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if peak != 1 {
+		t.Fatalf("expected sequential execution, peak=%d", peak)
+	}
+	calls := client.allCalls()
+	got := toolMessages(calls[len(calls)-1])
+	want := []string{"call_0=ro:0", "call_1=rw:1", "call_2=ro:2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("tool observations out of order: got %v want %v", got, want)
+	}
+}
+
+type countingAuditSink struct {
+	mu  sync.Mutex
+	pre int
+}
+
+func (s *countingAuditSink) Emit(_ context.Context, e guard.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.ActionType == guard.ActionToolCallPre {
+		s.pre++
+	}
+	return nil
+}
+
+func (s *countingAuditSink) Close() error { return nil }
+
+func TestToolConcurrency_ApprovalGatedCallsAreAuditedOnce(t *testing.T) {
+	var active, peak int32
+	reg := tools.NewRegistry()
+	reg.Register(&barrierTool{name: "bash", readOnly: true, active: &active, peak: &peak})
+
+	audit := &countingAuditSink{}
+	g := guard.New(guard.Config{Enabled: true, Bash: guard.BashConfig{RequireApproval: true}}, audit, nil)
+	client := newMockClient(
+		multiToolCallResponse("bash", "bash"),
+		finalResponse("done"),
+	)
+	cfg := baseCfg()
+	cfg.ToolConcurrency = 2
+	e := New(client, reg, cfg, DefaultPromptSpec(), WithGuard(g))
+	if _, _, err := e.Run(context.Background(), "task", RunOptions{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if peak != 0 {
+		t.Fatalf("approval-gated calls should not run, peak=%d", peak)
+	}
+	if audit.pre != 2 {
+		t.Fatalf("ToolCallPre audit events = %d, want 2", audit.pre)
+	}
+}
@@ -81,5 +81,8 @@ func (e *Engine) Resume(ctx context.Context, approvalRequestID string) (*Final,
 		pendingTool:         &rs.PendingTool,
 		approvedPendingTool: true,
 		nextStep:            rs.Step,
+
+		skillAuthProfiles: rs.SkillAuthProfiles,
+		enforceSkillAuth:  rs.EnforceSkillAuth,
 	})
 }
@@ -30,6 +30,8 @@ func (i Intent) Empty() bool {
 		!i.Ask
 }
 
+var intentSchema = llm.SchemaFor("intent", Intent{})
+
 func InferIntent(ctx context.Context, client llm.Client, model string, task string, history []llm.Message, maxHistory int) (Intent, error) {
 	if client == nil {
 		return Intent{}, fmt.Errorf("nil llm client")
@@ -47,6 +49,7 @@ func InferIntent(ctx context.Context, client llm.Client, model string, task stri
 	res, err := client.Chat(ctx, llm.Request{
 		Model:     model,
 		ForceJSON: true,
+		Schema:    intentSchema,
 		Messages: []llm.Message{
 			{Role: "system", Content: sys},
 			{Role: "user", Content: user},
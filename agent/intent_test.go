@@ -1,8 +1,11 @@
 package agent
 
 import (
+	"context"
 	"strings"
 	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
 )
 
 func TestNormalizeIntentStripsMetaConstraints(t *testing.T) {
@@ -42,3 +45,23 @@ func TestIntentEmptyWithQuestionOrRequest(t *testing.T) {
 		t.Fatalf("request=true should make intent non-empty")
 	}
 }
+
+func TestEngineInferIntentUsesIntentModel(t *testing.T) {
+	main := newMockClient(finalResponse("done"))
+	intentClient := newMockClient(llm.Result{Text: `{"goal":"greet","deliverable":"a greeting","request":true}`})
+	cfg := baseCfg()
+	cfg.IntentEnabled = true
+	e := New(main, baseRegistry(), cfg, DefaultPromptSpec(), WithIntentModel(intentClient, "small-model"))
+
+	if _, _, err := e.Run(context.Background(), "say hi", RunOptions{Model: "big-model"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	intentCalls := intentClient.allCalls()
+	if len(intentCalls) != 1 || intentCalls[0].Model != "small-model" {
+		t.Fatalf("intent calls = %+v, want one call with small-model", intentCalls)
+	}
+	mainCalls := main.allCalls()
+	if len(mainCalls) != 1 || mainCalls[0].Model != "big-model" {
+		t.Fatalf("main calls = %d, want one call with big-model", len(mainCalls))
+	}
+}
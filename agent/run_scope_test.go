@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+type runScopedTool struct {
+	mockTool
+	seen  string
+	ended []string
+}
+
+func (t *runScopedTool) Execute(ctx context.Context, _ map[string]any) (string, error) {
+	t.seen = tools.RunIDFromContext(ctx)
+	return "started", nil
+}
+
+func (t *runScopedTool) EndRun(runID string) { t.ended = append(t.ended, runID) }
+
+func TestRunScopedToolsEndWithRun(t *testing.T) {
+	client := newMockClient(toolCallResponse("job"), finalResponse("done"))
+	reg := baseRegistry()
+	tool := &runScopedTool{mockTool: mockTool{name: "job"}}
+	reg.Register(tool)
+
+	e := New(client, reg, baseCfg(), DefaultPromptSpec())
+	if _, _, err := e.Run(context.Background(), "start a job", RunOptions{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if tool.seen == "" {
+		t.Fatal("tool saw no run id")
+	}
+	if len(tool.ended) != 1 || tool.ended[0] != tool.seen {
+		t.Fatalf("EndRun calls = %v, want [%s]", tool.ended, tool.seen)
+	}
+}
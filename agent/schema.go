@@ -0,0 +1,48 @@
+package agent
+
+import "github.com/quailyquaily/mistermorph/llm"
+
+// ResponseSchema describes the JSON responses the engine accepts when the
+// model does not call tools (see the Response Format section of the system
+// prompt). It is not strict: final.output may be any JSON value, which a
+// strict schema cannot express. ParseResponse still validates the result.
+var ResponseSchema = &llm.JSONSchema{
+	Name: "agent_response",
+	Schema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"type": map[string]any{"type": "string", "enum": []string{TypePlan, TypeFinal}},
+			"plan": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"thought": map[string]any{"type": "string"},
+					"summary": map[string]any{"type": "string"},
+					"steps": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"step":   map[string]any{"type": "string"},
+								"status": map[string]any{"type": "string", "enum": []string{"pending", "in_progress", "completed"}},
+							},
+							"required": []string{"step"},
+						},
+					},
+					"risks":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"questions":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"completion": map[string]any{"type": "string"},
+				},
+				"required": []string{"summary", "steps"},
+			},
+			"final": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"thought": map[string]any{"type": "string"},
+					"output":  map[string]any{},
+				},
+				"required": []string{"output"},
+			},
+		},
+		"required": []string{"type"},
+	},
+}
@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEngineRequestsResponseSchema(t *testing.T) {
+	client := newMockClient(finalResponse("done"))
+	e := New(client, baseRegistry(), baseCfg(), DefaultPromptSpec())
+	if _, _, err := e.Run(context.Background(), "task", RunOptions{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	calls := client.allCalls()
+	if len(calls) != 1 || calls[0].Schema != ResponseSchema || !calls[0].ForceJSON {
+		t.Fatalf("request schema = %+v", calls[0].Schema)
+	}
+}
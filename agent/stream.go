@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// StreamDelta is forwarded to the WithStreamDelta callback for every
+// partial chunk the model produces during a step.
+type StreamDelta struct {
+	Step  int
+	Event llm.StreamEvent
+}
+
+// WithStreamDelta streams LLM calls (when the client supports it) and
+// forwards each delta to fn. Without this option the engine uses Chat.
+func WithStreamDelta(fn func(*Context, StreamDelta)) Option {
+	return func(e *Engine) {
+		if fn != nil {
+			e.onStreamDelta = fn
+		}
+	}
+}
+
+func (e *Engine) chat(ctx context.Context, step int, agentCtx *Context, req llm.Request) (llm.Result, error) {
+	if e.onStreamDelta == nil {
+		return e.client.Chat(ctx, req)
+	}
+	return llm.ChatStream(ctx, e.client, req, func(ev llm.StreamEvent) {
+		e.onStreamDelta(agentCtx, StreamDelta{Step: step, Event: ev})
+	})
+}
+
+// FinalOutputStream turns streamed response text into the user-visible
+// final.output string as it is being generated. Feed it every StreamDelta;
+// Push returns the newly decoded suffix of the output (if any).
+//
+// Deltas for a new step reset the buffer, so a step that ends up being a
+// plan or tool call simply never yields output.
+type FinalOutputStream struct {
+	step    int
+	started bool
+	buf     strings.Builder
+	output  string
+}
+
+func (s *FinalOutputStream) Push(d StreamDelta) string {
+	if s == nil || d.Event.Type != llm.StreamEventText || d.Event.Text == "" {
+		return ""
+	}
+	if !s.started || d.Step != s.step {
+		s.step = d.Step
+		s.started = true
+		s.buf.Reset()
+		s.output = ""
+	}
+	s.buf.WriteString(d.Event.Text)
+	out, ok := partialFinalOutput(s.buf.String())
+	if !ok || len(out) <= len(s.output) || !strings.HasPrefix(out, s.output) {
+		return ""
+	}
+	delta := out[len(s.output):]
+	s.output = out
+	return delta
+}
+
+// Output returns the output decoded so far for the current step.
+func (s *FinalOutputStream) Output() string {
+	if s == nil {
+		return ""
+	}
+	return s.output
+}
+
+// partialFinalOutput extracts the (possibly unterminated) string value of the
+// "output" key from a streamed final response.
+func partialFinalOutput(text string) (string, bool) {
+	if !strings.Contains(text, `"final`) {
+		return "", false
+	}
+	idx := strings.Index(text, `"output"`)
+	if idx < 0 {
+		return "", false
+	}
+	rest := strings.TrimLeft(text[idx+len(`"output"`):], " \t\r\n")
+	if !strings.HasPrefix(rest, ":") {
+		return "", false
+	}
+	rest = strings.TrimLeft(rest[1:], " \t\r\n")
+	if !strings.HasPrefix(rest, `"`) {
+		return "", false
+	}
+	return decodePartialJSONString(rest[1:]), true
+}
+
+// decodePartialJSONString decodes a JSON string body up to its closing quote
+// or up to the last complete character when the input is truncated.
+func decodePartialJSONString(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String()
+		case c == '\\':
+			if i+1 >= len(s) {
+				return b.String()
+			}
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				if i+6 > len(s) {
+					return b.String()
+				}
+				n, err := strconv.ParseUint(s[i+2:i+6], 16, 32)
+				if err != nil {
+					return b.String()
+				}
+				r := rune(n)
+				if utf16Surrogate(r) {
+					if i+12 > len(s) || s[i+6] != '\\' || s[i+7] != 'u' {
+						return b.String()
+					}
+					lo, err := strconv.ParseUint(s[i+8:i+12], 16, 32)
+					if err != nil {
+						return b.String()
+					}
+					r = (r-0xd800)<<10 + (rune(lo) - 0xdc00) + 0x10000
+					i += 6
+				}
+				b.WriteRune(r)
+				i += 6
+				continue
+			default:
+				b.WriteByte(s[i+1])
+			}
+			i += 2
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size <= 1 && !utf8.FullRuneInString(s[i:]) {
+				return b.String()
+			}
+			b.WriteString(s[i : i+size])
+			i += size
+		}
+	}
+	return b.String()
+}
+
+func utf16Surrogate(r rune) bool {
+	return r >= 0xd800 && r < 0xdc00
+}
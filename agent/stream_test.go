@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+type mockStreamingClient struct {
+	*mockClient
+	streamed int
+}
+
+func (m *mockStreamingClient) ChatStream(ctx context.Context, req llm.Request, onEvent llm.StreamHandler) (llm.Result, error) {
+	res, err := m.Chat(ctx, req)
+	if err != nil {
+		return res, err
+	}
+	m.streamed++
+	// Emit the text in small chunks to exercise partial decoding.
+	for i := 0; i < len(res.Text); i += 3 {
+		end := i + 3
+		if end > len(res.Text) {
+			end = len(res.Text)
+		}
+		onEvent(llm.StreamEvent{Type: llm.StreamEventText, Text: res.Text[i:end]})
+	}
+	return res, nil
+}
+
+func TestPartialFinalOutput(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{`{"type":"final","final":{"output":"hel`, "hel", true},
+		{`{"type":"final","final":{"output": "a\nb\"c`, "a\nb\"c", true},
+		{`{"type":"final","final":{"output":"done"},"x":"y"}`, "done", true},
+		{`{"type":"final","final":{"output":"x\`, "x", true},
+		{`{"type":"final","final":{"output":"é\u00`, "é", true},
+		{`{"type":"final","final":{"output":{"a":1}}}`, "", false},
+		{`{"type":"plan","plan":{"summary":"s"}}`, "", false},
+	}
+	for _, tc := range cases {
+		got, ok := partialFinalOutput(tc.in)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("partialFinalOutput(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestFinalOutputStream_ResetsPerStep(t *testing.T) {
+	var s FinalOutputStream
+	push := func(step int, text string) string {
+		return s.Push(StreamDelta{Step: step, Event: llm.StreamEvent{Type: llm.StreamEventText, Text: text}})
+	}
+	if got := push(0, `{"type":"final","final":{"output":"ab`); got != "ab" {
+		t.Fatalf("first delta = %q", got)
+	}
+	if got := push(0, `c"}}`); got != "c" {
+		t.Fatalf("second delta = %q", got)
+	}
+	if got := push(1, `{"type":"final","final":{"output":"z`); got != "z" {
+		t.Fatalf("new step delta = %q", got)
+	}
+	if s.Output() != "z" {
+		t.Fatalf("Output() = %q", s.Output())
+	}
+}
+
+func TestWithStreamDelta_ForwardsDeltas(t *testing.T) {
+	client := &mockStreamingClient{mockClient: newMockClient(finalResponse("streamed answer"))}
+	var (
+		stream FinalOutputStream
+		out    strings.Builder
+	)
+	e := New(client, baseRegistry(), baseCfg(), DefaultPromptSpec(), WithStreamDelta(func(_ *Context, d StreamDelta) {
+		out.WriteString(stream.Push(d))
+	}))
+	final, _, err := e.Run(context.Background(), "task", RunOptions{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if client.streamed != 1 {
+		t.Fatalf("expected 1 streamed call, got %d", client.streamed)
+	}
+	if final == nil || final.Output != "streamed answer" {
+		t.Fatalf("unexpected final: %#v", final)
+	}
+	if out.String() != "streamed answer" {
+		t.Fatalf("streamed output = %q", out.String())
+	}
+}
+
+func TestWithStreamDelta_FallsBackForNonStreamingClient(t *testing.T) {
+	client := newMockClient(finalResponse("ok"))
+	var events int
+	e := New(client, baseRegistry(), baseCfg(), DefaultPromptSpec(), WithStreamDelta(func(_ *Context, d StreamDelta) {
+		events++
+	}))
+	if _, _, err := e.Run(context.Background(), "task", RunOptions{}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if events != 1 {
+		t.Fatalf("expected a single fallback event, got %d", events)
+	}
+}
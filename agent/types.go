@@ -67,6 +67,20 @@ type PlanStepUpdate struct {
 	Reason         string
 }
 
+// ToolEvent reports a tool call starting (Phase "call") or finishing
+// (Phase "done"). Args is the same redacted summary used in logs.
+type ToolEvent struct {
+	Step           int
+	Phase          string
+	Tool           string
+	Args           map[string]any
+	Index          int
+	Count          int
+	Duration       time.Duration
+	ObservationLen int
+	Error          string
+}
+
 type Final struct {
 	Thought string `json:"thought,omitempty"`
 	Output  any    `json:"output,omitempty"`
@@ -108,4 +122,8 @@ type RunOptions struct {
 	Model   string
 	History []llm.Message
 	Meta    map[string]any
+	// Parts are attached to the task message (e.g. images the user sent).
+	Parts []llm.ContentPart
+	// RunID overrides the generated run id (e.g. to key checkpoints by task id).
+	RunID string
 }
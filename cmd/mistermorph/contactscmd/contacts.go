@@ -607,6 +607,11 @@ func newLLMClientForFeatures() (llm.Client, string, error) {
 	if err != nil {
 		return nil, "", err
 	}
+	models, err := llmutil.ModelsFromViper(client, model)
+	if err != nil {
+		return nil, "", err
+	}
+	client, model = models.For(llmutil.PurposeContacts)
 	return client, model, nil
 }
 
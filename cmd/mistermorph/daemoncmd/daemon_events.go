@@ -0,0 +1,258 @@
+package daemoncmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	taskEventHistoryLimit = 500
+	taskEventBuffer       = 64
+	// taskEventLogTTL is how long a finished task's events stay available
+	// for replay (e.g. a client reconnecting with Last-Event-ID).
+	taskEventLogTTL = 10 * time.Minute
+)
+
+// Event types emitted on GET /tasks/{id}/events.
+const (
+	EventStatus   = "status"
+	EventPlan     = "plan"
+	EventPlanStep = "plan_step"
+	EventToolCall = "tool_call"
+	EventToolDone = "tool_done"
+	EventApproval = "approval"
+	EventFinal    = "final"
+	// EventDelta carries the next piece of the final output ("text") while
+	// it is generated, with the agent step producing it ("step"); only
+	// tasks enqueued with streaming publish it.
+	EventDelta = "delta"
+)
+
+type TaskEvent struct {
+	Seq  int64     `json:"seq"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// taskEventHub keeps a bounded event history per task and fans new events
+// out to subscribers. A task's stream is closed when it reaches a terminal
+// status and reopened if the task is resumed. Closed logs are dropped after
+// taskEventLogTTL.
+type taskEventHub struct {
+	mu   sync.Mutex
+	logs map[string]*taskEventLog
+}
+
+type taskEventLog struct {
+	seq      int64
+	events   []TaskEvent
+	subs     map[chan TaskEvent]struct{}
+	closed   bool
+	closedAt time.Time
+}
+
+func newTaskEventHub() *taskEventHub {
+	return &taskEventHub{logs: make(map[string]*taskEventLog)}
+}
+
+func (h *taskEventHub) log(id string) *taskEventLog {
+	l := h.logs[id]
+	if l == nil {
+		l = &taskEventLog{subs: make(map[chan TaskEvent]struct{})}
+		h.logs[id] = l
+	}
+	return l
+}
+
+func (h *taskEventHub) publish(id string, typ string, data any) {
+	if h == nil || id == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := h.log(id)
+	l.closed = false
+	l.seq++
+	ev := TaskEvent{Seq: l.seq, Type: typ, Time: time.Now(), Data: data}
+	l.events = append(l.events, ev)
+	if len(l.events) > taskEventHistoryLimit {
+		l.events = l.events[len(l.events)-taskEventHistoryLimit:]
+	}
+	for ch := range l.subs {
+		select {
+		case ch <- ev:
+		default:
+			// Slow subscriber; it can reconnect with Last-Event-ID.
+		}
+	}
+}
+
+// finish closes all current subscriptions of a task and drops the logs of
+// tasks that finished more than taskEventLogTTL ago.
+func (h *taskEventHub) finish(id string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	l := h.log(id)
+	l.closed = true
+	l.closedAt = now
+	for ch := range l.subs {
+		close(ch)
+		delete(l.subs, ch)
+	}
+	for other, ol := range h.logs {
+		if ol.closed && now.Sub(ol.closedAt) > taskEventLogTTL {
+			delete(h.logs, other)
+		}
+	}
+}
+
+// drop forgets a task's events, closing any subscriptions.
+func (h *taskEventHub) drop(id string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := h.logs[id]
+	if l == nil {
+		return
+	}
+	for ch := range l.subs {
+		close(ch)
+		delete(l.subs, ch)
+	}
+	delete(h.logs, id)
+}
+
+// since returns the recorded events after afterSeq.
+func (h *taskEventHub) since(id string, afterSeq int64) []TaskEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := h.logs[id]
+	if l == nil {
+		return nil
+	}
+	var out []TaskEvent
+	for _, ev := range l.events {
+		if ev.Seq > afterSeq {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// subscribe returns the events after afterSeq and, unless the stream is
+// already closed or was dropped, a channel for new ones. Call unsubscribe
+// when done.
+func (h *taskEventHub) subscribe(id string, afterSeq int64) (history []TaskEvent, ch chan TaskEvent, unsubscribe func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l := h.logs[id]
+	if l == nil {
+		return nil, nil, func() {}
+	}
+	for _, ev := range l.events {
+		if ev.Seq > afterSeq {
+			history = append(history, ev)
+		}
+	}
+	if l.closed {
+		return history, nil, func() {}
+	}
+	ch = make(chan TaskEvent, taskEventBuffer)
+	l.subs[ch] = struct{}{}
+	return history, ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			delete(l.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func isTerminalStatus(s TaskStatus) bool {
+	switch s {
+	case TaskDone, TaskFailed, TaskCanceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// serveTaskEvents streams a task's events as Server-Sent Events. Past events
+// are replayed first (after Last-Event-ID when given); the stream ends when
+// the task reaches a terminal status.
+func serveTaskEvents(w http.ResponseWriter, r *http.Request, store *TaskStore, id string) {
+	info, ok := store.Get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	afterSeq, _ := strconv.ParseInt(strings.TrimSpace(r.Header.Get("Last-Event-ID")), 10, 64)
+
+	history, ch, unsubscribe := store.events.subscribe(id, afterSeq)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for _, ev := range history {
+		writeSSEEvent(w, ev)
+	}
+	if ch == nil || (isTerminalStatus(info.Status) && len(history) == 0) {
+		if len(history) == 0 {
+			// No recorded events (e.g. restored after a restart): report the status.
+			writeSSEEvent(w, TaskEvent{Type: EventStatus, Time: time.Now(), Data: map[string]any{"id": info.ID, "status": info.Status, "error": info.Error}})
+		}
+		flusher.Flush()
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeSSEEvent(w, ev)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSEEvent(w http.ResponseWriter, ev TaskEvent) {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	if ev.Seq > 0 {
+		_, _ = fmt.Fprintf(w, "id: %d\n", ev.Seq)
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+}
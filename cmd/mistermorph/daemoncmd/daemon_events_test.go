@@ -0,0 +1,91 @@
+package daemoncmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTaskEventHubReplayAndFinish(t *testing.T) {
+	h := newTaskEventHub()
+	h.publish("t1", EventStatus, map[string]any{"status": "queued"})
+	h.publish("t1", EventToolCall, map[string]any{"tool": "read_file"})
+
+	history, ch, unsubscribe := h.subscribe("t1", 1)
+	defer unsubscribe()
+	if len(history) != 1 || history[0].Type != EventToolCall || history[0].Seq != 2 {
+		t.Fatalf("history after seq 1 = %+v", history)
+	}
+
+	h.publish("t1", EventToolDone, nil)
+	if ev := <-ch; ev.Type != EventToolDone || ev.Seq != 3 {
+		t.Fatalf("live event = %+v", ev)
+	}
+
+	h.finish("t1")
+	if _, ok := <-ch; ok {
+		t.Fatalf("subscription should be closed after finish")
+	}
+	if _, ch, _ := h.subscribe("t1", 0); ch != nil {
+		t.Fatalf("subscribe on a finished stream should not return a channel")
+	}
+}
+
+func TestServeTaskEventsFinishedTask(t *testing.T) {
+	s := NewTaskStore(10)
+	info, _ := s.Enqueue(context.Background(), "task", "m", time.Minute, PriorityNormal)
+	s.Publish(info.ID, EventToolCall, map[string]any{"tool": "bash"})
+	s.Update(info.ID, func(ti *TaskInfo) { ti.Status = TaskDone })
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveTaskEvents(w, r, s, info.ID)
+	}))
+	defer srv.Close()
+
+	var got []string
+	err := streamTaskEvents(srv.URL, "token", info.ID, func(ev TaskEvent) bool {
+		got = append(got, ev.Type)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("streamTaskEvents() error = %v", err)
+	}
+	want := []string{EventStatus, EventToolCall, EventStatus}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestTaskEventHubDropsFinishedLogs(t *testing.T) {
+	h := newTaskEventHub()
+	h.publish("old", EventStatus, map[string]any{"status": "done"})
+	h.finish("old")
+	h.logs["old"].closedAt = time.Now().Add(-2 * taskEventLogTTL)
+
+	h.publish("t2", EventStatus, map[string]any{"status": "done"})
+	h.finish("t2")
+	if _, ok := h.logs["old"]; ok {
+		t.Fatalf("expired log should be dropped")
+	}
+	if history, ch, _ := h.subscribe("old", 0); history != nil || ch != nil {
+		t.Fatalf("subscribe on a dropped log = %v, %v", history, ch)
+	}
+	if _, ok := h.logs["old"]; ok {
+		t.Fatalf("subscribe should not recreate a dropped log")
+	}
+
+	h.publish("t3", EventStatus, map[string]any{"status": "running"})
+	_, ch, unsubscribe := h.subscribe("t3", 0)
+	h.drop("t3")
+	if _, ok := <-ch; ok {
+		t.Fatalf("drop should close subscriptions")
+	}
+	unsubscribe()
+}
@@ -0,0 +1,392 @@
+package daemoncmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/agent"
+	"github.com/quailyquaily/mistermorph/internal/heartbeatutil"
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// openAIModelID is the model name the daemon advertises on /v1/models.
+const openAIModelID = "mistermorph"
+
+type chatCompletionRequest struct {
+	Model    string              `json:"model"`
+	Messages []chatCompletionMsg `json:"messages"`
+	Stream   bool                `json:"stream,omitempty"`
+}
+
+type chatCompletionMsg struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+type chatCompletionChoice struct {
+	Index        int            `json:"index"`
+	Message      map[string]any `json:"message,omitempty"`
+	Delta        map[string]any `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type chatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []chatCompletionChoice `json:"choices"`
+	Usage   map[string]int         `json:"usage,omitempty"`
+}
+
+// chatCompletionsHandler serves an OpenAI-compatible /v1/chat/completions.
+// Each request becomes a daemon task: earlier messages are passed as run
+// history, the last user message is the task, and the agent's final output is
+// returned as the assistant message. With stream=true the final output is
+// forwarded as content chunks while the model generates it (keep-alives are
+// sent during tool steps). Responses name the model the task ran with; the
+// request's model field only selects this endpoint. A task that pauses for
+// approval is reported as an error; it stays pending and its result is
+// available from GET /tasks/{id}.
+type chatCompletionsHandler struct {
+	store   *TaskStore
+	model   string
+	timeout time.Duration
+}
+
+func (h *chatCompletionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error")
+		return
+	}
+	var req chatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid json", "invalid_request_error")
+		return
+	}
+	task, history, err := chatMessagesToTask(req.Messages)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, err.Error(), "invalid_request_error")
+		return
+	}
+
+	info, err := h.store.EnqueueWithHistory(context.Background(), task, history, h.model, h.timeout, PriorityNormal, req.Stream)
+	if err != nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, err.Error(), "server_error")
+		return
+	}
+	id := info.ID
+	respModel := info.Model
+	w.Header().Set("X-Mistermorph-Task-Id", id)
+
+	var flusher http.Flusher
+	if req.Stream {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			_, _ = h.store.Cancel(id)
+			writeOpenAIError(w, http.StatusInternalServerError, "streaming unsupported", "server_error")
+			return
+		}
+		flusher = f
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		writeChatChunk(w, id, respModel, map[string]any{"role": "assistant"}, nil)
+		flusher.Flush()
+	}
+
+	// sent is the streamed output, without the leading whitespace the
+	// final answer is trimmed of. Only deltas of the first step that
+	// streams are forwarded: a later step means that answer was rejected
+	// and is being regenerated.
+	var (
+		sent       strings.Builder
+		streamStep = -1
+	)
+	onEvent := func(ev TaskEvent) {
+		if flusher == nil || ev.Type != EventDelta {
+			return
+		}
+		m, _ := ev.Data.(map[string]any)
+		text, _ := m["text"].(string)
+		step, _ := m["step"].(int)
+		if streamStep >= 0 && step != streamStep {
+			return
+		}
+		streamStep = step
+		if sent.Len() == 0 {
+			text = strings.TrimLeft(text, " \t\r\n")
+		}
+		if text == "" {
+			return
+		}
+		sent.WriteString(text)
+		writeChatChunk(w, id, respModel, map[string]any{"content": text}, nil)
+		flusher.Flush()
+	}
+	final, err := waitTaskSettled(r.Context(), h.store, id, func() {
+		if flusher != nil {
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}, onEvent)
+	if err != nil && r.Context().Err() != nil {
+		// Client went away; don't keep working on an answer nobody reads.
+		_, _ = h.store.Cancel(id)
+		return
+	}
+	status, errType := http.StatusInternalServerError, "server_error"
+	if err == nil {
+		switch final.Status {
+		case TaskDone:
+		case TaskPending:
+			status, errType = http.StatusConflict, "approval_pending"
+			err = fmt.Errorf("task %s is waiting for approval %s; approve it and read the result from GET /tasks/%s", id, final.ApprovalRequestID, id)
+		default:
+			err = fmt.Errorf("task %s: %s", final.Status, final.Error)
+		}
+	}
+
+	if req.Stream {
+		// Send whatever the deltas did not cover (e.g. a non-string output,
+		// or deltas still in flight when the task finished). Streamed text
+		// that is not a prefix of the final answer cannot be taken back.
+		var rest string
+		if err == nil {
+			var ok bool
+			if rest, ok = strings.CutPrefix(taskResultContent(final.Result), sent.String()); !ok {
+				err = fmt.Errorf("task %s: the streamed answer was replaced by a regenerated one; read the final answer from GET /tasks/%s", id, id)
+			}
+		}
+		if err != nil {
+			b, _ := json.Marshal(map[string]any{"error": map[string]any{"message": err.Error(), "type": errType}})
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", b)
+		} else {
+			if rest != "" {
+				writeChatChunk(w, id, respModel, map[string]any{"content": rest}, nil)
+			}
+			stop := "stop"
+			writeChatChunk(w, id, respModel, map[string]any{}, &stop)
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		flusher.Flush()
+		return
+	}
+
+	if err != nil {
+		writeOpenAIError(w, status, err.Error(), errType)
+		return
+	}
+	stop := "stop"
+	resp := chatCompletionResponse{
+		ID:      "chatcmpl-" + id,
+		Object:  "chat.completion",
+		Created: info.CreatedAt.Unix(),
+		Model:   respModel,
+		Choices: []chatCompletionChoice{{
+			Message:      map[string]any{"role": "assistant", "content": taskResultContent(final.Result)},
+			FinishReason: &stop,
+		}},
+	}
+	if tokens := taskResultTokens(final.Result); tokens > 0 {
+		resp.Usage = map[string]int{"total_tokens": tokens}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// serveOpenAIModels lists the single model name clients can use.
+func serveOpenAIModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "method not allowed", "invalid_request_error")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object": "list",
+		"data": []map[string]any{{
+			"id":       openAIModelID,
+			"object":   "model",
+			"owned_by": openAIModelID,
+		}},
+	})
+}
+
+// chatMessagesToTask maps chat messages onto a task and run history. The last
+// message must come from the user; system/developer messages are prepended to
+// the task as instructions, since the agent owns the system prompt.
+func chatMessagesToTask(msgs []chatCompletionMsg) (string, []llm.Message, error) {
+	if len(msgs) == 0 {
+		return "", nil, fmt.Errorf("missing messages")
+	}
+	var (
+		instructions []string
+		history      []llm.Message
+	)
+	for i, m := range msgs {
+		role := strings.ToLower(strings.TrimSpace(m.Role))
+		text, err := chatContentText(m.Content)
+		if err != nil {
+			return "", nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		if i == len(msgs)-1 {
+			if role != "user" {
+				return "", nil, fmt.Errorf("last message must have role user")
+			}
+			if strings.TrimSpace(text) == "" {
+				return "", nil, fmt.Errorf("missing task: last user message is empty")
+			}
+			task := text
+			if len(instructions) > 0 {
+				task = "Instructions:\n" + strings.Join(instructions, "\n\n") + "\n\nRequest:\n" + text
+			}
+			return task, history, nil
+		}
+		switch role {
+		case "system", "developer":
+			if strings.TrimSpace(text) != "" {
+				instructions = append(instructions, text)
+			}
+		case "user", "assistant":
+			if strings.TrimSpace(text) != "" {
+				history = append(history, llm.Message{Role: role, Content: text})
+			}
+		default:
+			// Client-side tool calls/results have no meaning to the agent.
+		}
+	}
+	return "", nil, fmt.Errorf("missing messages")
+}
+
+// chatContentText accepts either a string or an array of content parts and
+// returns the concatenated text parts.
+func chatContentText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("content must be a string or an array of parts")
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// waitTaskSettled blocks until the task is done, failed, canceled or pending
+// approval. onWait is called periodically while waiting, and onEvent (if set)
+// with each of the task's events in order; events a slow reader missed are
+// replayed from the task's history.
+func waitTaskSettled(ctx context.Context, store *TaskStore, id string, onWait func(), onEvent func(TaskEvent)) (*TaskInfo, error) {
+	history, ch, unsubscribe := store.events.subscribe(id, 0)
+	defer unsubscribe()
+	var lastSeq int64
+	for _, ev := range history {
+		if onEvent != nil {
+			onEvent(ev)
+		}
+		lastSeq = ev.Seq
+	}
+	tick := time.NewTicker(15 * time.Second)
+	defer tick.Stop()
+	for {
+		info, ok := store.Get(id)
+		if !ok {
+			return nil, errTaskNotFound
+		}
+		if info.Status == TaskPending || isTerminalStatus(info.Status) {
+			return info, nil
+		}
+		if ch == nil {
+			return info, fmt.Errorf("task event stream closed while %s", info.Status)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-tick.C:
+			if onWait != nil {
+				onWait()
+			}
+		case ev, ok := <-ch:
+			if !ok {
+				ch = nil
+				continue
+			}
+			if onEvent == nil || ev.Seq <= lastSeq {
+				continue
+			}
+			if ev.Seq > lastSeq+1 {
+				for _, missed := range store.events.since(id, lastSeq) {
+					if missed.Seq < ev.Seq {
+						onEvent(missed)
+					}
+				}
+			}
+			onEvent(ev)
+			lastSeq = ev.Seq
+		}
+	}
+}
+
+func taskResultContent(result any) string {
+	m, _ := result.(map[string]any)
+	switch v := m["final"].(type) {
+	case *agent.Final:
+		return heartbeatutil.FormatFinalOutput(v)
+	case map[string]any:
+		// Restored from the task store file.
+		if s, ok := v["output"].(string); ok {
+			return strings.TrimSpace(s)
+		}
+		b, _ := json.MarshalIndent(v["output"], "", "  ")
+		return strings.TrimSpace(string(b))
+	default:
+		return ""
+	}
+}
+
+func taskResultTokens(result any) int {
+	m, _ := result.(map[string]any)
+	if metrics, ok := m["metrics"].(*agent.Metrics); ok && metrics != nil {
+		return metrics.TotalTokens
+	}
+	return 0
+}
+
+func writeChatChunk(w http.ResponseWriter, id string, model string, delta map[string]any, finishReason *string) {
+	b, err := json.Marshal(chatCompletionResponse{
+		ID:      "chatcmpl-" + id,
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []chatCompletionChoice{{Delta: delta, FinishReason: finishReason}},
+	})
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "data: %s\n\n", b)
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, msg string, typ string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"message": msg, "type": typ},
+	})
+}
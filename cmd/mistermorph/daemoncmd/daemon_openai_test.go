@@ -0,0 +1,178 @@
+package daemoncmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/agent"
+)
+
+func TestChatMessagesToTask(t *testing.T) {
+	msgs := []chatCompletionMsg{
+		{Role: "system", Content: json.RawMessage(`"Answer briefly."`)},
+		{Role: "user", Content: json.RawMessage(`"hi"`)},
+		{Role: "assistant", Content: json.RawMessage(`"hello"`)},
+		{Role: "user", Content: json.RawMessage(`[{"type":"text","text":"what is 2+2?"}]`)},
+	}
+	task, history, err := chatMessagesToTask(msgs)
+	if err != nil {
+		t.Fatalf("chatMessagesToTask() error = %v", err)
+	}
+	if !strings.Contains(task, "Answer briefly.") || !strings.HasSuffix(task, "what is 2+2?") {
+		t.Fatalf("task = %q", task)
+	}
+	if len(history) != 2 || history[0].Role != "user" || history[1].Content != "hello" {
+		t.Fatalf("history = %+v", history)
+	}
+
+	if _, _, err := chatMessagesToTask(msgs[:3]); err == nil {
+		t.Fatalf("expected error when the last message is not from the user")
+	}
+}
+
+func TestChatCompletionsHandler(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		s := NewTaskStore(10)
+		go func() {
+			qt := s.Next()
+			if len(qt.history) != 1 {
+				t.Errorf("queued history = %+v", qt.history)
+			}
+			s.Update(qt.info.ID, func(info *TaskInfo) {
+				info.Status = TaskDone
+				info.Result = map[string]any{"final": &agent.Final{Output: "4"}}
+			})
+		}()
+
+		srv := httptest.NewServer(&chatCompletionsHandler{store: s, model: "m", timeout: time.Minute})
+		body := `{"model":"mistermorph","stream":` + map[bool]string{false: "false", true: "true"}[stream] +
+			`,"messages":[{"role":"user","content":"hi"},{"role":"user","content":"2+2?"}]}`
+		resp, err := http.Post(srv.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST error = %v", err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("stream=%v status = %d: %s", stream, resp.StatusCode, raw)
+		}
+		if !stream {
+			var out chatCompletionResponse
+			if err := json.Unmarshal(raw, &out); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(out.Choices) != 1 || out.Choices[0].Message["content"] != "4" || out.Model != "m" {
+				t.Fatalf("response = %s", raw)
+			}
+			continue
+		}
+		text := string(raw)
+		if !strings.Contains(text, `"content":"4"`) || !strings.HasSuffix(strings.TrimSpace(text), "data: [DONE]") {
+			t.Fatalf("stream = %s", text)
+		}
+	}
+}
+
+func TestChatCompletionsHandlerStreamsDeltas(t *testing.T) {
+	s := NewTaskStore(10)
+	go func() {
+		qt := s.Next()
+		if !qt.streamOutput {
+			t.Errorf("stream request should enqueue a streaming task")
+		}
+		s.Publish(qt.info.ID, EventDelta, map[string]any{"text": "\nThe answer"})
+		s.Publish(qt.info.ID, EventDelta, map[string]any{"text": " is"})
+		s.Update(qt.info.ID, func(info *TaskInfo) {
+			info.Status = TaskDone
+			info.Result = map[string]any{"final": &agent.Final{Output: "The answer is 4."}}
+		})
+	}()
+	srv := httptest.NewServer(&chatCompletionsHandler{store: s, model: "m", timeout: time.Minute})
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"stream":true,"messages":[{"role":"user","content":"2+2?"}]}`))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var content []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk chatCompletionResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("decode chunk %q: %v", data, err)
+		}
+		if c, ok := chunk.Choices[0].Delta["content"].(string); ok {
+			content = append(content, c)
+		}
+	}
+	// Deltas still in flight when the task finishes arrive with the rest.
+	if len(content) < 2 || content[0] != "The answer" || strings.Join(content, "") != "The answer is 4." {
+		t.Fatalf("streamed content = %q\n%s", content, raw)
+	}
+}
+
+func TestChatCompletionsHandlerRegeneratedAnswer(t *testing.T) {
+	s := NewTaskStore(10)
+	go func() {
+		qt := s.Next()
+		s.Publish(qt.info.ID, EventDelta, map[string]any{"text": "Draft", "step": 1})
+		// The first final was rejected; step 2 regenerates it.
+		s.Publish(qt.info.ID, EventDelta, map[string]any{"text": "Fixed", "step": 2})
+		s.Update(qt.info.ID, func(info *TaskInfo) {
+			info.Status = TaskDone
+			info.Result = map[string]any{"final": &agent.Final{Output: "Fixed answer"}}
+		})
+	}()
+	srv := httptest.NewServer(&chatCompletionsHandler{store: s, model: "m", timeout: time.Minute})
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	text := string(raw)
+	if strings.Contains(text, `"content":"Fixed`) || !strings.Contains(text, "regenerated") || strings.Contains(text, `"finish_reason":"stop"`) {
+		t.Fatalf("stream = %s", text)
+	}
+}
+
+func TestChatCompletionsHandlerPendingApproval(t *testing.T) {
+	s := NewTaskStore(10)
+	go func() {
+		qt := s.Next()
+		s.Update(qt.info.ID, func(info *TaskInfo) {
+			info.Status = TaskPending
+			info.ApprovalRequestID = "apr_1"
+		})
+	}()
+	srv := httptest.NewServer(&chatCompletionsHandler{store: s, model: "m", timeout: time.Minute})
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"messages":[{"role":"user","content":"rm -rf /tmp/x"}]}`))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(string(raw), "apr_1") || !strings.Contains(string(raw), "approval_pending") {
+		t.Fatalf("status = %d, body = %s", resp.StatusCode, raw)
+	}
+	if info, _ := s.Get(resp.Header.Get("X-Mistermorph-Task-Id")); info == nil || info.Status != TaskPending {
+		t.Fatalf("task should stay pending, got %+v", info)
+	}
+}
@@ -2,22 +2,38 @@ package daemoncmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand/v2"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/quailyquaily/mistermorph/internal/heartbeatutil"
+	"github.com/quailyquaily/mistermorph/llm"
 )
 
+var errTaskNotFound = errors.New("task not found")
+
 type queuedTask struct {
 	info   *TaskInfo
 	ctx    context.Context
 	cancel context.CancelFunc
+	// gen counts the times the task was queued. A queue entry whose gen no
+	// longer matches is stale (the task was canceled and queued again) and
+	// is skipped by Next.
+	gen uint64
 
 	// resumeApprovalID is set when re-queued to resume a paused run from an approval request.
 	resumeApprovalID string
+	// resumeCheckpoint is set when re-queued to continue a run from its last checkpoint.
+	resumeCheckpoint bool
+
+	// history holds prior conversation turns (e.g. from /v1/chat/completions).
+	history []llm.Message
+	// streamOutput publishes EventDelta events while the final output is generated.
+	streamOutput bool
 
 	// Internal-only heartbeat fields.
 	meta           map[string]any
@@ -25,10 +41,37 @@ type queuedTask struct {
 	heartbeatState *heartbeatutil.State
 }
 
+// queueEntry is one push of a task onto a priority queue.
+type queueEntry struct {
+	qt  *queuedTask
+	gen uint64
+}
+
 type TaskStore struct {
 	mu    sync.RWMutex
 	tasks map[string]*queuedTask
-	queue chan *queuedTask
+
+	// One queue per priority class, each holding up to maxQueue tasks.
+	high   chan queueEntry
+	normal chan queueEntry
+	low    chan queueEntry
+
+	// path and lockPath are set by OpenTaskStore; empty means memory-only.
+	path     string
+	lockPath string
+
+	// saveMu serializes disk writes, which happen outside mu; saveSeq
+	// numbers snapshots and savedSeq is the last one written.
+	saveMu   sync.Mutex
+	saveSeq  uint64
+	savedSeq uint64
+
+	// Finished tasks older than retainFor, or beyond the newest retainMax,
+	// are dropped. Zero disables a limit.
+	retainFor time.Duration
+	retainMax int
+
+	events *taskEventHub
 }
 
 func NewTaskStore(maxQueue int) *TaskStore {
@@ -36,20 +79,137 @@ func NewTaskStore(maxQueue int) *TaskStore {
 		maxQueue = 100
 	}
 	return &TaskStore{
-		tasks: make(map[string]*queuedTask),
-		queue: make(chan *queuedTask, maxQueue),
+		tasks:  make(map[string]*queuedTask),
+		high:   make(chan queueEntry, maxQueue),
+		normal: make(chan queueEntry, maxQueue),
+		low:    make(chan queueEntry, maxQueue),
+		events: newTaskEventHub(),
+	}
+}
+
+// SetRetention limits how long finished (done, failed or canceled) tasks
+// are kept: maxAge after they finish, and at most maxFinished of them.
+// Zero disables a limit. Tasks past the limits are dropped right away and
+// whenever another task finishes.
+func (s *TaskStore) SetRetention(maxAge time.Duration, maxFinished int) {
+	s.mu.Lock()
+	s.retainFor = maxAge
+	s.retainMax = maxFinished
+	pruned := s.pruneLocked(time.Now())
+	var snap *taskSnapshot
+	if pruned {
+		snap = s.snapshotLocked()
+	}
+	s.mu.Unlock()
+	s.save(snap)
+}
+
+// pruneLocked drops finished tasks past the retention limits and reports
+// whether any were dropped. Callers hold s.mu.
+func (s *TaskStore) pruneLocked(now time.Time) bool {
+	if s.retainFor <= 0 && s.retainMax <= 0 {
+		return false
 	}
+	type finished struct {
+		id string
+		at time.Time
+	}
+	var done []finished
+	pruned := false
+	for id, qt := range s.tasks {
+		if qt == nil || qt.info == nil || !isTerminalStatus(qt.info.Status) {
+			continue
+		}
+		at := qt.info.CreatedAt
+		if qt.info.FinishedAt != nil {
+			at = *qt.info.FinishedAt
+		}
+		if s.retainFor > 0 && now.Sub(at) > s.retainFor {
+			delete(s.tasks, id)
+			s.events.drop(id)
+			pruned = true
+			continue
+		}
+		done = append(done, finished{id: id, at: at})
+	}
+	if s.retainMax > 0 && len(done) > s.retainMax {
+		sort.Slice(done, func(i, j int) bool { return done[i].at.After(done[j].at) })
+		for _, f := range done[s.retainMax:] {
+			delete(s.tasks, f.id)
+			s.events.drop(f.id)
+		}
+		pruned = true
+	}
+	return pruned
 }
 
-func (s *TaskStore) Enqueue(parent context.Context, task string, model string, timeout time.Duration) (*TaskInfo, error) {
-	return s.enqueue(parent, task, model, timeout, nil, false, nil)
+// Publish records a progress event for a task (see GET /tasks/{id}/events).
+// It does not take s.mu, so Update callbacks may call it.
+func (s *TaskStore) Publish(id string, typ string, data any) {
+	s.events.publish(id, typ, data)
+}
+
+// statusChangedLocked publishes a status event and closes the event stream
+// once the task reaches a terminal status, pruning old finished tasks.
+// Callers hold s.mu.
+func (s *TaskStore) statusChangedLocked(info *TaskInfo) {
+	data := map[string]any{"id": info.ID, "status": info.Status}
+	if info.Error != "" {
+		data["error"] = info.Error
+	}
+	if info.ApprovalRequestID != "" && info.Status == TaskPending {
+		data["approval_request_id"] = info.ApprovalRequestID
+	}
+	s.events.publish(info.ID, EventStatus, data)
+	if isTerminalStatus(info.Status) {
+		s.events.finish(info.ID)
+		s.pruneLocked(time.Now())
+	}
+}
+
+func (s *TaskStore) queueFor(p TaskPriority) chan queueEntry {
+	switch p {
+	case PriorityHigh:
+		return s.high
+	case PriorityLow:
+		return s.low
+	default:
+		return s.normal
+	}
+}
+
+// pushLocked queues qt under a new generation, which makes any entry of it
+// still in a queue stale, and reports whether there was room. Callers hold
+// s.mu.
+func (s *TaskStore) pushLocked(qt *queuedTask) bool {
+	qt.gen++
+	select {
+	case s.queueFor(qt.info.Priority) <- queueEntry{qt: qt, gen: qt.gen}:
+		return true
+	default:
+		qt.gen--
+		return false
+	}
+}
+
+func (s *TaskStore) Enqueue(parent context.Context, task string, model string, timeout time.Duration, priority TaskPriority) (*TaskInfo, error) {
+	return s.enqueue(parent, task, model, timeout, priority, nil, false, nil, false, nil)
+}
+
+// EnqueueWithHistory enqueues a task that continues an existing conversation.
+// With streamOutput, the final output is also published as EventDelta events.
+func (s *TaskStore) EnqueueWithHistory(parent context.Context, task string, history []llm.Message, model string, timeout time.Duration, priority TaskPriority, streamOutput bool) (*TaskInfo, error) {
+	return s.enqueue(parent, task, model, timeout, priority, history, streamOutput, nil, false, nil)
 }
 
 func (s *TaskStore) EnqueueHeartbeat(parent context.Context, task string, model string, timeout time.Duration, meta map[string]any, hbState *heartbeatutil.State) (*TaskInfo, error) {
-	return s.enqueue(parent, task, model, timeout, meta, true, hbState)
+	return s.enqueue(parent, task, model, timeout, PriorityLow, nil, false, meta, true, hbState)
 }
 
-func (s *TaskStore) enqueue(parent context.Context, task string, model string, timeout time.Duration, meta map[string]any, isHeartbeat bool, hbState *heartbeatutil.State) (*TaskInfo, error) {
+func (s *TaskStore) enqueue(parent context.Context, task string, model string, timeout time.Duration, priority TaskPriority, history []llm.Message, streamOutput bool, meta map[string]any, isHeartbeat bool, hbState *heartbeatutil.State) (*TaskInfo, error) {
+	if priority == "" {
+		priority = PriorityNormal
+	}
 	if timeout <= 0 {
 		timeout = 10 * time.Minute
 	}
@@ -67,27 +227,29 @@ func (s *TaskStore) enqueue(parent context.Context, task string, model string, t
 		Task:      task,
 		Model:     model,
 		Timeout:   timeout.String(),
+		Priority:  priority,
 		CreatedAt: now,
 	}
-	qt := &queuedTask{info: info, ctx: ctx, cancel: cancel}
+	qt := &queuedTask{info: info, ctx: ctx, cancel: cancel, history: history, streamOutput: streamOutput}
 	qt.meta = meta
 	qt.isHeartbeat = isHeartbeat
 	qt.heartbeatState = hbState
 
 	s.mu.Lock()
-	s.tasks[id] = qt
-	s.mu.Unlock()
-
-	select {
-	case s.queue <- qt:
-		return info, nil
-	default:
-		qt.cancel()
-		s.mu.Lock()
-		delete(s.tasks, id)
+	if !s.pushLocked(qt) {
 		s.mu.Unlock()
+		qt.cancel()
 		return nil, fmt.Errorf("queue is full")
 	}
+	s.tasks[id] = qt
+	s.statusChangedLocked(info)
+	var snap *taskSnapshot
+	if !isHeartbeat {
+		snap = s.snapshotLocked()
+	}
+	s.mu.Unlock()
+	s.save(snap)
+	return info, nil
 }
 
 func (s *TaskStore) Get(id string) (*TaskInfo, bool) {
@@ -102,22 +264,114 @@ func (s *TaskStore) Get(id string) (*TaskInfo, bool) {
 	return &cp, true
 }
 
+// Next blocks until a task is available, preferring higher priorities.
+// Stale entries of tasks queued again since are skipped, so a task never
+// runs twice for one enqueue.
 func (s *TaskStore) Next() *queuedTask {
-	return <-s.queue
+	for {
+		e := s.dequeue()
+		s.mu.RLock()
+		current := e.gen == e.qt.gen
+		s.mu.RUnlock()
+		if current {
+			return e.qt
+		}
+	}
+}
+
+func (s *TaskStore) dequeue() queueEntry {
+	select {
+	case e := <-s.high:
+		return e
+	default:
+	}
+	select {
+	case e := <-s.high:
+		return e
+	case e := <-s.normal:
+		return e
+	default:
+	}
+	select {
+	case e := <-s.high:
+		return e
+	case e := <-s.normal:
+		return e
+	case e := <-s.low:
+		return e
+	}
 }
 
 func (s *TaskStore) QueueLen() int {
-	return len(s.queue)
+	return len(s.high) + len(s.normal) + len(s.low)
+}
+
+// Cancel cancels a queued, running or pending task. A running task stops at
+// its next step boundary (or when the in-flight LLM/tool call returns).
+func (s *TaskStore) Cancel(id string) (*TaskInfo, error) {
+	s.mu.Lock()
+	qt := s.tasks[strings.TrimSpace(id)]
+	if qt == nil || qt.info == nil {
+		s.mu.Unlock()
+		return nil, errTaskNotFound
+	}
+	switch qt.info.Status {
+	case TaskQueued, TaskRunning, TaskPending:
+	default:
+		s.mu.Unlock()
+		return nil, fmt.Errorf("task is already %s", qt.info.Status)
+	}
+	now := time.Now()
+	qt.info.Status = TaskCanceled
+	qt.info.Error = "canceled by request"
+	qt.info.FinishedAt = &now
+	qt.resumeApprovalID = ""
+	qt.resumeCheckpoint = false
+	cancel := qt.cancel
+	s.statusChangedLocked(qt.info)
+	var snap *taskSnapshot
+	if !qt.isHeartbeat {
+		snap = s.snapshotLocked()
+	}
+	cp := *qt.info
+	s.mu.Unlock()
+	s.save(snap)
+
+	if cancel != nil {
+		cancel()
+	}
+	return &cp, nil
 }
 
 func (s *TaskStore) Update(id string, fn func(info *TaskInfo)) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	qt := s.tasks[id]
 	if qt == nil || qt.info == nil {
+		s.mu.Unlock()
 		return
 	}
+	prev := qt.info.Status
 	fn(qt.info)
+	if qt.info.Status != prev {
+		s.statusChangedLocked(qt.info)
+	}
+	var snap *taskSnapshot
+	if !qt.isHeartbeat {
+		snap = s.snapshotLocked()
+	}
+	s.mu.Unlock()
+	s.save(snap)
+}
+
+// takeResume returns and clears the resume request of a dequeued task.
+func (s *TaskStore) takeResume(qt *queuedTask) (approvalID string, checkpoint bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	approvalID = strings.TrimSpace(qt.resumeApprovalID)
+	checkpoint = qt.resumeCheckpoint
+	qt.resumeApprovalID = ""
+	qt.resumeCheckpoint = false
+	return approvalID, checkpoint
 }
 
 func (s *TaskStore) EnqueueResumeByApprovalID(approvalRequestID string) (string, error) {
@@ -151,15 +405,16 @@ func (s *TaskStore) EnqueueResumeByApprovalID(approvalRequestID string) (string,
 	}
 
 	qt.resumeApprovalID = approvalRequestID
-	select {
-	case s.queue <- qt:
-		s.mu.Unlock()
-		return qt.info.ID, nil
-	default:
+	if !s.pushLocked(qt) {
 		qt.resumeApprovalID = ""
 		s.mu.Unlock()
 		return "", fmt.Errorf("queue is full")
 	}
+	id := qt.info.ID
+	snap := s.snapshotLocked()
+	s.mu.Unlock()
+	s.save(snap)
+	return id, nil
 }
 
 func (s *TaskStore) FailPendingByApprovalID(approvalRequestID string, errMsg string) (string, bool) {
@@ -170,6 +425,7 @@ func (s *TaskStore) FailPendingByApprovalID(approvalRequestID string, errMsg str
 
 	var cancel context.CancelFunc
 	var id string
+	var snap *taskSnapshot
 	now := time.Now()
 
 	s.mu.Lock()
@@ -188,12 +444,71 @@ func (s *TaskStore) FailPendingByApprovalID(approvalRequestID string, errMsg str
 		qt.info.Error = strings.TrimSpace(errMsg)
 		qt.info.FinishedAt = &now
 		cancel = qt.cancel
+		s.statusChangedLocked(qt.info)
+		snap = s.snapshotLocked()
 		break
 	}
 	s.mu.Unlock()
+	s.save(snap)
 
 	if cancel != nil {
 		cancel()
 	}
 	return id, cancel != nil
 }
+
+// EnqueueResumeCheckpoint re-queues a failed or canceled task (or, after a
+// restart, a task only known by its checkpoint) to continue from its last
+// checkpoint. The task id is the run id the checkpoint is stored under.
+func (s *TaskStore) EnqueueResumeCheckpoint(parent context.Context, id string, timeout time.Duration) (*TaskInfo, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, fmt.Errorf("missing id")
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+
+	s.mu.Lock()
+	qt := s.tasks[id]
+	var prev TaskInfo
+	if qt != nil && qt.info != nil {
+		switch qt.info.Status {
+		case TaskFailed, TaskCanceled:
+		default:
+			s.mu.Unlock()
+			return nil, fmt.Errorf("task is %s", qt.info.Status)
+		}
+		prev = *qt.info
+		qt.cancel()
+	} else {
+		qt = &queuedTask{info: &TaskInfo{ID: id, CreatedAt: time.Now()}}
+		s.tasks[id] = qt
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	qt.ctx = ctx
+	qt.cancel = cancel
+	qt.resumeCheckpoint = true
+	qt.info.Status = TaskQueued
+	qt.info.Timeout = timeout.String()
+	qt.info.Error = ""
+	qt.info.FinishedAt = nil
+	cp := *qt.info
+
+	if !s.pushLocked(qt) {
+		qt.resumeCheckpoint = false
+		if prev.ID == "" {
+			delete(s.tasks, id)
+		} else {
+			*qt.info = prev
+		}
+		s.mu.Unlock()
+		cancel()
+		return nil, fmt.Errorf("queue is full")
+	}
+	s.statusChangedLocked(qt.info)
+	snap := s.snapshotLocked()
+	s.mu.Unlock()
+	s.save(snap)
+	return &cp, nil
+}
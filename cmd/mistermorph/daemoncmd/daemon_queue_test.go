@@ -0,0 +1,62 @@
+package daemoncmd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTaskStoreNextPrefersHigherPriority(t *testing.T) {
+	s := NewTaskStore(10)
+	low, _ := s.EnqueueHeartbeat(context.Background(), "heartbeat", "m", time.Minute, nil, nil)
+	normal, _ := s.Enqueue(context.Background(), "normal", "m", time.Minute, PriorityNormal)
+	high, _ := s.Enqueue(context.Background(), "high", "m", time.Minute, PriorityHigh)
+
+	for _, want := range []string{high.ID, normal.ID, low.ID} {
+		if got := s.Next().info.ID; got != want {
+			t.Fatalf("Next() = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestTaskStoreCancel(t *testing.T) {
+	s := NewTaskStore(10)
+	info, _ := s.Enqueue(context.Background(), "task", "m", time.Minute, PriorityNormal)
+
+	got, err := s.Cancel(info.ID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if got.Status != TaskCanceled || got.FinishedAt == nil {
+		t.Fatalf("Cancel() = %+v", got)
+	}
+	qt := s.Next()
+	if qt.ctx.Err() == nil {
+		t.Fatalf("canceled task context should be done")
+	}
+	if _, err := s.Cancel(info.ID); err == nil {
+		t.Fatalf("second Cancel() should fail")
+	}
+	if _, err := s.Cancel("missing"); err != errTaskNotFound {
+		t.Fatalf("Cancel(missing) error = %v, want errTaskNotFound", err)
+	}
+}
+
+func TestTaskStoreResumeCanceledQueuedTaskRunsOnce(t *testing.T) {
+	s := NewTaskStore(10)
+	info, _ := s.Enqueue(context.Background(), "task", "m", time.Minute, PriorityNormal)
+	if _, err := s.Cancel(info.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if _, err := s.EnqueueResumeCheckpoint(context.Background(), info.ID, time.Minute); err != nil {
+		t.Fatalf("EnqueueResumeCheckpoint() error = %v", err)
+	}
+
+	qt := s.Next()
+	if _, checkpoint := s.takeResume(qt); !checkpoint || qt.ctx.Err() != nil {
+		t.Fatalf("Next() returned the canceled entry (checkpoint=%v, ctx err=%v)", checkpoint, qt.ctx.Err())
+	}
+	if n := s.QueueLen(); n != 0 {
+		t.Fatalf("QueueLen() = %d after the resumed run, want the stale entry gone", n)
+	}
+}
@@ -0,0 +1,173 @@
+package daemoncmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/internal/fsstore"
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+const taskStoreFileVersion = 1
+
+type taskStoreFile struct {
+	Version int          `json:"version"`
+	Tasks   []taskRecord `json:"tasks"`
+}
+
+type taskRecord struct {
+	Info             TaskInfo      `json:"info"`
+	ResumeApprovalID string        `json:"resume_approval_id,omitempty"`
+	ResumeCheckpoint bool          `json:"resume_checkpoint,omitempty"`
+	History          []llm.Message `json:"history,omitempty"`
+}
+
+// OpenTaskStore returns a TaskStore that mirrors every change to
+// <dir>/tasks.json and restores it on startup. Tasks that were queued or
+// running when the process stopped are re-queued; a running task continues
+// from its checkpoint when hasCheckpoint reports one, otherwise it starts over.
+// Pending tasks keep their ApprovalRequestID so approvals still resume them.
+func OpenTaskStore(maxQueue int, dir string, hasCheckpoint func(id string) bool) (*TaskStore, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return nil, fmt.Errorf("missing task store dir")
+	}
+	lockPath, err := fsstore.BuildLockPath(filepath.Join(dir, ".fslocks"), "state.daemon_tasks")
+	if err != nil {
+		return nil, err
+	}
+	s := NewTaskStore(maxQueue)
+	s.path = filepath.Join(dir, "tasks.json")
+	s.lockPath = lockPath
+
+	var file taskStoreFile
+	if _, err := fsstore.ReadJSON(s.path, &file); err != nil {
+		return nil, err
+	}
+	if file.Version != 0 && file.Version != taskStoreFileVersion {
+		return nil, fmt.Errorf("unsupported task store version: %d", file.Version)
+	}
+	sort.SliceStable(file.Tasks, func(i, j int) bool {
+		return file.Tasks[i].Info.CreatedAt.Before(file.Tasks[j].Info.CreatedAt)
+	})
+
+	s.mu.Lock()
+	for _, rec := range file.Tasks {
+		info := rec.Info
+		if strings.TrimSpace(info.ID) == "" {
+			continue
+		}
+		qt := &queuedTask{
+			info:             &info,
+			resumeApprovalID: rec.ResumeApprovalID,
+			resumeCheckpoint: rec.ResumeCheckpoint,
+			history:          rec.History,
+		}
+		s.tasks[info.ID] = qt
+
+		requeue := false
+		switch info.Status {
+		case TaskQueued:
+			requeue = true
+		case TaskRunning:
+			info.Status = TaskQueued
+			qt.resumeCheckpoint = hasCheckpoint != nil && hasCheckpoint(info.ID)
+			requeue = true
+		case TaskPending:
+			requeue = strings.TrimSpace(qt.resumeApprovalID) != ""
+		}
+
+		switch info.Status {
+		case TaskQueued, TaskPending:
+			timeout, err := time.ParseDuration(info.Timeout)
+			if err != nil || timeout <= 0 {
+				timeout = 10 * time.Minute
+			}
+			qt.ctx, qt.cancel = context.WithTimeout(context.Background(), timeout)
+		default:
+			qt.ctx, qt.cancel = context.WithCancel(context.Background())
+			qt.cancel()
+		}
+
+		if !requeue {
+			continue
+		}
+		if !s.pushLocked(qt) {
+			now := time.Now()
+			qt.cancel()
+			qt.resumeApprovalID = ""
+			qt.resumeCheckpoint = false
+			info.Status = TaskFailed
+			info.Error = "queue is full after restart"
+			info.FinishedAt = &now
+		}
+	}
+	// Open an event stream for every task that can still make progress.
+	for _, qt := range s.tasks {
+		if !isTerminalStatus(qt.info.Status) {
+			s.statusChangedLocked(qt.info)
+		}
+	}
+	snap := s.snapshotLocked()
+	s.mu.Unlock()
+	s.save(snap)
+	return s, nil
+}
+
+// taskSnapshot is the file content captured under s.mu; seq orders
+// snapshots so a late writer never replaces a newer file.
+type taskSnapshot struct {
+	seq  uint64
+	file taskStoreFile
+}
+
+// snapshotLocked captures all non-heartbeat tasks for save. It returns nil
+// for a memory-only store. Callers hold s.mu and call save after unlocking.
+func (s *TaskStore) snapshotLocked() *taskSnapshot {
+	if s.path == "" {
+		return nil
+	}
+	file := taskStoreFile{Version: taskStoreFileVersion, Tasks: make([]taskRecord, 0, len(s.tasks))}
+	for _, qt := range s.tasks {
+		if qt == nil || qt.info == nil || qt.isHeartbeat {
+			continue
+		}
+		file.Tasks = append(file.Tasks, taskRecord{
+			Info:             *qt.info,
+			ResumeApprovalID: qt.resumeApprovalID,
+			ResumeCheckpoint: qt.resumeCheckpoint,
+			History:          qt.history,
+		})
+	}
+	sort.SliceStable(file.Tasks, func(i, j int) bool {
+		return file.Tasks[i].Info.CreatedAt.Before(file.Tasks[j].Info.CreatedAt)
+	})
+	s.saveSeq++
+	return &taskSnapshot{seq: s.saveSeq, file: file}
+}
+
+// save writes a snapshot to disk unless a newer one was already written.
+// It must be called without s.mu held.
+func (s *TaskStore) save(snap *taskSnapshot) {
+	if snap == nil {
+		return
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if snap.seq <= s.savedSeq {
+		return
+	}
+	err := fsstore.WithLock(context.Background(), s.lockPath, func() error {
+		return fsstore.WriteJSONAtomic(s.path, snap.file, fsstore.FileOptions{})
+	})
+	if err != nil {
+		slog.Default().Warn("daemon_task_store_save_error", "path", s.path, "error", err.Error())
+		return
+	}
+	s.savedSeq = snap.seq
+}
@@ -0,0 +1,107 @@
+package daemoncmd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOpenTaskStoreRestoresTasks(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := OpenTaskStore(10, dir, nil)
+	if err != nil {
+		t.Fatalf("OpenTaskStore() error = %v", err)
+	}
+	queued, _ := s.Enqueue(context.Background(), "queued task", "m", time.Minute, PriorityNormal)
+	running, _ := s.Enqueue(context.Background(), "running task", "m", time.Minute, PriorityNormal)
+	pending, _ := s.Enqueue(context.Background(), "pending task", "m", time.Minute, PriorityNormal)
+	done, _ := s.Enqueue(context.Background(), "done task", "m", time.Minute, PriorityNormal)
+	s.Update(running.ID, func(info *TaskInfo) { info.Status = TaskRunning })
+	s.Update(pending.ID, func(info *TaskInfo) {
+		info.Status = TaskPending
+		info.ApprovalRequestID = "apr_1"
+	})
+	s.Update(done.ID, func(info *TaskInfo) {
+		info.Status = TaskDone
+		info.Result = map[string]any{"final": "ok"}
+	})
+
+	restored, err := OpenTaskStore(10, dir, func(id string) bool { return id == running.ID })
+	if err != nil {
+		t.Fatalf("OpenTaskStore() reopen error = %v", err)
+	}
+	if n := restored.QueueLen(); n != 2 {
+		t.Fatalf("QueueLen() = %d, want 2 (queued + running)", n)
+	}
+	first := restored.Next()
+	second := restored.Next()
+	if first.info.ID != queued.ID || second.info.ID != running.ID {
+		t.Fatalf("requeue order = %s, %s", first.info.ID, second.info.ID)
+	}
+	if approvalID, checkpoint := restored.takeResume(second); approvalID != "" || !checkpoint {
+		t.Fatalf("running task should resume from checkpoint, got approval=%q checkpoint=%v", approvalID, checkpoint)
+	}
+
+	info, ok := restored.Get(done.ID)
+	if !ok || info.Status != TaskDone || info.Result == nil {
+		t.Fatalf("done task not restored: %+v", info)
+	}
+
+	id, err := restored.EnqueueResumeByApprovalID("apr_1")
+	if err != nil || id != pending.ID {
+		t.Fatalf("EnqueueResumeByApprovalID() = %q, %v", id, err)
+	}
+}
+
+func TestTaskStoreRetention(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenTaskStore(10, dir, nil)
+	if err != nil {
+		t.Fatalf("OpenTaskStore() error = %v", err)
+	}
+	old, _ := s.Enqueue(context.Background(), "old", "m", time.Minute, PriorityNormal)
+	s.Update(old.ID, func(info *TaskInfo) {
+		finished := time.Now().Add(-48 * time.Hour)
+		info.Status = TaskDone
+		info.FinishedAt = &finished
+	})
+	var done []string
+	for i := 0; i < 3; i++ {
+		info, _ := s.Enqueue(context.Background(), "done", "m", time.Minute, PriorityNormal)
+		s.Update(info.ID, func(info *TaskInfo) {
+			finished := time.Now().Add(-time.Duration(3-i) * time.Minute)
+			info.Status = TaskFailed
+			info.FinishedAt = &finished
+		})
+		done = append(done, info.ID)
+	}
+	queued, _ := s.Enqueue(context.Background(), "queued", "m", time.Minute, PriorityNormal)
+
+	s.SetRetention(24*time.Hour, 2)
+	for _, id := range []string{old.ID, done[0]} {
+		if _, ok := s.Get(id); ok {
+			t.Fatalf("task %s should have been pruned", id)
+		}
+	}
+	for _, id := range []string{done[1], done[2], queued.ID} {
+		if _, ok := s.Get(id); !ok {
+			t.Fatalf("task %s should be kept", id)
+		}
+	}
+
+	// Another finished task pushes out the oldest kept one, on disk as well.
+	s.Cancel(queued.ID)
+	restored, err := OpenTaskStore(10, dir, nil)
+	if err != nil {
+		t.Fatalf("OpenTaskStore() reopen error = %v", err)
+	}
+	if _, ok := restored.Get(done[1]); ok {
+		t.Fatalf("task %s should have been pruned from disk", done[1])
+	}
+	for _, id := range []string{done[2], queued.ID} {
+		if _, ok := restored.Get(id); !ok {
+			t.Fatalf("task %s missing after reopen", id)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package daemoncmd
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type TaskStatus string
 
@@ -13,10 +17,34 @@ const (
 	TaskCanceled TaskStatus = "canceled"
 )
 
+// TaskPriority selects the queue a task waits in. Workers always take the
+// highest non-empty priority first; heartbeat tasks use PriorityLow.
+type TaskPriority string
+
+const (
+	PriorityHigh   TaskPriority = "high"
+	PriorityNormal TaskPriority = "normal"
+	PriorityLow    TaskPriority = "low"
+)
+
+func ParseTaskPriority(s string) (TaskPriority, error) {
+	switch TaskPriority(strings.ToLower(strings.TrimSpace(s))) {
+	case "", PriorityNormal:
+		return PriorityNormal, nil
+	case PriorityHigh:
+		return PriorityHigh, nil
+	case PriorityLow:
+		return PriorityLow, nil
+	default:
+		return "", fmt.Errorf("invalid priority %q (use high|normal|low)", s)
+	}
+}
+
 type SubmitTaskRequest struct {
-	Task    string `json:"task"`
-	Model   string `json:"model,omitempty"`
-	Timeout string `json:"timeout,omitempty"` // time.ParseDuration; optional
+	Task     string       `json:"task"`
+	Model    string       `json:"model,omitempty"`
+	Timeout  string       `json:"timeout,omitempty"`  // time.ParseDuration; optional
+	Priority TaskPriority `json:"priority,omitempty"` // high|normal|low; optional
 }
 
 type SubmitTaskResponse struct {
@@ -25,17 +53,21 @@ type SubmitTaskResponse struct {
 }
 
 type TaskInfo struct {
-	ID                string     `json:"id"`
-	Status            TaskStatus `json:"status"`
-	Task              string     `json:"task"`
-	Model             string     `json:"model"`
-	Timeout           string     `json:"timeout"`
-	CreatedAt         time.Time  `json:"created_at"`
-	StartedAt         *time.Time `json:"started_at,omitempty"`
-	PendingAt         *time.Time `json:"pending_at,omitempty"`
-	ResumedAt         *time.Time `json:"resumed_at,omitempty"`
-	FinishedAt        *time.Time `json:"finished_at,omitempty"`
-	ApprovalRequestID string     `json:"approval_request_id,omitempty"`
-	Error             string     `json:"error,omitempty"`
-	Result            any        `json:"result,omitempty"`
+	ID                string       `json:"id"`
+	Status            TaskStatus   `json:"status"`
+	Task              string       `json:"task"`
+	Model             string       `json:"model"`
+	Timeout           string       `json:"timeout"`
+	Priority          TaskPriority `json:"priority,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+	StartedAt         *time.Time   `json:"started_at,omitempty"`
+	PendingAt         *time.Time   `json:"pending_at,omitempty"`
+	ResumedAt         *time.Time   `json:"resumed_at,omitempty"`
+	FinishedAt        *time.Time   `json:"finished_at,omitempty"`
+	ApprovalRequestID string       `json:"approval_request_id,omitempty"`
+	Error             string       `json:"error,omitempty"`
+	Result            any          `json:"result,omitempty"`
+	// LLM usage of the latest run attempt, set when it finishes or pauses.
+	TotalTokens int     `json:"total_tokens,omitempty"`
+	CostUSD     float64 `json:"cost_usd,omitempty"`
 }
@@ -0,0 +1,237 @@
+package daemoncmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/agent"
+	"github.com/quailyquaily/mistermorph/guard"
+	"github.com/quailyquaily/mistermorph/internal/heartbeatutil"
+	"github.com/quailyquaily/mistermorph/internal/llmutil"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+// taskWorker runs dequeued tasks. Several workers may share one taskWorker:
+// every task gets its own engine, context and task-scoped logger.
+type taskWorker struct {
+	store       *TaskStore
+	logger      *slog.Logger
+	logOpts     agent.LogOptions
+	client      llm.Client
+	models      *llmutil.Models
+	registry    *tools.Registry
+	baseCfg     agent.Config
+	guard       *guard.Guard
+	checkpoints agent.CheckpointStore
+}
+
+func (w *taskWorker) process(qt *queuedTask) {
+	if qt == nil || qt.info == nil {
+		return
+	}
+	store := w.store
+	id := qt.info.ID
+	logger := w.logger.With("task_id", id)
+	resumeApprovalID, resumeCheckpoint := store.takeResume(qt)
+
+	if err := qt.ctx.Err(); err != nil {
+		// Timed out (or canceled) while waiting in the queue.
+		if qt.isHeartbeat && qt.heartbeatState != nil {
+			qt.heartbeatState.EndSkipped()
+		}
+		finished := time.Now()
+		store.Update(id, func(info *TaskInfo) {
+			if isTerminalStatus(info.Status) {
+				return
+			}
+			info.Status = TaskCanceled
+			info.Error = err.Error()
+			info.FinishedAt = &finished
+		})
+		return
+	}
+
+	started := time.Now()
+	store.Update(id, func(info *TaskInfo) {
+		info.Status = TaskRunning
+		info.PendingAt = nil
+		if resumeApprovalID != "" || resumeCheckpoint {
+			info.ResumedAt = &started
+		} else if info.StartedAt == nil {
+			info.StartedAt = &started
+		}
+	})
+
+	var (
+		final  *agent.Final
+		runCtx *agent.Context
+		runErr error
+	)
+
+	extra := append(w.progressOptions(id),
+		agent.WithIntentModel(w.models.Override(llmutil.PurposeIntent)),
+		agent.WithCompactionModel(w.models.Override(llmutil.PurposeCompaction)),
+	)
+	if qt.streamOutput {
+		extra = append(extra, w.streamOption(id))
+	}
+	skillsClient, _ := w.models.For(llmutil.PurposeSkills)
+	switch {
+	case resumeApprovalID != "":
+		final, runCtx, runErr = resumeOneTask(qt.ctx, logger, w.logOpts, w.client, w.registry, w.baseCfg, w.guard, w.checkpoints, resumeApprovalID, extra...)
+	case resumeCheckpoint:
+		final, runCtx, runErr = resumeCheckpointTask(qt.ctx, logger, w.logOpts, w.client, w.registry, w.baseCfg, w.guard, w.checkpoints, id, extra...)
+		if runCtx != nil {
+			store.Update(id, func(info *TaskInfo) {
+				if info.Task == "" {
+					info.Task = runCtx.Task
+				}
+			})
+		}
+	default:
+		final, runCtx, runErr = runOneTask(qt.ctx, logger, w.logOpts, w.client, skillsClient, w.registry, w.baseCfg, w.guard, w.checkpoints, id, qt.info.Task, qt.history, qt.info.Model, qt.meta, extra...)
+	}
+
+	if pendingID, ok := pendingApprovalID(final); ok && runErr == nil {
+		if qt.isHeartbeat && qt.heartbeatState != nil {
+			alert, msg := qt.heartbeatState.EndFailure(fmt.Errorf("heartbeat pending approval"))
+			if alert {
+				logger.Warn("heartbeat_alert", "message", msg)
+			}
+		}
+		pendingAt := time.Now()
+		store.Update(id, func(info *TaskInfo) {
+			if info.Status == TaskCanceled {
+				return
+			}
+			// Published under the store lock so a concurrent cancel cannot
+			// interleave; canceled tasks only get their terminal status event.
+			store.Publish(id, EventApproval, map[string]any{"approval_request_id": pendingID, "final": final})
+			info.Status = TaskPending
+			info.PendingAt = &pendingAt
+			setTaskUsage(info, runCtx)
+			info.ApprovalRequestID = pendingID
+			info.Result = map[string]any{
+				"final":   final,
+				"metrics": runCtx.Metrics,
+				"steps":   summarizeSteps(runCtx),
+			}
+		})
+		// Don't cancel: task remains resumable until approval timeout or task timeout.
+		return
+	}
+
+	finished := time.Now()
+	store.Update(id, func(info *TaskInfo) {
+		if info.Status == TaskCanceled {
+			// Canceled via DELETE /tasks/{id}; keep that outcome.
+			return
+		}
+		info.FinishedAt = &finished
+		setTaskUsage(info, runCtx)
+		if runErr != nil {
+			if errorsIsContextDeadline(qt.ctx, runErr) {
+				info.Status = TaskCanceled
+			} else {
+				info.Status = TaskFailed
+			}
+			info.Error = runErr.Error()
+			return
+		}
+		store.Publish(id, EventFinal, map[string]any{"final": final})
+		info.Status = TaskDone
+		info.Result = map[string]any{
+			"final":   final,
+			"metrics": runCtx.Metrics,
+			"steps":   summarizeSteps(runCtx),
+		}
+	})
+	if qt.isHeartbeat && qt.heartbeatState != nil {
+		if runErr != nil {
+			alert, msg := qt.heartbeatState.EndFailure(runErr)
+			if alert {
+				logger.Warn("heartbeat_alert", "message", msg)
+			} else {
+				logger.Warn("heartbeat_error", "error", runErr.Error())
+			}
+		} else {
+			qt.heartbeatState.EndSuccess(finished)
+			out := heartbeatutil.FormatFinalOutput(final)
+			if strings.TrimSpace(out) != "" {
+				logger.Info("heartbeat_summary", "message", out)
+			} else {
+				logger.Info("heartbeat_summary", "message", "empty")
+			}
+		}
+	}
+	qt.cancel()
+}
+
+// progressOptions forwards plan and tool progress to the task's event stream.
+func (w *taskWorker) progressOptions(id string) []agent.Option {
+	store := w.store
+	return []agent.Option{
+		agent.WithPlanCreated(func(_ *agent.Context, plan *agent.Plan) {
+			store.Publish(id, EventPlan, plan)
+		}),
+		agent.WithPlanStepUpdate(func(runCtx *agent.Context, update agent.PlanStepUpdate) {
+			data := map[string]any{
+				"completed_index": update.CompletedIndex,
+				"completed_step":  update.CompletedStep,
+				"started_index":   update.StartedIndex,
+				"started_step":    update.StartedStep,
+				"reason":          update.Reason,
+			}
+			if runCtx != nil && runCtx.Plan != nil {
+				data["total_steps"] = len(runCtx.Plan.Steps)
+			}
+			store.Publish(id, EventPlanStep, data)
+		}),
+		agent.WithToolEvent(func(_ *agent.Context, ev agent.ToolEvent) {
+			data := map[string]any{
+				"step": ev.Step,
+				"tool": ev.Tool,
+			}
+			if len(ev.Args) > 0 {
+				data["args"] = ev.Args
+			}
+			if ev.Count > 1 {
+				data["tool_index"] = ev.Index
+				data["tool_count"] = ev.Count
+			}
+			typ := EventToolCall
+			if ev.Phase == "done" {
+				typ = EventToolDone
+				data["duration_ms"] = ev.Duration.Milliseconds()
+				data["observation_len"] = ev.ObservationLen
+				if ev.Error != "" {
+					data["error"] = ev.Error
+				}
+			}
+			store.Publish(id, typ, data)
+		}),
+	}
+}
+
+// streamOption publishes the final output as EventDelta events while the
+// model generates it.
+func (w *taskWorker) streamOption(id string) agent.Option {
+	store := w.store
+	stream := &agent.FinalOutputStream{}
+	return agent.WithStreamDelta(func(_ *agent.Context, delta agent.StreamDelta) {
+		if text := stream.Push(delta); text != "" {
+			store.Publish(id, EventDelta, map[string]any{"text": text, "step": delta.Step})
+		}
+	})
+}
+
+func setTaskUsage(info *TaskInfo, runCtx *agent.Context) {
+	if runCtx == nil || runCtx.Metrics == nil {
+		return
+	}
+	info.TotalTokens = runCtx.Metrics.TotalTokens
+	info.CostUSD = runCtx.Metrics.TotalCost
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -55,7 +56,6 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 			}
 
 			maxQueue := configutil.FlagOrViperInt(cmd, "server-max-queue", "server.max_queue")
-			store := NewTaskStore(maxQueue)
 
 			logger, err := logutil.LoggerFromViper()
 			if err != nil {
@@ -90,6 +90,10 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			models, err := llmutil.ModelsFromViper(client, llmutil.ModelFromViper())
+			if err != nil {
+				return err
+			}
 			var reg *tools.Registry
 			if deps.RegistryFromViper != nil {
 				reg = deps.RegistryFromViper()
@@ -97,7 +101,8 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 			if reg == nil {
 				reg = tools.NewRegistry()
 			}
-			toolsutil.RegisterPlanTool(reg, client, llmutil.ModelFromViper())
+			planClient, planModel := models.For(llmutil.PurposePlan)
+			toolsutil.RegisterPlanTool(reg, planClient, planModel)
 
 			logOpts := logutil.LogOptionsFromViper()
 
@@ -105,112 +110,71 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 				MaxSteps:         viper.GetInt("max_steps"),
 				ParseRetries:     viper.GetInt("parse_retries"),
 				MaxTokenBudget:   viper.GetInt("max_token_budget"),
+				MaxCostUSD:       viper.GetFloat64("max_cost_usd"),
+				StrictBudget:     viper.GetBool("max_token_budget_strict"),
 				IntentEnabled:    viper.GetBool("intent.enabled"),
 				IntentTimeout:    requestTimeout,
 				IntentMaxHistory: viper.GetInt("intent.max_history"),
+				ToolConcurrency:  viper.GetInt("tool_concurrency"),
+
+				CompactionMaxTokens:  viper.GetInt("compaction.max_tokens"),
+				CompactionKeepRecent: viper.GetInt("compaction.keep_recent"),
+				CompactionMaxChars:   viper.GetInt("compaction.max_chars"),
+				CompactionStrategy:   viper.GetString("compaction.strategy"),
 			}
 
 			var sharedGuard *guard.Guard
 			if deps.GuardFromViper != nil {
 				sharedGuard = deps.GuardFromViper(logger)
 			}
-			hbState := &heartbeatutil.State{}
-
-			// Worker: process tasks sequentially.
-			go func() {
-				for {
-					qt := store.Next()
-					if qt == nil || qt.info == nil {
-						continue
-					}
-					id := qt.info.ID
-					resumeApprovalID := strings.TrimSpace(qt.resumeApprovalID)
-					started := time.Now()
-					store.Update(id, func(info *TaskInfo) {
-						info.Status = TaskRunning
-						info.PendingAt = nil
-						if resumeApprovalID != "" {
-							info.ResumedAt = &started
-						} else if info.StartedAt == nil {
-							info.StartedAt = &started
-						}
-					})
-
-					var (
-						final  *agent.Final
-						runCtx *agent.Context
-						runErr error
-					)
-
-					if resumeApprovalID != "" {
-						qt.resumeApprovalID = ""
-						final, runCtx, runErr = resumeOneTask(qt.ctx, logger, logOpts, client, reg, baseCfg, sharedGuard, resumeApprovalID)
-					} else {
-						final, runCtx, runErr = runOneTask(qt.ctx, logger, logOpts, client, reg, baseCfg, sharedGuard, qt.info.Task, qt.info.Model, qt.meta)
-					}
+			var checkpoints agent.CheckpointStore
+			if viper.GetBool("checkpoint.enabled") {
+				fileCheckpoints, err := agent.NewFileCheckpointStore(statepaths.CheckpointsDir())
+				if err != nil {
+					return err
+				}
+				checkpoints = fileCheckpoints
+			}
 
-					if pendingID, ok := pendingApprovalID(final); ok && runErr == nil {
-						if qt.isHeartbeat && qt.heartbeatState != nil {
-							alert, msg := qt.heartbeatState.EndFailure(fmt.Errorf("heartbeat pending approval"))
-							if alert {
-								logger.Warn("heartbeat_alert", "message", msg)
-							}
-						}
-						pendingAt := time.Now()
-						store.Update(id, func(info *TaskInfo) {
-							info.Status = TaskPending
-							info.PendingAt = &pendingAt
-							info.ApprovalRequestID = pendingID
-							info.Result = map[string]any{
-								"final":   final,
-								"metrics": runCtx.Metrics,
-								"steps":   summarizeSteps(runCtx),
-							}
-						})
-						// Don't cancel: task remains resumable until approval timeout or task timeout.
-						continue
+			store := NewTaskStore(maxQueue)
+			if viper.GetBool("server.persist_tasks") {
+				store, err = OpenTaskStore(maxQueue, statepaths.DaemonDir(), func(id string) bool {
+					if checkpoints == nil {
+						return false
 					}
+					_, ok, err := checkpoints.Load(context.Background(), id)
+					return err == nil && ok
+				})
+				if err != nil {
+					return fmt.Errorf("open task store: %w", err)
+				}
+				logger.Info("daemon_task_store_ready", "dir", statepaths.DaemonDir(), "queued", store.QueueLen())
+			}
+			store.SetRetention(viper.GetDuration("server.task_retention"), viper.GetInt("server.max_finished_tasks"))
+			hbState := &heartbeatutil.State{}
 
-					finished := time.Now()
-					store.Update(id, func(info *TaskInfo) {
-						info.FinishedAt = &finished
-						if runErr != nil {
-							if errorsIsContextDeadline(qt.ctx, runErr) {
-								info.Status = TaskCanceled
-							} else {
-								info.Status = TaskFailed
-							}
-							info.Error = runErr.Error()
-							return
-						}
-						info.Status = TaskDone
-						info.Result = map[string]any{
-							"final":   final,
-							"metrics": runCtx.Metrics,
-							"steps":   summarizeSteps(runCtx),
-						}
-					})
-					if qt.isHeartbeat && qt.heartbeatState != nil {
-						if runErr != nil {
-							alert, msg := qt.heartbeatState.EndFailure(runErr)
-							if alert {
-								logger.Warn("heartbeat_alert", "message", msg)
-							} else {
-								logger.Warn("heartbeat_error", "error", runErr.Error())
-							}
-						} else {
-							qt.heartbeatState.EndSuccess(finished)
-							out := heartbeatutil.FormatFinalOutput(final)
-							if strings.TrimSpace(out) != "" {
-								logger.Info("heartbeat_summary", "message", out)
-							} else {
-								logger.Info("heartbeat_summary", "message", "empty")
-							}
-						}
+			workers := configutil.FlagOrViperInt(cmd, "server-workers", "server.workers")
+			if workers <= 0 {
+				workers = 1
+			}
+			w := &taskWorker{
+				store:       store,
+				logger:      logger,
+				logOpts:     logOpts,
+				client:      client,
+				models:      models,
+				registry:    reg,
+				baseCfg:     baseCfg,
+				guard:       sharedGuard,
+				checkpoints: checkpoints,
+			}
+			for i := 0; i < workers; i++ {
+				go func() {
+					for {
+						w.process(store.Next())
 					}
-					qt.cancel()
-				}
-			}()
+				}()
+			}
 
 			hbEnabled := viper.GetBool("heartbeat.enabled")
 			hbInterval := viper.GetDuration("heartbeat.interval")
@@ -300,8 +264,13 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 				if model == "" {
 					model = llmutil.ModelFromViper()
 				}
+				priority, err := ParseTaskPriority(string(req.Priority))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
 
-				info, err := store.Enqueue(context.Background(), req.Task, model, timeout)
+				info, err := store.Enqueue(context.Background(), req.Task, model, timeout, priority)
 				if err != nil {
 					http.Error(w, err.Error(), http.StatusServiceUnavailable)
 					return
@@ -310,20 +279,72 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 				_ = json.NewEncoder(w).Encode(SubmitTaskResponse{ID: info.ID, Status: info.Status})
 			})
 			mux.HandleFunc("/tasks/", func(w http.ResponseWriter, r *http.Request) {
-				if r.Method != http.MethodGet {
-					http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-					return
-				}
 				if !checkAuth(r, auth) {
 					http.Error(w, "unauthorized", http.StatusUnauthorized)
 					return
 				}
-				id := strings.TrimPrefix(r.URL.Path, "/tasks/")
-				id = strings.TrimSpace(id)
+				path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tasks/"), "/")
+				parts := strings.Split(path, "/")
+				id := strings.TrimSpace(parts[0])
 				if id == "" {
 					http.Error(w, "missing id", http.StatusBadRequest)
 					return
 				}
+				if len(parts) == 2 && parts[1] == "resume" {
+					if r.Method != http.MethodPost {
+						http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+						return
+					}
+					if checkpoints == nil {
+						http.Error(w, "checkpoints are not enabled", http.StatusBadRequest)
+						return
+					}
+					if _, ok, err := checkpoints.Load(r.Context(), id); err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					} else if !ok {
+						http.NotFound(w, r)
+						return
+					}
+					info, err := store.EnqueueResumeCheckpoint(context.Background(), id, viper.GetDuration("timeout"))
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusConflict)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(SubmitTaskResponse{ID: info.ID, Status: info.Status})
+					return
+				}
+				if len(parts) == 2 && parts[1] == "events" {
+					if r.Method != http.MethodGet {
+						http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+						return
+					}
+					serveTaskEvents(w, r, store, id)
+					return
+				}
+				if len(parts) != 1 {
+					http.Error(w, "not found", http.StatusNotFound)
+					return
+				}
+				if r.Method == http.MethodDelete {
+					info, err := store.Cancel(id)
+					if errors.Is(err, errTaskNotFound) {
+						http.NotFound(w, r)
+						return
+					}
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusConflict)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					_ = json.NewEncoder(w).Encode(info)
+					return
+				}
+				if r.Method != http.MethodGet {
+					http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+					return
+				}
 				info, ok := store.Get(id)
 				if !ok {
 					http.NotFound(w, r)
@@ -444,13 +465,32 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 				}
 			})
 
+			openAICompat := configutil.FlagOrViperBool(cmd, "server-openai-compat", "server.openai_compat")
+			if openAICompat {
+				chat := &chatCompletionsHandler{store: store, model: llmutil.ModelFromViper(), timeout: viper.GetDuration("timeout")}
+				mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+					if !checkAuth(r, auth) {
+						writeOpenAIError(w, http.StatusUnauthorized, "unauthorized", "invalid_request_error")
+						return
+					}
+					chat.ServeHTTP(w, r)
+				})
+				mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+					if !checkAuth(r, auth) {
+						writeOpenAIError(w, http.StatusUnauthorized, "unauthorized", "invalid_request_error")
+						return
+					}
+					serveOpenAIModels(w, r)
+				})
+			}
+
 			addr := bind + ":" + strconv.Itoa(port)
 			srv := &http.Server{
 				Addr:              addr,
 				Handler:           mux,
 				ReadHeaderTimeout: 5 * time.Second,
 			}
-			logger.Info("server_start", "addr", addr, "max_queue", maxQueue)
+			logger.Info("server_start", "addr", addr, "max_queue", maxQueue, "workers", workers, "openai_compat", openAICompat)
 			return srv.ListenAndServe()
 		},
 	}
@@ -458,7 +498,9 @@ func NewServeCmd(deps ServeDependencies) *cobra.Command {
 	cmd.Flags().String("server-bind", "127.0.0.1", "Bind address (default: 127.0.0.1).")
 	cmd.Flags().Int("server-port", 8787, "HTTP port to listen on.")
 	cmd.Flags().String("server-auth-token", "", "Bearer token required for all non-/health endpoints.")
-	cmd.Flags().Int("server-max-queue", 100, "Max queued tasks in memory.")
+	cmd.Flags().Int("server-max-queue", 100, "Max queued tasks per priority class.")
+	cmd.Flags().Int("server-workers", 1, "Number of tasks processed concurrently.")
+	cmd.Flags().Bool("server-openai-compat", false, "Expose an OpenAI-compatible /v1/chat/completions endpoint.")
 	cmd.Flags().Bool("with-maep", false, "Start MAEP listener together with daemon serve.")
 	cmd.Flags().StringArray("maep-listen", nil, "MAEP listen multiaddr for --with-maep (repeatable). Defaults to maep.listen_addrs or MAEP defaults.")
 
@@ -481,40 +523,45 @@ func errorsIsContextDeadline(ctx context.Context, err error) bool {
 	return strings.Contains(strings.ToLower(err.Error()), "context deadline exceeded")
 }
 
-func runOneTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, registry *tools.Registry, baseCfg agent.Config, sharedGuard *guard.Guard, task string, model string, meta map[string]any) (*agent.Final, *agent.Context, error) {
-	promptSpec, _, skillAuthProfiles, err := skillsutil.PromptSpecWithSkills(ctx, logger, logOpts, task, client, model, skillsutil.SkillsConfigFromViper(model))
+func runOneTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, skillsClient llm.Client, registry *tools.Registry, baseCfg agent.Config, sharedGuard *guard.Guard, checkpoints agent.CheckpointStore, runID string, task string, history []llm.Message, model string, meta map[string]any, extra ...agent.Option) (*agent.Final, *agent.Context, error) {
+	promptSpec, _, skillAuthProfiles, err := skillsutil.PromptSpecWithSkills(ctx, logger, logOpts, task, skillsClient, model, skillsutil.SkillsConfigFromViper(model))
 	if err != nil {
 		return nil, nil, err
 	}
 	promptprofile.ApplyPersonaIdentity(&promptSpec, logger)
 	promptprofile.AppendLocalToolNotesBlock(&promptSpec, logger)
-	engine := agent.New(
-		client,
-		registry,
-		baseCfg,
-		promptSpec,
+	opts := []agent.Option{
 		agent.WithLogger(logger),
 		agent.WithLogOptions(logOpts),
 		agent.WithSkillAuthProfiles(skillAuthProfiles, viper.GetBool("secrets.require_skill_profiles")),
 		agent.WithGuard(sharedGuard),
-	)
-	return engine.Run(ctx, task, agent.RunOptions{Model: model, Meta: meta})
+		agent.WithCheckpointStore(checkpoints),
+	}
+	engine := agent.New(client, registry, baseCfg, promptSpec, append(opts, extra...)...)
+	return engine.Run(ctx, task, agent.RunOptions{Model: model, History: history, Meta: meta, RunID: runID})
 }
 
-func resumeOneTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, registry *tools.Registry, baseCfg agent.Config, sharedGuard *guard.Guard, approvalRequestID string) (*agent.Final, *agent.Context, error) {
+func resumeOneTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, registry *tools.Registry, baseCfg agent.Config, sharedGuard *guard.Guard, checkpoints agent.CheckpointStore, approvalRequestID string, extra ...agent.Option) (*agent.Final, *agent.Context, error) {
+	engine := newResumeEngine(logger, logOpts, client, registry, baseCfg, sharedGuard, checkpoints, extra...)
+	return engine.Resume(ctx, approvalRequestID)
+}
+
+func resumeCheckpointTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, registry *tools.Registry, baseCfg agent.Config, sharedGuard *guard.Guard, checkpoints agent.CheckpointStore, runID string, extra ...agent.Option) (*agent.Final, *agent.Context, error) {
+	engine := newResumeEngine(logger, logOpts, client, registry, baseCfg, sharedGuard, checkpoints, extra...)
+	return engine.ResumeCheckpoint(ctx, runID)
+}
+
+func newResumeEngine(logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, registry *tools.Registry, baseCfg agent.Config, sharedGuard *guard.Guard, checkpoints agent.CheckpointStore, extra ...agent.Option) *agent.Engine {
 	promptSpec := agent.DefaultPromptSpec()
 	promptprofile.ApplyPersonaIdentity(&promptSpec, logger)
 	promptprofile.AppendLocalToolNotesBlock(&promptSpec, logger)
-	engine := agent.New(
-		client,
-		registry,
-		baseCfg,
-		promptSpec,
+	opts := []agent.Option{
 		agent.WithLogger(logger),
 		agent.WithLogOptions(logOpts),
 		agent.WithGuard(sharedGuard),
-	)
-	return engine.Resume(ctx, approvalRequestID)
+		agent.WithCheckpointStore(checkpoints),
+	}
+	return agent.New(client, registry, baseCfg, promptSpec, append(opts, extra...)...)
 }
 
 func pendingApprovalID(final *agent.Final) (string, bool) {
@@ -1,6 +1,7 @@
 package daemoncmd
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -54,10 +55,15 @@ func NewSubmitCmd() *cobra.Command {
 			if model == "" {
 				model = llmutil.ModelFromViper()
 			}
+			priority, err := ParseTaskPriority(configutil.FlagOrViperString(cmd, "priority", ""))
+			if err != nil {
+				return err
+			}
 			reqBody := SubmitTaskRequest{
-				Task:    task,
-				Model:   model,
-				Timeout: strings.TrimSpace(configutil.FlagOrViperString(cmd, "submit-timeout", "submit.timeout")),
+				Task:     task,
+				Model:    model,
+				Timeout:  strings.TrimSpace(configutil.FlagOrViperString(cmd, "submit-timeout", "submit.timeout")),
+				Priority: priority,
 			}
 			b, _ := json.Marshal(reqBody)
 
@@ -96,14 +102,27 @@ func NewSubmitCmd() *cobra.Command {
 				interval = 1 * time.Second
 			}
 
-			for {
+			// Show live progress on stderr; fall back to polling if the daemon
+			// has no event stream or the connection drops.
+			streamed := false
+			if err := streamTaskEvents(serverURL, auth, submitResp.ID, func(ev TaskEvent) bool {
+				streamed = true
+				if line := formatTaskEvent(ev); line != "" {
+					_, _ = fmt.Fprintln(os.Stderr, line)
+				}
+				return ev.Type != EventApproval
+			}); err != nil && !streamed {
 				time.Sleep(interval)
+			}
+
+			for {
 				info, err := fetchTaskInfo(client, serverURL, auth, submitResp.ID)
 				if err != nil {
 					return err
 				}
 				switch info.Status {
 				case TaskQueued, TaskRunning:
+					time.Sleep(interval)
 					continue
 				case TaskPending:
 					// Print the final object if present (it should include status=pending + approval_request_id).
@@ -143,6 +162,7 @@ func NewSubmitCmd() *cobra.Command {
 	cmd.Flags().String("auth-token", "", "Bearer token for daemon auth.")
 	cmd.Flags().String("model", "", "Model name override (optional).")
 	cmd.Flags().String("submit-timeout", "", "Per-task timeout override (e.g. 2m, 30s).")
+	cmd.Flags().String("priority", "normal", "Task priority: high|normal|low.")
 	cmd.Flags().Bool("wait", false, "Wait for completion and print the final JSON.")
 	cmd.Flags().Duration("poll-interval", 1*time.Second, "Polling interval when --wait is set.")
 
@@ -171,3 +191,87 @@ func fetchTaskInfo(client *http.Client, serverURL, auth, id string) (*TaskInfo,
 	}
 	return &info, nil
 }
+
+// streamTaskEvents reads GET /tasks/{id}/events until the stream ends or
+// onEvent returns false.
+func streamTaskEvents(serverURL, auth, id string, onEvent func(TaskEvent) bool) error {
+	httpReq, err := http.NewRequest(http.MethodGet, strings.TrimRight(serverURL, "/")+"/tasks/"+id+"/events", nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+auth)
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := (&http.Client{}).Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var data strings.Builder
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var ev TaskEvent
+			err := json.Unmarshal([]byte(data.String()), &ev)
+			data.Reset()
+			if err != nil {
+				continue
+			}
+			if !onEvent(ev) {
+				return nil
+			}
+		}
+	}
+	return sc.Err()
+}
+
+func formatTaskEvent(ev TaskEvent) string {
+	m, _ := ev.Data.(map[string]any)
+	str := func(k string) string {
+		v, _ := m[k].(string)
+		return strings.TrimSpace(v)
+	}
+	num := func(k string) int {
+		v, _ := m[k].(float64)
+		return int(v)
+	}
+	switch ev.Type {
+	case EventStatus:
+		if e := str("error"); e != "" {
+			return fmt.Sprintf("[status] %s: %s", str("status"), e)
+		}
+		return "[status] " + str("status")
+	case EventPlan:
+		steps, _ := m["steps"].([]any)
+		return fmt.Sprintf("[plan] %s (%d steps)", str("summary"), len(steps))
+	case EventPlanStep:
+		if _, ok := m["total_steps"]; ok {
+			return fmt.Sprintf("[plan] step %d/%d done: %s", num("completed_index")+1, num("total_steps"), str("completed_step"))
+		}
+		return "[plan] step done: " + str("completed_step")
+	case EventToolCall:
+		return "[tool] " + str("tool")
+	case EventToolDone:
+		if e := str("error"); e != "" {
+			return fmt.Sprintf("[tool] %s failed (%dms): %s", str("tool"), num("duration_ms"), e)
+		}
+		return fmt.Sprintf("[tool] %s done (%dms)", str("tool"), num("duration_ms"))
+	case EventApproval:
+		return "[approval] required: " + str("approval_request_id")
+	default:
+		return ""
+	}
+}
@@ -14,10 +14,29 @@ func initViperDefaults() {
 	viper.SetDefault("llm.api_key", "")
 	viper.SetDefault("llm.request_timeout", 90*time.Second)
 	viper.SetDefault("llm.tools_emulation_mode", "off")
+	viper.SetDefault("llm.vision", true)
+	viper.SetDefault("llm.structured_output", true)
+	viper.SetDefault("llm.prompt_cache", true)
+	viper.SetDefault("llm.anthropic.cache_control", false)
+	viper.SetDefault("llm.retry.max_attempts", 1)
+	viper.SetDefault("llm.retry.base_delay", time.Second)
+	viper.SetDefault("llm.retry.max_delay", 20*time.Second)
+	viper.SetDefault("llm.router.policy", "failover")
+	viper.SetDefault("llm.router.cooldown", time.Minute)
 
 	viper.SetDefault("max_steps", 15)
 	viper.SetDefault("parse_retries", 2)
 	viper.SetDefault("max_token_budget", 0)
+	viper.SetDefault("max_token_budget_strict", false)
+	viper.SetDefault("max_cost_usd", 0.0)
+	viper.SetDefault("tool_concurrency", 1)
+	viper.SetDefault("compaction.max_tokens", 0)
+	viper.SetDefault("compaction.keep_recent", 4)
+	viper.SetDefault("compaction.max_chars", 800)
+	viper.SetDefault("compaction.strategy", "truncate")
+	viper.SetDefault("checkpoint.enabled", false)
+	viper.SetDefault("checkpoint.dir_name", "checkpoints")
+	viper.SetDefault("tokenizers.dir_name", "tokenizers")
 	viper.SetDefault("timeout", 10*time.Minute)
 	viper.SetDefault("plan.max_steps", 6)
 
@@ -52,9 +71,19 @@ func initViperDefaults() {
 	viper.SetDefault("server.bind", "127.0.0.1")
 	viper.SetDefault("server.port", 8787)
 	viper.SetDefault("server.max_queue", 100)
+	viper.SetDefault("server.workers", 1)
+	viper.SetDefault("server.openai_compat", false)
+	viper.SetDefault("server.persist_tasks", true)
+	viper.SetDefault("server.task_retention", 7*24*time.Hour)
+	viper.SetDefault("server.max_finished_tasks", 1000)
+	viper.SetDefault("server.dir_name", "daemon")
 	viper.SetDefault("server.url", "http://127.0.0.1:8787")
 	viper.SetDefault("server.with_maep", false)
 
+	// MCP server (`mistermorph mcp serve`)
+	viper.SetDefault("mcp.serve.tools", []string{})
+	viper.SetDefault("mcp.serve.agent_run", true)
+
 	// Submit client
 	viper.SetDefault("submit.wait", false)
 	viper.SetDefault("submit.poll_interval", 1*time.Second)
@@ -68,6 +97,7 @@ func initViperDefaults() {
 	viper.SetDefault("telegram.smart_addressing_confidence", 0.55)
 	viper.SetDefault("telegram.max_concurrency", 3)
 	viper.SetDefault("telegram.reactions.enabled", true)
+	viper.SetDefault("telegram.stream_replies", false)
 	viper.SetDefault("telegram.with_maep", false)
 
 	// Heartbeat
@@ -85,6 +115,7 @@ func initViperDefaults() {
 	viper.SetDefault("memory.short_term_days", 7)
 	viper.SetDefault("memory.injection.enabled", true)
 	viper.SetDefault("memory.injection.max_items", 50)
+	viper.SetDefault("memory.injection.max_tokens", 2000)
 
 	// Secrets / auth profiles (Phase 0: disabled by default, fail-closed).
 	viper.SetDefault("secrets.enabled", false)
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/quailyquaily/mistermorph/tools"
+	"github.com/quailyquaily/mistermorph/tools/mcp"
+	"github.com/spf13/viper"
+)
+
+// mcpConns holds the connections to mcp.servers. They are opened by the
+// first registryFromViper call and shared by every registry built after it
+// (the telegram and daemon commands build one per task).
+var mcpConns struct {
+	once    sync.Once
+	mu      sync.Mutex
+	clients []*mcp.Client
+	tools   []tools.Tool
+}
+
+// mcpServerTools connects to the configured MCP servers once per process
+// and returns their tools.
+func mcpServerTools() []tools.Tool {
+	mcpConns.once.Do(func() {
+		var servers []mcp.ServerConfig
+		if err := viper.UnmarshalKey("mcp.servers", &servers); err != nil {
+			slog.Default().Warn("mcp_servers_invalid", "err", err)
+			return
+		}
+		if len(servers) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("mcp.connect_timeout"))
+		defer cancel()
+		reg := tools.NewRegistry()
+		clients := mcp.RegisterServers(ctx, reg, servers, slog.Default())
+
+		mcpConns.mu.Lock()
+		defer mcpConns.mu.Unlock()
+		mcpConns.clients = clients
+		mcpConns.tools = reg.All()
+	})
+	mcpConns.mu.Lock()
+	defer mcpConns.mu.Unlock()
+	return mcpConns.tools
+}
+
+// closeMCPClients closes the MCP server connections (stopping stdio servers
+// and ending HTTP sessions). Registries built earlier keep tools that fail
+// from then on.
+func closeMCPClients() {
+	mcpConns.mu.Lock()
+	clients := mcpConns.clients
+	mcpConns.clients = nil
+	mcpConns.mu.Unlock()
+	for _, c := range clients {
+		if err := c.Close(); err != nil {
+			slog.Default().Warn("mcp_server_close_error", "server", c.Name(), "error", err.Error())
+		}
+	}
+}
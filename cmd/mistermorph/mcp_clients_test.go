@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestRegistryFromViperSharesMCPConnections(t *testing.T) {
+	var inits, deletes atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deletes.Add(1)
+			return
+		}
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		var result string
+		switch msg.Method {
+		case "initialize":
+			inits.Add(1)
+			result = `{"protocolVersion":"2025-03-26","capabilities":{"tools":{}},"serverInfo":{"name":"fake","version":"0.1"}}`
+		case "tools/list":
+			result = `{"tools":[{"name":"lookup","inputSchema":{"type":"object"}}]}`
+		default:
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", "s1")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(msg.ID) + `,"result":` + result + `}`))
+	}))
+	defer srv.Close()
+
+	viper.Set("mcp.servers", []map[string]any{{"name": "fake", "url": srv.URL}})
+	viper.Set("mcp.connect_timeout", 5*time.Second)
+	t.Cleanup(func() {
+		viper.Set("mcp.servers", nil)
+		closeMCPClients()
+		mcpConns.once = sync.Once{}
+		mcpConns.tools = nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, ok := registryFromViper().Get("fake__lookup"); !ok {
+			t.Fatalf("registry %d is missing the MCP tool", i)
+		}
+	}
+	if n := inits.Load(); n != 1 {
+		t.Fatalf("initialize calls = %d, want 1", n)
+	}
+	closeMCPClients()
+	if n := deletes.Load(); n != 1 {
+		t.Fatalf("session deletes = %d, want 1", n)
+	}
+}
@@ -0,0 +1,98 @@
+package mcpcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/agent"
+	"github.com/quailyquaily/mistermorph/guard"
+	"github.com/quailyquaily/mistermorph/internal/heartbeatutil"
+	"github.com/quailyquaily/mistermorph/internal/llmutil"
+	"github.com/quailyquaily/mistermorph/internal/promptprofile"
+	"github.com/quailyquaily/mistermorph/internal/skillsutil"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/tools"
+	"github.com/spf13/viper"
+)
+
+// agentRunTool runs a full agent task (skills, tools, guard) for an MCP
+// client and returns the final output.
+type agentRunTool struct {
+	client   llm.Client
+	models   *llmutil.Models
+	registry *tools.Registry
+	cfg      agent.Config
+	guard    *guard.Guard
+	logger   *slog.Logger
+	logOpts  agent.LogOptions
+	model    string
+	timeout  time.Duration
+}
+
+func (t *agentRunTool) Name() string { return "agent_run" }
+
+func (t *agentRunTool) Description() string {
+	return "Runs a complete Mister Morph agent task (with its own tools, skills and guard policies) and returns the final answer."
+}
+
+func (t *agentRunTool) ParameterSchema() string {
+	s := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"task": map[string]any{
+				"type":        "string",
+				"description": "Task for the agent, in natural language.",
+			},
+			"model": map[string]any{
+				"type":        "string",
+				"description": "Optional model override.",
+			},
+		},
+		"required": []string{"task"},
+	}
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+func (t *agentRunTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	task, _ := params["task"].(string)
+	task = strings.TrimSpace(task)
+	if task == "" {
+		return "", fmt.Errorf("missing required param: task")
+	}
+	model := t.model
+	if m, ok := params["model"].(string); ok && strings.TrimSpace(m) != "" {
+		model = strings.TrimSpace(m)
+	}
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	skillsClient, _ := t.models.For(llmutil.PurposeSkills)
+	promptSpec, _, skillAuthProfiles, err := skillsutil.PromptSpecWithSkills(ctx, t.logger, t.logOpts, task, skillsClient, model, skillsutil.SkillsConfigFromViper(model))
+	if err != nil {
+		return "", err
+	}
+	promptprofile.ApplyPersonaIdentity(&promptSpec, t.logger)
+	promptprofile.AppendLocalToolNotesBlock(&promptSpec, t.logger)
+
+	engine := agent.New(t.client, t.registry, t.cfg, promptSpec,
+		agent.WithLogger(t.logger),
+		agent.WithLogOptions(t.logOpts),
+		agent.WithSkillAuthProfiles(skillAuthProfiles, viper.GetBool("secrets.require_skill_profiles")),
+		agent.WithGuard(t.guard),
+		agent.WithIntentModel(t.models.Override(llmutil.PurposeIntent)),
+		agent.WithCompactionModel(t.models.Override(llmutil.PurposeCompaction)),
+	)
+	final, _, err := engine.Run(ctx, task, agent.RunOptions{Model: model, Meta: map[string]any{"trigger": "mcp"}})
+	if err != nil {
+		return "", err
+	}
+	return heartbeatutil.FormatFinalOutput(final), nil
+}
@@ -0,0 +1,159 @@
+package mcpcmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/quailyquaily/mistermorph/agent"
+	"github.com/quailyquaily/mistermorph/guard"
+	"github.com/quailyquaily/mistermorph/internal/configutil"
+	"github.com/quailyquaily/mistermorph/internal/llmconfig"
+	"github.com/quailyquaily/mistermorph/internal/llmutil"
+	"github.com/quailyquaily/mistermorph/internal/logutil"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/tools"
+	"github.com/quailyquaily/mistermorph/tools/mcp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type Dependencies struct {
+	RegistryFromViper func() *tools.Registry
+	GuardFromViper    func(*slog.Logger) *guard.Guard
+	RegisterPlanTool  func(*tools.Registry, llm.Client, string)
+}
+
+func New(deps Dependencies) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Model Context Protocol (MCP) integration",
+	}
+	cmd.AddCommand(newServeCmd(deps))
+	return cmd
+}
+
+func newServeCmd(deps Dependencies) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the tool registry and an agent_run tool over stdio MCP",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// stdout carries the protocol; logs go to stderr.
+			logger, err := logutil.LoggerFromViper()
+			if err != nil {
+				return err
+			}
+			slog.SetDefault(logger)
+			logOpts := logutil.LogOptionsFromViper()
+
+			requestTimeout := viper.GetDuration("llm.request_timeout")
+			model := llmutil.ModelFromViper()
+			client, err := llmutil.ClientFromConfig(llmconfig.ClientConfig{
+				Provider:       llmutil.ProviderFromViper(),
+				Endpoint:       llmutil.EndpointFromViper(),
+				APIKey:         llmutil.APIKeyFromViper(),
+				Model:          model,
+				RequestTimeout: requestTimeout,
+			})
+			if err != nil {
+				return err
+			}
+			models, err := llmutil.ModelsFromViper(client, model)
+			if err != nil {
+				return err
+			}
+
+			var reg *tools.Registry
+			if deps.RegistryFromViper != nil {
+				reg = deps.RegistryFromViper()
+			}
+			if reg == nil {
+				reg = tools.NewRegistry()
+			}
+			if deps.RegisterPlanTool != nil {
+				planClient, planModel := models.For(llmutil.PurposePlan)
+				deps.RegisterPlanTool(reg, planClient, planModel)
+			}
+			var sharedGuard *guard.Guard
+			if deps.GuardFromViper != nil {
+				sharedGuard = deps.GuardFromViper(logger)
+			}
+
+			cfg := agent.Config{
+				MaxSteps:         viper.GetInt("max_steps"),
+				ParseRetries:     viper.GetInt("parse_retries"),
+				MaxTokenBudget:   viper.GetInt("max_token_budget"),
+				MaxCostUSD:       viper.GetFloat64("max_cost_usd"),
+				StrictBudget:     viper.GetBool("max_token_budget_strict"),
+				IntentEnabled:    viper.GetBool("intent.enabled"),
+				IntentTimeout:    requestTimeout,
+				IntentMaxHistory: viper.GetInt("intent.max_history"),
+				ToolConcurrency:  viper.GetInt("tool_concurrency"),
+
+				CompactionMaxTokens:  viper.GetInt("compaction.max_tokens"),
+				CompactionKeepRecent: viper.GetInt("compaction.keep_recent"),
+				CompactionMaxChars:   viper.GetInt("compaction.max_chars"),
+				CompactionStrategy:   viper.GetString("compaction.strategy"),
+			}
+
+			exposed := tools.NewRegistry()
+			allow := make(map[string]bool)
+			for _, name := range configutil.FlagOrViperStringArray(cmd, "tool", "mcp.serve.tools") {
+				if name = strings.TrimSpace(name); name != "" {
+					allow[name] = true
+				}
+			}
+			for _, t := range reg.All() {
+				if len(allow) == 0 || allow[t.Name()] {
+					exposed.Register(t)
+				}
+			}
+			if configutil.FlagOrViperBool(cmd, "agent-run", "mcp.serve.agent_run") {
+				exposed.Register(&agentRunTool{
+					client:   client,
+					models:   models,
+					registry: reg,
+					cfg:      cfg,
+					guard:    sharedGuard,
+					logger:   logger,
+					logOpts:  logOpts,
+					model:    model,
+					timeout:  viper.GetDuration("timeout"),
+				})
+			}
+			if len(exposed.All()) == 0 {
+				return fmt.Errorf("no tools to serve (check --tool / mcp.serve.tools)")
+			}
+
+			// Direct tool calls go through the same guard checks as calls in a
+			// run. No skill is loaded for them, so with
+			// secrets.require_skill_profiles no auth_profile may be used.
+			direct := agent.New(client, exposed, cfg, agent.DefaultPromptSpec(),
+				agent.WithLogger(logger),
+				agent.WithLogOptions(logOpts),
+				agent.WithGuard(sharedGuard),
+				agent.WithSkillAuthProfiles(nil, viper.GetBool("secrets.require_skill_profiles")),
+			)
+			srv := &mcp.Server{
+				Name:     "mistermorph",
+				Version:  "dev",
+				Registry: exposed,
+				Call:     direct.CallTool,
+				Logger:   logger,
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			logger.Info("mcp_serve_start", "transport", mcp.TransportStdio, "tools", exposed.ToolNames())
+			return srv.Serve(ctx, os.Stdin, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringArray("tool", nil, "Only expose these tools (repeatable). Defaults to all registered tools.")
+	cmd.Flags().Bool("agent-run", true, "Expose the agent_run tool that runs a full agent task.")
+
+	return cmd
+}
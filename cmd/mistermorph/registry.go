@@ -21,14 +21,38 @@ func registryFromViper() *tools.Registry {
 	viper.SetDefault("tools.read_file.max_bytes", 256*1024)
 	viper.SetDefault("tools.read_file.deny_paths", []string{"config.yaml"})
 
+	viper.SetDefault("tools.list_dir.enabled", true)
+	viper.SetDefault("tools.list_dir.max_entries", 1000)
+
+	viper.SetDefault("tools.search_files.enabled", true)
+	viper.SetDefault("tools.search_files.max_matches", 200)
+	viper.SetDefault("tools.search_files.max_file_bytes", 4*1024*1024)
+
+	viper.SetDefault("tools.read_image.enabled", true)
+	viper.SetDefault("tools.read_image.max_bytes", 20*1024*1024)
+
 	viper.SetDefault("tools.write_file.enabled", true)
 	viper.SetDefault("tools.write_file.max_bytes", 512*1024)
 
+	viper.SetDefault("tools.edit_file.enabled", true)
+	viper.SetDefault("tools.edit_file.max_bytes", 1024*1024)
+
 	viper.SetDefault("tools.bash.enabled", true)
 	viper.SetDefault("tools.bash.confirm", false)
 	viper.SetDefault("tools.bash.timeout", 30*time.Second)
 	viper.SetDefault("tools.bash.max_output_bytes", 256*1024)
 	viper.SetDefault("tools.bash.deny_paths", []string{"config.yaml"})
+	viper.SetDefault("tools.bash.sandbox.enabled", false)
+	viper.SetDefault("tools.bash.sandbox.bwrap", "bwrap")
+	viper.SetDefault("tools.bash.sandbox.network", false)
+	viper.SetDefault("tools.bash.sandbox.read_only_dirs", builtin.DefaultSandboxReadOnlyDirs)
+	viper.SetDefault("tools.bash.sandbox.writable_dirs", []string{})
+	viper.SetDefault("tools.bash.sandbox.cpu_seconds", 60)
+	viper.SetDefault("tools.bash.sandbox.memory_mb", 1024)
+	viper.SetDefault("tools.bash.sandbox.max_processes", 256)
+	viper.SetDefault("tools.process.enabled", true)
+	viper.SetDefault("tools.process.max_jobs", 4)
+	viper.SetDefault("tools.process.buffer_bytes", 64*1024)
 
 	viper.SetDefault("tools.url_fetch.enabled", true)
 	viper.SetDefault("tools.url_fetch.timeout", 30*time.Second)
@@ -41,6 +65,7 @@ func registryFromViper() *tools.Registry {
 	viper.SetDefault("tools.contacts.enabled", true)
 	viper.SetDefault("tools.memory.enabled", true)
 	viper.SetDefault("tools.memory.recently.max_items", 50)
+	viper.SetDefault("mcp.connect_timeout", 30*time.Second)
 
 	userAgent := strings.TrimSpace(viper.GetString("user_agent"))
 
@@ -97,6 +122,34 @@ func registryFromViper() *tools.Registry {
 		strings.TrimSpace(viper.GetString("file_state_dir")),
 	))
 
+	if viper.GetBool("tools.list_dir.enabled") {
+		r.Register(builtin.NewListDirTool(
+			viper.GetInt("tools.list_dir.max_entries"),
+			viper.GetStringSlice("tools.read_file.deny_paths"),
+			strings.TrimSpace(viper.GetString("file_cache_dir")),
+			strings.TrimSpace(viper.GetString("file_state_dir")),
+		))
+	}
+
+	if viper.GetBool("tools.search_files.enabled") {
+		r.Register(builtin.NewSearchFilesTool(
+			viper.GetInt("tools.search_files.max_matches"),
+			int64(viper.GetInt("tools.search_files.max_file_bytes")),
+			viper.GetStringSlice("tools.read_file.deny_paths"),
+			strings.TrimSpace(viper.GetString("file_cache_dir")),
+			strings.TrimSpace(viper.GetString("file_state_dir")),
+		))
+	}
+
+	if viper.GetBool("tools.read_image.enabled") {
+		r.Register(builtin.NewReadImageTool(
+			int64(viper.GetInt("tools.read_image.max_bytes")),
+			viper.GetStringSlice("tools.read_file.deny_paths"),
+			strings.TrimSpace(viper.GetString("file_cache_dir")),
+			strings.TrimSpace(viper.GetString("file_state_dir")),
+		))
+	}
+
 	r.Register(builtin.NewWriteFileTool(
 		viper.GetBool("tools.write_file.enabled"),
 		viper.GetInt("tools.write_file.max_bytes"),
@@ -104,6 +157,13 @@ func registryFromViper() *tools.Registry {
 		strings.TrimSpace(viper.GetString("file_state_dir")),
 	))
 
+	r.Register(builtin.NewEditFileTool(
+		viper.GetBool("tools.edit_file.enabled"),
+		viper.GetInt("tools.edit_file.max_bytes"),
+		strings.TrimSpace(viper.GetString("file_cache_dir")),
+		strings.TrimSpace(viper.GetString("file_state_dir")),
+	))
+
 	if viper.GetBool("tools.bash.enabled") {
 		bt := builtin.NewBashTool(
 			true,
@@ -116,7 +176,40 @@ func registryFromViper() *tools.Registry {
 			// Safety default: allow bash for local automation, but deny curl to avoid "bash + curl" carrying auth.
 			bt.DenyTokens = append(bt.DenyTokens, "curl")
 		}
+		if viper.GetBool("tools.bash.sandbox.enabled") {
+			// file_cache_dir is always writable; nothing else is unless listed.
+			writable := append([]string{strings.TrimSpace(viper.GetString("file_cache_dir"))}, viper.GetStringSlice("tools.bash.sandbox.writable_dirs")...)
+			bt.Sandbox = &builtin.BashSandbox{
+				Bwrap:        viper.GetString("tools.bash.sandbox.bwrap"),
+				ReadOnlyDirs: viper.GetStringSlice("tools.bash.sandbox.read_only_dirs"),
+				WritableDirs: writable,
+				Network:      viper.GetBool("tools.bash.sandbox.network"),
+				CPUSeconds:   viper.GetInt("tools.bash.sandbox.cpu_seconds"),
+				MemoryMB:     viper.GetInt("tools.bash.sandbox.memory_mb"),
+				MaxProcesses: viper.GetInt("tools.bash.sandbox.max_processes"),
+			}
+		}
 		r.Register(bt)
+
+		if viper.GetBool("tools.process.enabled") {
+			pm := builtin.NewProcessManager(
+				viper.GetInt("tools.process.max_jobs"),
+				viper.GetInt("tools.process.buffer_bytes"),
+			)
+			pm.DenyPaths = bt.DenyPaths
+			pm.DenyTokens = bt.DenyTokens
+			pm.Sandbox = bt.Sandbox
+			for _, t := range pm.Tools() {
+				r.Register(t)
+			}
+		}
+	}
+
+	httpAuth := &builtin.URLFetchAuth{
+		Enabled:       secretsEnabled,
+		AllowProfiles: allowProfiles,
+		Profiles:      profileStore,
+		Resolver:      resolver,
 	}
 
 	if viper.GetBool("tools.url_fetch.enabled") {
@@ -127,22 +220,19 @@ func registryFromViper() *tools.Registry {
 			viper.GetInt64("tools.url_fetch.max_bytes_download"),
 			userAgent,
 			strings.TrimSpace(viper.GetString("file_cache_dir")),
-			&builtin.URLFetchAuth{
-				Enabled:       secretsEnabled,
-				AllowProfiles: allowProfiles,
-				Profiles:      profileStore,
-				Resolver:      resolver,
-			},
+			httpAuth,
 		))
 	}
 
 	if viper.GetBool("tools.web_search.enabled") {
-		r.Register(builtin.NewWebSearchTool(
+		r.Register(builtin.NewWebSearchToolWithBackends(
 			true,
 			viper.GetString("tools.web_search.base_url"),
 			viper.GetDuration("tools.web_search.timeout"),
 			viper.GetInt("tools.web_search.max_results"),
 			userAgent,
+			webSearchBackends(),
+			httpAuth,
 		))
 	}
 
@@ -183,9 +273,37 @@ func registryFromViper() *tools.Registry {
 		}))
 	}
 
+	// MCP tools are registered last so they never shadow built-ins.
+	for _, t := range mcpServerTools() {
+		if _, exists := r.Get(t.Name()); exists {
+			slog.Default().Warn("mcp_tool_name_conflict", "name", t.Name())
+			continue
+		}
+		r.Register(t)
+	}
+
 	return r
 }
 
+// webSearchBackends loads tools.web_search.backends, dropping invalid
+// entries. An empty result keeps the DuckDuckGo default.
+func webSearchBackends() []builtin.WebSearchBackendConfig {
+	var cfgs []builtin.WebSearchBackendConfig
+	if err := viper.UnmarshalKey("tools.web_search.backends", &cfgs); err != nil {
+		slog.Default().Warn("web_search_backends_invalid", "err", err)
+		return nil
+	}
+	out := cfgs[:0]
+	for i, c := range cfgs {
+		if err := c.Validate(); err != nil {
+			slog.Default().Warn("web_search_backend_invalid", "index", i, "type", c.Type, "err", err)
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
 func contactsDefaultFreshnessWindow() time.Duration {
 	if viper.IsSet("contacts.proactive.freshness_window") {
 		return viper.GetDuration("contacts.proactive.freshness_window")
@@ -12,6 +12,7 @@ import (
 	"github.com/quailyquaily/mistermorph/cmd/mistermorph/contactscmd"
 	"github.com/quailyquaily/mistermorph/cmd/mistermorph/daemoncmd"
 	"github.com/quailyquaily/mistermorph/cmd/mistermorph/maepcmd"
+	"github.com/quailyquaily/mistermorph/cmd/mistermorph/mcpcmd"
 	"github.com/quailyquaily/mistermorph/cmd/mistermorph/runcmd"
 	"github.com/quailyquaily/mistermorph/cmd/mistermorph/skillscmd"
 	"github.com/quailyquaily/mistermorph/cmd/mistermorph/telegramcmd"
@@ -20,8 +21,10 @@ import (
 	"github.com/quailyquaily/mistermorph/internal/llmutil"
 	"github.com/quailyquaily/mistermorph/internal/logutil"
 	"github.com/quailyquaily/mistermorph/internal/skillsutil"
+	"github.com/quailyquaily/mistermorph/internal/statepaths"
 	"github.com/quailyquaily/mistermorph/internal/toolsutil"
 	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/llm/tokens"
 	"github.com/quailyquaily/mistermorph/memory"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -33,7 +36,11 @@ const (
 
 func Execute() {
 	root := newRootCmd()
-	if err := root.Execute(); err != nil {
+	err := root.Execute()
+	// Commands return after their own graceful shutdown, so no task is
+	// still using the MCP connections.
+	closeMCPClients()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -44,7 +51,7 @@ func newRootCmd() *cobra.Command {
 		Short: "Unified Agent CLI",
 	}
 
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initTokenizers)
 
 	cmd.PersistentFlags().String("config", "", "Config file path (optional).")
 	_ = viper.BindPFlag("config", cmd.PersistentFlags().Lookup("config"))
@@ -94,6 +101,11 @@ func newRootCmd() *cobra.Command {
 		GuardFromViper:    guardFromViper,
 	}))
 	cmd.AddCommand(daemoncmd.NewSubmitCmd())
+	cmd.AddCommand(mcpcmd.New(mcpcmd.Dependencies{
+		RegistryFromViper: registryFromViper,
+		GuardFromViper:    guardFromViper,
+		RegisterPlanTool:  toolsutil.RegisterPlanTool,
+	}))
 	cmd.AddCommand(telegramcmd.NewCommand(telegramcmd.Dependencies{
 		LoggerFromViper:     logutil.LoggerFromViper,
 		LogOptionsFromViper: logutil.LogOptionsFromViper,
@@ -110,6 +122,7 @@ func newRootCmd() *cobra.Command {
 		LLMEndpointForProvider: llmutil.EndpointForProvider,
 		LLMAPIKeyForProvider:   llmutil.APIKeyForProvider,
 		LLMModelForProvider:    llmutil.ModelForProvider,
+		LLMModelsFromViper:     llmutil.ModelsFromViper,
 		RegistryFromViper:      registryFromViper,
 		RegisterPlanTool:       toolsutil.RegisterPlanTool,
 		GuardFromViper:         guardFromViper,
@@ -156,3 +169,9 @@ func initConfig() {
 		_, _ = fmt.Fprintf(os.Stderr, "Failed to read config: %v\n", err)
 	}
 }
+
+// initTokenizers points llm/tokens at the BPE vocabularies under
+// file_state_dir; it runs after the config file is read.
+func initTokenizers() {
+	tokens.SetVocabularyDir(statepaths.TokenizersDir())
+}
@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/quailyquaily/mistermorph/guard"
 	"github.com/quailyquaily/mistermorph/internal/configutil"
 	"github.com/quailyquaily/mistermorph/internal/heartbeatutil"
+	"github.com/quailyquaily/mistermorph/internal/llmcassette"
 	"github.com/quailyquaily/mistermorph/internal/llmconfig"
 	"github.com/quailyquaily/mistermorph/internal/llminspect"
 	"github.com/quailyquaily/mistermorph/internal/llmutil"
@@ -44,9 +46,13 @@ func New(deps Dependencies) *cobra.Command {
 		Short: "Run an agent task",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			isHeartbeat := configutil.FlagOrViperBool(cmd, "heartbeat", "")
+			resumeRunID := strings.TrimSpace(configutil.FlagOrViperString(cmd, "resume", ""))
 			task := ""
 			var runMeta map[string]any
-			if isHeartbeat {
+			switch {
+			case resumeRunID != "":
+				// The task and system prompt are restored from the checkpoint.
+			case isHeartbeat:
 				hbChecklist := statepaths.HeartbeatChecklistPath()
 				var hbSnapshot string
 				if viper.GetBool("memory.enabled") {
@@ -73,7 +79,7 @@ func New(deps Dependencies) *cobra.Command {
 						nil,
 					),
 				}
-			} else {
+			default:
 				task = strings.TrimSpace(configutil.FlagOrViperString(cmd, "task", "task"))
 				if task == "" {
 					data, err := os.ReadFile("/dev/stdin")
@@ -114,6 +120,10 @@ func New(deps Dependencies) *cobra.Command {
 			if err != nil {
 				return err
 			}
+			models, err := llmutil.ModelsFromViper(client, model)
+			if err != nil {
+				return err
+			}
 
 			timeout := configutil.FlagOrViperDuration(cmd, "timeout", "timeout")
 			ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -127,6 +137,22 @@ func New(deps Dependencies) *cobra.Command {
 
 			logOpts := logutil.LogOptionsFromViper()
 
+			if path := strings.TrimSpace(configutil.FlagOrViperString(cmd, "llm-cassette", "")); path != "" {
+				cassette, err := llmcassette.New(client, path, configutil.FlagOrViperString(cmd, "llm-cassette-mode", ""))
+				if err != nil {
+					return err
+				}
+				logger.Info("llm_cassette", "path", path, "mode", cassette.Mode())
+				client = cassette
+				// Purposes with their own client record to a cassette next
+				// to the main one.
+				if err := models.Wrap(func(purpose string, c llm.Client) (llm.Client, error) {
+					return llmcassette.New(c, purposeCassettePath(path, purpose), cassette.Mode())
+				}); err != nil {
+					return err
+				}
+			}
+
 			if configutil.FlagOrViperBool(cmd, "inspect-request", "") {
 				inspector, err := llminspect.NewRequestInspector(llminspect.Options{
 					Task: task,
@@ -138,6 +164,10 @@ func New(deps Dependencies) *cobra.Command {
 				if err := llminspect.SetDebugHook(client, inspector.Dump); err != nil {
 					return fmt.Errorf("inspect-request requires uniai provider client")
 				}
+				_ = models.Wrap(func(_ string, c llm.Client) (llm.Client, error) {
+					_ = llminspect.SetDebugHook(c, inspector.Dump)
+					return c, nil
+				})
 			}
 
 			if configutil.FlagOrViperBool(cmd, "inspect-prompt", "") {
@@ -149,11 +179,21 @@ func New(deps Dependencies) *cobra.Command {
 				}
 				defer func() { _ = inspector.Close() }()
 				client = &llminspect.PromptClient{Base: client, Inspector: inspector}
+				_ = models.Wrap(func(_ string, c llm.Client) (llm.Client, error) {
+					return &llminspect.PromptClient{Base: c, Inspector: inspector}, nil
+				})
 			}
-
-			promptSpec, _, skillAuthProfiles, err := skillsutil.PromptSpecWithSkills(ctx, logger, logOpts, task, client, model, skillsutil.SkillsConfigFromRunCmd(cmd, model))
-			if err != nil {
-				return err
+			models.SetMain(client)
+
+			promptSpec := agent.DefaultPromptSpec()
+			var skillAuthProfiles []string
+			if resumeRunID == "" {
+				skillsCfg := skillsutil.SkillsConfigFromRunCmd(cmd, model)
+				skillsClient, _ := models.For(llmutil.PurposeSkills)
+				promptSpec, _, skillAuthProfiles, err = skillsutil.PromptSpecWithSkills(ctx, logger, logOpts, task, skillsClient, model, skillsCfg)
+				if err != nil {
+					return err
+				}
 			}
 			promptprofile.ApplyPersonaIdentity(&promptSpec, logger)
 			promptprofile.AppendLocalToolNotesBlock(&promptSpec, logger)
@@ -167,7 +207,7 @@ func New(deps Dependencies) *cobra.Command {
 					memManager = memory.NewManager(statepaths.MemoryDir(), viper.GetInt("memory.short_term_days"))
 					if viper.GetBool("memory.injection.enabled") {
 						maxItems := viper.GetInt("memory.injection.max_items")
-						snap, err := memManager.BuildInjection(id.SubjectID, memory.ContextPrivate, maxItems)
+						snap, err := memManager.BuildInjection(id.SubjectID, memory.ContextPrivate, maxItems, viper.GetInt("memory.injection.max_tokens"))
 						if err != nil {
 							return fmt.Errorf("memory injection: %w", err)
 						}
@@ -196,6 +236,8 @@ func New(deps Dependencies) *cobra.Command {
 			opts = append(opts, agent.WithLogger(logger))
 			opts = append(opts, agent.WithLogOptions(logOpts))
 			opts = append(opts, agent.WithSkillAuthProfiles(skillAuthProfiles, viper.GetBool("secrets.require_skill_profiles")))
+			opts = append(opts, agent.WithIntentModel(models.Override(llmutil.PurposeIntent)))
+			opts = append(opts, agent.WithCompactionModel(models.Override(llmutil.PurposeCompaction)))
 			if !isHeartbeat {
 				opts = append(opts, agent.WithPlanStepUpdate(func(runCtx *agent.Context, update agent.PlanStepUpdate) {
 					if payload := formatPlanProgressUpdate(runCtx, update); payload != "" {
@@ -203,11 +245,27 @@ func New(deps Dependencies) *cobra.Command {
 					}
 				}))
 			}
+			var stream *agent.FinalOutputStream
+			if configutil.FlagOrViperBool(cmd, "stream", "") {
+				stream = &agent.FinalOutputStream{}
+				opts = append(opts, agent.WithStreamDelta(func(_ *agent.Context, delta agent.StreamDelta) {
+					if text := stream.Push(delta); text != "" {
+						_, _ = fmt.Fprint(os.Stderr, text)
+					}
+				}))
+			}
 			if deps.GuardFromViper != nil {
 				if g := deps.GuardFromViper(logger); g != nil {
 					opts = append(opts, agent.WithGuard(g))
 				}
 			}
+			if resumeRunID != "" || configutil.FlagOrViperBool(cmd, "checkpoint", "checkpoint.enabled") {
+				checkpoints, err := agent.NewFileCheckpointStore(statepaths.CheckpointsDir())
+				if err != nil {
+					return err
+				}
+				opts = append(opts, agent.WithCheckpointStore(checkpoints))
+			}
 			reg := (*tools.Registry)(nil)
 			if deps.RegistryFromViper != nil {
 				reg = deps.RegistryFromViper()
@@ -216,7 +274,8 @@ func New(deps Dependencies) *cobra.Command {
 				reg = tools.NewRegistry()
 			}
 			if deps.RegisterPlanTool != nil {
-				deps.RegisterPlanTool(reg, client, model)
+				planClient, planModel := models.For(llmutil.PurposePlan)
+				deps.RegisterPlanTool(reg, planClient, planModel)
 			}
 
 			engine := agent.New(
@@ -226,15 +285,34 @@ func New(deps Dependencies) *cobra.Command {
 					MaxSteps:         configutil.FlagOrViperInt(cmd, "max-steps", "max_steps"),
 					ParseRetries:     configutil.FlagOrViperInt(cmd, "parse-retries", "parse_retries"),
 					MaxTokenBudget:   configutil.FlagOrViperInt(cmd, "max-token-budget", "max_token_budget"),
+					MaxCostUSD:       configutil.FlagOrViperFloat64(cmd, "max-cost-usd", "max_cost_usd"),
+					StrictBudget:     viper.GetBool("max_token_budget_strict"),
 					IntentEnabled:    viper.GetBool("intent.enabled"),
 					IntentTimeout:    requestTimeout,
 					IntentMaxHistory: viper.GetInt("intent.max_history"),
+					ToolConcurrency:  configutil.FlagOrViperInt(cmd, "tool-concurrency", "tool_concurrency"),
+
+					CompactionMaxTokens:  viper.GetInt("compaction.max_tokens"),
+					CompactionKeepRecent: viper.GetInt("compaction.keep_recent"),
+					CompactionMaxChars:   viper.GetInt("compaction.max_chars"),
+					CompactionStrategy:   viper.GetString("compaction.strategy"),
 				},
 				promptSpec,
 				opts...,
 			)
 
-			final, runCtx, err := engine.Run(ctx, task, agent.RunOptions{Model: model, Meta: runMeta})
+			var (
+				final  *agent.Final
+				runCtx *agent.Context
+			)
+			if resumeRunID != "" {
+				final, runCtx, err = engine.ResumeCheckpoint(ctx, resumeRunID)
+			} else {
+				final, runCtx, err = engine.Run(ctx, task, agent.RunOptions{Model: model, Meta: runMeta})
+			}
+			if stream != nil && stream.Output() != "" {
+				_, _ = fmt.Fprintln(os.Stderr)
+			}
 			if err != nil {
 				if errors.Is(err, errAbortedByUser) {
 					return nil
@@ -242,11 +320,16 @@ func New(deps Dependencies) *cobra.Command {
 				return err
 			}
 
+			if resumeRunID != "" {
+				task = runCtx.Task
+			}
+
 			if !isHeartbeat && memManager != nil && memIdentity.Enabled && strings.TrimSpace(memIdentity.SubjectID) != "" {
-				if err := updateRunMemory(ctx, logger, client, model, memManager, memIdentity, task, final, requestTimeout); err != nil {
+				memClient, memModel := models.For(llmutil.PurposeMemory)
+				if err := updateRunMemory(ctx, logger, memClient, memModel, memManager, memIdentity, task, final, requestTimeout); err != nil {
 					if errors.Is(err, context.DeadlineExceeded) {
 						retryutil.AsyncRetry(logger, "memory_update", 2*time.Second, requestTimeout, func(retryCtx context.Context) error {
-							return updateRunMemory(retryCtx, logger, client, model, memManager, memIdentity, task, final, requestTimeout)
+							return updateRunMemory(retryCtx, logger, memClient, memModel, memManager, memIdentity, task, final, requestTimeout)
 						})
 					}
 					logger.Warn("memory_update_error", "error", err.Error())
@@ -257,6 +340,7 @@ func New(deps Dependencies) *cobra.Command {
 				"steps", len(runCtx.Steps),
 				"llm_rounds", runCtx.Metrics.LLMRounds,
 				"total_tokens", runCtx.Metrics.TotalTokens,
+				"total_cost_usd", runCtx.Metrics.TotalCost,
 				"parse_retries", runCtx.Metrics.ParseRetries,
 			)
 
@@ -268,14 +352,19 @@ func New(deps Dependencies) *cobra.Command {
 
 	cmd.Flags().String("task", "", "Task to run (if empty, reads from stdin).")
 	cmd.Flags().Bool("heartbeat", false, "Run a single heartbeat check (ignores --task and stdin).")
-	cmd.Flags().String("provider", "openai", "Provider: openai|openai_custom|deepseek|xai|gemini|azure|anthropic|bedrock|susanoo.")
+	cmd.Flags().String("resume", "", "Continue the run with this run_id from its last checkpoint (ignores --task and stdin).")
+	cmd.Flags().Bool("checkpoint", false, "Checkpoint the run state before every step so it can be continued with --resume.")
+	cmd.Flags().String("provider", "openai", "Provider: openai|openai_custom|deepseek|xai|gemini|azure|anthropic|bedrock|susanoo|ollama|llamacpp|vllm|fake.")
 	cmd.Flags().String("endpoint", "https://api.openai.com", "Base URL for provider.")
 	cmd.Flags().String("model", "gpt-5.2", "Model name.")
 	cmd.Flags().String("api-key", "", "API key.")
 	cmd.Flags().Duration("llm-request-timeout", 90*time.Second, "Per-LLM HTTP request timeout (0 uses provider default).")
 	cmd.Flags().Bool("interactive", false, "Ctrl-C pauses and lets you inject extra context, then continues.")
+	cmd.Flags().Bool("stream", false, "Stream the final answer to stderr as it is generated.")
 	cmd.Flags().Bool("inspect-prompt", false, "Dump prompts (messages) to ./dump/prompt_YYYYMMDD_HHmm.md.")
 	cmd.Flags().Bool("inspect-request", false, "Dump LLM request/response payloads to ./dump/request_YYYYMMDD_HHmm.md.")
+	cmd.Flags().String("llm-cassette", "", "Record LLM requests/results to this cassette file, or replay them from it.")
+	cmd.Flags().String("llm-cassette-mode", llmcassette.ModeAuto, "Cassette mode: record|replay|auto (auto replays when the file exists).")
 	cmd.Flags().StringArray("skills-dir", nil, "Skills root directory (repeatable). Defaults: ~/.codex/skills, ~/.claude/skills")
 	cmd.Flags().StringArray("skill", nil, "Skill(s) to load by name or id (repeatable).")
 	cmd.Flags().Bool("skills-auto", true, "Auto-load skills referenced in task via $SkillName.")
@@ -288,6 +377,8 @@ func New(deps Dependencies) *cobra.Command {
 	cmd.Flags().Int("max-steps", 15, "Max tool-call steps.")
 	cmd.Flags().Int("parse-retries", 2, "Max JSON parse retries.")
 	cmd.Flags().Int("max-token-budget", 0, "Max cumulative token budget (0 disables).")
+	cmd.Flags().Float64("max-cost-usd", 0, "Max cumulative LLM cost in USD, priced with llm.pricing (0 disables).")
+	cmd.Flags().Int("tool-concurrency", 1, "Max read-only tool calls from one step to run concurrently (1 runs them sequentially).")
 
 	cmd.Flags().Duration("timeout", 10*time.Minute, "Overall timeout.")
 
@@ -476,3 +567,10 @@ func readMultiline(r *bufio.Reader) (string, error) {
 	}
 	return strings.Join(lines, "\n"), nil
 }
+
+// purposeCassettePath names the cassette for a purpose-specific client, e.g.
+// run.json -> run.intent.json.
+func purposeCassettePath(path, purpose string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + purpose + ext
+}
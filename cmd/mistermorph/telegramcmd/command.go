@@ -34,6 +34,7 @@ import (
 	"github.com/quailyquaily/mistermorph/internal/jsonutil"
 	"github.com/quailyquaily/mistermorph/internal/llmconfig"
 	"github.com/quailyquaily/mistermorph/internal/llminspect"
+	"github.com/quailyquaily/mistermorph/internal/llmutil"
 	"github.com/quailyquaily/mistermorph/internal/maepruntime"
 	"github.com/quailyquaily/mistermorph/internal/pathutil"
 	"github.com/quailyquaily/mistermorph/internal/promptprofile"
@@ -62,6 +63,8 @@ type telegramJob struct {
 	IsHeartbeat     bool
 	Meta            map[string]any
 	MentionUsers    []string
+	// Parts carries downloaded images so vision-capable models see them.
+	Parts []llm.ContentPart
 }
 
 type telegramChatWorker struct {
@@ -97,6 +100,8 @@ type maepFeedbackClassification struct {
 	Confidence     float64 `json:"confidence"`
 }
 
+const defaultTelegramBaseURL = "https://api.telegram.org"
+
 func newTelegramCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "telegram",
@@ -107,7 +112,11 @@ func newTelegramCmd() *cobra.Command {
 				return fmt.Errorf("missing telegram.bot_token (set via --telegram-bot-token or MISTER_MORPH_TELEGRAM_BOT_TOKEN)")
 			}
 
-			baseURL := "https://api.telegram.org"
+			baseURL, _ := cmd.Flags().GetString("telegram-base-url")
+			baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+			if baseURL == "" {
+				baseURL = defaultTelegramBaseURL
+			}
 
 			allowed := make(map[int64]bool)
 			for _, s := range configutil.FlagOrViperStringArray(cmd, "telegram-allowed-chat-id", "telegram.allowed_chat_ids") {
@@ -163,6 +172,11 @@ func newTelegramCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			model := llmModelFromViper()
+			models, err := llmModelsFromViper(client, model)
+			if err != nil {
+				return err
+			}
 			if configutil.FlagOrViperBool(cmd, "inspect-request", "") {
 				inspector, err := llminspect.NewRequestInspector(llminspect.Options{
 					Mode:            "telegram",
@@ -176,6 +190,10 @@ func newTelegramCmd() *cobra.Command {
 				if err := llminspect.SetDebugHook(client, inspector.Dump); err != nil {
 					return fmt.Errorf("inspect-request requires uniai provider client")
 				}
+				_ = models.Wrap(func(_ string, c llm.Client) (llm.Client, error) {
+					_ = llminspect.SetDebugHook(c, inspector.Dump)
+					return c, nil
+				})
 			}
 			if configutil.FlagOrViperBool(cmd, "inspect-prompt", "") {
 				inspector, err := llminspect.NewPromptInspector(llminspect.Options{
@@ -188,8 +206,11 @@ func newTelegramCmd() *cobra.Command {
 				}
 				defer func() { _ = inspector.Close() }()
 				client = &llminspect.PromptClient{Base: client, Inspector: inspector}
+				_ = models.Wrap(func(_ string, c llm.Client) (llm.Client, error) {
+					return &llminspect.PromptClient{Base: c, Inspector: inspector}, nil
+				})
 			}
-			model := llmModelFromViper()
+			models.SetMain(client)
 			reg := registryFromViper()
 			logOpts := logOptionsFromViper()
 
@@ -197,9 +218,17 @@ func newTelegramCmd() *cobra.Command {
 				MaxSteps:         viper.GetInt("max_steps"),
 				ParseRetries:     viper.GetInt("parse_retries"),
 				MaxTokenBudget:   viper.GetInt("max_token_budget"),
+				MaxCostUSD:       viper.GetFloat64("max_cost_usd"),
+				StrictBudget:     viper.GetBool("max_token_budget_strict"),
 				IntentEnabled:    viper.GetBool("intent.enabled"),
 				IntentTimeout:    requestTimeout,
 				IntentMaxHistory: viper.GetInt("intent.max_history"),
+				ToolConcurrency:  viper.GetInt("tool_concurrency"),
+
+				CompactionMaxTokens:  viper.GetInt("compaction.max_tokens"),
+				CompactionKeepRecent: viper.GetInt("compaction.keep_recent"),
+				CompactionMaxChars:   viper.GetInt("compaction.max_chars"),
+				CompactionStrategy:   viper.GetString("compaction.strategy"),
 			}
 			contactsSvc := contacts.NewService(contacts.NewFileStore(statepaths.ContactsDir()))
 			var maepMemMgr *memory.Manager
@@ -232,6 +261,7 @@ func newTelegramCmd() *cobra.Command {
 			maepSessionCooldown := configuredMAEPSessionCooldown()
 
 			reactionCfg := readTelegramReactionConfig()
+			streamReplies := viper.GetBool("telegram.stream_replies")
 
 			httpClient := &http.Client{Timeout: 60 * time.Second}
 			api := newTelegramAPI(httpClient, baseURL, token)
@@ -306,6 +336,7 @@ func newTelegramCmd() *cobra.Command {
 				heartbeatRunning   = make(map[int64]bool)
 				heartbeatFailures  = make(map[int64]int)
 				knownMentions      = make(map[int64]map[string]string)
+				chatStats          = make(map[int64]telegramChatStats)
 				offset             int64
 			)
 			initRequired := false
@@ -458,9 +489,17 @@ func newTelegramCmd() *cobra.Command {
 								defer typingStop()
 							}
 
+							var draft *telegramStreamReply
+							if streamReplies && !job.IsHeartbeat {
+								draft = newTelegramStreamReply(api, chatID, logger)
+							}
+
 							ctx, cancel := context.WithTimeout(context.Background(), taskTimeout)
-							final, _, loadedSkills, reaction, runErr := runTelegramTask(ctx, logger, logOpts, client, reg, api, filesEnabled, fileCacheDir, filesMaxBytes, sharedGuard, cfg, reactionCfg, allowed, job, model, h, sticky, requestTimeout)
+							final, runAgentCtx, loadedSkills, reaction, runErr := runTelegramTask(ctx, logger, logOpts, client, models, reg, api, filesEnabled, fileCacheDir, filesMaxBytes, sharedGuard, cfg, reactionCfg, allowed, job, model, h, sticky, requestTimeout, draft)
 							cancel()
+							mu.Lock()
+							chatStats[chatID] = chatStats[chatID].add(runAgentCtx)
+							mu.Unlock()
 
 							if runErr != nil {
 								if job.IsHeartbeat {
@@ -487,6 +526,10 @@ func newTelegramCmd() *cobra.Command {
 									}
 									return
 								}
+								if draft != nil {
+									_ = draft.Finish(context.Background(), "error: "+runErr.Error())
+									return
+								}
 								_ = api.sendMessageMarkdownV2(context.Background(), chatID, "error: "+runErr.Error(), true)
 								return
 							}
@@ -503,12 +546,21 @@ func newTelegramCmd() *cobra.Command {
 									if err := api.sendMessageChunked(context.Background(), chatID, outText); err != nil {
 										logger.Warn("telegram_send_error", "error", err.Error())
 									}
+								} else if draft != nil {
+									if err := draft.Finish(context.Background(), outText); err != nil {
+										logger.Warn("telegram_send_error", "error", err.Error())
+									}
 								} else {
 									if err := api.sendMessageChunked(context.Background(), chatID, outText); err != nil {
 										logger.Warn("telegram_send_error", "error", err.Error())
 									}
 								}
 							} else {
+								if draft != nil {
+									if err := draft.Discard(context.Background()); err != nil {
+										logger.Warn("telegram_delete_error", "error", err.Error())
+									}
+								}
 								if job.IsHeartbeat {
 									mu.Lock()
 									heartbeatRunning[chatID] = false
@@ -712,8 +764,9 @@ func newTelegramCmd() *cobra.Command {
 								NextAction: "continue",
 								Confidence: 1,
 							}
+							feedbackClient, feedbackModel := purposeLLM(models, llmutil.PurposeMAEPFeedback, client, model)
 							feedbackCtx, feedbackCancel := context.WithTimeout(context.Background(), maepFeedbackTimeout(requestTimeout))
-							classified, classifyErr := classifyMAEPFeedback(feedbackCtx, client, model, historySnapshot, task)
+							classified, classifyErr := classifyMAEPFeedback(feedbackCtx, feedbackClient, feedbackModel, historySnapshot, task)
 							feedbackCancel()
 							if classifyErr != nil {
 								logger.Warn("telegram_maep_feedback_classify_error", "from_peer_id", peerID, "topic", event.Topic, "error", classifyErr.Error())
@@ -747,8 +800,9 @@ func newTelegramCmd() *cobra.Command {
 								maepMu.Unlock()
 								preferenceChanged := false
 								if shouldRefreshPreferences {
+									contactsClient, contactsModel := purposeLLM(models, llmutil.PurposeContacts, client, model)
 									prefCtx, prefCancel := context.WithTimeout(context.Background(), maepFeedbackTimeout(requestTimeout))
-									changed, prefErr := refreshMAEPPreferencesOnSessionEnd(prefCtx, contactsSvc, maepSvc, client, model, peerID, event.Topic, sessionID, task, historySnapshot, now, blockedReason)
+									changed, prefErr := refreshMAEPPreferencesOnSessionEnd(prefCtx, contactsSvc, maepSvc, contactsClient, contactsModel, peerID, event.Topic, sessionID, task, historySnapshot, now, blockedReason)
 									prefCancel()
 									if prefErr != nil {
 										logger.Warn("telegram_maep_preference_refresh_error", "from_peer_id", peerID, "topic", event.Topic, "session_key", sessionKey, "reason", blockedReason, "error", prefErr.Error())
@@ -778,7 +832,7 @@ func newTelegramCmd() *cobra.Command {
 
 							logger.Info("telegram_maep_task_enqueued", "from_peer_id", peerID, "topic", event.Topic, "task_len", len(task))
 							runCtx, cancel := context.WithTimeout(context.Background(), taskTimeout)
-							final, _, loadedSkills, runErr := runMAEPTask(runCtx, logger, logOpts, client, reg, sharedGuard, cfg, model, peerID, maepMemMgr, h, sticky, task)
+							final, _, loadedSkills, runErr := runMAEPTask(runCtx, logger, logOpts, client, models, reg, sharedGuard, cfg, model, peerID, maepMemMgr, h, sticky, task)
 							cancel()
 							if runErr != nil {
 								logger.Warn("telegram_maep_task_error", "from_peer_id", peerID, "topic", event.Topic, "error", runErr.Error())
@@ -800,7 +854,8 @@ func newTelegramCmd() *cobra.Command {
 										contactNickname = strings.TrimSpace(item.ContactNickname)
 									}
 								}
-								if memErr := updateMAEPMemory(context.Background(), logger, client, model, maepMemMgr, peerID, event.Topic, sessionID, task, output, h, contactID, contactNickname, requestTimeout); memErr != nil {
+								memClient, memModel := purposeLLM(models, llmutil.PurposeMemory, client, model)
+								if memErr := updateMAEPMemory(context.Background(), logger, memClient, memModel, maepMemMgr, peerID, event.Topic, sessionID, task, output, h, contactID, contactNickname, requestTimeout); memErr != nil {
 									logger.Warn("memory_update_error", "source", "maep", "peer_id", peerID, "error", memErr.Error())
 								} else {
 									logger.Info("memory_update_ok", "source", "maep", "peer_id", peerID, "topic", event.Topic)
@@ -1014,7 +1069,7 @@ func newTelegramCmd() *cobra.Command {
 					switch normalizedCmd {
 					case "/start", "/help":
 						help := "Send a message and I will run it as an agent task.\n" +
-							"Commands: /ask <task>, /mem, /reset, /id\n\n" +
+							"Commands: /ask <task>, /mem, /stats, /reset, /id\n\n" +
 							"Group chats: use /ask <task>, reply to me, or mention @" + botUser + ".\n" +
 							"You can also send a file (document/photo). It will be downloaded under file_cache_dir/telegram/ and the agent can process it.\n" +
 							"Note: if Bot Privacy Mode is enabled, I may not receive normal group messages (so aliases won't trigger unless I receive the message)."
@@ -1056,7 +1111,7 @@ func newTelegramCmd() *cobra.Command {
 
 						mgr := memory.NewManager(statepaths.MemoryDir(), viper.GetInt("memory.short_term_days"))
 						maxItems := viper.GetInt("memory.injection.max_items")
-						snap, err := mgr.BuildInjection(id.SubjectID, memory.ContextPrivate, maxItems)
+						snap, err := mgr.BuildInjection(id.SubjectID, memory.ContextPrivate, maxItems, viper.GetInt("memory.injection.max_tokens"))
 						if err != nil {
 							_ = api.sendMessageMarkdownV2(context.Background(), chatID, "memory load error: "+err.Error(), true)
 							continue
@@ -1069,6 +1124,17 @@ func newTelegramCmd() *cobra.Command {
 							logger.Warn("telegram_send_error", "error", err.Error())
 						}
 						continue
+					case "/stats":
+						if len(allowed) > 0 && !allowed[chatID] {
+							logger.Warn("telegram_unauthorized_chat", "chat_id", chatID)
+							_ = api.sendMessageMarkdownV2(context.Background(), chatID, "unauthorized", true)
+							continue
+						}
+						mu.Lock()
+						stats := chatStats[chatID]
+						mu.Unlock()
+						_ = api.sendMessageMarkdownV2(context.Background(), chatID, formatTelegramStats(stats), true)
+						continue
 					case "/reset":
 						if len(allowed) > 0 && !allowed[chatID] {
 							logger.Warn("telegram_unauthorized_chat", "chat_id", chatID)
@@ -1080,6 +1146,7 @@ func newTelegramCmd() *cobra.Command {
 						delete(stickySkillsByChat, chatID)
 						delete(knownMentions, chatID)
 						delete(initSessions, chatID)
+						delete(chatStats, chatID)
 						if w := getOrStartWorkerLocked(chatID); w != nil {
 							w.Version++
 						}
@@ -1114,7 +1181,8 @@ func newTelegramCmd() *cobra.Command {
 								if addressingLLMTimeout > 0 {
 									addrCtx, cancel = context.WithTimeout(context.Background(), addressingLLMTimeout)
 								}
-								llmDec, llmOK, llmErr := addressingDecisionViaLLM(addrCtx, client, model, botUser, aliases, rawText)
+								addrClient, addrModel := purposeLLM(models, llmutil.PurposeAddressing, client, model)
+								llmDec, llmOK, llmErr := addressingDecisionViaLLM(addrCtx, addrClient, addrModel, botUser, aliases, rawText)
 								cancel()
 								if llmErr != nil {
 									logger.Warn("telegram_addressing_llm_error",
@@ -1260,6 +1328,7 @@ func newTelegramCmd() *cobra.Command {
 						Text:            text,
 						Version:         v,
 						MentionUsers:    mentionUsers,
+						Parts:           telegramImageParts(downloaded),
 					}
 				}
 			}
@@ -1267,7 +1336,10 @@ func newTelegramCmd() *cobra.Command {
 	}
 
 	cmd.Flags().String("telegram-bot-token", "", "Telegram bot token.")
-	// Note: base_url is intentionally not configurable.
+	// Note: base_url is intentionally not part of the config; the hidden flag
+	// only exists to point end-to-end tests at a local Bot API stand-in.
+	cmd.Flags().String("telegram-base-url", defaultTelegramBaseURL, "Telegram Bot API base URL (testing only).")
+	_ = cmd.Flags().MarkHidden("telegram-base-url")
 	cmd.Flags().StringArray("telegram-allowed-chat-id", nil, "Allowed chat id(s). If empty, allows all.")
 	cmd.Flags().StringArray("telegram-alias", nil, "Bot alias keywords (group messages containing these may trigger a response).")
 	cmd.Flags().String("telegram-group-trigger-mode", "smart", "Group trigger mode: strict|smart.")
@@ -1286,7 +1358,7 @@ func newTelegramCmd() *cobra.Command {
 	return cmd
 }
 
-func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, baseReg *tools.Registry, api *telegramAPI, filesEnabled bool, fileCacheDir string, filesMaxBytes int64, sharedGuard *guard.Guard, cfg agent.Config, reactionCfg telegramReactionConfig, allowedIDs map[int64]bool, job telegramJob, model string, history []llm.Message, stickySkills []string, requestTimeout time.Duration) (*agent.Final, *agent.Context, []string, *telegramReaction, error) {
+func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, models *llmutil.Models, baseReg *tools.Registry, api *telegramAPI, filesEnabled bool, fileCacheDir string, filesMaxBytes int64, sharedGuard *guard.Guard, cfg agent.Config, reactionCfg telegramReactionConfig, allowedIDs map[int64]bool, job telegramJob, model string, history []llm.Message, stickySkills []string, requestTimeout time.Duration, draft *telegramStreamReply) (*agent.Final, *agent.Context, []string, *telegramReaction, error) {
 	task := job.Text
 	if baseReg == nil {
 		baseReg = registryFromViper()
@@ -1296,7 +1368,7 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 	var hasPreIntent bool
 	var preIntent agent.Intent
 	if !job.IsHeartbeat && api != nil && job.MessageID != 0 && strings.TrimSpace(task) != "" && reactionCfg.Enabled {
-		dec, err := decideTelegramReaction(ctx, client, model, task, history, cfg, reactionCfg)
+		dec, err := decideTelegramReaction(ctx, client, model, models, task, history, cfg, reactionCfg)
 		if err != nil {
 			if logger != nil {
 				logger.Warn("telegram_reaction_intent_error", "error", err.Error())
@@ -1352,7 +1424,8 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 	for _, t := range baseReg.All() {
 		reg.Register(t)
 	}
-	registerPlanTool(reg, client, model)
+	planClient, planModel := purposeLLM(models, llmutil.PurposePlan, client, model)
+	registerPlanTool(reg, planClient, planModel)
 	reg.Register(newTelegramSendVoiceTool(api, job.ChatID, fileCacheDir, filesMaxBytes, nil))
 	if filesEnabled && api != nil {
 		reg.Register(newTelegramSendFileTool(api, job.ChatID, fileCacheDir, filesMaxBytes))
@@ -1363,7 +1436,8 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 		reg.Register(reactTool)
 	}
 
-	promptSpec, loadedSkills, skillAuthProfiles, err := promptSpecForTelegram(ctx, logger, logOpts, task, client, model, stickySkills)
+	skillsClient, _ := purposeLLM(models, llmutil.PurposeSkills, client, model)
+	promptSpec, loadedSkills, skillAuthProfiles, err := promptSpecForTelegram(ctx, logger, logOpts, task, skillsClient, model, stickySkills)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
@@ -1408,7 +1482,7 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 			memManager = memory.NewManager(statepaths.MemoryDir(), viper.GetInt("memory.short_term_days"))
 			if viper.GetBool("memory.injection.enabled") {
 				maxItems := viper.GetInt("memory.injection.max_items")
-				snap, err := memManager.BuildInjection(id.SubjectID, memReqCtx, maxItems)
+				snap, err := memManager.BuildInjection(id.SubjectID, memReqCtx, maxItems, viper.GetInt("memory.injection.max_tokens"))
 				if err != nil {
 					return nil, nil, loadedSkills, nil, fmt.Errorf("memory injection: %w", err)
 				}
@@ -1433,11 +1507,12 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 
 	var planUpdateHook func(runCtx *agent.Context, update agent.PlanStepUpdate)
 	if !job.IsHeartbeat {
+		progressClient, progressModel := purposeLLM(models, llmutil.PurposePlanProgress, client, model)
 		planUpdateHook = func(runCtx *agent.Context, update agent.PlanStepUpdate) {
 			if api == nil || runCtx == nil || runCtx.Plan == nil {
 				return
 			}
-			msg, err := generateTelegramPlanProgressMessage(ctx, client, model, task, runCtx.Plan, update, requestTimeout)
+			msg, err := generateTelegramPlanProgressMessage(ctx, progressClient, progressModel, task, runCtx.Plan, update, requestTimeout)
 			if err != nil {
 				logger.Warn("telegram_plan_progress_error", "error", err.Error())
 				return
@@ -1456,10 +1531,15 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 		agent.WithLogOptions(logOpts),
 		agent.WithSkillAuthProfiles(skillAuthProfiles, viper.GetBool("secrets.require_skill_profiles")),
 		agent.WithGuard(sharedGuard),
+		agent.WithIntentModel(models.Override(llmutil.PurposeIntent)),
+		agent.WithCompactionModel(models.Override(llmutil.PurposeCompaction)),
 	}
 	if planUpdateHook != nil {
 		engineOpts = append(engineOpts, agent.WithPlanStepUpdate(planUpdateHook))
 	}
+	if draft != nil {
+		engineOpts = append(engineOpts, agent.WithStreamDelta(draft.OnDelta))
+	}
 	engine := agent.New(
 		client,
 		reg,
@@ -1477,7 +1557,7 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 			"telegram_from_user_id": job.FromUserID,
 		}
 	}
-	final, agentCtx, err := engine.Run(ctx, task, agent.RunOptions{Model: model, History: history, Meta: meta})
+	final, agentCtx, err := engine.Run(ctx, task, agent.RunOptions{Model: model, History: history, Meta: meta, Parts: job.Parts})
 	if err != nil {
 		return final, agentCtx, loadedSkills, nil, err
 	}
@@ -1496,10 +1576,11 @@ func runTelegramTask(ctx context.Context, logger *slog.Logger, logOpts agent.Log
 	}
 
 	if reaction == nil && !job.IsHeartbeat && memManager != nil && memIdentity.Enabled && strings.TrimSpace(memIdentity.SubjectID) != "" {
-		if err := updateTelegramMemory(ctx, logger, client, model, memManager, memIdentity, job, history, final, requestTimeout); err != nil {
+		memClient, memModel := purposeLLM(models, llmutil.PurposeMemory, client, model)
+		if err := updateTelegramMemory(ctx, logger, memClient, memModel, memManager, memIdentity, job, history, final, requestTimeout); err != nil {
 			if errors.Is(err, context.DeadlineExceeded) {
 				retryutil.AsyncRetry(logger, "memory_update", 2*time.Second, requestTimeout, func(retryCtx context.Context) error {
-					return updateTelegramMemory(retryCtx, logger, client, model, memManager, memIdentity, job, history, final, requestTimeout)
+					return updateTelegramMemory(retryCtx, logger, memClient, memModel, memManager, memIdentity, job, history, final, requestTimeout)
 				})
 			}
 			logger.Warn("memory_update_error", "error", err.Error())
@@ -1552,7 +1633,7 @@ func applyTelegramGroupRuntimePromptRules(spec *agent.PromptSpec, chatType strin
 	})
 }
 
-func runMAEPTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, baseReg *tools.Registry, sharedGuard *guard.Guard, cfg agent.Config, model string, peerID string, memManager *memory.Manager, history []llm.Message, stickySkills []string, task string) (*agent.Final, *agent.Context, []string, error) {
+func runMAEPTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOptions, client llm.Client, models *llmutil.Models, baseReg *tools.Registry, sharedGuard *guard.Guard, cfg agent.Config, model string, peerID string, memManager *memory.Manager, history []llm.Message, stickySkills []string, task string) (*agent.Final, *agent.Context, []string, error) {
 	if strings.TrimSpace(task) == "" {
 		return nil, nil, nil, fmt.Errorf("empty maep task")
 	}
@@ -1560,9 +1641,11 @@ func runMAEPTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOpti
 		baseReg = registryFromViper()
 	}
 	reg := buildMAEPRegistry(baseReg)
-	registerPlanTool(reg, client, model)
+	planClient, planModel := purposeLLM(models, llmutil.PurposePlan, client, model)
+	registerPlanTool(reg, planClient, planModel)
 
-	promptSpec, loadedSkills, skillAuthProfiles, err := promptSpecForTelegram(ctx, logger, logOpts, task, client, model, stickySkills)
+	skillsClient, _ := purposeLLM(models, llmutil.PurposeSkills, client, model)
+	promptSpec, loadedSkills, skillAuthProfiles, err := promptSpecForTelegram(ctx, logger, logOpts, task, skillsClient, model, stickySkills)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -1575,7 +1658,7 @@ func runMAEPTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOpti
 		if peerID != "" {
 			subjectID := "ext:maep:" + peerID
 			maxItems := viper.GetInt("memory.injection.max_items")
-			snap, err := memManager.BuildInjection(subjectID, memory.ContextPrivate, maxItems)
+			snap, err := memManager.BuildInjection(subjectID, memory.ContextPrivate, maxItems, viper.GetInt("memory.injection.max_tokens"))
 			if err != nil {
 				return nil, nil, nil, fmt.Errorf("memory injection: %w", err)
 			}
@@ -1606,6 +1689,8 @@ func runMAEPTask(ctx context.Context, logger *slog.Logger, logOpts agent.LogOpti
 		agent.WithLogOptions(logOpts),
 		agent.WithSkillAuthProfiles(skillAuthProfiles, viper.GetBool("secrets.require_skill_profiles")),
 		agent.WithGuard(sharedGuard),
+		agent.WithIntentModel(models.Override(llmutil.PurposeIntent)),
+		agent.WithCompactionModel(models.Override(llmutil.PurposeCompaction)),
 	)
 	final, runCtx, err := engine.Run(ctx, task, agent.RunOptions{
 		Model:   model,
@@ -1968,6 +2053,8 @@ func rewriteGenericActorReference(text string, label string) string {
 	return strings.TrimSpace(text)
 }
 
+var memoryDraftSchema = llm.SchemaFor("memory_draft", memory.SessionDraft{})
+
 func BuildMemoryDraft(ctx context.Context, client llm.Client, model string, history []llm.Message, task string, output string, existing memory.ShortTermContent, ctxInfo MemoryDraftContext) (memory.SessionDraft, error) {
 	if client == nil {
 		return memory.SessionDraft{}, fmt.Errorf("nil llm client")
@@ -1982,6 +2069,7 @@ func BuildMemoryDraft(ctx context.Context, client llm.Client, model string, hist
 	res, err := client.Chat(ctx, llm.Request{
 		Model:     model,
 		ForceJSON: true,
+		Schema:    memoryDraftSchema,
 		Messages: []llm.Message{
 			{Role: "system", Content: sys},
 			{Role: "user", Content: user},
@@ -2584,6 +2672,24 @@ type telegramSendMessageRequest struct {
 	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
 }
 
+type telegramSendMessageResponse struct {
+	OK     bool             `json:"ok"`
+	Result *telegramMessage `json:"result,omitempty"`
+}
+
+type telegramEditMessageTextRequest struct {
+	ChatID                int64  `json:"chat_id"`
+	MessageID             int64  `json:"message_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+}
+
+type telegramDeleteMessageRequest struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int64 `json:"message_id"`
+}
+
 type telegramSendChatActionRequest struct {
 	ChatID int64  `json:"chat_id"`
 	Action string `json:"action"`
@@ -2743,6 +2849,11 @@ func (api *telegramAPI) sendMessageChunked(ctx context.Context, chatID int64, te
 }
 
 func (api *telegramAPI) sendMessageWithParseMode(ctx context.Context, chatID int64, text string, disablePreview bool, parseMode string) error {
+	_, err := api.sendMessageWithParseModeID(ctx, chatID, text, disablePreview, parseMode)
+	return err
+}
+
+func (api *telegramAPI) sendMessageWithParseModeID(ctx context.Context, chatID int64, text string, disablePreview bool, parseMode string) (int64, error) {
 	reqBody := telegramSendMessageRequest{
 		ChatID:                chatID,
 		Text:                  text,
@@ -2752,6 +2863,41 @@ func (api *telegramAPI) sendMessageWithParseMode(ctx context.Context, chatID int
 	b, _ := json.Marshal(reqBody)
 	url := fmt.Sprintf("%s/bot%s/sendMessage", api.baseURL, api.token)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := api.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("telegram http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var ok telegramSendMessageResponse
+	_ = json.Unmarshal(raw, &ok)
+	if !ok.OK {
+		return 0, fmt.Errorf("telegram sendMessage: ok=false")
+	}
+	if ok.Result == nil {
+		return 0, nil
+	}
+	return ok.Result.MessageID, nil
+}
+
+func (api *telegramAPI) editMessageText(ctx context.Context, chatID int64, messageID int64, text string, parseMode string) error {
+	reqBody := telegramEditMessageTextRequest{
+		ChatID:                chatID,
+		MessageID:             messageID,
+		Text:                  text,
+		ParseMode:             strings.TrimSpace(parseMode),
+		DisableWebPagePreview: true,
+	}
+	b, _ := json.Marshal(reqBody)
+	url := fmt.Sprintf("%s/bot%s/editMessageText", api.baseURL, api.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
@@ -2763,12 +2909,16 @@ func (api *telegramAPI) sendMessageWithParseMode(ctx context.Context, chatID int
 	raw, _ := io.ReadAll(resp.Body)
 	_ = resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Editing with identical text is not an error for our purposes.
+		if strings.Contains(string(raw), "message is not modified") {
+			return nil
+		}
 		return fmt.Errorf("telegram http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
 	}
 	var ok telegramOKResponse
 	_ = json.Unmarshal(raw, &ok)
 	if !ok.OK {
-		return fmt.Errorf("telegram sendMessage: ok=false")
+		return fmt.Errorf("telegram editMessageText: ok=false")
 	}
 	return nil
 }
@@ -2923,6 +3073,32 @@ func (api *telegramAPI) sendVoice(ctx context.Context, chatID int64, filePath st
 	return nil
 }
 
+func (api *telegramAPI) deleteMessage(ctx context.Context, chatID int64, messageID int64) error {
+	b, _ := json.Marshal(telegramDeleteMessageRequest{ChatID: chatID, MessageID: messageID})
+	url := fmt.Sprintf("%s/bot%s/deleteMessage", api.baseURL, api.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := api.http.Do(req)
+	if err != nil {
+		return err
+	}
+	raw, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telegram http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var ok telegramOKResponse
+	_ = json.Unmarshal(raw, &ok)
+	if !ok.OK {
+		return fmt.Errorf("telegram deleteMessage: ok=false")
+	}
+	return nil
+}
+
 func (api *telegramAPI) setMessageReaction(ctx context.Context, chatID int64, messageID int64, reactions []telegramReactionType, isBig *bool) error {
 	if messageID == 0 {
 		return fmt.Errorf("missing message_id")
@@ -4352,6 +4528,8 @@ type telegramAddressingLLMDecision struct {
 	Reason     string  `json:"reason"`
 }
 
+var addressingSchema = llm.SchemaFor("addressing_decision", telegramAddressingLLMDecision{})
+
 func addressingDecisionViaLLM(ctx context.Context, client llm.Client, model string, botUser string, aliases []string, text string) (telegramAddressingLLMDecision, bool, error) {
 	if ctx == nil || client == nil {
 		return telegramAddressingLLMDecision{}, false, nil
@@ -4376,6 +4554,7 @@ func addressingDecisionViaLLM(ctx context.Context, client llm.Client, model stri
 	res, err := client.Chat(ctx, llm.Request{
 		Model:     model,
 		ForceJSON: true,
+		Schema:    addressingSchema,
 		Messages: []llm.Message{
 			{Role: "system", Content: sys},
 			{Role: "user", Content: user},
@@ -4628,6 +4807,27 @@ func appendDownloadedFilesToTask(task string, files []telegramDownloadedFile) st
 	return strings.TrimSpace(b.String())
 }
 
+// telegramImageParts returns image parts for downloaded photos and image
+// documents.
+func telegramImageParts(files []telegramDownloadedFile) []llm.ContentPart {
+	var parts []llm.ContentPart
+	for _, f := range files {
+		if strings.TrimSpace(f.Path) == "" {
+			continue
+		}
+		if f.Kind != "photo" && !strings.HasPrefix(strings.ToLower(strings.TrimSpace(f.MimeType)), "image/") {
+			continue
+		}
+		parts = append(parts, llm.ContentPart{
+			Type:     llm.PartImage,
+			Path:     f.Path,
+			MimeType: strings.TrimSpace(f.MimeType),
+			Name:     strings.TrimSpace(f.OriginalName),
+		})
+	}
+	return parts
+}
+
 func downloadTelegramMessageFiles(ctx context.Context, api *telegramAPI, cacheDir string, maxBytes int64, msg *telegramMessage, chatID int64) ([]telegramDownloadedFile, error) {
 	if api == nil {
 		return nil, fmt.Errorf("telegram api not available")
@@ -9,6 +9,7 @@ import (
 	"github.com/quailyquaily/mistermorph/agent"
 	"github.com/quailyquaily/mistermorph/guard"
 	"github.com/quailyquaily/mistermorph/internal/llmconfig"
+	"github.com/quailyquaily/mistermorph/internal/llmutil"
 	"github.com/quailyquaily/mistermorph/llm"
 	"github.com/quailyquaily/mistermorph/memory"
 	"github.com/quailyquaily/mistermorph/tools"
@@ -23,6 +24,7 @@ type Dependencies struct {
 	LLMEndpointForProvider         func(provider string) string
 	LLMAPIKeyForProvider           func(provider string) string
 	LLMModelForProvider            func(provider string) string
+	LLMModelsFromViper             func(main llm.Client, mainModel string) (*llmutil.Models, error)
 	RegistryFromViper              func() *tools.Registry
 	RegisterPlanTool               func(reg *tools.Registry, client llm.Client, model string)
 	GuardFromViper                 func(logger *slog.Logger) *guard.Guard
@@ -101,6 +103,22 @@ func llmClientFromConfig(cfg llmconfig.ClientConfig) (llm.Client, error) {
 	return deps.CreateLLMClient(cfg.Provider, cfg.Endpoint, cfg.APIKey, cfg.Model, cfg.RequestTimeout)
 }
 
+func llmModelsFromViper(main llm.Client, mainModel string) (*llmutil.Models, error) {
+	if deps.LLMModelsFromViper == nil {
+		return nil, fmt.Errorf("LLMModelsFromViper dependency missing")
+	}
+	return deps.LLMModelsFromViper(main, mainModel)
+}
+
+// purposeLLM returns the client and model configured for purpose, falling
+// back to client and model when models is nil or has no entry for it.
+func purposeLLM(models *llmutil.Models, purpose string, client llm.Client, model string) (llm.Client, string) {
+	if c, m := models.Override(purpose); c != nil {
+		return c, m
+	}
+	return client, model
+}
+
 func registryFromViper() *tools.Registry {
 	if deps.RegistryFromViper == nil {
 		return nil
@@ -120,6 +120,7 @@ func TestRunTelegramTask_PreflightReactionNoTextReply(t *testing.T) {
 		nil,
 		agent.LogOptions{},
 		&staticIntentClient{},
+		nil,
 		tools.NewRegistry(),
 		api,
 		false,
@@ -139,6 +140,7 @@ func TestRunTelegramTask_PreflightReactionNoTextReply(t *testing.T) {
 		nil,
 		nil,
 		5*time.Second,
+		nil,
 	)
 	if err != nil {
 		t.Fatalf("runTelegramTask() error = %v", err)
@@ -7,6 +7,7 @@ import (
 
 	"github.com/quailyquaily/mistermorph/agent"
 	"github.com/quailyquaily/mistermorph/internal/jsonutil"
+	"github.com/quailyquaily/mistermorph/internal/llmutil"
 	"github.com/quailyquaily/mistermorph/llm"
 	"github.com/spf13/viper"
 )
@@ -90,7 +91,7 @@ func reactionHistoryNote(emoji string) string {
 	return "[reacted: " + emoji + "]"
 }
 
-func decideTelegramReaction(ctx context.Context, client llm.Client, model string, task string, history []llm.Message, intentCfg agent.Config, reactCfg telegramReactionConfig) (telegramReactionDecision, error) {
+func decideTelegramReaction(ctx context.Context, client llm.Client, model string, models *llmutil.Models, task string, history []llm.Message, intentCfg agent.Config, reactCfg telegramReactionConfig) (telegramReactionDecision, error) {
 	decision := telegramReactionDecision{}
 	if !reactCfg.Enabled {
 		return decision, nil
@@ -118,7 +119,8 @@ func decideTelegramReaction(ctx context.Context, client llm.Client, model string
 	if cancel != nil {
 		defer cancel()
 	}
-	intent, err := agent.InferIntent(intentCtx, client, model, task, history, intentCfg.IntentMaxHistory)
+	intentClient, intentModel := purposeLLM(models, llmutil.PurposeIntent, client, model)
+	intent, err := agent.InferIntent(intentCtx, intentClient, intentModel, task, history, intentCfg.IntentMaxHistory)
 	if err != nil {
 		return decision, err
 	}
@@ -147,7 +149,8 @@ func decideTelegramReaction(ctx context.Context, client llm.Client, model string
 	}
 	match := classifyReactionCategory(intent, task)
 	if match.Category == "" {
-		intentMatch, err := classifyReactionCategoryViaIntent(intentCtx, client, model, intent, task)
+		reactionClient, reactionModel := purposeLLM(models, llmutil.PurposeReaction, client, model)
+		intentMatch, err := classifyReactionCategoryViaIntent(intentCtx, reactionClient, reactionModel, intent, task)
 		if err == nil && intentMatch.Category != "" {
 			match = intentMatch
 		}
@@ -273,6 +276,8 @@ type reactionCategoryDecision struct {
 	Reason   string `json:"reason"`
 }
 
+var reactionCategorySchema = llm.SchemaFor("reaction_category", reactionCategoryDecision{})
+
 func classifyReactionCategoryViaIntent(ctx context.Context, client llm.Client, model string, intent agent.Intent, task string) (reactionMatch, error) {
 	if client == nil {
 		return reactionMatch{}, nil
@@ -291,6 +296,7 @@ func classifyReactionCategoryViaIntent(ctx context.Context, client llm.Client, m
 	req := llm.Request{
 		Model:     model,
 		ForceJSON: true,
+		Schema:    reactionCategorySchema,
 		Messages: []llm.Message{
 			{Role: "system", Content: sys},
 			{Role: "user", Content: user},
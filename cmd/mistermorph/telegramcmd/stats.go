@@ -0,0 +1,46 @@
+package telegramcmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/agent"
+)
+
+// telegramChatStats accumulates LLM usage of a chat's runs since the bot
+// started (or since the last /reset).
+type telegramChatStats struct {
+	Runs        int
+	LLMRounds   int
+	TotalTokens int
+	CostUSD     float64
+	Since       time.Time
+}
+
+func (s telegramChatStats) add(runCtx *agent.Context) telegramChatStats {
+	if runCtx == nil || runCtx.Metrics == nil {
+		return s
+	}
+	if s.Since.IsZero() {
+		s.Since = time.Now()
+	}
+	s.Runs++
+	s.LLMRounds += runCtx.Metrics.LLMRounds
+	s.TotalTokens += runCtx.Metrics.TotalTokens
+	s.CostUSD += runCtx.Metrics.TotalCost
+	return s
+}
+
+func formatTelegramStats(s telegramChatStats) string {
+	if s.Runs == 0 {
+		return "no runs yet"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "runs: %d\n", s.Runs)
+	fmt.Fprintf(&b, "llm calls: %d\n", s.LLMRounds)
+	fmt.Fprintf(&b, "tokens: %d\n", s.TotalTokens)
+	fmt.Fprintf(&b, "cost: $%.4f\n", s.CostUSD)
+	fmt.Fprintf(&b, "since: %s", s.Since.UTC().Format(time.RFC3339))
+	return b.String()
+}
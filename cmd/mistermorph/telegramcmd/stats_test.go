@@ -0,0 +1,26 @@
+package telegramcmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/agent"
+)
+
+func TestTelegramChatStats(t *testing.T) {
+	var s telegramChatStats
+	if got := formatTelegramStats(s); got != "no runs yet" {
+		t.Fatalf("formatTelegramStats(empty) = %q", got)
+	}
+	run := agent.NewContext("t", 5)
+	run.Metrics.LLMRounds = 2
+	run.Metrics.TotalTokens = 300
+	run.Metrics.TotalCost = 0.0125
+	s = s.add(run).add(run).add(nil)
+	if s.Runs != 2 || s.TotalTokens != 600 || s.LLMRounds != 4 {
+		t.Fatalf("stats = %+v", s)
+	}
+	if got := formatTelegramStats(s); !strings.Contains(got, "cost: $0.0250") || !strings.Contains(got, "runs: 2") {
+		t.Fatalf("formatTelegramStats() = %q", got)
+	}
+}
@@ -0,0 +1,146 @@
+package telegramcmd
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/quailyquaily/mistermorph/agent"
+)
+
+const (
+	telegramStreamEditInterval = 1500 * time.Millisecond
+	// telegramStreamMaxChars is in UTF-16 code units, the unit of Telegram's
+	// message length limit.
+	telegramStreamMaxChars = 3500
+)
+
+// telegramStreamReply progressively edits a single Telegram message while the
+// final answer is being streamed, then replaces it with the formatted reply.
+type telegramStreamReply struct {
+	api    *telegramAPI
+	chatID int64
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	stream    agent.FinalOutputStream
+	messageID int64
+	lastEdit  time.Time
+	lastText  string
+	failed    bool
+}
+
+func newTelegramStreamReply(api *telegramAPI, chatID int64, logger *slog.Logger) *telegramStreamReply {
+	if api == nil {
+		return nil
+	}
+	return &telegramStreamReply{api: api, chatID: chatID, logger: logger}
+}
+
+func (r *telegramStreamReply) OnDelta(_ *agent.Context, delta agent.StreamDelta) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed || r.stream.Push(delta) == "" {
+		return
+	}
+	if time.Since(r.lastEdit) < telegramStreamEditInterval {
+		return
+	}
+	text, _ := cutTelegramText(strings.TrimSpace(r.stream.Output()), telegramStreamMaxChars)
+	if text == "" || text == r.lastText {
+		return
+	}
+	r.lastEdit = time.Now()
+	r.lastText = text
+
+	// Drafts are sent as plain text: partial MarkdownV2 is rarely valid.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if r.messageID == 0 {
+		id, err := r.api.sendMessageWithParseModeID(ctx, r.chatID, text, true, "")
+		if err != nil || id == 0 {
+			r.fail(err)
+			return
+		}
+		r.messageID = id
+		return
+	}
+	if err := r.api.editMessageText(ctx, r.chatID, r.messageID, text, ""); err != nil {
+		r.fail(err)
+	}
+}
+
+func (r *telegramStreamReply) fail(err error) {
+	r.failed = true
+	if err != nil && r.logger != nil {
+		r.logger.Warn("telegram_stream_reply_error", "chat_id", r.chatID, "error", err.Error())
+	}
+}
+
+// Finish delivers the final text. It edits the draft message in place when
+// one was sent and otherwise falls back to a regular (chunked) send.
+func (r *telegramStreamReply) Finish(ctx context.Context, text string) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	messageID := r.messageID
+	r.failed = true // stop further edits
+	r.mu.Unlock()
+
+	if messageID == 0 {
+		return r.api.sendMessageChunked(ctx, r.chatID, text)
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = "(empty)"
+	}
+	head, rest := cutTelegramText(text, telegramStreamMaxChars)
+	rest = strings.TrimSpace(rest)
+	if err := r.api.editMessageText(ctx, r.chatID, messageID, head, "MarkdownV2"); err != nil {
+		if err := r.api.editMessageText(ctx, r.chatID, messageID, head, ""); err != nil {
+			return err
+		}
+	}
+	if rest != "" {
+		return r.api.sendMessageChunked(ctx, r.chatID, rest)
+	}
+	return nil
+}
+
+// Discard deletes the draft message, for runs that end without a text
+// reply (e.g. a reaction).
+func (r *telegramStreamReply) Discard(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	messageID := r.messageID
+	r.messageID = 0
+	r.failed = true
+	r.mu.Unlock()
+
+	if messageID == 0 {
+		return nil
+	}
+	return r.api.deleteMessage(ctx, r.chatID, messageID)
+}
+
+// cutTelegramText splits s after at most max UTF-16 code units, on a rune
+// boundary.
+func cutTelegramText(s string, max int) (string, string) {
+	n := 0
+	for i, r := range s {
+		if n += utf16.RuneLen(r); n > max {
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
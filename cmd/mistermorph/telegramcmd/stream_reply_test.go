@@ -0,0 +1,30 @@
+package telegramcmd
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCutTelegramText(t *testing.T) {
+	head, rest := cutTelegramText("héllo", 2)
+	if head != "hé" || rest != "llo" {
+		t.Fatalf("cut = %q, %q", head, rest)
+	}
+
+	// Emoji outside the BMP take two UTF-16 units each.
+	s := strings.Repeat("😀", 3)
+	head, rest = cutTelegramText(s, 5)
+	if head != "😀😀" || rest != "😀" {
+		t.Fatalf("cut = %q, %q", head, rest)
+	}
+
+	s = strings.Repeat("中文", 3000)
+	head, rest = cutTelegramText(s, telegramStreamMaxChars)
+	if !utf8.ValidString(head) || !utf8.ValidString(rest) || head+rest != s || utf8.RuneCountInString(head) != telegramStreamMaxChars {
+		t.Fatalf("cut at %d runes, want %d", utf8.RuneCountInString(head), telegramStreamMaxChars)
+	}
+	if head, rest := cutTelegramText("short", 10); head != "short" || rest != "" {
+		t.Fatalf("cut = %q, %q", head, rest)
+	}
+}
@@ -188,7 +188,7 @@ func (g *Guard) Close() error {
 func (g *Guard) evalToolCallPre(_ context.Context, a Action) Result {
 	name := strings.TrimSpace(strings.ToLower(a.ToolName))
 	switch name {
-	case "bash":
+	case "bash", "process_start":
 		if g.cfg.Bash.RequireApproval {
 			return Result{
 				RiskLevel: RiskHigh,
@@ -0,0 +1,319 @@
+// Package llmcassette records LLM requests and results to a cassette file
+// and replays them, so real sessions can be rerun without a live model.
+package llmcassette
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+	// ModeAuto replays when the cassette file exists and records otherwise.
+	ModeAuto = "auto"
+
+	cassetteVersion = 1
+)
+
+// ErrNoInteraction is returned in replay mode for a request the cassette
+// has no recording of.
+var ErrNoInteraction = errors.New("llmcassette: no recorded interaction for request")
+
+// Cassette is the on-disk format.
+type Cassette struct {
+	Version      int           `json:"version"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded Chat call. Error is set instead of Result when
+// the call failed, so failures replay too.
+type Interaction struct {
+	Fingerprint string  `json:"fingerprint"`
+	Request     Request `json:"request"`
+	Result      *Result `json:"result,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// Request mirrors llm.Request with JSON field names. It is kept for
+// reading and diffing cassettes; replay only looks at the fingerprint.
+type Request struct {
+	Model      string          `json:"model"`
+	Messages   []llm.Message   `json:"messages"`
+	Tools      []llm.Tool      `json:"tools,omitempty"`
+	ForceJSON  bool            `json:"force_json,omitempty"`
+	Schema     *llm.JSONSchema `json:"schema,omitempty"`
+	Parameters map[string]any  `json:"parameters,omitempty"`
+}
+
+func fromLLMRequest(r llm.Request) Request {
+	return Request{
+		Model:      r.Model,
+		Messages:   r.Messages,
+		Tools:      r.Tools,
+		ForceJSON:  r.ForceJSON,
+		Schema:     r.Schema,
+		Parameters: r.Parameters,
+	}
+}
+
+// Result mirrors llm.Result with JSON field names.
+type Result struct {
+	Text       string         `json:"text,omitempty"`
+	JSON       any            `json:"json,omitempty"`
+	ToolCalls  []llm.ToolCall `json:"tool_calls,omitempty"`
+	Usage      llm.Usage      `json:"usage"`
+	DurationMs int64          `json:"duration_ms,omitempty"`
+	Backend    string         `json:"backend,omitempty"`
+}
+
+func fromLLMResult(r llm.Result) *Result {
+	return &Result{
+		Text:       r.Text,
+		JSON:       r.JSON,
+		ToolCalls:  r.ToolCalls,
+		Usage:      r.Usage,
+		DurationMs: r.Duration.Milliseconds(),
+		Backend:    r.Backend,
+	}
+}
+
+func (r *Result) toLLMResult() llm.Result {
+	return llm.Result{
+		Text:      r.Text,
+		JSON:      r.JSON,
+		ToolCalls: r.ToolCalls,
+		Usage:     r.Usage,
+		Duration:  time.Duration(r.DurationMs) * time.Millisecond,
+		Backend:   r.Backend,
+	}
+}
+
+type Option func(*Client)
+
+// WithNormalize rewrites requests before they are fingerprinted, e.g. to
+// blank out timestamps that change from run to run. The request sent to the
+// base client is not affected.
+func WithNormalize(fn func(llm.Request) llm.Request) Option {
+	return func(c *Client) {
+		c.normalize = fn
+	}
+}
+
+// Client is an llm.Client decorator that records to or replays from a
+// cassette file.
+type Client struct {
+	base      llm.Client
+	path      string
+	mode      string
+	normalize func(llm.Request) llm.Request
+
+	mu       sync.Mutex
+	cassette Cassette
+	// served counts replayed interactions per fingerprint so repeated
+	// identical requests get their recorded answers in order.
+	served map[string]int
+}
+
+// New opens the cassette at path. In record mode the file is rewritten from
+// scratch; in replay mode it must exist and base may be nil.
+func New(base llm.Client, path string, mode string, opts ...Option) (*Client, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("llmcassette: path is required")
+	}
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "", ModeAuto:
+		mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			mode = ModeReplay
+		}
+	case ModeRecord, ModeReplay:
+	default:
+		return nil, fmt.Errorf("llmcassette: unknown mode %q (expected record|replay|auto)", mode)
+	}
+
+	c := &Client{base: base, path: path, mode: mode, served: make(map[string]int)}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	switch mode {
+	case ModeReplay:
+		cas, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		c.cassette = cas
+	case ModeRecord:
+		if base == nil {
+			return nil, fmt.Errorf("llmcassette: record mode needs a base client")
+		}
+		c.cassette = Cassette{Version: cassetteVersion, RecordedAt: time.Now().UTC()}
+		if err := c.save(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Load reads a cassette file.
+func Load(path string) (Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Cassette{}, fmt.Errorf("llmcassette: read %s: %w", path, err)
+	}
+	var cas Cassette
+	if err := json.Unmarshal(data, &cas); err != nil {
+		return Cassette{}, fmt.Errorf("llmcassette: parse %s: %w", path, err)
+	}
+	if cas.Version != cassetteVersion {
+		return Cassette{}, fmt.Errorf("llmcassette: unsupported cassette version %d", cas.Version)
+	}
+	return cas, nil
+}
+
+// Mode reports the effective mode (auto is resolved in New).
+func (c *Client) Mode() string { return c.mode }
+
+func (c *Client) Chat(ctx context.Context, req llm.Request) (llm.Result, error) {
+	if c.mode == ModeReplay {
+		return c.replay(req)
+	}
+	res, err := c.base.Chat(ctx, req)
+	return res, c.record(req, res, err)
+}
+
+// ChatStream records the aggregate result of a streamed call. Replayed
+// results are emitted as a single event.
+func (c *Client) ChatStream(ctx context.Context, req llm.Request, onEvent llm.StreamHandler) (llm.Result, error) {
+	if c.mode == ModeReplay {
+		return llm.ChatStream(ctx, replayOnly{c}, req, onEvent)
+	}
+	res, err := llm.ChatStream(ctx, c.base, req, onEvent)
+	return res, c.record(req, res, err)
+}
+
+// SetDebugFn forwards the debug hook to the base client when recording.
+func (c *Client) SetDebugFn(fn func(label, payload string)) {
+	if setter, ok := c.base.(interface {
+		SetDebugFn(func(label, payload string))
+	}); ok {
+		setter.SetDebugFn(fn)
+	}
+}
+
+// record appends the interaction and rewrites the cassette. It returns the
+// call's own error (if any) so callers can return it directly.
+func (c *Client) record(req llm.Request, res llm.Result, callErr error) error {
+	it := Interaction{Fingerprint: c.fingerprint(req), Request: fromLLMRequest(req)}
+	if callErr != nil {
+		it.Error = callErr.Error()
+	} else {
+		it.Result = fromLLMResult(res)
+	}
+	c.mu.Lock()
+	c.cassette.Interactions = append(c.cassette.Interactions, it)
+	err := c.save()
+	c.mu.Unlock()
+	if callErr != nil {
+		return callErr
+	}
+	return err
+}
+
+func (c *Client) replay(req llm.Request) (llm.Result, error) {
+	fp := c.fingerprint(req)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matches []Interaction
+	for _, it := range c.cassette.Interactions {
+		if it.Fingerprint == fp {
+			matches = append(matches, it)
+		}
+	}
+	if len(matches) == 0 {
+		return llm.Result{}, fmt.Errorf("%w (fingerprint %s, last message: %q)", ErrNoInteraction, fp, lastMessagePreview(req))
+	}
+	// Once recordings run out, keep answering with the last one.
+	i := c.served[fp]
+	if i >= len(matches) {
+		i = len(matches) - 1
+	}
+	c.served[fp]++
+	it := matches[i]
+	if it.Error != "" {
+		return llm.Result{}, errors.New(it.Error)
+	}
+	if it.Result == nil {
+		return llm.Result{}, nil
+	}
+	return it.Result.toLLMResult(), nil
+}
+
+// save writes the cassette atomically; callers hold c.mu (or own c).
+func (c *Client) save() error {
+	data, err := json.MarshalIndent(c.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("llmcassette: encode: %w", err)
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("llmcassette: create dir: %w", err)
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("llmcassette: write: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func (c *Client) fingerprint(req llm.Request) string {
+	if c.normalize != nil {
+		req = c.normalize(req)
+	}
+	return Fingerprint(req)
+}
+
+// Fingerprint identifies a request by everything that is sent to the model:
+// model, messages, tools, JSON mode and extra parameters.
+func Fingerprint(req llm.Request) string {
+	// Map keys (parameters, tool arguments) marshal in sorted order, so the
+	// encoding is stable.
+	data, _ := json.Marshal(fromLLMRequest(req))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func lastMessagePreview(req llm.Request) string {
+	if len(req.Messages) == 0 {
+		return ""
+	}
+	s := req.Messages[len(req.Messages)-1].Content
+	if len(s) > 80 {
+		s = s[:80] + "..."
+	}
+	return s
+}
+
+// replayOnly hides ChatStream so llm.ChatStream falls back to Chat.
+type replayOnly struct{ c *Client }
+
+func (r replayOnly) Chat(_ context.Context, req llm.Request) (llm.Result, error) {
+	return r.c.replay(req)
+}
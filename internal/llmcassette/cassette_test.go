@@ -0,0 +1,105 @@
+package llmcassette
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+type countingClient struct {
+	calls int
+}
+
+func (c *countingClient) Chat(_ context.Context, req llm.Request) (llm.Result, error) {
+	c.calls++
+	last := req.Messages[len(req.Messages)-1].Content
+	if last == "fail" {
+		return llm.Result{}, errors.New("provider exploded")
+	}
+	return llm.Result{
+		Text:      strings.ToUpper(last) + strings.Repeat("!", c.calls),
+		ToolCalls: []llm.ToolCall{{ID: "c1", Name: "echo", Arguments: map[string]any{"n": 1}}},
+		Usage:     llm.Usage{InputTokens: 3, OutputTokens: 2, TotalTokens: 5},
+	}, nil
+}
+
+func userReq(text string) llm.Request {
+	return llm.Request{Model: "m", Messages: []llm.Message{{Role: "user", Content: text}}, ForceJSON: true, Parameters: map[string]any{"temperature": 0}}
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassettes", "run.json")
+	base := &countingClient{}
+	rec, err := New(base, path, ModeAuto)
+	if err != nil {
+		t.Fatalf("New(record) error = %v", err)
+	}
+	if rec.Mode() != ModeRecord {
+		t.Fatalf("Mode() = %q, want record", rec.Mode())
+	}
+	ctx := context.Background()
+	first, _ := rec.Chat(ctx, userReq("hi"))
+	second, _ := rec.Chat(ctx, userReq("hi"))
+	if _, err := rec.Chat(ctx, userReq("fail")); err == nil {
+		t.Fatalf("expected recorded call error")
+	}
+
+	rep, err := New(nil, path, ModeAuto)
+	if err != nil {
+		t.Fatalf("New(replay) error = %v", err)
+	}
+	if rep.Mode() != ModeReplay {
+		t.Fatalf("Mode() = %q, want replay", rep.Mode())
+	}
+	got1, err := rep.Chat(ctx, userReq("hi"))
+	if err != nil || got1.Text != first.Text || got1.Usage.TotalTokens != 5 || got1.ToolCalls[0].Name != "echo" {
+		t.Fatalf("replay 1 = %+v, %v", got1, err)
+	}
+	var streamed strings.Builder
+	got2, err := rep.ChatStream(ctx, userReq("hi"), func(ev llm.StreamEvent) { streamed.WriteString(ev.Text) })
+	if err != nil || got2.Text != second.Text || streamed.String() != second.Text {
+		t.Fatalf("replay 2 = %q (streamed %q), %v; want %q", got2.Text, streamed.String(), err, second.Text)
+	}
+	if _, err := rep.Chat(ctx, userReq("fail")); err == nil || err.Error() != "provider exploded" {
+		t.Fatalf("replayed error = %v", err)
+	}
+	if _, err := rep.Chat(ctx, userReq("something new")); !errors.Is(err, ErrNoInteraction) {
+		t.Fatalf("unrecorded request error = %v", err)
+	}
+	if base.calls != 3 {
+		t.Fatalf("base calls = %d, want 3 (replay must not call the provider)", base.calls)
+	}
+}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.json")
+	dropTime := WithNormalize(func(req llm.Request) llm.Request {
+		msgs := append([]llm.Message(nil), req.Messages...)
+		for i := range msgs {
+			if j := strings.Index(msgs[i].Content, " @"); j >= 0 {
+				msgs[i].Content = msgs[i].Content[:j]
+			}
+		}
+		req.Messages = msgs
+		return req
+	})
+	rec, err := New(&countingClient{}, path, ModeRecord, dropTime)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := rec.Chat(context.Background(), userReq("hi @2026-01-01")); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	rep, err := New(nil, path, ModeReplay, dropTime)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if res, err := rep.Chat(context.Background(), userReq("hi @2026-02-02")); err != nil || res.Text != "HI @2026-01-01!" {
+		t.Fatalf("Chat() = %q, %v", res.Text, err)
+	}
+}
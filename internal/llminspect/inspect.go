@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/llm/tokens"
 )
 
 type Options struct {
@@ -64,7 +65,8 @@ func (p *PromptInspector) Close() error {
 	return p.file.Close()
 }
 
-func (p *PromptInspector) Dump(messages []llm.Message) error {
+// Dump writes req's messages, annotated with estimated token counts.
+func (p *PromptInspector) Dump(req llm.Request) error {
 	if p == nil || p.file == nil {
 		return nil
 	}
@@ -74,8 +76,9 @@ func (p *PromptInspector) Dump(messages []llm.Message) error {
 	p.requestCount++
 	var b strings.Builder
 	fmt.Fprintf(&b, "\n## Request #%d\n\n", p.requestCount)
-	for i, msg := range messages {
-		fmt.Fprintf(&b, "### Message #%d-%d\n\n", p.requestCount, i+1)
+	fmt.Fprintf(&b, "model: %s, estimated input tokens: ~%d\n\n", req.Model, tokens.Request(req))
+	for i, msg := range req.Messages {
+		fmt.Fprintf(&b, "### Message #%d-%d (~%d tokens)\n\n", p.requestCount, i+1, tokens.Message(req.Model, msg))
 		b.WriteString("```\n")
 		fmt.Fprintf(&b, "role: %s\n\n", msg.Role)
 		if strings.TrimSpace(msg.ToolCallID) != "" {
@@ -200,13 +203,25 @@ func (c *PromptClient) Chat(ctx context.Context, req llm.Request) (llm.Result, e
 		return llm.Result{}, fmt.Errorf("inspect client is not initialized")
 	}
 	if c.Inspector != nil {
-		if err := c.Inspector.Dump(req.Messages); err != nil {
+		if err := c.Inspector.Dump(req); err != nil {
 			return llm.Result{}, err
 		}
 	}
 	return c.Base.Chat(ctx, req)
 }
 
+func (c *PromptClient) ChatStream(ctx context.Context, req llm.Request, onEvent llm.StreamHandler) (llm.Result, error) {
+	if c == nil || c.Base == nil {
+		return llm.Result{}, fmt.Errorf("inspect client is not initialized")
+	}
+	if c.Inspector != nil {
+		if err := c.Inspector.Dump(req); err != nil {
+			return llm.Result{}, err
+		}
+	}
+	return llm.ChatStream(ctx, c.Base, req, onEvent)
+}
+
 func SetDebugHook(client llm.Client, dumpFn func(label, payload string)) error {
 	setter, ok := client.(interface {
 		SetDebugFn(func(label, payload string))
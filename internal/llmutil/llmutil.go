@@ -2,10 +2,15 @@ package llmutil
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/quailyquaily/mistermorph/internal/llmconfig"
 	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/llm/pricing"
+	fakeProvider "github.com/quailyquaily/mistermorph/providers/fake"
+	"github.com/quailyquaily/mistermorph/providers/local"
+	"github.com/quailyquaily/mistermorph/providers/router"
 	uniaiProvider "github.com/quailyquaily/mistermorph/providers/uniai"
 	"github.com/spf13/viper"
 )
@@ -31,6 +36,17 @@ func EndpointForProvider(provider string) string {
 	switch provider {
 	case "azure":
 		return firstNonEmpty(viper.GetString("llm.azure.endpoint"), viper.GetString("llm.endpoint"))
+	case "fake":
+		// The fake provider's "endpoint" is its script file.
+		return strings.TrimSpace(viper.GetString("llm.fake.script"))
+	case local.KindOllama, local.KindLlamaCpp, local.KindVLLM:
+		// llm.endpoint defaults to OpenAI; local servers default to their
+		// usual address instead.
+		endpoint := strings.TrimSpace(viper.GetString("llm.endpoint"))
+		if endpoint == "" || strings.Contains(endpoint, "api.openai.com") {
+			return local.DefaultEndpoint(provider)
+		}
+		return endpoint
 	default:
 		return strings.TrimSpace(viper.GetString("llm.endpoint"))
 	}
@@ -59,34 +75,141 @@ func ModelForProvider(provider string) string {
 	}
 }
 
+// FallbackConfig is one llm.fallbacks entry: a secondary provider tried
+// when the primary keeps failing. ${VAR} in endpoint and api_key is expanded.
+type FallbackConfig struct {
+	Name     string `mapstructure:"name"`
+	Provider string `mapstructure:"provider"`
+	Endpoint string `mapstructure:"endpoint"`
+	APIKey   string `mapstructure:"api_key"`
+	Model    string `mapstructure:"model"`
+}
+
+// ClientFromConfig builds the main client for cfg. When llm.retry.max_attempts
+// is above 1 or llm.fallbacks are configured, the provider client is wrapped
+// in a providers/router client that retries transient errors and fails over.
 func ClientFromConfig(cfg llmconfig.ClientConfig) (llm.Client, error) {
+	return clientFromConfig(cfg, true)
+}
+
+// routeClientFromConfig builds the client of a models.<purpose> route. It
+// retries like the main client but never fails over to llm.fallbacks, which
+// name replacements for the main model.
+func routeClientFromConfig(cfg llmconfig.ClientConfig) (llm.Client, error) {
+	return clientFromConfig(cfg, false)
+}
+
+func clientFromConfig(cfg llmconfig.ClientConfig, withFallbacks bool) (llm.Client, error) {
+	primary, err := providerClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var fallbacks []FallbackConfig
+	if withFallbacks {
+		if err := viper.UnmarshalKey("llm.fallbacks", &fallbacks); err != nil {
+			return nil, fmt.Errorf("invalid llm.fallbacks: %w", err)
+		}
+	}
+	retry := router.Retry{
+		MaxAttempts: viper.GetInt("llm.retry.max_attempts"),
+		BaseDelay:   viper.GetDuration("llm.retry.base_delay"),
+		MaxDelay:    viper.GetDuration("llm.retry.max_delay"),
+	}
+	if len(fallbacks) == 0 && retry.MaxAttempts <= 1 {
+		return primary, nil
+	}
+
+	backends := []router.Backend{{Name: backendName("", cfg.Provider, cfg.Model), Client: primary}}
+	for i, fb := range fallbacks {
+		if strings.TrimSpace(fb.Provider) == "" || strings.TrimSpace(fb.Model) == "" {
+			return nil, fmt.Errorf("llm.fallbacks[%d]: provider and model are required", i)
+		}
+		c, err := providerClient(llmconfig.ClientConfig{
+			Provider:       fb.Provider,
+			Endpoint:       os.ExpandEnv(fb.Endpoint),
+			APIKey:         os.ExpandEnv(fb.APIKey),
+			Model:          fb.Model,
+			RequestTimeout: cfg.RequestTimeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("llm.fallbacks[%d]: %w", i, err)
+		}
+		backends = append(backends, router.Backend{
+			Name:   backendName(fb.Name, fb.Provider, fb.Model),
+			Client: c,
+			Model:  strings.TrimSpace(fb.Model),
+		})
+	}
+	return router.New(backends,
+		router.WithPolicy(viper.GetString("llm.router.policy")),
+		router.WithRetry(retry),
+		router.WithCooldown(viper.GetDuration("llm.router.cooldown")),
+	)
+}
+
+func providerClient(cfg llmconfig.ClientConfig) (llm.Client, error) {
 	toolsEmulationMode, err := toolsEmulationModeFromViper()
 	if err != nil {
 		return nil, err
 	}
 	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
 	case "openai", "openai_custom", "deepseek", "xai", "gemini", "azure", "anthropic", "bedrock", "susanoo":
+		provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
 		c := uniaiProvider.New(uniaiProvider.Config{
-			Provider:           strings.ToLower(strings.TrimSpace(cfg.Provider)),
-			Endpoint:           strings.TrimSpace(cfg.Endpoint),
-			APIKey:             strings.TrimSpace(cfg.APIKey),
-			Model:              strings.TrimSpace(cfg.Model),
-			RequestTimeout:     cfg.RequestTimeout,
-			ToolsEmulationMode: toolsEmulationMode,
-			AzureAPIKey:        firstNonEmpty(viper.GetString("llm.azure.api_key"), viper.GetString("llm.api_key")),
-			AzureEndpoint:      firstNonEmpty(viper.GetString("llm.azure.endpoint"), viper.GetString("llm.endpoint")),
-			AzureDeployment:    firstNonEmpty(viper.GetString("llm.azure.deployment"), viper.GetString("llm.model")),
-			AwsKey:             firstNonEmpty(viper.GetString("llm.bedrock.aws_key"), viper.GetString("llm.aws.key")),
-			AwsSecret:          firstNonEmpty(viper.GetString("llm.bedrock.aws_secret"), viper.GetString("llm.aws.secret")),
-			AwsRegion:          firstNonEmpty(viper.GetString("llm.bedrock.region"), viper.GetString("llm.aws.region")),
-			AwsBedrockModelArn: firstNonEmpty(viper.GetString("llm.bedrock.model_arn"), viper.GetString("llm.aws.bedrock_model_arn")),
+			Provider:              provider,
+			Endpoint:              strings.TrimSpace(cfg.Endpoint),
+			APIKey:                strings.TrimSpace(cfg.APIKey),
+			Model:                 strings.TrimSpace(cfg.Model),
+			RequestTimeout:        cfg.RequestTimeout,
+			ToolsEmulationMode:    toolsEmulationMode,
+			DisableVision:         viper.IsSet("llm.vision") && !viper.GetBool("llm.vision"),
+			DisableJSONSchema:     viper.IsSet("llm.structured_output") && !viper.GetBool("llm.structured_output"),
+			DisablePromptCache:    viper.IsSet("llm.prompt_cache") && !viper.GetBool("llm.prompt_cache"),
+			AnthropicCacheControl: viper.GetBool("llm.anthropic.cache_control"),
+			AzureAPIKey:           firstNonEmpty(viper.GetString("llm.azure.api_key"), viper.GetString("llm.api_key")),
+			AzureEndpoint:         firstNonEmpty(viper.GetString("llm.azure.endpoint"), viper.GetString("llm.endpoint")),
+			AzureDeployment:       firstNonEmpty(viper.GetString("llm.azure.deployment"), viper.GetString("llm.model")),
+			AwsKey:                firstNonEmpty(viper.GetString("llm.bedrock.aws_key"), viper.GetString("llm.aws.key")),
+			AwsSecret:             firstNonEmpty(viper.GetString("llm.bedrock.aws_secret"), viper.GetString("llm.aws.secret")),
+			AwsRegion:             firstNonEmpty(viper.GetString("llm.bedrock.region"), viper.GetString("llm.aws.region")),
+			AwsBedrockModelArn:    firstNonEmpty(viper.GetString("llm.bedrock.model_arn"), viper.GetString("llm.aws.bedrock_model_arn")),
 		})
-		return c, nil
+		table, err := PricingFromViper()
+		if err != nil {
+			return nil, err
+		}
+		return pricing.NewClient(c, provider, strings.TrimSpace(cfg.Model), table), nil
+	case local.KindOllama, local.KindLlamaCpp, local.KindVLLM:
+		return localClient(cfg, toolsEmulationMode)
+	case "fake":
+		return fakeProvider.NewFromFile(cfg.Endpoint)
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", cfg.Provider)
 	}
 }
 
+// backendName labels a backend in logs and metrics as "provider/model"
+// unless a name is configured.
+func backendName(name, provider, model string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	provider = normalizeProvider(provider)
+	if model = strings.TrimSpace(model); model != "" {
+		return provider + "/" + model
+	}
+	return provider
+}
+
+// PricingFromViper reads the llm.pricing table (USD per million tokens).
+func PricingFromViper() (pricing.Table, error) {
+	var table pricing.Table
+	if err := viper.UnmarshalKey("llm.pricing", &table); err != nil {
+		return nil, fmt.Errorf("invalid llm.pricing: %w", err)
+	}
+	return table, nil
+}
+
 func toolsEmulationModeFromViper() (string, error) {
 	mode := strings.ToLower(strings.TrimSpace(viper.GetString("llm.tools_emulation_mode")))
 	if mode == "" {
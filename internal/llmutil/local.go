@@ -0,0 +1,135 @@
+package llmutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/internal/llmconfig"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/llm/pricing"
+	"github.com/quailyquaily/mistermorph/providers/local"
+	uniaiProvider "github.com/quailyquaily/mistermorph/providers/uniai"
+	"github.com/spf13/viper"
+)
+
+// defaultProbeTimeout bounds a capability probe when no request timeout is
+// configured. Probes may load the model, which takes a while on Ollama.
+const defaultProbeTimeout = 90 * time.Second
+
+// probeCache keeps successful probes for the process, so the main client,
+// fallbacks and per-purpose routes on the same server probe it once.
+// probedCompaction is the compaction.max_tokens taken from a probe, if any.
+var (
+	probeMu          sync.Mutex
+	probeCache       = map[string]local.Capabilities{}
+	probedCompaction int
+)
+
+// localClient builds an OpenAI-compatible client for a local inference
+// server, configured from a capability probe: native tools or emulation,
+// the response_format the server accepts, vision, and (when unset) the
+// compaction threshold. When the server cannot be reached the client falls
+// back to tool emulation and tries response formats until one is accepted.
+func localClient(cfg llmconfig.ClientConfig, toolsEmulationMode string) (llm.Client, error) {
+	kind := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	endpoint := firstNonEmpty(cfg.Endpoint, local.DefaultEndpoint(kind))
+	model := strings.TrimSpace(cfg.Model)
+
+	caps, err := probeLocal(kind, endpoint, cfg.APIKey, model, cfg.RequestTimeout)
+	probed := err == nil
+	if err != nil {
+		if errors.Is(err, local.ErrModelNotServed) {
+			return nil, fmt.Errorf("%s at %s: %w", kind, endpoint, err)
+		}
+		slog.Default().Warn("llm_probe_failed", "provider", kind, "endpoint", endpoint, "model", model, "error", err.Error())
+	}
+
+	// Explicit emulation settings win; "off" (the default) lets the probe
+	// decide.
+	if toolsEmulationMode == "off" {
+		switch {
+		case !probed:
+			toolsEmulationMode = "fallback"
+		case !caps.Tools:
+			toolsEmulationMode = "force"
+		}
+	}
+	c := uniaiProvider.New(uniaiProvider.Config{
+		Provider: "openai_custom",
+		Endpoint: endpoint,
+		// OpenAI clients insist on a key; local servers mostly ignore it.
+		APIKey:             firstNonEmpty(cfg.APIKey, kind),
+		Model:              model,
+		RequestTimeout:     cfg.RequestTimeout,
+		ToolsEmulationMode: toolsEmulationMode,
+		DisableVision:      (viper.IsSet("llm.vision") && !viper.GetBool("llm.vision")) || (probed && !caps.Vision),
+		DisableJSONSchema:  viper.IsSet("llm.structured_output") && !viper.GetBool("llm.structured_output"),
+		ResponseFormat:     caps.ResponseFormat,
+	})
+	table, err := PricingFromViper()
+	if err != nil {
+		return nil, err
+	}
+	return pricing.NewClient(c, kind, model, table), nil
+}
+
+func probeLocal(kind, endpoint, apiKey, model string, timeout time.Duration) (local.Capabilities, error) {
+	key := kind + "|" + endpoint + "|" + model
+	probeMu.Lock()
+	defer probeMu.Unlock()
+	if caps, ok := probeCache[key]; ok {
+		return caps, nil
+	}
+
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	caps, err := local.Prober{Kind: kind, Endpoint: endpoint, APIKey: apiKey, Model: model}.Probe(ctx)
+	if err != nil {
+		return local.Capabilities{}, err
+	}
+	probeCache[key] = caps
+
+	log := slog.Default()
+	log.Info("llm_probe",
+		"provider", kind,
+		"endpoint", endpoint,
+		"model", model,
+		"tools", caps.Tools,
+		"response_format", caps.ResponseFormat,
+		"vision", caps.Vision,
+		"context_length", caps.ContextLength,
+	)
+	if n := caps.ContextLength; n > 0 {
+		if limit, ok := compactionFromContext(n); ok {
+			log.Info("compaction_from_context", "provider", kind, "model", model, "context_length", n, "compaction_max_tokens", limit)
+		}
+		if limit := viper.GetInt("compaction.max_tokens"); limit > n {
+			log.Warn("compaction_exceeds_context", "provider", kind, "model", model, "context_length", n, "compaction_max_tokens", limit,
+				"hint", "set compaction.max_tokens below the context length so long runs are compacted before the server truncates them")
+		}
+	}
+	return caps, nil
+}
+
+// compactionFromContext sets compaction.max_tokens to three quarters of a
+// probed context length n, leaving the rest for the reply and the next
+// observation, when it is not configured (0). Of several probed models the
+// smallest window wins. It reports the limit it set.
+func compactionFromContext(n int) (int, bool) {
+	limit := n * 3 / 4
+	cur := viper.GetInt("compaction.max_tokens")
+	if limit <= 0 || (cur != 0 && (cur != probedCompaction || limit >= cur)) {
+		return 0, false
+	}
+	probedCompaction = limit
+	viper.Set("compaction.max_tokens", limit)
+	return limit, true
+}
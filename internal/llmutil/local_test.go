@@ -0,0 +1,30 @@
+package llmutil
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestCompactionFromContext(t *testing.T) {
+	setModelsConfig(t, nil)
+	probedCompaction = 0
+	t.Cleanup(func() { probedCompaction = 0 })
+
+	if limit, ok := compactionFromContext(8192); !ok || limit != 6144 || viper.GetInt("compaction.max_tokens") != 6144 {
+		t.Fatalf("compactionFromContext(8192) = %d, %v; max_tokens = %d", limit, ok, viper.GetInt("compaction.max_tokens"))
+	}
+	// A larger window from a second model keeps the smaller limit; a
+	// smaller one lowers it.
+	if _, ok := compactionFromContext(32768); ok || viper.GetInt("compaction.max_tokens") != 6144 {
+		t.Fatalf("larger window changed max_tokens to %d", viper.GetInt("compaction.max_tokens"))
+	}
+	if limit, ok := compactionFromContext(4096); !ok || limit != 3072 {
+		t.Fatalf("compactionFromContext(4096) = %d, %v", limit, ok)
+	}
+
+	setModelsConfig(t, map[string]any{"compaction.max_tokens": 2000})
+	if _, ok := compactionFromContext(8192); ok || viper.GetInt("compaction.max_tokens") != 2000 {
+		t.Fatalf("configured max_tokens overridden: %d", viper.GetInt("compaction.max_tokens"))
+	}
+}
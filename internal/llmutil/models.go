@@ -0,0 +1,203 @@
+package llmutil
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/internal/llmconfig"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/spf13/viper"
+)
+
+// Purposes of auxiliary LLM calls. Each can be routed to its own provider
+// and model under models.<purpose>; the main loop always uses llm.*.
+const (
+	PurposeIntent       = "intent"
+	PurposeSkills       = "skills"
+	PurposePlan         = "plan"
+	PurposeCompaction   = "compaction"
+	PurposeMemory       = "memory"
+	PurposeAddressing   = "addressing"
+	PurposeReaction     = "reaction"
+	PurposePlanProgress = "plan_progress"
+	PurposeContacts     = "contacts"
+	PurposeMAEPFeedback = "maep_feedback"
+)
+
+var purposes = []string{
+	PurposeIntent, PurposeSkills, PurposePlan, PurposeCompaction, PurposeMemory,
+	PurposeAddressing, PurposeReaction, PurposePlanProgress, PurposeContacts, PurposeMAEPFeedback,
+}
+
+// ModelConfig is one models.<purpose> entry. Setting only model reuses the
+// main client; setting provider (or endpoint/api_key) builds a separate one.
+// ${VAR} in endpoint and api_key is expanded.
+type ModelConfig struct {
+	Provider string `mapstructure:"provider"`
+	Endpoint string `mapstructure:"endpoint"`
+	APIKey   string `mapstructure:"api_key"`
+	Model    string `mapstructure:"model"`
+}
+
+type route struct {
+	client llm.Client
+	model  string
+	// own is true when the route has its own client rather than main.
+	own bool
+}
+
+// Models resolves the client and model for each purpose.
+type Models struct {
+	main      llm.Client
+	mainModel string
+	routes    map[string]route
+}
+
+// ModelsFromViper reads the models section. Unknown purposes are rejected so
+// typos do not silently fall back to the main model. skills.selector_model
+// is honored as the legacy form of models.skills.model.
+func ModelsFromViper(main llm.Client, mainModel string) (*Models, error) {
+	m := &Models{main: main, mainModel: strings.TrimSpace(mainModel), routes: map[string]route{}}
+
+	var entries map[string]ModelConfig
+	if err := viper.UnmarshalKey("models", &entries); err != nil {
+		return nil, fmt.Errorf("invalid models: %w", err)
+	}
+	if legacy := strings.TrimSpace(viper.GetString("skills.selector_model")); legacy != "" {
+		e := entries[PurposeSkills]
+		if strings.TrimSpace(e.Model) == "" {
+			e.Model = legacy
+			if entries == nil {
+				entries = map[string]ModelConfig{}
+			}
+			entries[PurposeSkills] = e
+		}
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		purpose := strings.ToLower(strings.TrimSpace(name))
+		if !isPurpose(purpose) {
+			return nil, fmt.Errorf("models.%s: unknown purpose (expected one of %s)", name, strings.Join(purposes, ", "))
+		}
+		r, err := buildRoute(entries[name], main, m.mainModel)
+		if err != nil {
+			return nil, fmt.Errorf("models.%s: %w", name, err)
+		}
+		m.routes[purpose] = r
+	}
+	return m, nil
+}
+
+func buildRoute(e ModelConfig, main llm.Client, mainModel string) (route, error) {
+	model := strings.TrimSpace(e.Model)
+	provider := strings.TrimSpace(e.Provider)
+	endpoint := strings.TrimSpace(os.ExpandEnv(e.Endpoint))
+	apiKey := strings.TrimSpace(os.ExpandEnv(e.APIKey))
+	if provider == "" && endpoint == "" && apiKey == "" {
+		if model == "" {
+			model = mainModel
+		}
+		return route{client: main, model: model}, nil
+	}
+
+	mainProvider := ProviderFromViper()
+	if provider == "" {
+		provider = mainProvider
+	}
+	if normalizeProvider(provider) == normalizeProvider(mainProvider) {
+		// Same provider: inherit the main endpoint and key unless overridden.
+		endpoint = firstNonEmpty(endpoint, EndpointForProvider(provider))
+		apiKey = firstNonEmpty(apiKey, APIKeyForProvider(provider))
+	}
+	if model == "" {
+		return route{}, fmt.Errorf("model is required when provider, endpoint or api_key is set")
+	}
+	c, err := routeClientFromConfig(llmconfig.ClientConfig{
+		Provider:       provider,
+		Endpoint:       endpoint,
+		APIKey:         apiKey,
+		Model:          model,
+		RequestTimeout: viper.GetDuration("llm.request_timeout"),
+	})
+	if err != nil {
+		return route{}, err
+	}
+	return route{client: c, model: model, own: true}, nil
+}
+
+func isPurpose(name string) bool {
+	for _, p := range purposes {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// For returns the client and model for purpose, or the main ones when the
+// purpose has no entry. A nil Models always returns (nil, "").
+func (m *Models) For(purpose string) (llm.Client, string) {
+	if m == nil {
+		return nil, ""
+	}
+	if r, ok := m.routes[purpose]; ok {
+		return r.client, r.model
+	}
+	return m.main, m.mainModel
+}
+
+// Override returns the purpose's configured client and model, or (nil, "")
+// when it has no entry, for APIs that fall back on their own defaults (see
+// agent.WithIntentModel).
+func (m *Models) Override(purpose string) (llm.Client, string) {
+	if m == nil {
+		return nil, ""
+	}
+	if r, ok := m.routes[purpose]; ok {
+		return r.client, r.model
+	}
+	return nil, ""
+}
+
+// Wrap replaces every purpose-specific client with fn's result, so wrappers
+// applied to the main client (request inspection, cassettes) cover them too.
+// Routes that reuse the main client are left alone.
+func (m *Models) Wrap(fn func(purpose string, c llm.Client) (llm.Client, error)) error {
+	if m == nil || fn == nil {
+		return nil
+	}
+	for purpose, r := range m.routes {
+		if !r.own {
+			continue
+		}
+		c, err := fn(purpose, r.client)
+		if err != nil {
+			return fmt.Errorf("models.%s: %w", purpose, err)
+		}
+		r.client = c
+		m.routes[purpose] = r
+	}
+	return nil
+}
+
+// SetMain replaces the main client, e.g. after wrapping it. Routes that
+// reuse the main client follow.
+func (m *Models) SetMain(c llm.Client) {
+	if m == nil {
+		return
+	}
+	m.main = c
+	for purpose, r := range m.routes {
+		if !r.own {
+			r.client = c
+			m.routes[purpose] = r
+		}
+	}
+}
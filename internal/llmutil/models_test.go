@@ -0,0 +1,99 @@
+package llmutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/internal/llmconfig"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/providers/router"
+	"github.com/spf13/viper"
+)
+
+type stubClient struct{ name string }
+
+func (c *stubClient) Chat(context.Context, llm.Request) (llm.Result, error) {
+	return llm.Result{Text: c.name}, nil
+}
+
+func setModelsConfig(t *testing.T, values map[string]any) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	for k, v := range values {
+		viper.Set(k, v)
+	}
+}
+
+func TestModelsFromViperModelOnlyReusesMain(t *testing.T) {
+	setModelsConfig(t, map[string]any{
+		"models": map[string]any{"intent": map[string]any{"model": "small"}},
+	})
+	main := &stubClient{name: "main"}
+	m, err := ModelsFromViper(main, "big")
+	if err != nil {
+		t.Fatalf("ModelsFromViper: %v", err)
+	}
+	if c, model := m.For(PurposeIntent); c != main || model != "small" {
+		t.Fatalf("For(intent) = %v, %q; want main, small", c, model)
+	}
+	if c, model := m.For(PurposeMemory); c != main || model != "big" {
+		t.Fatalf("For(memory) = %v, %q; want main, big", c, model)
+	}
+	if c, model := m.Override(PurposeMemory); c != nil || model != "" {
+		t.Fatalf("Override(memory) = %v, %q; want nil", c, model)
+	}
+
+	wrapped := &stubClient{name: "wrapped"}
+	m.SetMain(wrapped)
+	if c, _ := m.Override(PurposeIntent); c != wrapped {
+		t.Fatalf("Override(intent) after SetMain = %v, want wrapped", c)
+	}
+}
+
+func TestModelsFromViperLegacySelectorModel(t *testing.T) {
+	setModelsConfig(t, map[string]any{"skills.selector_model": "selector"})
+	m, err := ModelsFromViper(&stubClient{}, "big")
+	if err != nil {
+		t.Fatalf("ModelsFromViper: %v", err)
+	}
+	if _, model := m.For(PurposeSkills); model != "selector" {
+		t.Fatalf("For(skills) model = %q, want selector", model)
+	}
+}
+
+func TestModelsFromViperRejectsUnknownPurpose(t *testing.T) {
+	setModelsConfig(t, map[string]any{
+		"models": map[string]any{"intnet": map[string]any{"model": "small"}},
+	})
+	_, err := ModelsFromViper(&stubClient{}, "big")
+	if err == nil || !strings.Contains(err.Error(), "unknown purpose") {
+		t.Fatalf("err = %v, want unknown purpose", err)
+	}
+}
+
+func TestModelsFromViperRoutesDoNotUseFallbacks(t *testing.T) {
+	setModelsConfig(t, map[string]any{
+		"llm.provider":           "openai",
+		"llm.api_key":            "k",
+		"llm.retry.max_attempts": 1,
+		"llm.fallbacks":          []map[string]any{{"provider": "openai", "model": "backup", "api_key": "k2"}},
+		"models":                 map[string]any{"intent": map[string]any{"provider": "openai", "model": "small"}},
+	})
+	main, err := ClientFromConfig(llmconfig.ClientConfig{Provider: "openai", APIKey: "k", Model: "big"})
+	if err != nil {
+		t.Fatalf("ClientFromConfig: %v", err)
+	}
+	if _, ok := main.(*router.Client); !ok {
+		t.Fatalf("main client = %T, want a router with the fallback", main)
+	}
+	m, err := ModelsFromViper(main, "big")
+	if err != nil {
+		t.Fatalf("ModelsFromViper: %v", err)
+	}
+	c, model := m.For(PurposeIntent)
+	if _, ok := c.(*router.Client); ok || model != "small" {
+		t.Fatalf("For(intent) = %T, %q; want a plain provider client for small", c, model)
+	}
+}
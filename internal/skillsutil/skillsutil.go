@@ -43,9 +43,13 @@ func SkillsConfigFromViper(model string) SkillsConfig {
 		PreviewBytes:  viper.GetInt64("skills.preview_bytes"),
 		CatalogLimit:  viper.GetInt("skills.catalog_limit"),
 		SelectTimeout: viper.GetDuration("llm.request_timeout"),
-		SelectorModel: strings.TrimSpace(viper.GetString("skills.selector_model")),
+		SelectorModel: strings.TrimSpace(viper.GetString("models.skills.model")),
 		Trace:         strings.EqualFold(strings.TrimSpace(viper.GetString("logging.level")), "debug"),
 	}
+	if cfg.SelectorModel == "" {
+		// Legacy key, superseded by models.skills.model.
+		cfg.SelectorModel = strings.TrimSpace(viper.GetString("skills.selector_model"))
+	}
 	cfg.Requested = append(cfg.Requested, getStringSlice("skills.load")...)
 	if strings.TrimSpace(cfg.Mode) == "" {
 		cfg.Mode = "smart"
@@ -47,6 +47,30 @@ func ContactsDir() string {
 	)
 }
 
+func CheckpointsDir() string {
+	return pathutil.ResolveStateChildDir(
+		viper.GetString("file_state_dir"),
+		viper.GetString("checkpoint.dir_name"),
+		"checkpoints",
+	)
+}
+
+func TokenizersDir() string {
+	return pathutil.ResolveStateChildDir(
+		viper.GetString("file_state_dir"),
+		viper.GetString("tokenizers.dir_name"),
+		"tokenizers",
+	)
+}
+
+func DaemonDir() string {
+	return pathutil.ResolveStateChildDir(
+		viper.GetString("file_state_dir"),
+		viper.GetString("server.dir_name"),
+		"daemon",
+	)
+}
+
 func HeartbeatChecklistPath() string {
 	return pathutil.ResolveStateFile(viper.GetString("file_state_dir"), HeartbeatChecklistFilename)
 }
@@ -10,6 +10,13 @@ type Message struct {
 	Content    string     `json:"content"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	// Parts follow Content in user messages: images and file references
+	// (see ContentPart). Providers without vision get TextContent instead.
+	Parts []ContentPart `json:"parts,omitempty"`
+	// Cache marks the end of a prompt prefix that stays the same across
+	// requests (the system prompt, prior history). Providers with prompt
+	// caching may cache everything up to and including this message.
+	Cache bool `json:"cache,omitempty"`
 }
 
 type Tool struct {
@@ -28,7 +35,10 @@ type Usage struct {
 	InputTokens  int
 	OutputTokens int
 	TotalTokens  int
-	Cost         float64 // USD
+	// CachedInputTokens is the part of InputTokens served from the
+	// provider's prompt cache, when reported.
+	CachedInputTokens int
+	Cost              float64 // USD
 }
 
 type Result struct {
@@ -37,6 +47,9 @@ type Result struct {
 	ToolCalls []ToolCall
 	Usage     Usage
 	Duration  time.Duration
+	// Backend names the backend that served the call, when the client
+	// routes between several (see providers/router).
+	Backend string
 }
 
 type Request struct {
@@ -44,6 +57,7 @@ type Request struct {
 	Messages   []Message
 	Tools      []Tool
 	ForceJSON  bool
+	Schema     *JSONSchema
 	Parameters map[string]any
 }
 
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	PartText  = "text"
+	PartImage = "image"
+	PartFile  = "file"
+)
+
+// ContentPart is one typed piece of a message beyond its plain Content.
+// Images and files name their source with exactly one of Path (a local
+// file), URL or Data (base64, with MimeType).
+type ContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Path     string `json:"path,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	// Name is a display name (e.g. the original file name).
+	Name string `json:"name,omitempty"`
+}
+
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: PartText, Text: text}
+}
+
+func ImagePath(path string) ContentPart {
+	return ContentPart{Type: PartImage, Path: path}
+}
+
+func ImageURL(url string) ContentPart {
+	return ContentPart{Type: PartImage, URL: url}
+}
+
+func ImageData(mimeType, base64Data string) ContentPart {
+	return ContentPart{Type: PartImage, MimeType: mimeType, Data: base64Data}
+}
+
+func FilePath(path, name string) ContentPart {
+	return ContentPart{Type: PartFile, Path: path, Name: name}
+}
+
+// Source describes where an image or file part comes from, for logs and
+// text-only fallbacks.
+func (p ContentPart) Source() string {
+	switch {
+	case strings.TrimSpace(p.Path) != "":
+		return strings.TrimSpace(p.Path)
+	case strings.TrimSpace(p.URL) != "":
+		return strings.TrimSpace(p.URL)
+	case p.Data != "":
+		mime := strings.TrimSpace(p.MimeType)
+		if mime == "" {
+			mime = "application/octet-stream"
+		}
+		return fmt.Sprintf("inline %s, %d base64 bytes", mime, len(p.Data))
+	}
+	return ""
+}
+
+// Placeholder renders a non-text part as a short text reference.
+func (p ContentPart) Placeholder() string {
+	label := p.Type
+	if name := strings.TrimSpace(p.Name); name != "" {
+		label += " " + name
+	}
+	if src := p.Source(); src != "" {
+		return fmt.Sprintf("[%s: %s]", label, src)
+	}
+	return fmt.Sprintf("[%s]", label)
+}
+
+// HasImages reports whether the message carries image parts.
+func (m Message) HasImages() bool {
+	for _, p := range m.Parts {
+		if p.Type == PartImage {
+			return true
+		}
+	}
+	return false
+}
+
+// TextContent flattens Content and Parts into plain text, replacing images
+// and files with placeholders. Clients without multimodal support send this.
+func (m Message) TextContent() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	chunks := make([]string, 0, len(m.Parts)+1)
+	if strings.TrimSpace(m.Content) != "" {
+		chunks = append(chunks, m.Content)
+	}
+	for _, p := range m.Parts {
+		if p.Type == PartText {
+			if strings.TrimSpace(p.Text) != "" {
+				chunks = append(chunks, p.Text)
+			}
+			continue
+		}
+		chunks = append(chunks, p.Placeholder())
+	}
+	return strings.Join(chunks, "\n")
+}
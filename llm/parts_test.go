@@ -0,0 +1,21 @@
+package llm
+
+import "testing"
+
+func TestMessageTextContent(t *testing.T) {
+	m := Message{Role: "user", Content: "look", Parts: []ContentPart{
+		TextPart("at this"),
+		{Type: PartImage, Path: "/tmp/cat.png", Name: "cat.png"},
+		FilePath("/tmp/a.pdf", ""),
+	}}
+	want := "look\nat this\n[image cat.png: /tmp/cat.png]\n[file: /tmp/a.pdf]"
+	if got := m.TextContent(); got != want {
+		t.Fatalf("TextContent() = %q, want %q", got, want)
+	}
+	if !m.HasImages() {
+		t.Fatalf("HasImages() = false")
+	}
+	if (Message{Content: "plain"}).TextContent() != "plain" {
+		t.Fatalf("plain message changed")
+	}
+}
@@ -0,0 +1,132 @@
+// Package pricing turns token usage into USD cost using a per-model price
+// table.
+package pricing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// Price holds USD rates per million tokens for one provider/model. Model is
+// an exact name or a prefix ending in "*"; an empty Provider matches any
+// provider.
+type Price struct {
+	Provider string  `mapstructure:"provider"`
+	Model    string  `mapstructure:"model"`
+	Input    float64 `mapstructure:"input"`
+	Output   float64 `mapstructure:"output"`
+	// CachedInput applies to cached prompt tokens; 0 means the Input rate.
+	CachedInput float64 `mapstructure:"cached_input"`
+}
+
+// Cost returns the USD cost of u at this price.
+func (p Price) Cost(u llm.Usage) float64 {
+	cached := u.CachedInputTokens
+	if cached > u.InputTokens {
+		cached = u.InputTokens
+	}
+	if cached < 0 {
+		cached = 0
+	}
+	cachedRate := p.CachedInput
+	if cachedRate == 0 {
+		cachedRate = p.Input
+	}
+	return (float64(u.InputTokens-cached)*p.Input +
+		float64(cached)*cachedRate +
+		float64(u.OutputTokens)*p.Output) / 1e6
+}
+
+type Table []Price
+
+// Lookup finds the price for provider/model. Exact model names win over
+// prefixes, longer prefixes over shorter ones, and provider-specific entries
+// over provider-less ones.
+func (t Table) Lookup(provider, model string) (Price, bool) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	model = strings.ToLower(strings.TrimSpace(model))
+	if model == "" {
+		return Price{}, false
+	}
+	best, bestScore := Price{}, -1
+	for _, p := range t {
+		pp := strings.ToLower(strings.TrimSpace(p.Provider))
+		if pp != "" && pp != provider {
+			continue
+		}
+		pm := strings.ToLower(strings.TrimSpace(p.Model))
+		score := 0
+		switch {
+		case pm == model:
+			score = 1 << 20
+		case strings.HasSuffix(pm, "*") && strings.HasPrefix(model, strings.TrimSuffix(pm, "*")):
+			score = len(pm) << 1
+		default:
+			continue
+		}
+		if pp != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// Client fills Usage.Cost on results from base using the price table. Costs
+// a provider already reported are kept.
+type Client struct {
+	base     llm.Client
+	provider string
+	model    string
+	table    Table
+}
+
+// NewClient wraps base; model is used when a request leaves Model empty.
+// It returns base unchanged when the table is empty.
+func NewClient(base llm.Client, provider, model string, table Table) llm.Client {
+	if len(table) == 0 {
+		return base
+	}
+	return &Client{base: base, provider: provider, model: model, table: table}
+}
+
+func (c *Client) Chat(ctx context.Context, req llm.Request) (llm.Result, error) {
+	res, err := c.base.Chat(ctx, req)
+	if err == nil {
+		c.price(req, &res)
+	}
+	return res, err
+}
+
+func (c *Client) ChatStream(ctx context.Context, req llm.Request, onEvent llm.StreamHandler) (llm.Result, error) {
+	res, err := llm.ChatStream(ctx, c.base, req, onEvent)
+	if err == nil {
+		c.price(req, &res)
+	}
+	return res, err
+}
+
+func (c *Client) SetDebugFn(fn func(label, payload string)) {
+	if setter, ok := c.base.(interface {
+		SetDebugFn(func(label, payload string))
+	}); ok {
+		setter.SetDebugFn(fn)
+	}
+}
+
+func (c *Client) price(req llm.Request, res *llm.Result) {
+	if res.Usage.Cost != 0 {
+		return
+	}
+	model := strings.TrimSpace(req.Model)
+	if model == "" {
+		model = c.model
+	}
+	if p, ok := c.table.Lookup(c.provider, model); ok {
+		res.Usage.Cost = p.Cost(res.Usage)
+	}
+}
@@ -0,0 +1,74 @@
+package pricing
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+func TestLookupPrecedence(t *testing.T) {
+	table := Table{
+		{Model: "gpt-*", Input: 1},
+		{Model: "gpt-5*", Input: 2},
+		{Provider: "azure", Model: "gpt-5*", Input: 3},
+		{Model: "gpt-5.2", Input: 4},
+	}
+	cases := []struct {
+		provider, model string
+		want            float64
+	}{
+		{"openai", "gpt-4o", 1},
+		{"openai", "gpt-5-mini", 2},
+		{"azure", "gpt-5-mini", 3},
+		{"azure", "GPT-5.2", 4},
+	}
+	for _, tc := range cases {
+		p, ok := table.Lookup(tc.provider, tc.model)
+		if !ok || p.Input != tc.want {
+			t.Errorf("Lookup(%s, %s) = %v, %v; want input %v", tc.provider, tc.model, p.Input, ok, tc.want)
+		}
+	}
+	if _, ok := table.Lookup("openai", "o3"); ok {
+		t.Errorf("Lookup(o3) should not match")
+	}
+}
+
+func TestCost(t *testing.T) {
+	p := Price{Input: 2, Output: 10, CachedInput: 0.5}
+	got := p.Cost(llm.Usage{InputTokens: 1_000_000, CachedInputTokens: 400_000, OutputTokens: 100_000})
+	// 600k*2 + 400k*0.5 + 100k*10, per million.
+	if want := 1.2 + 0.2 + 1.0; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("Cost() = %v, want %v", got, want)
+	}
+	if got := (Price{Input: 2}).Cost(llm.Usage{InputTokens: 500_000, CachedInputTokens: 500_000}); math.Abs(got-1) > 1e-9 {
+		t.Fatalf("Cost() without cached rate = %v, want 1", got)
+	}
+}
+
+type usageClient struct{ usage llm.Usage }
+
+func (c usageClient) Chat(context.Context, llm.Request) (llm.Result, error) {
+	return llm.Result{Usage: c.usage}, nil
+}
+
+func TestClientFillsCost(t *testing.T) {
+	table := Table{{Provider: "openai", Model: "gpt-5.2", Input: 1, Output: 2}}
+	c := NewClient(usageClient{llm.Usage{InputTokens: 1000, OutputTokens: 500}}, "openai", "gpt-5.2", table)
+	res, err := c.Chat(context.Background(), llm.Request{})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if math.Abs(res.Usage.Cost-0.002) > 1e-12 {
+		t.Fatalf("Cost = %v, want 0.002", res.Usage.Cost)
+	}
+
+	reported := NewClient(usageClient{llm.Usage{InputTokens: 1000, Cost: 0.5}}, "openai", "gpt-5.2", table)
+	if res, _ := reported.Chat(context.Background(), llm.Request{}); res.Usage.Cost != 0.5 {
+		t.Fatalf("provider-reported cost overwritten: %v", res.Usage.Cost)
+	}
+	if base := (usageClient{}); NewClient(base, "openai", "m", nil) != llm.Client(base) {
+		t.Fatalf("NewClient with empty table should return base")
+	}
+}
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"reflect"
+	"strings"
+)
+
+// JSONSchema asks the provider for structured output matching Schema.
+// Providers without structured output support fall back to plain JSON mode,
+// so callers still parse (and validate) the response themselves.
+type JSONSchema struct {
+	// Name identifies the schema to the provider ([a-zA-Z0-9_-]).
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema"`
+	// Strict requests exact schema adherence. Strict schemas must list every
+	// property as required and forbid additional properties.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// SchemaFor derives a JSON schema from the type of v (a struct or pointer to
+// one). Fields are named by their json tags and all of them are required.
+// The schema is strict unless v holds maps or interface values, whose shape
+// a strict schema cannot describe.
+func SchemaFor(name string, v any) *JSONSchema {
+	b := schemaBuilder{strict: true}
+	schema := b.build(reflect.TypeOf(v))
+	return &JSONSchema{Name: name, Schema: schema, Strict: b.strict}
+}
+
+type schemaBuilder struct {
+	strict bool
+}
+
+func (b *schemaBuilder) build(t reflect.Type) map[string]any {
+	if t == nil {
+		b.strict = false
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.build(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = b.build(f.Type)
+			required = append(required, name)
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		b.strict = false
+		return map[string]any{"type": "object", "additionalProperties": b.build(t.Elem())}
+	default:
+		b.strict = false
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaFor(t *testing.T) {
+	type item struct {
+		Title string `json:"title"`
+		Done  bool   `json:"done,omitempty"`
+		skip  string
+	}
+	type draft struct {
+		Summary string   `json:"summary"`
+		Score   float64  `json:"score"`
+		Count   int      `json:"count"`
+		Items   []item   `json:"items"`
+		Tags    []string `json:"tags"`
+		Ignored string   `json:"-"`
+	}
+	s := SchemaFor("draft", draft{})
+	if s.Name != "draft" || !s.Strict {
+		t.Fatalf("schema = %+v, want strict draft", s)
+	}
+	if got := s.Schema["required"]; !reflect.DeepEqual(got, []string{"summary", "score", "count", "items", "tags"}) {
+		t.Fatalf("required = %v", got)
+	}
+	props := s.Schema["properties"].(map[string]any)
+	if props["score"].(map[string]any)["type"] != "number" || props["count"].(map[string]any)["type"] != "integer" {
+		t.Fatalf("props = %v", props)
+	}
+	items := props["items"].(map[string]any)["items"].(map[string]any)
+	if items["additionalProperties"] != false || !reflect.DeepEqual(items["required"], []string{"title", "done"}) {
+		t.Fatalf("items = %v", items)
+	}
+}
+
+func TestSchemaForFreeFormIsNotStrict(t *testing.T) {
+	type payload struct {
+		Output any            `json:"output"`
+		Params map[string]any `json:"params"`
+	}
+	if s := SchemaFor("payload", &payload{}); s.Strict {
+		t.Fatalf("schema with free-form values must not be strict")
+	}
+}
@@ -0,0 +1,54 @@
+package llm
+
+import "context"
+
+const (
+	StreamEventText     = "text"
+	StreamEventToolCall = "tool_call"
+)
+
+// StreamEvent is one incremental chunk of a streamed chat response.
+// For text events, Text holds the newly generated characters.
+// For tool_call events, ToolCallIndex identifies the call being built and
+// ArgumentsDelta holds the next fragment of its JSON arguments.
+type StreamEvent struct {
+	Type string
+
+	Text string
+
+	ToolCallIndex  int
+	ToolCallID     string
+	ToolName       string
+	ArgumentsDelta string
+}
+
+type StreamHandler func(StreamEvent)
+
+// StreamingClient is implemented by clients that can emit partial output
+// while a response is generated. The returned Result is the same aggregate
+// Chat would have returned.
+type StreamingClient interface {
+	Client
+	ChatStream(ctx context.Context, req Request, onEvent StreamHandler) (Result, error)
+}
+
+// ChatStream streams when client supports it and falls back to Chat,
+// emitting the complete text as a single event.
+func ChatStream(ctx context.Context, client Client, req Request, onEvent StreamHandler) (Result, error) {
+	if sc, ok := client.(StreamingClient); ok && onEvent != nil {
+		return sc.ChatStream(ctx, req, onEvent)
+	}
+	result, err := client.Chat(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	if onEvent != nil {
+		if result.Text != "" {
+			onEvent(StreamEvent{Type: StreamEventText, Text: result.Text})
+		}
+		for i, call := range result.ToolCalls {
+			onEvent(StreamEvent{Type: StreamEventToolCall, ToolCallIndex: i, ToolCallID: call.ID, ToolName: call.Name})
+		}
+	}
+	return result, nil
+}
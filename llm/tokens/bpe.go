@@ -0,0 +1,364 @@
+package tokens
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Vocabularies of the OpenAI model families, named like tiktoken's
+// encodings. SetVocabularyDir looks for <name>.tiktoken files.
+const (
+	O200KBase  = "o200k_base"
+	CL100KBase = "cl100k_base"
+)
+
+// maxBPEPiece bounds the bytes merged at once. Byte pair merging is
+// quadratic in the piece length, so longer pieces (such as a long run of
+// spaces or dashes) are counted in chunks of this size.
+const maxBPEPiece = 2048
+
+var vocabularies struct {
+	mu   sync.Mutex
+	dir  string
+	encs map[string]*bpeEncoder
+}
+
+// SetVocabularyDir makes the OpenAI model families count tokens with BPE,
+// using the tiktoken files o200k_base.tiktoken and cl100k_base.tiktoken
+// from dir. A file is loaded on first use; while it is missing or
+// unreadable its family keeps the shape estimator. An empty dir turns BPE
+// off.
+func SetVocabularyDir(dir string) {
+	vocabularies.mu.Lock()
+	defer vocabularies.mu.Unlock()
+	vocabularies.dir = dir
+	vocabularies.encs = nil
+}
+
+// bpeFor returns the encoder of vocabulary name, or nil when it is not
+// available. A failed load is not retried until SetVocabularyDir is called
+// again.
+func bpeFor(name string) *bpeEncoder {
+	vocabularies.mu.Lock()
+	defer vocabularies.mu.Unlock()
+	if vocabularies.dir == "" {
+		return nil
+	}
+	if enc, ok := vocabularies.encs[name]; ok {
+		return enc
+	}
+	if vocabularies.encs == nil {
+		vocabularies.encs = map[string]*bpeEncoder{}
+	}
+	var enc *bpeEncoder
+	if ranks, err := loadVocabulary(filepath.Join(vocabularies.dir, name+".tiktoken")); err == nil {
+		enc = newBPEEncoder(name, ranks)
+	}
+	vocabularies.encs[name] = enc
+	return enc
+}
+
+// loadVocabulary reads a tiktoken vocabulary file: one base64-encoded token
+// and its rank per line.
+func loadVocabulary(path string) (map[string]int, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ranks := make(map[string]int, bytes.Count(raw, []byte{'\n'})+1)
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	for line := 1; sc.Scan(); line++ {
+		fields := bytes.Fields(sc.Bytes())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a token and a rank", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("%s: empty vocabulary", path)
+	}
+	return ranks, nil
+}
+
+// bpeEncoder counts tokens the way tiktoken encodes them: text is split by
+// the encoding's pre-tokenizer and each piece is byte pair merged by rank.
+type bpeEncoder struct {
+	ranks map[string]int
+	next  func(r []rune, i int) int
+}
+
+func newBPEEncoder(name string, ranks map[string]int) *bpeEncoder {
+	next := nextPieceCL100K
+	if name == O200KBase {
+		next = nextPieceO200K
+	}
+	return &bpeEncoder{ranks: ranks, next: next}
+}
+
+func (b *bpeEncoder) Count(text string) int {
+	runes := []rune(text)
+	n := 0
+	for i := 0; i < len(runes); {
+		j := i + b.next(runes, i)
+		piece := []byte(string(runes[i:j]))
+		for len(piece) > maxBPEPiece {
+			cut := maxBPEPiece
+			for cut > 0 && !utf8.RuneStart(piece[cut]) {
+				cut--
+			}
+			n += b.pieceTokens(piece[:cut])
+			piece = piece[cut:]
+		}
+		n += b.pieceTokens(piece)
+		i = j
+	}
+	return n
+}
+
+// pieceTokens byte pair merges piece: the adjacent pair with the lowest
+// rank is merged until no pair is in the vocabulary.
+func (b *bpeEncoder) pieceTokens(piece []byte) int {
+	if len(piece) == 0 {
+		return 0
+	}
+	if _, ok := b.ranks[string(piece)]; ok {
+		return 1
+	}
+	// bounds[k] is where the k-th part starts; the last entry is len(piece).
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := math.MaxInt, -1
+		for k := 0; k+2 < len(bounds); k++ {
+			if rank, ok := b.ranks[string(piece[bounds[k]:bounds[k+2]])]; ok && rank < best {
+				best, at = rank, k
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// nextPieceCL100K returns the length in runes of the piece starting at
+// r[i], following cl100k_base's pre-tokenizer:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}|
+//	 ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func nextPieceCL100K(r []rune, i int) int {
+	if n := contractionAt(r, i); n > 0 {
+		return n
+	}
+	start := i
+	if canLeadWord(r[i]) && i+1 < len(r) && unicode.IsLetter(r[i+1]) {
+		start = i + 1
+	}
+	if unicode.IsLetter(r[start]) {
+		j := start
+		for j < len(r) && unicode.IsLetter(r[j]) {
+			j++
+		}
+		return j - i
+	}
+	if n := numberAt(r, i); n > 0 {
+		return n
+	}
+	if n := punctuationAt(r, i, "\r\n"); n > 0 {
+		return n
+	}
+	return spaceAt(r, i)
+}
+
+// nextPieceO200K returns the length in runes of the piece starting at
+// r[i], following o200k_base's pre-tokenizer:
+//
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?|
+//	\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+
+func nextPieceO200K(r []rune, i int) int {
+	starts := []int{i}
+	if canLeadWord(r[i]) && i+1 < len(r) {
+		starts = []int{i + 1, i}
+	}
+	for _, s := range starts {
+		if end := lowerWordEnd(r, s); end > 0 {
+			return end - i
+		}
+	}
+	for _, s := range starts {
+		if end := upperWordEnd(r, s); end > 0 {
+			return end - i
+		}
+	}
+	if n := numberAt(r, i); n > 0 {
+		return n
+	}
+	if n := punctuationAt(r, i, "\r\n/"); n > 0 {
+		return n
+	}
+	return spaceAt(r, i)
+}
+
+// lowerWordEnd matches [upper]*[lower]+ contraction? at r[s] and returns
+// where it ends, or -1. The upper run gives back characters (both sets
+// share Lm, Lo and M) until a lower one can follow.
+func lowerWordEnd(r []rune, s int) int {
+	u := s
+	for u < len(r) && isUpperCased(r[u]) {
+		u++
+	}
+	for k := u; k >= s; k-- {
+		if k < len(r) && isLowerCased(r[k]) {
+			end := k
+			for end < len(r) && isLowerCased(r[end]) {
+				end++
+			}
+			return end + contractionAt(r, end)
+		}
+	}
+	return -1
+}
+
+// upperWordEnd matches [upper]+[lower]* contraction? at r[s].
+func upperWordEnd(r []rune, s int) int {
+	end := s
+	for end < len(r) && isUpperCased(r[end]) {
+		end++
+	}
+	if end == s {
+		return -1
+	}
+	for end < len(r) && isLowerCased(r[end]) {
+		end++
+	}
+	return end + contractionAt(r, end)
+}
+
+func isUpperCased(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+func isLowerCased(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// canLeadWord reports [^\r\n\p{L}\p{N}], the optional character a word
+// piece may start with.
+func canLeadWord(r rune) bool {
+	return r != '\r' && r != '\n' && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+func isPunctuation(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// contractionAt matches (?i:'s|'t|'re|'ve|'m|'ll|'d) at r[i].
+func contractionAt(r []rune, i int) int {
+	if i+1 >= len(r) || r[i] != '\'' {
+		return 0
+	}
+	switch a := r[i+1]; {
+	case foldsTo(a, 's'), foldsTo(a, 't'), foldsTo(a, 'm'), foldsTo(a, 'd'):
+		return 2
+	case i+2 < len(r) && (foldsTo(a, 'r') || foldsTo(a, 'v')) && foldsTo(r[i+2], 'e'):
+		return 3
+	case i+2 < len(r) && foldsTo(a, 'l') && foldsTo(r[i+2], 'l'):
+		return 3
+	}
+	return 0
+}
+
+// foldsTo reports whether r matches c case-insensitively (including
+// Unicode folds such as ſ for s).
+func foldsTo(r, c rune) bool {
+	for f := c; ; {
+		if f == r {
+			return true
+		}
+		if f = unicode.SimpleFold(f); f == c {
+			return false
+		}
+	}
+}
+
+// numberAt matches \p{N}{1,3}.
+func numberAt(r []rune, i int) int {
+	j := i
+	for j < len(r) && j-i < 3 && unicode.IsNumber(r[j]) {
+		j++
+	}
+	return j - i
+}
+
+// punctuationAt matches ` ?[^\s\p{L}\p{N}]+[<trail>]*`.
+func punctuationAt(r []rune, i int, trail string) int {
+	j := i
+	if r[j] == ' ' && j+1 < len(r) && isPunctuation(r[j+1]) {
+		j++
+	}
+	if !isPunctuation(r[j]) {
+		return 0
+	}
+	for j < len(r) && isPunctuation(r[j]) {
+		j++
+	}
+	for j < len(r) && containsRune(trail, r[j]) {
+		j++
+	}
+	return j - i
+}
+
+// spaceAt matches \s*[\r\n]+|\s+(?!\S)|\s+ at a whitespace rune: a run
+// with line breaks ends after the last one, and otherwise the last space
+// before a non-space is left to lead the next piece.
+func spaceAt(r []rune, i int) int {
+	j := i
+	for j < len(r) && unicode.IsSpace(r[j]) {
+		j++
+	}
+	for k := j - 1; k >= i; k-- {
+		if r[k] == '\r' || r[k] == '\n' {
+			return k + 1 - i
+		}
+	}
+	if j == len(r) || j-i <= 1 {
+		return max(j-i, 1)
+	}
+	return j - i - 1
+}
+
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func splitPieces(next func([]rune, int) int, text string) []string {
+	r := []rune(text)
+	var out []string
+	for i := 0; i < len(r); {
+		j := i + next(r, i)
+		out = append(out, string(r[i:j]))
+		i = j
+	}
+	return out
+}
+
+func TestPreTokenizers(t *testing.T) {
+	cases := []struct {
+		next func([]rune, int) int
+		text string
+		want []string
+	}{
+		{nextPieceCL100K, "I'm sure it's 2024 now.", []string{"I", "'m", " sure", " it", "'s", " ", "202", "4", " now", "."}},
+		{nextPieceCL100K, "hello   world\n\n", []string{"hello", "  ", " world", "\n\n"}},
+		{nextPieceCL100K, "x = f(a);\r\n\tend", []string{"x", " =", " f", "(a", ");\r\n", "\tend"}},
+		{nextPieceO200K, "I'm sure it's 2024 now.", []string{"I'm", " sure", " it's", " ", "202", "4", " now", "."}},
+		{nextPieceO200K, "HELLO world", []string{"HELLO", " world"}},
+		{nextPieceO200K, "getHTTPResponse", []string{"get", "HTTPResponse"}},
+		{nextPieceO200K, "path/to\n", []string{"path", "/to", "\n"}},
+		{nextPieceO200K, "你好，世界", []string{"你好", "，世界"}},
+	}
+	for _, tc := range cases {
+		if got := splitPieces(tc.next, tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("split(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestBPEMergesByRank(t *testing.T) {
+	enc := newBPEEncoder(CL100KBase, map[string]int{"a": 0, "b": 1, "c": 2, "ab": 3, "bc": 4, "abc": 5})
+	// "abcb" merges ab, then abc, leaving b; " abc" leaves the unknown space.
+	if got := enc.Count("abcb abc"); got != 4 {
+		t.Fatalf("Count() = %d, want 4", got)
+	}
+	if got := enc.Count(strings.Repeat("ab", maxBPEPiece)); got != maxBPEPiece/2*2 {
+		t.Fatalf("long piece Count() = %d", got)
+	}
+}
+
+func TestSetVocabularyDir(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i, tok := range []string{"a", "b", "ab", " ", " ab"} {
+		lines = append(lines, fmt.Sprintf("%s %d", base64.StdEncoding.EncodeToString([]byte(tok)), i))
+	}
+	if err := os.WriteFile(filepath.Join(dir, O200KBase+".tiktoken"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	SetVocabularyDir(dir)
+	t.Cleanup(func() { SetVocabularyDir("") })
+
+	if _, ok := ForModel("gpt-4o").(*bpeEncoder); !ok {
+		t.Fatalf("ForModel(gpt-4o) = %#v, want the BPE encoder", ForModel("gpt-4o"))
+	}
+	if got := Count("gpt-4o", "ab ab"); got != 2 {
+		t.Fatalf("Count() = %d, want 2", got)
+	}
+	// No cl100k_base file: the shape estimator stays in place.
+	if got := ForModel("gpt-4"); got != cl100k {
+		t.Fatalf("ForModel(gpt-4) = %#v, want the shape estimator", got)
+	}
+}
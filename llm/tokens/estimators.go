@@ -0,0 +1,149 @@
+package tokens
+
+import (
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// heuristicEstimator counts ASCII by characters per token and counts
+// non-ASCII runes, which tokenizers split much finer, individually.
+type heuristicEstimator struct {
+	charsPerToken float64
+}
+
+func (h heuristicEstimator) Count(text string) int {
+	var ascii, wide, other int
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case isWide(r):
+			wide++
+		default:
+			other++
+		}
+	}
+	n := float64(ascii)/h.charsPerToken + float64(wide) + float64(other)/2
+	return int(math.Ceil(n))
+}
+
+// shapeEstimator imitates the counts of a tiktoken-style BPE tokenizer
+// without its vocabulary; it stands in for bpeEncoder while the vocabulary
+// files are not installed. Text is split into pieces resembling those of
+// tiktoken's pre-tokenizer (words with their leading space, contractions,
+// digit groups, punctuation runs, whitespace) and each piece is priced by
+// its shape: short ASCII words are one token, longer ones one per wordChars
+// characters, digits one per three, CJK and other non-ASCII letters by a
+// per-rune ratio.
+type shapeEstimator struct {
+	wordChars float64
+	wideRune  float64
+	otherRune float64
+}
+
+func (b shapeEstimator) Count(text string) int {
+	runes := []rune(text)
+	total := 0.0
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\'' && contractionLen(runes[i+1:]) > 0:
+			// 's 't 'd 'm 're 've 'll
+			i += 1 + contractionLen(runes[i+1:])
+			total++
+		case unicode.IsLetter(r) || (!unicode.IsDigit(r) && r != '\n' && r != '\r' && i+1 < len(runes) && unicode.IsLetter(runes[i+1])):
+			// A word, optionally led by one space or punctuation mark.
+			j := i + 1
+			for j < len(runes) && unicode.IsLetter(runes[j]) {
+				j++
+			}
+			total += b.word(runes[i:j])
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			total += math.Ceil(float64(j-i) / 3)
+			i = j
+		case unicode.IsSpace(r):
+			j := i
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+			// A single space before a word or number belongs to it.
+			if j-i == 1 && r == ' ' && j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				i = j
+				continue
+			}
+			total++
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !unicode.IsLetter(runes[j]) && !unicode.IsDigit(runes[j]) {
+				j++
+			}
+			// Common punctuation pairs ("),", "{\"", "=>") merge.
+			total += math.Ceil(float64(j-i) / 2)
+			i = j
+		}
+	}
+	return int(math.Ceil(total))
+}
+
+func (b shapeEstimator) word(w []rune) float64 {
+	letters := 0
+	var wide, other float64
+	for _, r := range w {
+		switch {
+		case r < utf8.RuneSelf:
+			if unicode.IsLetter(r) {
+				letters++
+			}
+		case isWide(r):
+			wide++
+		default:
+			other++
+		}
+	}
+	n := wide*b.wideRune + other*b.otherRune
+	if letters > 0 {
+		if letters <= 6 {
+			n++
+		} else {
+			n += 1 + math.Ceil(float64(letters-6)/b.wordChars)
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// contractionLen returns the length of the contraction suffix rest starts
+// with, or 0.
+func contractionLen(rest []rune) int {
+	if len(rest) == 0 {
+		return 0
+	}
+	switch unicode.ToLower(rest[0]) {
+	case 's', 't', 'd', 'm':
+		return 1
+	case 'r', 'v':
+		if len(rest) > 1 && unicode.ToLower(rest[1]) == 'e' {
+			return 2
+		}
+	case 'l':
+		if len(rest) > 1 && unicode.ToLower(rest[1]) == 'l' {
+			return 2
+		}
+	}
+	return 0
+}
+
+// isWide reports CJK, kana, hangul and similar scripts that tokenizers
+// encode at roughly a token per character.
+func isWide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
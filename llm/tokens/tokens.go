@@ -0,0 +1,128 @@
+// Package tokens estimates token counts before a request is sent, so
+// budgets and size limits can be checked without a provider round trip.
+//
+// OpenAI model families are counted with byte pair encoding, the way
+// tiktoken encodes them, once SetVocabularyDir points at the o200k_base and
+// cl100k_base vocabulary files. Without those files they fall back to a
+// shape estimator that splits text roughly like tiktoken's pre-tokenizer and
+// prices each piece by its length and script. Other families use a
+// character ratio. Heuristic counts carry a margin of roughly 10-20% either
+// way, more on unusual text.
+package tokens
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// Estimator counts the tokens of a piece of text.
+type Estimator interface {
+	Count(text string) int
+}
+
+const (
+	// messageOverhead covers role and framing tokens around each message.
+	messageOverhead = 4
+	// requestOverhead covers the reply priming at the end of a request.
+	requestOverhead = 3
+	// toolOverhead covers the framing around each tool definition.
+	toolOverhead = 8
+	// imageTokens approximates one image input (a high-detail 1024px tile
+	// set on OpenAI; Anthropic charges a similar amount).
+	imageTokens = 765
+)
+
+var (
+	o200k   Estimator = shapeEstimator{wordChars: 4.5, wideRune: 0.8, otherRune: 0.45}
+	cl100k  Estimator = shapeEstimator{wordChars: 4, wideRune: 1.1, otherRune: 0.6}
+	claude  Estimator = heuristicEstimator{charsPerToken: 3.5}
+	generic Estimator = heuristicEstimator{charsPerToken: 4}
+)
+
+// ForModel returns the estimator for model's family. Unknown models (and an
+// empty model) get a generic ~4 characters per token heuristic.
+//
+// The BPE estimator of an OpenAI family is loaded from disk the first
+// time it is asked for.
+func ForModel(model string) Estimator {
+	m := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		// Router-style names such as "openai/gpt-4o".
+		m = m[i+1:]
+	}
+	switch {
+	case hasAnyPrefix(m, "gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "gpt-oss", "chatgpt-", "o1", "o3", "o4"):
+		if enc := bpeFor(O200KBase); enc != nil {
+			return enc
+		}
+		return o200k
+	case hasAnyPrefix(m, "gpt-4", "gpt-3.5", "text-embedding-"):
+		if enc := bpeFor(CL100KBase); enc != nil {
+			return enc
+		}
+		return cl100k
+	case strings.HasPrefix(m, "claude"):
+		return claude
+	default:
+		return generic
+	}
+}
+
+// Count estimates the tokens of text for model.
+func Count(model string, text string) int {
+	if text == "" {
+		return 0
+	}
+	return ForModel(model).Count(text)
+}
+
+// Message estimates the tokens of one message: content, tool calls and
+// attached parts, plus per-message framing.
+func Message(model string, m llm.Message) int {
+	e := ForModel(model)
+	n := messageOverhead + e.Count(m.Content)
+	for _, tc := range m.ToolCalls {
+		args, _ := json.Marshal(tc.Arguments)
+		n += e.Count(tc.Name) + e.Count(string(args))
+	}
+	for _, p := range m.Parts {
+		switch p.Type {
+		case llm.PartText:
+			n += e.Count(p.Text)
+		case llm.PartImage:
+			n += imageTokens
+		default:
+			n += e.Count(p.Placeholder())
+		}
+	}
+	return n
+}
+
+// Request estimates the input tokens of req: its messages, tool
+// definitions and response schema.
+func Request(req llm.Request) int {
+	e := ForModel(req.Model)
+	n := requestOverhead
+	for _, m := range req.Messages {
+		n += Message(req.Model, m)
+	}
+	for _, t := range req.Tools {
+		n += toolOverhead + e.Count(t.Name) + e.Count(t.Description) + e.Count(t.ParametersJSON)
+	}
+	if req.Schema != nil {
+		b, _ := json.Marshal(req.Schema.Schema)
+		n += e.Count(string(b))
+	}
+	return n
+}
+
+func hasAnyPrefix(s string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
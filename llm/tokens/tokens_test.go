@@ -0,0 +1,66 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+func TestForModelFamilies(t *testing.T) {
+	cases := map[string]Estimator{
+		"gpt-4o-mini":   o200k,
+		"openai/gpt-5":  o200k,
+		"o3-mini":       o200k,
+		"gpt-4-turbo":   cl100k,
+		"gpt-3.5-turbo": cl100k,
+		"claude-sonnet": claude,
+		"deepseek-chat": generic,
+		"":              generic,
+	}
+	for model, want := range cases {
+		if got := ForModel(model); got != want {
+			t.Errorf("ForModel(%q) = %#v, want %#v", model, got, want)
+		}
+	}
+}
+
+func TestShapeEstimatorEnglish(t *testing.T) {
+	// 10 tokens with cl100k_base and o200k_base.
+	if got := Count("gpt-4", "The quick brown fox jumps over the lazy dog."); got != 10 {
+		t.Fatalf("Count() = %d, want 10", got)
+	}
+	if got := Count("gpt-4o", "I'm sure it's 2024 now."); got < 7 || got > 10 {
+		t.Fatalf("Count() = %d, want about 8", got)
+	}
+}
+
+func TestEstimatorsScale(t *testing.T) {
+	text := strings.Repeat("Tokenization estimates should scale linearly with text. ", 50)
+	for _, model := range []string{"gpt-4o", "gpt-4", "claude-3", "llama"} {
+		one, many := Count(model, text[:57]), Count(model, text)
+		if many < one*45 || many > one*55 {
+			t.Errorf("%s: Count = %d for 1x and %d for 50x", model, one, many)
+		}
+	}
+	if n := Count("gpt-4o", "你好，世界"); n < 3 || n > 6 {
+		t.Fatalf("CJK Count() = %d", n)
+	}
+}
+
+func TestRequestCountsToolsAndImages(t *testing.T) {
+	base := llm.Request{Model: "gpt-4o", Messages: []llm.Message{{Role: "user", Content: "hi"}}}
+	n := Request(base)
+
+	withTool := base
+	withTool.Tools = []llm.Tool{{Name: "search", Description: "Search the web.", ParametersJSON: `{"type":"object"}`}}
+	if Request(withTool) <= n {
+		t.Fatalf("tool definitions not counted")
+	}
+
+	withImage := base
+	withImage.Messages = []llm.Message{{Role: "user", Content: "hi", Parts: []llm.ContentPart{llm.ImagePath("a.png")}}}
+	if got := Request(withImage); got != n+imageTokens {
+		t.Fatalf("Request() with image = %d, want %d", got, n+imageTokens)
+	}
+}
@@ -6,9 +6,14 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/quailyquaily/mistermorph/llm/tokens"
 )
 
-func (m *Manager) BuildInjection(subjectID string, reqCtx RequestContext, maxItems int) (string, error) {
+// BuildInjection renders the memory summaries to inject into the prompt,
+// capped at maxItems entries and, when maxTokens > 0, at an estimated
+// maxTokens tokens.
+func (m *Manager) BuildInjection(subjectID string, reqCtx RequestContext, maxItems int, maxTokens int) (string, error) {
 	if m == nil {
 		return "", fmt.Errorf("nil memory manager")
 	}
@@ -30,7 +35,7 @@ func (m *Manager) BuildInjection(subjectID string, reqCtx RequestContext, maxIte
 		return "", err
 	}
 
-	return formatInjection(longSummary, shortSummaries, maxItems), nil
+	return formatInjection(longSummary, shortSummaries, maxItems, maxTokens), nil
 }
 
 func (m *Manager) LoadLongTermSummary(subjectID string) (string, error) {
@@ -137,16 +142,34 @@ func (m *Manager) LoadShortTermSummaries(days int) ([]ShortTermSummary, error) {
 	return out, nil
 }
 
-func formatInjection(longSummary string, shortSummaries []ShortTermSummary, maxItems int) string {
+func formatInjection(longSummary string, shortSummaries []ShortTermSummary, maxItems int, maxTokens int) string {
 	lines := make([]string, 0, 8)
 	count := 0
+	used := 0
+	// fits reports whether the next lines stay within maxTokens.
+	fits := func(next ...string) bool {
+		if maxTokens <= 0 {
+			return true
+		}
+		n := 0
+		for _, l := range next {
+			n += tokens.Count("", l) + 1
+		}
+		if used+n > maxTokens {
+			return false
+		}
+		used += n
+		return true
+	}
 	if strings.TrimSpace(longSummary) != "" && count < maxItems {
-		lines = append(lines, "[Memory:LongTerm:Summary]")
-		lines = append(lines, "- "+strings.TrimSpace(longSummary))
-		count++
+		header, line := "[Memory:LongTerm:Summary]", "- "+strings.TrimSpace(longSummary)
+		if fits(header, line) {
+			lines = append(lines, header, line)
+			count++
+		}
 	}
 
-	if len(shortSummaries) > 0 && count < maxItems {
+	if len(shortSummaries) > 0 && count < maxItems && fits("[Memory:ShortTerm:Recent]") {
 		if len(lines) > 0 {
 			lines = append(lines, "")
 		}
@@ -155,14 +178,23 @@ func formatInjection(longSummary string, shortSummaries []ShortTermSummary, maxI
 		sort.SliceStable(summaries, func(i, j int) bool {
 			return summaries[i].Date > summaries[j].Date
 		})
+		added := 0
 		for _, s := range summaries {
 			if count >= maxItems {
 				break
 			}
 			progress := fmt.Sprintf(" [progress: tasks %d/%d, follow_ups %d/%d]", s.TasksDone, s.TasksTotal, s.FollowUpsDone, s.FollowUpsTotal)
 			line := fmt.Sprintf("- %s: %s (%s)%s", s.Date, strings.TrimSpace(s.Summary), strings.TrimSpace(s.RelPath), progress)
+			if !fits(line) {
+				break
+			}
 			lines = append(lines, line)
 			count++
+			added++
+		}
+		if added == 0 {
+			// Drop the header (and separator) when no entry fit.
+			lines = lines[:len(lines)-1]
 		}
 	}
 
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormatInjectionMaxTokens(t *testing.T) {
+	var summaries []ShortTermSummary
+	for i := 1; i <= 20; i++ {
+		summaries = append(summaries, ShortTermSummary{
+			Date:    fmt.Sprintf("2026-02-%02d", i),
+			Summary: strings.Repeat("discussed the release plan and open questions ", 3),
+			RelPath: fmt.Sprintf("2026-02-%02d/session.md", i),
+		})
+	}
+
+	all := formatInjection("long term facts", summaries, 50, 0)
+	capped := formatInjection("long term facts", summaries, 50, 200)
+	if got := strings.Count(all, "\n- 2026"); got != 20 {
+		t.Fatalf("uncapped entries = %d, want 20", got)
+	}
+	got := strings.Count(capped, "\n- 2026")
+	if got == 0 || got >= 20 {
+		t.Fatalf("capped entries = %d, want some but not all", got)
+	}
+	if !strings.Contains(capped, "- 2026-02-20:") {
+		t.Fatalf("capped injection should keep the newest entries:\n%s", capped)
+	}
+	if none := formatInjection("", summaries, 50, 5); none != "" {
+		t.Fatalf("injection with a tiny budget = %q, want empty", none)
+	}
+}
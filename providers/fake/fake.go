@@ -0,0 +1,282 @@
+// Package fake provides a scripted llm.Client for offline end-to-end tests.
+//
+// A script lists the turns the model is expected to take. Each call picks
+// the first unused turn whose match conditions hold for the request (the
+// last user or tool message, and the response schema) and replies with the
+// turn's plan, tool calls, final answer or raw text. No network or API key
+// is involved, so `mistermorph run`, `serve` and the Telegram handler can be
+// exercised in CI.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/llm/tokens"
+	"gopkg.in/yaml.v3"
+)
+
+// mainSchema is the response schema of the agent loop.
+const mainSchema = "agent_response"
+
+// Script is the parsed form of a script file. JSON is valid YAML, so one
+// loader handles both.
+type Script struct {
+	Turns []Turn `yaml:"turns" json:"turns"`
+	// Default answers requests no turn matches. Without it such requests
+	// fail, naming the unmatched message.
+	Default *Turn `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// Turn is one scripted model response. Exactly one of Final, Plan,
+// ToolCalls, JSON, Text or Error should be set.
+type Turn struct {
+	// Match is a substring the last user or tool message must contain.
+	Match string `yaml:"match,omitempty" json:"match,omitempty"`
+	// Regex is a regular expression the last user or tool message must
+	// match.
+	Regex string `yaml:"regex,omitempty" json:"regex,omitempty"`
+	// Schema selects requests by response schema name ("intent",
+	// "memory_draft", ...). Empty selects the agent loop and requests
+	// without a schema, so helper prompts don't consume main-loop turns.
+	Schema string `yaml:"schema,omitempty" json:"schema,omitempty"`
+	// Repeat keeps the turn available after it has been used.
+	Repeat bool `yaml:"repeat,omitempty" json:"repeat,omitempty"`
+
+	Final     *Final         `yaml:"final,omitempty" json:"final,omitempty"`
+	Plan      map[string]any `yaml:"plan,omitempty" json:"plan,omitempty"`
+	ToolCalls []ToolCall     `yaml:"tool_calls,omitempty" json:"tool_calls,omitempty"`
+	// JSON is returned marshaled, for helper prompts with their own schema.
+	JSON any `yaml:"json,omitempty" json:"json,omitempty"`
+	// Text is returned verbatim.
+	Text string `yaml:"text,omitempty" json:"text,omitempty"`
+	// Error makes the call fail with this message (e.g. "status 429" to
+	// exercise retries).
+	Error string `yaml:"error,omitempty" json:"error,omitempty"`
+
+	re *regexp.Regexp
+}
+
+type Final struct {
+	Thought string `yaml:"thought,omitempty" json:"thought,omitempty"`
+	Output  any    `yaml:"output" json:"output"`
+}
+
+type ToolCall struct {
+	Name      string         `yaml:"name" json:"name"`
+	Arguments map[string]any `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+}
+
+// Load reads a YAML or JSON script from path.
+func Load(path string) (*Script, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("fake: missing script path")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fake: %w", err)
+	}
+	var s Script
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("fake: invalid script %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+type Client struct {
+	mu     sync.Mutex
+	turns  []Turn
+	def    *Turn
+	used   []bool
+	calls  int
+	nextID int
+}
+
+// New returns a client that plays script.
+func New(script *Script) (*Client, error) {
+	if script == nil {
+		return nil, fmt.Errorf("fake: nil script")
+	}
+	c := &Client{
+		turns: append([]Turn(nil), script.Turns...),
+		used:  make([]bool, len(script.Turns)),
+	}
+	for i := range c.turns {
+		if err := c.turns[i].compile(); err != nil {
+			return nil, fmt.Errorf("fake: turn %d: %w", i+1, err)
+		}
+	}
+	if script.Default != nil {
+		def := *script.Default
+		if err := def.compile(); err != nil {
+			return nil, fmt.Errorf("fake: default: %w", err)
+		}
+		c.def = &def
+	}
+	return c, nil
+}
+
+// NewFromFile loads the script at path and returns a client that plays it.
+func NewFromFile(path string) (*Client, error) {
+	s, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(s)
+}
+
+func (t *Turn) compile() error {
+	if t.Regex == "" {
+		return nil
+	}
+	re, err := regexp.Compile(t.Regex)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	t.re = re
+	return nil
+}
+
+func (t *Turn) matches(schema string, last string) bool {
+	if t.Schema != "" {
+		if t.Schema != schema {
+			return false
+		}
+	} else if schema != "" && schema != mainSchema {
+		return false
+	}
+	if t.Match != "" && !strings.Contains(last, t.Match) {
+		return false
+	}
+	if t.re != nil && !t.re.MatchString(last) {
+		return false
+	}
+	return true
+}
+
+// Pending returns how many non-repeating turns have not been used yet, so
+// tests can assert a script ran to completion.
+func (c *Client) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for i, t := range c.turns {
+		if !c.used[i] && !t.Repeat {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Client) Chat(ctx context.Context, req llm.Request) (llm.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return llm.Result{}, err
+	}
+	start := time.Now()
+	schema := ""
+	if req.Schema != nil {
+		schema = req.Schema.Name
+	}
+	last := lastInput(req.Messages)
+
+	c.mu.Lock()
+	c.calls++
+	var turn *Turn
+	for i := range c.turns {
+		if c.used[i] || !c.turns[i].matches(schema, last) {
+			continue
+		}
+		if !c.turns[i].Repeat {
+			c.used[i] = true
+		}
+		turn = &c.turns[i]
+		break
+	}
+	if turn == nil {
+		turn = c.def
+	}
+	call := c.calls
+	var res llm.Result
+	var err error
+	if turn != nil {
+		res, err = c.respond(turn)
+	}
+	c.mu.Unlock()
+
+	if turn == nil {
+		return llm.Result{}, fmt.Errorf("fake: no scripted turn matches call %d (schema %q, last message %q)", call, schema, truncate(last, 200))
+	}
+	if err != nil {
+		return llm.Result{}, err
+	}
+	in := tokens.Request(req)
+	out := tokens.Count(req.Model, res.Text)
+	for _, tc := range res.ToolCalls {
+		args, _ := json.Marshal(tc.Arguments)
+		out += tokens.Count(req.Model, tc.Name) + tokens.Count(req.Model, string(args))
+	}
+	res.Usage = llm.Usage{InputTokens: in, OutputTokens: out, TotalTokens: in + out}
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+// respond renders turn as a result. Callers hold c.mu.
+func (c *Client) respond(t *Turn) (llm.Result, error) {
+	switch {
+	case t.Error != "":
+		return llm.Result{}, fmt.Errorf("%s", t.Error)
+	case len(t.ToolCalls) > 0:
+		calls := make([]llm.ToolCall, 0, len(t.ToolCalls))
+		for _, tc := range t.ToolCalls {
+			c.nextID++
+			calls = append(calls, llm.ToolCall{
+				ID:        fmt.Sprintf("call_%d", c.nextID),
+				Name:      tc.Name,
+				Arguments: tc.Arguments,
+			})
+		}
+		return llm.Result{ToolCalls: calls}, nil
+	case t.Final != nil:
+		return jsonResult(map[string]any{"type": "final", "final": t.Final})
+	case t.Plan != nil:
+		return jsonResult(map[string]any{"type": "plan", "plan": t.Plan})
+	case t.JSON != nil:
+		return jsonResult(t.JSON)
+	default:
+		return llm.Result{Text: t.Text}, nil
+	}
+}
+
+func jsonResult(v any) (llm.Result, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return llm.Result{}, fmt.Errorf("fake: %w", err)
+	}
+	return llm.Result{Text: string(b)}, nil
+}
+
+// lastInput returns the text of the last user or tool message.
+func lastInput(msgs []llm.Message) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		switch msgs[i].Role {
+		case "user", "tool":
+			return msgs[i].TextContent()
+		}
+	}
+	return ""
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,112 @@
+package fake_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/agent"
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/providers/fake"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+type echoTool struct{}
+
+func (echoTool) Name() string            { return "echo" }
+func (echoTool) Description() string     { return "echoes text" }
+func (echoTool) ParameterSchema() string { return `{"type":"object"}` }
+func (echoTool) Execute(_ context.Context, params map[string]any) (string, error) {
+	text, _ := params["text"].(string)
+	return "echoed: " + text, nil
+}
+
+func userRequest(schema string, text string) llm.Request {
+	req := llm.Request{Messages: []llm.Message{{Role: "user", Content: text}}}
+	if schema != "" {
+		req.Schema = &llm.JSONSchema{Name: schema}
+	}
+	return req
+}
+
+func TestScriptDrivesAgentRun(t *testing.T) {
+	client, err := fake.NewFromFile("testdata/echo.yaml")
+	if err != nil {
+		t.Fatalf("NewFromFile: %v", err)
+	}
+	reg := tools.NewRegistry()
+	reg.Register(echoTool{})
+
+	e := agent.New(client, reg, agent.Config{MaxSteps: 5}, agent.DefaultPromptSpec())
+	final, _, err := e.Run(context.Background(), "please say hello", agent.RunOptions{})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if final == nil || final.Output != "hello from the script" {
+		t.Fatalf("final = %#v", final)
+	}
+	if n := client.Pending(); n != 0 {
+		t.Fatalf("pending turns = %d, want 0", n)
+	}
+}
+
+func TestTurnsAreConsumedInOrder(t *testing.T) {
+	client, err := fake.New(&fake.Script{Turns: []fake.Turn{
+		{Text: "first"},
+		{Text: "second"},
+		{Match: "again", Text: "repeat", Repeat: true},
+	}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+	for _, want := range []string{"first", "second", "repeat", "repeat"} {
+		res, err := client.Chat(ctx, userRequest("", "again"))
+		if err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+		if res.Text != want {
+			t.Fatalf("text = %q, want %q", res.Text, want)
+		}
+		if res.Usage.InputTokens == 0 {
+			t.Fatalf("usage not estimated: %+v", res.Usage)
+		}
+	}
+	if _, err := client.Chat(ctx, userRequest("", "other")); err == nil || !strings.Contains(err.Error(), "no scripted turn") {
+		t.Fatalf("err = %v, want unmatched error", err)
+	}
+}
+
+func TestSchemaSelectsHelperPrompts(t *testing.T) {
+	client, err := fake.New(&fake.Script{
+		Turns: []fake.Turn{
+			{Schema: "intent", JSON: map[string]any{"goal": "greet"}},
+			{Regex: `^hi\b`, Final: &fake.Final{Output: "hello"}},
+		},
+		Default: &fake.Turn{Error: "status 503"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	// A helper prompt skips turns meant for the agent loop.
+	res, err := client.Chat(ctx, userRequest("memory_draft", "hi there"))
+	if err == nil || err.Error() != "status 503" {
+		t.Fatalf("memory_draft: res=%+v err=%v, want default error", res, err)
+	}
+	res, err = client.Chat(ctx, userRequest("intent", "hi there"))
+	if err != nil || res.Text != `{"goal":"greet"}` {
+		t.Fatalf("intent: res=%+v err=%v", res, err)
+	}
+	res, err = client.Chat(ctx, userRequest("agent_response", "hi there"))
+	if err != nil || res.Text != `{"final":{"output":"hello"},"type":"final"}` {
+		t.Fatalf("agent_response: res=%+v err=%v", res, err)
+	}
+}
+
+func TestNewRejectsInvalidRegex(t *testing.T) {
+	if _, err := fake.New(&fake.Script{Turns: []fake.Turn{{Regex: "("}}}); err == nil {
+		t.Fatalf("expected error for invalid regex")
+	}
+}
@@ -0,0 +1,377 @@
+// Package local probes OpenAI-compatible inference servers that usually
+// run next to the agent (Ollama, llama.cpp server, vLLM), so the client can
+// be set up for what the server actually supports: native tool calling or
+// emulation, the richest response_format it accepts, and its context length.
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	KindOllama   = "ollama"
+	KindLlamaCpp = "llamacpp"
+	KindVLLM     = "vllm"
+)
+
+// Response formats, most specific first.
+const (
+	FormatJSONSchema = "json_schema"
+	FormatJSONObject = "json_object"
+	FormatNone       = "none"
+)
+
+// probeFormatTokens leaves room for the probe schema's JSON object, so a
+// reply can be checked against the response_format it was asked for.
+const probeFormatTokens = 32
+
+// ollamaDefaultContext is the num_ctx Ollama serves the OpenAI API with when
+// the model does not set one.
+const ollamaDefaultContext = 4096
+
+// ErrModelNotServed is returned when the server is up but does not list the
+// configured model.
+var ErrModelNotServed = errors.New("model is not served")
+
+// IsKind reports whether name is a local provider kind.
+func IsKind(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case KindOllama, KindLlamaCpp, KindVLLM:
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultEndpoint returns the address kind listens on out of the box.
+func DefaultEndpoint(kind string) string {
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case KindOllama:
+		return "http://localhost:11434"
+	case KindLlamaCpp:
+		return "http://localhost:8080"
+	case KindVLLM:
+		return "http://localhost:8000"
+	default:
+		return ""
+	}
+}
+
+// Capabilities is what a probe found out about a server and model.
+type Capabilities struct {
+	// Models lists the model ids the server serves.
+	Models []string
+	// Tools reports native tool calling support.
+	Tools bool
+	// ResponseFormat is the most specific response_format the server
+	// accepts: FormatJSONSchema, FormatJSONObject or FormatNone.
+	ResponseFormat string
+	// Vision reports image input support. vLLM does not advertise it, so
+	// it is assumed there and left to llm.vision.
+	Vision bool
+	// ContextLength is the context window the server runs the model with,
+	// or 0 when unknown.
+	ContextLength int
+}
+
+type Prober struct {
+	Kind     string
+	Endpoint string
+	APIKey   string
+	Model    string
+	HTTP     *http.Client
+}
+
+// Probe inspects the server. It returns ErrModelNotServed (wrapped) when
+// the model list does not include p.Model, and a transport error when the
+// server cannot be reached.
+func (p Prober) Probe(ctx context.Context) (Capabilities, error) {
+	kind := strings.ToLower(strings.TrimSpace(p.Kind))
+	if !IsKind(kind) {
+		return Capabilities{}, fmt.Errorf("local: unknown provider %q", p.Kind)
+	}
+	p.Kind = kind
+
+	caps, err := p.listModels(ctx)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if p.Model != "" && len(caps.Models) > 0 && !servesModel(caps.Models, p.Model) {
+		return Capabilities{}, fmt.Errorf("%w: %q (available: %s)", ErrModelNotServed, p.Model, strings.Join(caps.Models, ", "))
+	}
+
+	// Tools is nil while unknown; servers that cannot tell get a test call.
+	var tools *bool
+	switch kind {
+	case KindOllama:
+		tools, err = p.ollamaShow(ctx, &caps)
+	case KindLlamaCpp:
+		tools, err = p.llamaCppProps(ctx, &caps)
+	case KindVLLM:
+		caps.Vision = true
+	}
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if tools == nil {
+		_, ok, err := p.accepts(ctx, 1, map[string]any{
+			"tools": []map[string]any{{
+				"type": "function",
+				"function": map[string]any{
+					"name":       "ping",
+					"parameters": map[string]any{"type": "object", "properties": map[string]any{}},
+				},
+			}},
+			"tool_choice": "auto",
+		})
+		if err != nil {
+			return Capabilities{}, err
+		}
+		tools = &ok
+	}
+	caps.Tools = *tools
+
+	// A 2xx is not enough: Ollama and llama.cpp ignore response_format
+	// types they do not know and answer in prose.
+	caps.ResponseFormat = FormatNone
+	for _, format := range []string{FormatJSONSchema, FormatJSONObject} {
+		rf := map[string]any{"type": format}
+		if format == FormatJSONSchema {
+			rf["json_schema"] = map[string]any{"name": "probe", "schema": probeSchema}
+		}
+		reply, ok, err := p.accepts(ctx, probeFormatTokens, map[string]any{"response_format": rf})
+		if err != nil {
+			return Capabilities{}, err
+		}
+		if ok && conforms(format, reply) {
+			caps.ResponseFormat = format
+			break
+		}
+	}
+	return caps, nil
+}
+
+// probeSchema is the json_schema the response format probe asks for.
+var probeSchema = map[string]any{
+	"type":                 "object",
+	"properties":           map[string]any{"ok": map[string]any{"type": "boolean"}},
+	"required":             []string{"ok"},
+	"additionalProperties": false,
+}
+
+// conforms reports whether reply is what format asked for: a JSON object,
+// holding probeSchema's boolean "ok" for json_schema.
+func conforms(format, reply string) bool {
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(reply)), &obj); err != nil || obj == nil {
+		return false
+	}
+	if format == FormatJSONSchema {
+		_, ok := obj["ok"].(bool)
+		return ok
+	}
+	return true
+}
+
+// baseURL is the server root; the OpenAI API lives under /v1.
+func (p Prober) baseURL() string {
+	base := strings.TrimRight(strings.TrimSpace(p.Endpoint), "/")
+	if base == "" {
+		base = DefaultEndpoint(p.Kind)
+	}
+	return strings.TrimSuffix(base, "/v1")
+}
+
+func (p Prober) listModels(ctx context.Context) (Capabilities, error) {
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+			// vLLM
+			MaxModelLen int `json:"max_model_len"`
+			// llama.cpp
+			Meta struct {
+				NCtxTrain int `json:"n_ctx_train"`
+			} `json:"meta"`
+		} `json:"data"`
+	}
+	if _, err := p.do(ctx, http.MethodGet, "/v1/models", nil, &out); err != nil {
+		return Capabilities{}, err
+	}
+	var caps Capabilities
+	for _, m := range out.Data {
+		caps.Models = append(caps.Models, m.ID)
+		if m.ID != p.Model && len(out.Data) > 1 {
+			continue
+		}
+		if m.MaxModelLen > 0 {
+			caps.ContextLength = m.MaxModelLen
+		} else if m.Meta.NCtxTrain > 0 {
+			caps.ContextLength = m.Meta.NCtxTrain
+		}
+	}
+	sort.Strings(caps.Models)
+	return caps, nil
+}
+
+// ollamaShow reads the model's capabilities and context window.
+func (p Prober) ollamaShow(ctx context.Context, caps *Capabilities) (*bool, error) {
+	var out struct {
+		Capabilities []string       `json:"capabilities"`
+		Parameters   string         `json:"parameters"`
+		ModelInfo    map[string]any `json:"model_info"`
+	}
+	status, err := p.do(ctx, http.MethodPost, "/api/show", map[string]any{"model": p.Model}, &out)
+	if err != nil {
+		if status >= 400 && status < 500 {
+			// Older servers: fall back to a test call.
+			return nil, nil
+		}
+		return nil, err
+	}
+	caps.ContextLength = ollamaDefaultContext
+	for _, line := range strings.Split(out.Parameters, "\n") {
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimSpace(line), "num_ctx %d", &n); err == nil && n > 0 {
+			caps.ContextLength = n
+		}
+	}
+	if len(out.Capabilities) == 0 {
+		return nil, nil
+	}
+	tools := false
+	for _, c := range out.Capabilities {
+		switch c {
+		case "tools":
+			tools = true
+		case "vision":
+			caps.Vision = true
+		}
+	}
+	return &tools, nil
+}
+
+// llamaCppProps reads the runtime context size and, on newer builds, the
+// chat template's tool support and the loaded modalities.
+func (p Prober) llamaCppProps(ctx context.Context, caps *Capabilities) (*bool, error) {
+	var out struct {
+		Settings struct {
+			NCtx int `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+		TemplateCaps map[string]any `json:"chat_template_caps"`
+		Modalities   struct {
+			Vision bool `json:"vision"`
+		} `json:"modalities"`
+	}
+	status, err := p.do(ctx, http.MethodGet, "/props", nil, &out)
+	if err != nil {
+		if status >= 400 && status < 500 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if out.Settings.NCtx > 0 {
+		caps.ContextLength = out.Settings.NCtx
+	}
+	caps.Vision = out.Modalities.Vision
+	if v, ok := out.TemplateCaps["supports_tools"].(bool); ok {
+		return &v, nil
+	}
+	return nil, nil
+}
+
+// accepts sends a chat completion of up to maxTokens with extra merged into
+// the body and reports whether the server took it, along with the reply
+// text. A 4xx means the feature is not supported; 5xx and transport
+// failures are errors.
+func (p Prober) accepts(ctx context.Context, maxTokens int, extra map[string]any) (string, bool, error) {
+	body := map[string]any{
+		"model":      p.Model,
+		"messages":   []map[string]any{{"role": "user", "content": "ping"}},
+		"max_tokens": maxTokens,
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	status, err := p.do(ctx, http.MethodPost, "/v1/chat/completions", body, &out)
+	if err != nil {
+		if status >= 400 && status < 500 {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if len(out.Choices) == 0 {
+		return "", true, nil
+	}
+	return out.Choices[0].Message.Content, true, nil
+}
+
+// do sends a request to path and decodes a 2xx JSON response into out. It
+// returns the HTTP status (0 when no response arrived).
+func (p Prober) do(ctx context.Context, method, path string, in any, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL()+path, body)
+	if err != nil {
+		return 0, err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if key := strings.TrimSpace(p.APIKey); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	hc := p.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("local: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("local: %s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("local: %s %s: invalid response: %w", method, path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// servesModel matches model against the served ids. Ollama resolves a bare
+// name to its ":latest" tag.
+func servesModel(models []string, model string) bool {
+	for _, m := range models {
+		if m == model || m == model+":latest" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeServer answers the probe endpoints. chat decides the status of a
+// chat completion from its decoded body; replies follow the requested
+// response_format.
+func fakeServer(t *testing.T, models string, extra map[string]string, chat func(body map[string]any) int) *httptest.Server {
+	t.Helper()
+	return fakeServerReplying(t, models, extra, chat, formatReply)
+}
+
+// formatReply answers like a server that enforces response_format.
+func formatReply(body map[string]any) string {
+	rf, _ := body["response_format"].(map[string]any)
+	switch rf["type"] {
+	case FormatJSONSchema:
+		return `{"ok":true}`
+	case FormatJSONObject:
+		return `{"reply":"pong"}`
+	default:
+		return "pong"
+	}
+}
+
+func fakeServerReplying(t *testing.T, models string, extra map[string]string, chat func(body map[string]any) int, reply func(body map[string]any) string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(models))
+	})
+	for path, resp := range extra {
+		resp := resp
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(resp))
+		})
+	}
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		status := chat(body)
+		w.WriteHeader(status)
+		out, _ := json.Marshal(map[string]any{"choices": []map[string]any{{"message": map[string]any{"role": "assistant", "content": reply(body)}}}})
+		_, _ = w.Write(out)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProbeOllama(t *testing.T) {
+	srv := fakeServer(t,
+		`{"data":[{"id":"qwen3:8b"},{"id":"llama3.1:latest"}]}`,
+		map[string]string{"/api/show": `{"capabilities":["completion","tools"],"parameters":"num_ctx 16384\ntemperature 0.7"}`},
+		func(body map[string]any) int { return http.StatusOK },
+	)
+	caps, err := Prober{Kind: KindOllama, Endpoint: srv.URL + "/v1", Model: "llama3.1"}.Probe(context.Background())
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if !caps.Tools || caps.Vision || caps.ContextLength != 16384 || caps.ResponseFormat != FormatJSONSchema {
+		t.Fatalf("caps = %+v", caps)
+	}
+}
+
+func TestProbeVLLMWithoutToolParser(t *testing.T) {
+	srv := fakeServer(t,
+		`{"data":[{"id":"Qwen/Qwen2.5-7B-Instruct","max_model_len":32768}]}`,
+		nil,
+		func(body map[string]any) int {
+			if _, ok := body["tools"]; ok {
+				// "auto" tool choice requires --enable-auto-tool-choice
+				return http.StatusBadRequest
+			}
+			if rf, ok := body["response_format"].(map[string]any); ok && rf["type"] == FormatJSONSchema {
+				return http.StatusBadRequest
+			}
+			return http.StatusOK
+		},
+	)
+	caps, err := Prober{Kind: KindVLLM, Endpoint: srv.URL, Model: "Qwen/Qwen2.5-7B-Instruct"}.Probe(context.Background())
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if caps.Tools || caps.ContextLength != 32768 || caps.ResponseFormat != FormatJSONObject {
+		t.Fatalf("caps = %+v", caps)
+	}
+}
+
+func TestProbeLlamaCppProps(t *testing.T) {
+	chats := 0
+	srv := fakeServer(t,
+		`{"data":[{"id":"model.gguf","meta":{"n_ctx_train":131072}}]}`,
+		map[string]string{"/props": `{"default_generation_settings":{"n_ctx":8192},"chat_template_caps":{"supports_tools":true},"modalities":{"vision":true}}`},
+		func(body map[string]any) int {
+			chats++
+			return http.StatusOK
+		},
+	)
+	caps, err := Prober{Kind: KindLlamaCpp, Endpoint: srv.URL, Model: "model.gguf"}.Probe(context.Background())
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if !caps.Tools || !caps.Vision || caps.ContextLength != 8192 {
+		t.Fatalf("caps = %+v", caps)
+	}
+	// /props answered the tools question; only the response format was
+	// tested with a call.
+	if chats != 1 {
+		t.Fatalf("chat probes = %d, want 1", chats)
+	}
+}
+
+func TestProbeModelNotServed(t *testing.T) {
+	srv := fakeServer(t, `{"data":[{"id":"qwen3:8b"}]}`, nil, func(map[string]any) int { return http.StatusOK })
+	_, err := Prober{Kind: KindOllama, Endpoint: srv.URL, Model: "gpt-5.2"}.Probe(context.Background())
+	if !errors.Is(err, ErrModelNotServed) {
+		t.Fatalf("err = %v, want ErrModelNotServed", err)
+	}
+}
+
+func TestProbeIgnoredResponseFormat(t *testing.T) {
+	// The server takes json_schema without complaint but does not apply it.
+	srv := fakeServerReplying(t,
+		`{"data":[{"id":"llama3.1:latest"}]}`,
+		map[string]string{"/api/show": `{"capabilities":["completion"]}`},
+		func(map[string]any) int { return http.StatusOK },
+		func(body map[string]any) string {
+			if rf, _ := body["response_format"].(map[string]any); rf["type"] == FormatJSONObject {
+				return `{"reply":"pong"}`
+			}
+			return "Pong! How can I help?"
+		},
+	)
+	caps, err := Prober{Kind: KindOllama, Endpoint: srv.URL, Model: "llama3.1"}.Probe(context.Background())
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if caps.ResponseFormat != FormatJSONObject {
+		t.Fatalf("ResponseFormat = %q, want %q", caps.ResponseFormat, FormatJSONObject)
+	}
+}
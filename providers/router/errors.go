@@ -0,0 +1,81 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// statusCoder is implemented by SDK error types that carry an HTTP status.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// statusInText matches status fields such as "status 429", `"status_code": 502`,
+// "HTTP/1.1 503" and Go's `POST "...": 500 Internal ...`. Other numbers (token
+// counts, JSON values) are ignored.
+var statusInText = regexp.MustCompile(`(?:\bstatus(?:[ _]?code)?"?\s*[:=]?\s*|\bhttp(?:/\d(?:\.\d)?)?\s+|\b(?:get|post|put|patch|delete) "[^"]*":\s+)([1-5]\d\d)\b`)
+
+var transientPhrases = []string{
+	"rate limit",
+	"rate_limit",
+	"too many requests",
+	"overloaded",
+	"temporarily unavailable",
+	"service unavailable",
+	"bad gateway",
+	"gateway timeout",
+	"internal server error",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"unexpected eof",
+	"tls handshake timeout",
+	"i/o timeout",
+	"throttl",
+}
+
+// IsTransient reports whether err looks like a failure worth retrying:
+// rate limits, 5xx responses, timeouts and dropped connections. Provider
+// SDKs rarely share error types, so the error text is checked as well.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var sc statusCoder
+	if errors.As(err, &sc) {
+		return transientStatus(sc.StatusCode())
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	if m := statusInText.FindStringSubmatch(msg); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return transientStatus(code)
+	}
+	for _, p := range transientPhrases {
+		if strings.Contains(msg, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func transientStatus(code int) bool {
+	switch code {
+	case 408, 429:
+		return true
+	}
+	return code >= 500 && code <= 599
+}
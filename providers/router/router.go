@@ -0,0 +1,284 @@
+// Package router provides an llm.Client that retries transient provider
+// errors and fails over between several backends.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+const (
+	// PolicyFailover always tries backends in configured order.
+	PolicyFailover = "failover"
+	// PolicyRoundRobin rotates the first backend on every call and fails
+	// over to the rest in order.
+	PolicyRoundRobin = "round_robin"
+)
+
+// Backend is one underlying client. Model, when set, replaces the request
+// model for this backend (providers rarely share model names).
+type Backend struct {
+	Name   string
+	Client llm.Client
+	Model  string
+}
+
+// Retry controls per-backend retries of transient errors. Delays grow
+// exponentially from BaseDelay up to MaxDelay, with full jitter.
+type Retry struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+type Option func(*Client)
+
+func WithPolicy(policy string) Option {
+	return func(c *Client) {
+		c.policy = strings.ToLower(strings.TrimSpace(policy))
+	}
+}
+
+func WithRetry(r Retry) Option {
+	return func(c *Client) {
+		c.retry = r
+	}
+}
+
+// WithCooldown moves a backend that just exhausted its retries to the back
+// of the order for d, so later calls don't keep paying for its failures.
+func WithCooldown(d time.Duration) Option {
+	return func(c *Client) {
+		c.cooldown = d
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// Client implements llm.Client (and llm.StreamingClient) over a list of
+// backends. Result.Backend names the backend that served each call.
+type Client struct {
+	backends []Backend
+	policy   string
+	retry    Retry
+	cooldown time.Duration
+	logger   *slog.Logger
+
+	// sleep is replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu        sync.Mutex
+	next      int
+	downUntil map[int]time.Time
+}
+
+func New(backends []Backend, opts ...Option) (*Client, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("router: at least one backend is required")
+	}
+	c := &Client{
+		policy:    PolicyFailover,
+		retry:     Retry{MaxAttempts: 1},
+		sleep:     sleepContext,
+		downUntil: make(map[int]time.Time),
+	}
+	for i, b := range backends {
+		if b.Client == nil {
+			return nil, fmt.Errorf("router: backend %d has no client", i)
+		}
+		if strings.TrimSpace(b.Name) == "" {
+			b.Name = fmt.Sprintf("backend-%d", i)
+		}
+		c.backends = append(c.backends, b)
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	switch c.policy {
+	case "", PolicyFailover:
+		c.policy = PolicyFailover
+	case PolicyRoundRobin:
+	default:
+		return nil, fmt.Errorf("router: unknown policy %q (expected %s|%s)", c.policy, PolicyFailover, PolicyRoundRobin)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+	return c, nil
+}
+
+func (c *Client) Chat(ctx context.Context, req llm.Request) (llm.Result, error) {
+	return c.do(ctx, req, func(ctx context.Context, b Backend, req llm.Request) (llm.Result, error) {
+		return b.Client.Chat(ctx, req)
+	})
+}
+
+// ChatStream streams from the first backend that answers. Once a backend
+// has emitted events its errors are returned as-is: retrying then would
+// duplicate output the caller has already seen.
+func (c *Client) ChatStream(ctx context.Context, req llm.Request, onEvent llm.StreamHandler) (llm.Result, error) {
+	if onEvent == nil {
+		return c.Chat(ctx, req)
+	}
+	emitted := false
+	wrapped := func(ev llm.StreamEvent) {
+		emitted = true
+		onEvent(ev)
+	}
+	return c.do(ctx, req, func(ctx context.Context, b Backend, req llm.Request) (llm.Result, error) {
+		res, err := llm.ChatStream(ctx, b.Client, req, wrapped)
+		if err != nil && emitted {
+			return res, permanent{err}
+		}
+		return res, err
+	})
+}
+
+// SetDebugFn forwards the debug hook to every backend that supports one.
+func (c *Client) SetDebugFn(fn func(label, payload string)) {
+	for _, b := range c.backends {
+		if setter, ok := b.Client.(interface {
+			SetDebugFn(func(label, payload string))
+		}); ok {
+			setter.SetDebugFn(fn)
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, req llm.Request, call func(context.Context, Backend, llm.Request) (llm.Result, error)) (llm.Result, error) {
+	var errs []error
+	for _, idx := range c.order() {
+		b := c.backends[idx]
+		breq := req
+		if b.Model != "" {
+			breq.Model = b.Model
+		}
+		for attempt := 1; ; attempt++ {
+			res, err := call(ctx, b, breq)
+			if err == nil {
+				c.markUp(idx)
+				res.Backend = b.Name
+				return res, nil
+			}
+			var perm permanent
+			if errors.As(err, &perm) {
+				return llm.Result{}, fmt.Errorf("%s: %w", b.Name, perm.err)
+			}
+			if ctx.Err() != nil {
+				return llm.Result{}, err
+			}
+			if !IsTransient(err) || attempt >= c.retry.MaxAttempts {
+				errs = append(errs, fmt.Errorf("%s: %w", b.Name, err))
+				if IsTransient(err) {
+					c.markDown(idx)
+				}
+				break
+			}
+			delay := c.backoff(attempt)
+			c.logger.Warn("llm_retry", "backend", b.Name, "attempt", attempt, "delay_ms", delay.Milliseconds(), "error", err.Error())
+			if err := c.sleep(ctx, delay); err != nil {
+				return llm.Result{}, err
+			}
+		}
+		if len(errs) < len(c.backends) {
+			c.logger.Warn("llm_failover", "from", b.Name, "error", errs[len(errs)-1].Error())
+		}
+	}
+	if len(errs) == 1 {
+		return llm.Result{}, errs[0]
+	}
+	return llm.Result{}, fmt.Errorf("all %d llm backends failed: %w", len(errs), errors.Join(errs...))
+}
+
+// order returns backend indexes in the order to try them: the policy order,
+// with backends in cooldown moved to the end.
+func (c *Client) order() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.backends)
+	start := 0
+	if c.policy == PolicyRoundRobin {
+		start = c.next % n
+		c.next++
+	}
+	now := time.Now()
+	up := make([]int, 0, n)
+	var down []int
+	for i := 0; i < n; i++ {
+		idx := (start + i) % n
+		if until, ok := c.downUntil[idx]; ok && now.Before(until) {
+			down = append(down, idx)
+			continue
+		}
+		up = append(up, idx)
+	}
+	return append(up, down...)
+}
+
+func (c *Client) markDown(idx int) {
+	if c.cooldown <= 0 || len(c.backends) < 2 {
+		return
+	}
+	c.mu.Lock()
+	c.downUntil[idx] = time.Now().Add(c.cooldown)
+	c.mu.Unlock()
+}
+
+func (c *Client) markUp(idx int) {
+	c.mu.Lock()
+	delete(c.downUntil, idx)
+	c.mu.Unlock()
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	base := c.retry.BaseDelay
+	if base <= 0 {
+		return 0
+	}
+	d := base << (attempt - 1)
+	if d <= 0 || (c.retry.MaxDelay > 0 && d > c.retry.MaxDelay) {
+		d = c.retry.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Full jitter keeps parallel runs from retrying in lockstep.
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// permanent marks an error that must not be retried or failed over.
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
@@ -0,0 +1,148 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// scriptedClient returns errs in order, then succeeds with its name as text.
+type scriptedClient struct {
+	name   string
+	errs   []error
+	calls  int
+	models []string
+}
+
+func (c *scriptedClient) Chat(_ context.Context, req llm.Request) (llm.Result, error) {
+	c.calls++
+	c.models = append(c.models, req.Model)
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return llm.Result{}, err
+	}
+	return llm.Result{Text: c.name}, nil
+}
+
+func newTestClient(t *testing.T, backends []Backend, opts ...Option) *Client {
+	t.Helper()
+	c, err := New(backends, opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c.sleep = func(context.Context, time.Duration) error { return nil }
+	return c
+}
+
+func TestRetryThenSucceed(t *testing.T) {
+	primary := &scriptedClient{name: "primary", errs: []error{
+		errors.New(`POST "https://api.openai.com/v1/chat/completions": 429 Too Many Requests`),
+		errors.New("anthropic api error: status 529: overloaded"),
+	}}
+	c := newTestClient(t, []Backend{{Name: "primary", Client: primary}}, WithRetry(Retry{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+
+	res, err := c.Chat(context.Background(), llm.Request{Model: "gpt"})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if res.Backend != "primary" || primary.calls != 3 {
+		t.Fatalf("Backend = %q, calls = %d", res.Backend, primary.calls)
+	}
+}
+
+func TestFailoverAfterRetries(t *testing.T) {
+	transient := errors.New("uniai: stream http 503: unavailable")
+	primary := &scriptedClient{name: "primary", errs: []error{transient, transient, transient, transient}}
+	secondary := &scriptedClient{name: "secondary"}
+	c := newTestClient(t, []Backend{
+		{Name: "primary", Client: primary},
+		{Name: "secondary", Client: secondary, Model: "claude"},
+	}, WithRetry(Retry{MaxAttempts: 2}), WithCooldown(time.Hour))
+
+	res, err := c.Chat(context.Background(), llm.Request{Model: "gpt"})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if res.Backend != "secondary" || primary.calls != 2 {
+		t.Fatalf("Backend = %q, primary calls = %d", res.Backend, primary.calls)
+	}
+	if secondary.models[0] != "claude" {
+		t.Fatalf("secondary model = %q", secondary.models[0])
+	}
+
+	// The primary is cooling down, so the next call starts with the secondary.
+	if res, _ := c.Chat(context.Background(), llm.Request{Model: "gpt"}); res.Backend != "secondary" || primary.calls != 2 {
+		t.Fatalf("during cooldown: Backend = %q, primary calls = %d", res.Backend, primary.calls)
+	}
+}
+
+func TestNonTransientErrorSkipsRetries(t *testing.T) {
+	primary := &scriptedClient{name: "primary", errs: []error{errors.New(`POST "https://x": 401 Unauthorized`)}}
+	c := newTestClient(t, []Backend{{Name: "primary", Client: primary}}, WithRetry(Retry{MaxAttempts: 5}))
+
+	if _, err := c.Chat(context.Background(), llm.Request{}); err == nil || !strings.Contains(err.Error(), "primary: ") {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if primary.calls != 1 {
+		t.Fatalf("calls = %d, want 1", primary.calls)
+	}
+}
+
+func TestAllBackendsFail(t *testing.T) {
+	a := &scriptedClient{errs: []error{errors.New("status 500")}}
+	b := &scriptedClient{errs: []error{errors.New("status 502")}}
+	c := newTestClient(t, []Backend{{Name: "a", Client: a}, {Name: "b", Client: b}})
+
+	_, err := c.Chat(context.Background(), llm.Request{})
+	if err == nil || !strings.Contains(err.Error(), "all 2 llm backends failed") {
+		t.Fatalf("Chat() error = %v", err)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	a := &scriptedClient{name: "a"}
+	b := &scriptedClient{name: "b"}
+	c := newTestClient(t, []Backend{{Name: "a", Client: a}, {Name: "b", Client: b}}, WithPolicy(PolicyRoundRobin))
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		res, err := c.Chat(context.Background(), llm.Request{})
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		got = append(got, res.Backend)
+	}
+	if strings.Join(got, ",") != "a,b,a" {
+		t.Fatalf("backends = %v", got)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New(`POST "https://api.openai.com/v1/chat/completions": 429 Too Many Requests`), true},
+		{errors.New(`POST "https://api.openai.com/v1/chat/completions": 400 Bad Request`), false},
+		{errors.New("openai API request failed with status 503: upstream"), true},
+		{errors.New("context window of 500 tokens exceeded"), false},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), true},
+		{context.Canceled, false},
+		{errors.New("read tcp: connection reset by peer"), true},
+		{errors.New("ThrottlingException: rate exceeded"), true},
+		{errors.New(`upstream error: {"status_code": 502}`), true},
+		{errors.New("HTTP/1.1 504 from proxy"), true},
+		{errors.New(`invalid request: {"max_tokens": 500, "n": 429}`), false},
+	}
+	for _, tc := range cases {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("IsTransient(%q) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package uniai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/llm"
+	uniaiapi "github.com/quailyquaily/uniai"
+)
+
+const defaultAnthropicMessagesURL = "https://api.anthropic.com/v1/messages"
+
+// defaultAnthropicMaxTokens is sent when a request sets no max_tokens, which
+// the Messages API requires.
+const defaultAnthropicMaxTokens = 8192
+
+// anthropicMessagesURL returns the Messages API URL for endpoint. An empty
+// endpoint, or llm.endpoint's OpenAI default, means Anthropic's own API.
+func anthropicMessagesURL(endpoint string) string {
+	base := strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	switch {
+	case base == "" || strings.Contains(base, "api.openai.com"):
+		return defaultAnthropicMessagesURL
+	case strings.HasSuffix(base, "/messages"):
+		return base
+	case strings.HasSuffix(base, "/v1"):
+		return base + "/messages"
+	default:
+		return base + "/v1/messages"
+	}
+}
+
+// useAnthropicNative reports whether req is sent over the native Anthropic
+// Messages API instead of uniai, whose Anthropic provider is text-only and
+// cannot send cache_control. Requests with images always are (unless
+// vision is off); requests with cache hints are when anthropicCacheControl
+// opts in.
+func (c *Client) useAnthropicNative(req llm.Request) bool {
+	if c == nil || c.provider != "anthropic" {
+		return false
+	}
+	if c.toolsEmulationMode != "" && c.toolsEmulationMode != uniaiapi.ToolsEmulationOff {
+		return false
+	}
+	if !c.disableVision && requestHasImages(req) {
+		return true
+	}
+	return c.anthropicCacheControl() && requestHasCacheHints(req)
+}
+
+// anthropicCacheControl reports whether cache hints become cache_control
+// breakpoints. It is opt-in (Config.AnthropicCacheControl).
+func (c *Client) anthropicCacheControl() bool {
+	return c.anthropicCache && !c.disablePromptCache
+}
+
+type anthropicBlock struct {
+	Type         string          `json:"type"`
+	Text         string          `json:"text,omitempty"`
+	ID           string          `json:"id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Input        any             `json:"input,omitempty"`
+	ToolUseID    string          `json:"tool_use_id,omitempty"`
+	Content      string          `json:"content,omitempty"`
+	Source       *anthropicImage `json:"source,omitempty"`
+	CacheControl *anthropicCache `json:"cache_control,omitempty"`
+}
+
+type anthropicImage struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicCache struct {
+	Type string `json:"type"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicResponse struct {
+	Content []anthropicBlock `json:"content"`
+	Usage   struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	} `json:"usage"`
+}
+
+func (c *Client) chatAnthropic(ctx context.Context, req llm.Request) (llm.Result, error) {
+	payload, err := c.buildAnthropicBody(req, newChatParams(req))
+	if err != nil {
+		return llm.Result{}, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return llm.Result{}, err
+	}
+	if c.debugFn != nil {
+		c.debugFn("uniai.anthropic.request", string(body))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.anthropicURL, bytes.NewReader(body))
+	if err != nil {
+		return llm.Result{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return llm.Result{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return llm.Result{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return llm.Result{}, fmt.Errorf("anthropic api error: status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if c.debugFn != nil {
+		c.debugFn("uniai.anthropic.response", string(raw))
+	}
+
+	var out anthropicResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return llm.Result{}, fmt.Errorf("anthropic: invalid response: %w", err)
+	}
+	var text []string
+	var calls []llm.ToolCall
+	for _, b := range out.Content {
+		switch b.Type {
+		case "text":
+			if strings.TrimSpace(b.Text) != "" {
+				text = append(text, b.Text)
+			}
+		case "tool_use":
+			args, _ := b.Input.(map[string]any)
+			calls = append(calls, llm.ToolCall{ID: b.ID, Name: b.Name, Arguments: args})
+		}
+	}
+
+	// Anthropic's input_tokens excludes cache reads and writes.
+	u := out.Usage
+	input := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return llm.Result{
+		Text:      strings.Join(text, "\n"),
+		ToolCalls: calls,
+		Usage: llm.Usage{
+			InputTokens:       input,
+			OutputTokens:      u.OutputTokens,
+			TotalTokens:       input + u.OutputTokens,
+			CachedInputTokens: u.CacheReadInputTokens,
+		},
+	}, nil
+}
+
+// buildAnthropicBody renders req as a Messages API request. With
+// anthropicCacheControl on, the last block of every cache-marked message
+// gets a cache_control breakpoint.
+func (c *Client) buildAnthropicBody(req llm.Request, p chatParams) (map[string]any, error) {
+	var system []anthropicBlock
+	var msgs []anthropicMessage
+	for _, m := range req.Messages {
+		var blocks []anthropicBlock
+		role := strings.ToLower(strings.TrimSpace(m.Role))
+		switch role {
+		case "system":
+			if text := m.TextContent(); text != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: text})
+			}
+		case "user", "assistant":
+			content, err := c.anthropicContent(m)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, content...)
+			for _, call := range m.ToolCalls {
+				input := call.Arguments
+				if input == nil {
+					input = map[string]any{}
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: call.ID, Name: call.Name, Input: input})
+			}
+		case "tool":
+			if strings.TrimSpace(m.ToolCallID) == "" {
+				return nil, fmt.Errorf("anthropic: tool message without tool_call_id")
+			}
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.TextContent()})
+		default:
+			return nil, fmt.Errorf("anthropic: unsupported role %q", m.Role)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if m.Cache && c.anthropicCacheControl() {
+			blocks[len(blocks)-1].CacheControl = &anthropicCache{Type: "ephemeral"}
+		}
+		if role == "system" {
+			system = append(system, blocks...)
+			continue
+		}
+		msgs = append(msgs, anthropicMessage{Role: role, Content: blocks})
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("anthropic: at least one non-system message is required")
+	}
+
+	model := p.model
+	if model == "" {
+		model = c.model
+	}
+	maxTokens := p.maxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+	body := map[string]any{
+		"model":       model,
+		"messages":    msgs,
+		"max_tokens":  maxTokens,
+		"temperature": p.temperature,
+	}
+	if len(system) > 0 {
+		body["system"] = system
+	}
+
+	if len(p.tools) > 0 {
+		tools := make([]map[string]any, 0, len(p.tools))
+		for _, t := range p.tools {
+			schema := toolSchema(t)
+			if schema == nil {
+				schema = json.RawMessage(`{"type":"object"}`)
+			}
+			tools = append(tools, map[string]any{
+				"name":         strings.TrimSpace(t.Name),
+				"description":  strings.TrimSpace(t.Description),
+				"input_schema": schema,
+			})
+		}
+		body["tools"] = tools
+		body["tool_choice"] = map[string]any{"type": "auto"}
+	}
+
+	if p.topP != nil {
+		body["top_p"] = *p.topP
+	}
+	if len(p.stop) > 0 {
+		body["stop_sequences"] = p.stop
+	}
+	if p.user != "" {
+		body["metadata"] = map[string]any{"user_id": p.user}
+	}
+	return body, nil
+}
+
+// anthropicContent renders a user or assistant message's text and parts.
+// Images become image blocks unless vision is disabled; other parts stay
+// text placeholders.
+func (c *Client) anthropicContent(m llm.Message) ([]anthropicBlock, error) {
+	if len(m.Parts) == 0 || c.disableVision {
+		if text := m.TextContent(); strings.TrimSpace(text) != "" {
+			return []anthropicBlock{{Type: "text", Text: text}}, nil
+		}
+		return nil, nil
+	}
+	var out []anthropicBlock
+	if strings.TrimSpace(m.Content) != "" {
+		out = append(out, anthropicBlock{Type: "text", Text: m.Content})
+	}
+	for _, part := range m.Parts {
+		switch part.Type {
+		case llm.PartText:
+			if strings.TrimSpace(part.Text) != "" {
+				out = append(out, anthropicBlock{Type: "text", Text: part.Text})
+			}
+		case llm.PartImage:
+			url, err := imagePartURL(part)
+			if err != nil {
+				return nil, err
+			}
+			src := &anthropicImage{Type: "url", URL: url}
+			if rest, ok := strings.CutPrefix(url, "data:"); ok {
+				mediaType, data, _ := strings.Cut(rest, ";base64,")
+				src = &anthropicImage{Type: "base64", MediaType: mediaType, Data: data}
+			}
+			out = append(out, anthropicBlock{Type: "image", Source: src})
+		default:
+			out = append(out, anthropicBlock{Type: "text", Text: part.Placeholder()})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,147 @@
+package uniai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+func TestChatAnthropicSendsCacheControl(t *testing.T) {
+	var got map[string]any
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":90}}`))
+	}))
+	defer srv.Close()
+
+	used := false
+	hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		used = true
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	c := New(Config{Provider: "anthropic", Endpoint: srv.URL, APIKey: "k", Model: "claude", HTTPClient: hc, AnthropicCacheControl: true})
+	res, err := c.Chat(context.Background(), llm.Request{
+		Messages: []llm.Message{
+			{Role: "system", Content: "stable prompt", Cache: true},
+			{Role: "user", Content: "what is this?", Parts: []llm.ContentPart{llm.ImageData("image/png", "iVBORw0KGgo=")}},
+		},
+		Parameters: map[string]any{"max_tokens": 1024, "temperature": 0.3},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if !used || path != "/v1/messages" {
+		t.Fatalf("request went to %q (configured client used: %v)", path, used)
+	}
+	if res.Text != "ok" || res.Usage.InputTokens != 100 || res.Usage.CachedInputTokens != 90 {
+		t.Fatalf("result = %+v", res)
+	}
+	if got["max_tokens"] != float64(1024) || got["temperature"] != 0.3 {
+		t.Fatalf("params = max_tokens %v, temperature %v", got["max_tokens"], got["temperature"])
+	}
+	system, _ := got["system"].([]any)
+	if len(system) != 1 {
+		t.Fatalf("system = %#v", got["system"])
+	}
+	block, _ := system[0].(map[string]any)
+	if cc, _ := block["cache_control"].(map[string]any); cc["type"] != "ephemeral" {
+		t.Fatalf("system block = %#v, want ephemeral cache_control", block)
+	}
+	msgs, _ := got["messages"].([]any)
+	user, _ := msgs[0].(map[string]any)
+	content, _ := user["content"].([]any)
+	if len(content) != 2 {
+		t.Fatalf("user content = %#v", user["content"])
+	}
+	img, _ := content[1].(map[string]any)
+	src, _ := img["source"].(map[string]any)
+	if img["type"] != "image" || src["type"] != "base64" || src["media_type"] != "image/png" || src["data"] != "iVBORw0KGgo=" {
+		t.Fatalf("image block = %#v", img)
+	}
+}
+
+func TestAnthropicCacheIsOptIn(t *testing.T) {
+	req := llm.Request{Messages: []llm.Message{{Role: "system", Content: "s", Cache: true}, {Role: "user", Content: "u"}}}
+	if New(Config{Provider: "anthropic"}).useAnthropicNative(req) {
+		t.Fatal("native Anthropic path should be off by default")
+	}
+	if !New(Config{Provider: "anthropic", AnthropicCacheControl: true}).useAnthropicNative(req) {
+		t.Fatal("native Anthropic path should be used when enabled")
+	}
+}
+
+func TestChatAnthropicSendsImagesWithoutCacheOptIn(t *testing.T) {
+	var got map[string]any
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"a cat"}],"usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	defer srv.Close()
+
+	c := New(Config{Provider: "anthropic", Endpoint: srv.URL, APIKey: "k", Model: "claude"})
+	res, err := c.Chat(context.Background(), llm.Request{
+		Messages: []llm.Message{
+			{Role: "system", Content: "stable prompt", Cache: true},
+			{Role: "user", Content: "what is this?", Parts: []llm.ContentPart{llm.ImageData("image/png", "iVBORw0KGgo=")}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if path != "/v1/messages" || res.Text != "a cat" {
+		t.Fatalf("path = %q, result = %+v", path, res)
+	}
+	system, _ := got["system"].([]any)
+	if block, _ := system[0].(map[string]any); block["cache_control"] != nil {
+		t.Fatalf("system block = %#v, want no cache_control without the opt-in", block)
+	}
+	msgs, _ := got["messages"].([]any)
+	user, _ := msgs[0].(map[string]any)
+	content, _ := user["content"].([]any)
+	if img, _ := content[len(content)-1].(map[string]any); img["type"] != "image" {
+		t.Fatalf("user content = %#v, want an image block", user["content"])
+	}
+}
+
+func TestAnthropicMessagesURL(t *testing.T) {
+	cases := map[string]string{
+		"":                               defaultAnthropicMessagesURL,
+		"https://api.openai.com":         defaultAnthropicMessagesURL,
+		"https://gw.example/anthropic/":  "https://gw.example/anthropic/v1/messages",
+		"https://gw.example/v1":          "https://gw.example/v1/messages",
+		"https://gw.example/v1/messages": "https://gw.example/v1/messages",
+	}
+	for in, want := range cases {
+		if got := anthropicMessagesURL(in); got != want {
+			t.Errorf("anthropicMessagesURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPromptCacheKeyHashesCachedPrefix(t *testing.T) {
+	c := New(Config{Provider: "openai"})
+	req := func(task string) llm.Request {
+		return llm.Request{Messages: []llm.Message{
+			{Role: "system", Content: "stable", Cache: true},
+			{Role: "user", Content: task},
+		}}
+	}
+	a, b := c.promptCacheKey(req("one")), c.promptCacheKey(req("two"))
+	if a == "" || a != b {
+		t.Fatalf("keys = %q, %q; want equal and non-empty", a, b)
+	}
+	if key := c.promptCacheKey(llm.Request{Messages: []llm.Message{{Role: "user", Content: "x"}}}); key != "" {
+		t.Fatalf("key without hints = %q", key)
+	}
+}
@@ -0,0 +1,69 @@
+package uniai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+func requestHasCacheHints(req llm.Request) bool {
+	for _, m := range req.Messages {
+		if m.Cache {
+			return true
+		}
+	}
+	return false
+}
+
+// promptCacheKey returns the OpenAI prompt_cache_key for req, or "" when
+// req has no cache hints. OpenAI caches long prompt prefixes on its own;
+// the key (a hash of the first cacheable prefix, usually the system prompt)
+// routes requests sharing that prefix to the same cache.
+func (c *Client) promptCacheKey(req llm.Request) string {
+	if c == nil || c.disablePromptCache {
+		return ""
+	}
+	switch c.provider {
+	case "openai", "azure":
+	default:
+		return ""
+	}
+	h := sha256.New()
+	for _, m := range req.Messages {
+		_, _ = h.Write([]byte(m.Role))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(m.TextContent()))
+		_, _ = h.Write([]byte{0})
+		if m.Cache {
+			return "mm-" + hex.EncodeToString(h.Sum(nil))[:32]
+		}
+	}
+	return ""
+}
+
+// cachedTokensFromRaw reads the cached prompt token count from a provider
+// response, which uniai's usage does not carry.
+func cachedTokensFromRaw(raw any) int {
+	if raw == nil {
+		return 0
+	}
+	var data []byte
+	if r, ok := raw.(interface{ RawJSON() string }); ok {
+		data = []byte(r.RawJSON())
+	} else {
+		data, _ = json.Marshal(raw)
+	}
+	var out struct {
+		Usage struct {
+			PromptDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return 0
+	}
+	return out.Usage.PromptDetails.CachedTokens
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -31,13 +32,45 @@ type Config struct {
 	AwsRegion          string
 	AwsBedrockModelArn string
 
+	// HTTPClient sends the requests this package makes itself (streaming,
+	// image input). Nil uses a client with RequestTimeout.
+	HTTPClient *http.Client
+
+	// DisableVision sends image parts as text placeholders even when the
+	// provider could take them as input.
+	DisableVision bool
+	// DisableJSONSchema sends json_object mode instead of a request's
+	// JSON schema.
+	DisableJSONSchema bool
+	// DisablePromptCache ignores the requests' cache hints (llm.Message.Cache).
+	DisablePromptCache bool
+	// AnthropicCacheControl sends Anthropic requests that carry cache hints
+	// over the Messages API directly (at Endpoint, when set), since uniai
+	// cannot send cache_control breakpoints.
+	AnthropicCacheControl bool
+	// ResponseFormat pins the response_format sent for JSON requests
+	// ("json_schema", "json_object" or "none"), e.g. from a capability
+	// probe. Empty tries json_schema, then json_object, then none, falling
+	// back when the provider rejects one.
+	ResponseFormat string
+
 	Debug bool
 }
 
 type Client struct {
 	provider           string
+	openAIBase         string
+	apiKey             string
+	model              string
 	requestTimeout     time.Duration
 	toolsEmulationMode uniaiapi.ToolsEmulationMode
+	disableVision      bool
+	disableJSONSchema  bool
+	disablePromptCache bool
+	responseFormat     string
+	anthropicCache     bool
+	anthropicURL       string
+	httpClient         *http.Client
 	client             *uniaiapi.Client
 	debugFn            func(label, payload string)
 }
@@ -83,27 +116,61 @@ func New(cfg Config) *Client {
 		Debug: cfg.Debug,
 	}
 
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: cfg.RequestTimeout}
+	}
+
 	return &Client{
 		provider:           provider,
+		openAIBase:         openAIBase,
+		apiKey:             openAIKey,
+		model:              strings.TrimSpace(cfg.Model),
 		requestTimeout:     cfg.RequestTimeout,
 		toolsEmulationMode: normalizeToolsEmulationMode(cfg.ToolsEmulationMode),
+		disableVision:      cfg.DisableVision,
+		disableJSONSchema:  cfg.DisableJSONSchema,
+		disablePromptCache: cfg.DisablePromptCache,
+		responseFormat:     strings.ToLower(strings.TrimSpace(cfg.ResponseFormat)),
+		anthropicCache:     cfg.AnthropicCacheControl,
+		anthropicURL:       anthropicMessagesURL(cfg.Endpoint),
+		httpClient:         httpClient,
 		client:             uniaiapi.New(uCfg),
 	}
 }
 
 func (c *Client) Chat(ctx context.Context, req llm.Request) (llm.Result, error) {
+	// The uniai chat API is text-only; images go over the native
+	// OpenAI-compatible request instead (Anthropic's below).
+	if c.supportsVision() && requestHasImages(req) {
+		return c.chatNative(ctx, req, nil)
+	}
 	start := time.Now()
 	if c.requestTimeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
 		defer cancel()
 	}
+	// Anthropic images and cache_control breakpoints need a native request.
+	if c.useAnthropicNative(req) {
+		res, err := c.chatAnthropic(ctx, req)
+		if err != nil {
+			return llm.Result{}, err
+		}
+		res.Duration = time.Since(start)
+		return res, nil
+	}
 
-	opts := buildChatOptions(req, c.provider, req.ForceJSON, c.toolsEmulationMode, c.debugFn)
-	resp, err := c.client.Chat(ctx, opts...)
-	if err != nil && req.ForceJSON && shouldRetryWithoutResponseFormat(err) {
-		opts = buildChatOptions(req, c.provider, false, c.toolsEmulationMode, c.debugFn)
+	var resp *uniaiapi.ChatResult
+	var err error
+	params := newChatParams(req)
+	formats := c.responseFormats(req)
+	for i, format := range formats {
+		opts := buildChatOptions(req, params, c.provider, format, c.promptCacheKey(req), c.toolsEmulationMode, c.debugFn)
 		resp, err = c.client.Chat(ctx, opts...)
+		if err == nil || i == len(formats)-1 || !shouldRetryWithoutResponseFormat(err) {
+			break
+		}
 	}
 	if err != nil {
 		return llm.Result{}, err
@@ -118,18 +185,19 @@ func (c *Client) Chat(ctx context.Context, req llm.Request) (llm.Result, error)
 		Text:      resp.Text,
 		ToolCalls: toolCalls,
 		Usage: llm.Usage{
-			InputTokens:  resp.Usage.InputTokens,
-			OutputTokens: resp.Usage.OutputTokens,
-			TotalTokens:  resp.Usage.TotalTokens,
+			InputTokens:       resp.Usage.InputTokens,
+			OutputTokens:      resp.Usage.OutputTokens,
+			TotalTokens:       resp.Usage.TotalTokens,
+			CachedInputTokens: cachedTokensFromRaw(resp.Raw),
 		},
 		Duration: time.Since(start),
 	}, nil
 }
 
-func buildChatOptions(req llm.Request, provider string, forceJSON bool, toolsEmulationMode uniaiapi.ToolsEmulationMode, debugFn func(label, payload string)) []uniaiapi.ChatOption {
+func buildChatOptions(req llm.Request, p chatParams, provider string, format responseFormat, cacheKey string, toolsEmulationMode uniaiapi.ToolsEmulationMode, debugFn func(label, payload string)) []uniaiapi.ChatOption {
 	msgs := make([]uniaiapi.Message, len(req.Messages))
 	for i, m := range req.Messages {
-		msg := uniaiapi.Message{Role: m.Role, Content: m.Content}
+		msg := uniaiapi.Message{Role: m.Role, Content: m.TextContent()}
 		if strings.TrimSpace(m.ToolCallID) != "" {
 			msg.ToolCallID = strings.TrimSpace(m.ToolCallID)
 		}
@@ -143,65 +211,58 @@ func buildChatOptions(req llm.Request, provider string, forceJSON bool, toolsEmu
 	if provider != "" {
 		opts = append(opts, uniaiapi.WithProvider(provider))
 	}
-	if strings.TrimSpace(req.Model) != "" {
-		opts = append(opts, uniaiapi.WithModel(strings.TrimSpace(req.Model)))
+	if p.model != "" {
+		opts = append(opts, uniaiapi.WithModel(p.model))
 	}
 
-	if len(req.Tools) > 0 {
-		tools := make([]uniaiapi.Tool, 0, len(req.Tools))
-		for _, t := range req.Tools {
-			name := strings.TrimSpace(t.Name)
-			if name == "" {
-				continue
-			}
+	if len(p.tools) > 0 {
+		tools := make([]uniaiapi.Tool, 0, len(p.tools))
+		for _, t := range p.tools {
 			tools = append(tools, uniaiapi.FunctionTool(
-				name,
+				strings.TrimSpace(t.Name),
 				strings.TrimSpace(t.Description),
 				[]byte(t.ParametersJSON),
 			))
 		}
-		if len(tools) > 0 {
-			opts = append(opts, uniaiapi.WithTools(tools))
-			opts = append(opts, uniaiapi.WithToolChoice(uniaiapi.ToolChoiceAuto()))
-			if toolsEmulationMode != "" && toolsEmulationMode != uniaiapi.ToolsEmulationOff {
-				opts = append(opts, uniaiapi.WithToolsEmulationMode(toolsEmulationMode))
-			}
+		opts = append(opts, uniaiapi.WithTools(tools))
+		opts = append(opts, uniaiapi.WithToolChoice(uniaiapi.ToolChoiceAuto()))
+		if toolsEmulationMode != "" && toolsEmulationMode != uniaiapi.ToolsEmulationOff {
+			opts = append(opts, uniaiapi.WithToolsEmulationMode(toolsEmulationMode))
 		}
 	}
 
-	appliedTemperature := false
-	if req.Parameters != nil {
-		if v, ok := floatFromAny(req.Parameters["temperature"]); ok {
-			opts = append(opts, uniaiapi.WithTemperature(v))
-			appliedTemperature = true
-		}
-		if v, ok := floatFromAny(req.Parameters["top_p"]); ok {
-			opts = append(opts, uniaiapi.WithTopP(v))
-		}
-		if v, ok := intFromAny(req.Parameters["max_tokens"]); ok && v > 0 {
-			opts = append(opts, uniaiapi.WithMaxTokens(v))
-		}
-		if v, ok := stringSliceFromAny(req.Parameters["stop"]); ok && len(v) > 0 {
-			opts = append(opts, uniaiapi.WithStopWords(v...))
-		}
-		if v, ok := floatFromAny(req.Parameters["presence_penalty"]); ok {
-			opts = append(opts, uniaiapi.WithPresencePenalty(v))
-		}
-		if v, ok := floatFromAny(req.Parameters["frequency_penalty"]); ok {
-			opts = append(opts, uniaiapi.WithFrequencyPenalty(v))
-		}
-		if v, ok := req.Parameters["user"].(string); ok && strings.TrimSpace(v) != "" {
-			opts = append(opts, uniaiapi.WithUser(strings.TrimSpace(v)))
-		}
+	opts = append(opts, uniaiapi.WithTemperature(p.temperature))
+	if p.topP != nil {
+		opts = append(opts, uniaiapi.WithTopP(*p.topP))
+	}
+	if p.maxTokens > 0 {
+		opts = append(opts, uniaiapi.WithMaxTokens(p.maxTokens))
 	}
-	if !appliedTemperature {
-		opts = append(opts, uniaiapi.WithTemperature(0))
+	if len(p.stop) > 0 {
+		opts = append(opts, uniaiapi.WithStopWords(p.stop...))
+	}
+	if p.presencePenalty != nil {
+		opts = append(opts, uniaiapi.WithPresencePenalty(*p.presencePenalty))
+	}
+	if p.frequencyPenalty != nil {
+		opts = append(opts, uniaiapi.WithFrequencyPenalty(*p.frequencyPenalty))
+	}
+	if p.user != "" {
+		opts = append(opts, uniaiapi.WithUser(p.user))
 	}
 
-	if forceJSON {
-		opts = append(opts, uniaichat.WithOpenAIOptions(structs.JSONMap{
-			"response_format": "json_object",
-		}))
+	openAIOpts := structs.JSONMap{}
+	switch format {
+	case formatJSONObject:
+		openAIOpts["response_format"] = "json_object"
+	case formatJSONSchema:
+		openAIOpts["response_format"] = jsonSchemaFormat(req.Schema)
+	}
+	if cacheKey != "" {
+		openAIOpts["prompt_cache_key"] = cacheKey
+	}
+	if len(openAIOpts) > 0 {
+		opts = append(opts, uniaichat.WithOpenAIOptions(openAIOpts))
 	}
 
 	if debugFn != nil {
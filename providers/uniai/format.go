@@ -0,0 +1,69 @@
+package uniai
+
+import (
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// responseFormat is the kind of response_format sent with a request.
+type responseFormat int
+
+const (
+	formatNone responseFormat = iota
+	formatJSONObject
+	formatJSONSchema
+)
+
+// responseFormats lists the formats to try for req, most specific first.
+// Each later entry is the fallback when the provider rejects the previous
+// one's response_format. A pinned format (Config.ResponseFormat) is used
+// as is.
+func (c *Client) responseFormats(req llm.Request) []responseFormat {
+	if !req.ForceJSON && req.Schema == nil {
+		return []responseFormat{formatNone}
+	}
+	switch c.responseFormat {
+	case "none":
+		return []responseFormat{formatNone}
+	case "json_object":
+		return []responseFormat{formatJSONObject}
+	case "json_schema":
+		if req.Schema != nil && !c.disableJSONSchema {
+			return []responseFormat{formatJSONSchema}
+		}
+		return []responseFormat{formatJSONObject}
+	}
+	out := make([]responseFormat, 0, 3)
+	if req.Schema != nil && c.supportsJSONSchema() {
+		out = append(out, formatJSONSchema)
+	}
+	return append(out, formatJSONObject, formatNone)
+}
+
+// supportsJSONSchema reports whether json_schema response formats are sent.
+// Other providers get json_object mode and rely on the prompt for the shape.
+func (c *Client) supportsJSONSchema() bool {
+	if c == nil || c.disableJSONSchema {
+		return false
+	}
+	switch c.provider {
+	case "openai", "openai_custom", "azure", "xai":
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonSchemaFormat renders s as an OpenAI response_format value.
+func jsonSchemaFormat(s *llm.JSONSchema) map[string]any {
+	name := strings.TrimSpace(s.Name)
+	if name == "" {
+		name = "response"
+	}
+	schema := map[string]any{"name": name, "schema": s.Schema, "strict": s.Strict}
+	if d := strings.TrimSpace(s.Description); d != "" {
+		schema["description"] = d
+	}
+	return map[string]any{"type": "json_schema", "json_schema": schema}
+}
@@ -0,0 +1,66 @@
+package uniai
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// chatParams are a request's generation settings, read once from
+// llm.Request and rendered by each request builder (uniai options, the
+// OpenAI-compatible body, the Anthropic body).
+type chatParams struct {
+	model            string
+	tools            []llm.Tool
+	temperature      float64
+	topP             *float64
+	maxTokens        int
+	stop             []string
+	presencePenalty  *float64
+	frequencyPenalty *float64
+	user             string
+}
+
+func newChatParams(req llm.Request) chatParams {
+	p := chatParams{model: strings.TrimSpace(req.Model)}
+	for _, t := range req.Tools {
+		if strings.TrimSpace(t.Name) != "" {
+			p.tools = append(p.tools, t)
+		}
+	}
+	if req.Parameters == nil {
+		return p
+	}
+	if v, ok := floatFromAny(req.Parameters["temperature"]); ok {
+		p.temperature = v
+	}
+	if v, ok := floatFromAny(req.Parameters["top_p"]); ok {
+		p.topP = &v
+	}
+	if v, ok := intFromAny(req.Parameters["max_tokens"]); ok && v > 0 {
+		p.maxTokens = v
+	}
+	if v, ok := stringSliceFromAny(req.Parameters["stop"]); ok && len(v) > 0 {
+		p.stop = v
+	}
+	if v, ok := floatFromAny(req.Parameters["presence_penalty"]); ok {
+		p.presencePenalty = &v
+	}
+	if v, ok := floatFromAny(req.Parameters["frequency_penalty"]); ok {
+		p.frequencyPenalty = &v
+	}
+	if v, ok := req.Parameters["user"].(string); ok {
+		p.user = strings.TrimSpace(v)
+	}
+	return p
+}
+
+// toolSchema returns t's parameter schema, or nil when it is missing or
+// not valid JSON.
+func toolSchema(t llm.Tool) json.RawMessage {
+	if params := strings.TrimSpace(t.ParametersJSON); params != "" && json.Valid([]byte(params)) {
+		return json.RawMessage(params)
+	}
+	return nil
+}
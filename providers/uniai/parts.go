@@ -0,0 +1,92 @@
+package uniai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/internal/pathutil"
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// maxImageBytes bounds local images inlined into a request.
+const maxImageBytes = 20 << 20
+
+func requestHasImages(req llm.Request) bool {
+	for _, m := range req.Messages {
+		if m.HasImages() {
+			return true
+		}
+	}
+	return false
+}
+
+// openAIContentParts renders a message as OpenAI chat content parts. File
+// parts stay text references; the model reads them with tools.
+func openAIContentParts(m llm.Message) ([]map[string]any, error) {
+	out := make([]map[string]any, 0, len(m.Parts)+1)
+	if strings.TrimSpace(m.Content) != "" {
+		out = append(out, map[string]any{"type": "text", "text": m.Content})
+	}
+	for _, p := range m.Parts {
+		switch p.Type {
+		case llm.PartText:
+			if strings.TrimSpace(p.Text) != "" {
+				out = append(out, map[string]any{"type": "text", "text": p.Text})
+			}
+		case llm.PartImage:
+			url, err := imagePartURL(p)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, map[string]any{"type": "image_url", "image_url": map[string]any{"url": url}})
+		default:
+			out = append(out, map[string]any{"type": "text", "text": p.Placeholder()})
+		}
+	}
+	return out, nil
+}
+
+// imagePartURL returns an http(s) URL or a base64 data URL for an image part.
+func imagePartURL(p llm.ContentPart) (string, error) {
+	if url := strings.TrimSpace(p.URL); url != "" {
+		return url, nil
+	}
+	if p.Data != "" {
+		mimeType := strings.TrimSpace(p.MimeType)
+		if mimeType == "" {
+			mimeType = "image/png"
+		}
+		return "data:" + mimeType + ";base64," + p.Data, nil
+	}
+	path := pathutil.ExpandHomePath(strings.TrimSpace(p.Path))
+	if path == "" {
+		return "", fmt.Errorf("image part has no path, url or data")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("image %s: %w", path, err)
+	}
+	if info.Size() > maxImageBytes {
+		return "", fmt.Errorf("image %s is too large (%d bytes, max %d)", path, info.Size(), maxImageBytes)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("image %s: %w", path, err)
+	}
+	mimeType := strings.TrimSpace(p.MimeType)
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	}
+	if mimeType == "" || !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("%s is not an image (%s)", path, mimeType)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,399 @@
+package uniai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/llm"
+	uniaiapi "github.com/quailyquaily/uniai"
+)
+
+// ChatStream streams a chat completion. OpenAI-compatible providers are
+// streamed natively over SSE, with the request built from the same
+// chatParams as Chat and sent with the configured HTTP client; every other
+// provider (and tools emulation) falls back to Chat and emits the whole
+// response as one event.
+func (c *Client) ChatStream(ctx context.Context, req llm.Request, onEvent llm.StreamHandler) (llm.Result, error) {
+	if onEvent == nil || !c.supportsNativeStream() {
+		return llm.ChatStream(ctx, chatOnly{c}, req, onEvent)
+	}
+	return c.chatNative(ctx, req, onEvent)
+}
+
+// chatNative sends req as an OpenAI-compatible chat completion, streamed
+// when onEvent is set.
+func (c *Client) chatNative(ctx context.Context, req llm.Request, onEvent llm.StreamHandler) (llm.Result, error) {
+	start := time.Now()
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	var res llm.Result
+	var err error
+	params := newChatParams(req)
+	formats := c.responseFormats(req)
+	for i, format := range formats {
+		res, err = c.nativeOnce(ctx, req, params, format, onEvent)
+		if err == nil || i == len(formats)-1 || !shouldRetryWithoutResponseFormat(err) {
+			break
+		}
+	}
+	if err != nil {
+		return llm.Result{}, err
+	}
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+// chatOnly hides ChatStream so llm.ChatStream uses the blocking path.
+type chatOnly struct{ c *Client }
+
+func (o chatOnly) Chat(ctx context.Context, req llm.Request) (llm.Result, error) {
+	return o.c.Chat(ctx, req)
+}
+
+// supportsVision reports whether image parts are sent as images. Only
+// OpenAI-compatible providers known to accept image input qualify.
+func (c *Client) supportsVision() bool {
+	if c == nil || c.disableVision || !c.supportsNativeStream() {
+		return false
+	}
+	switch c.provider {
+	case "openai", "openai_custom", "xai":
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) supportsNativeStream() bool {
+	if c == nil || strings.TrimSpace(c.openAIBase) == "" {
+		return false
+	}
+	if c.toolsEmulationMode != "" && c.toolsEmulationMode != uniaiapi.ToolsEmulationOff {
+		return false
+	}
+	switch c.provider {
+	case "openai", "openai_custom", "deepseek", "xai":
+		return true
+	default:
+		return false
+	}
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	PromptDetails    *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+func (u *openAIUsage) toLLM() llm.Usage {
+	if u == nil {
+		return llm.Usage{}
+	}
+	out := llm.Usage{
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokens,
+	}
+	if u.PromptDetails != nil {
+		out.CachedInputTokens = u.PromptDetails.CachedTokens
+	}
+	return out
+}
+
+type openAIToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIMessage struct {
+	Content   string           `json:"content"`
+	ToolCalls []openAIToolCall `json:"tool_calls"`
+}
+
+type streamChunk struct {
+	Choices []struct {
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type chatCompletion struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type streamToolCall struct {
+	id   string
+	name string
+	args strings.Builder
+}
+
+func (c *Client) nativeOnce(ctx context.Context, req llm.Request, params chatParams, format responseFormat, onEvent llm.StreamHandler) (llm.Result, error) {
+	payload, err := c.buildOpenAIBody(req, params, format, onEvent != nil)
+	if err != nil {
+		return llm.Result{}, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return llm.Result{}, err
+	}
+	if c.debugFn != nil {
+		c.debugFn("uniai.native.request", string(body))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(c.openAIBase, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return llm.Result{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if onEvent != nil {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return llm.Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return llm.Result{}, fmt.Errorf("uniai: http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if onEvent == nil {
+		return c.readCompletion(resp.Body)
+	}
+	return c.readStream(resp.Body, onEvent)
+}
+
+func (c *Client) readCompletion(body io.Reader) (llm.Result, error) {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return llm.Result{}, err
+	}
+	if c.debugFn != nil {
+		c.debugFn("uniai.native.response", string(raw))
+	}
+	var out chatCompletion
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return llm.Result{}, fmt.Errorf("uniai: invalid response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return llm.Result{}, fmt.Errorf("uniai: empty response")
+	}
+	msg := out.Choices[0].Message
+	calls := make([]uniaiapi.ToolCall, 0, len(msg.ToolCalls))
+	for _, tc := range msg.ToolCalls {
+		calls = append(calls, uniaiapi.ToolCall{
+			ID:       tc.ID,
+			Type:     "function",
+			Function: uniaiapi.ToolCallFunction{Name: tc.Function.Name, Arguments: tc.Function.Arguments},
+		})
+	}
+	return llm.Result{
+		Text:      msg.Content,
+		ToolCalls: toLLMToolCalls(calls),
+		Usage:     out.Usage.toLLM(),
+	}, nil
+}
+
+func (c *Client) readStream(body io.Reader, onEvent llm.StreamHandler) (llm.Result, error) {
+	var (
+		text  strings.Builder
+		usage llm.Usage
+		calls = map[int]*streamToolCall{}
+		// done is set by [DONE] or a finish_reason; a stream that ends
+		// without either was cut off.
+		done bool
+	)
+	r := bufio.NewReader(body)
+	for {
+		line, readErr := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			data = strings.TrimSpace(data)
+			if data == "[DONE]" {
+				done = true
+				break
+			}
+			var chunk streamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return llm.Result{}, fmt.Errorf("uniai: invalid stream chunk: %w", err)
+			}
+			if chunk.Usage != nil {
+				usage = chunk.Usage.toLLM()
+			}
+			for _, choice := range chunk.Choices {
+				if choice.FinishReason != "" {
+					done = true
+				}
+				if choice.Delta.Content != "" {
+					text.WriteString(choice.Delta.Content)
+					onEvent(llm.StreamEvent{Type: llm.StreamEventText, Text: choice.Delta.Content})
+				}
+				for _, tc := range choice.Delta.ToolCalls {
+					call := calls[tc.Index]
+					if call == nil {
+						call = &streamToolCall{}
+						calls[tc.Index] = call
+					}
+					if tc.ID != "" {
+						call.id = tc.ID
+					}
+					if tc.Function.Name != "" {
+						call.name += tc.Function.Name
+					}
+					call.args.WriteString(tc.Function.Arguments)
+					onEvent(llm.StreamEvent{
+						Type:           llm.StreamEventToolCall,
+						ToolCallIndex:  tc.Index,
+						ToolCallID:     call.id,
+						ToolName:       call.name,
+						ArgumentsDelta: tc.Function.Arguments,
+					})
+				}
+			}
+		}
+		if readErr == io.EOF {
+			if !done {
+				return llm.Result{}, fmt.Errorf("uniai: stream ended before completion: %w", io.ErrUnexpectedEOF)
+			}
+			break
+		}
+		if readErr != nil {
+			return llm.Result{}, readErr
+		}
+	}
+
+	if c.debugFn != nil {
+		c.debugFn("uniai.native.response", text.String())
+	}
+
+	indexes := make([]int, 0, len(calls))
+	for i := range calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	raw := make([]uniaiapi.ToolCall, 0, len(indexes))
+	for _, i := range indexes {
+		call := calls[i]
+		raw = append(raw, uniaiapi.ToolCall{
+			ID:   call.id,
+			Type: "function",
+			Function: uniaiapi.ToolCallFunction{
+				Name:      call.name,
+				Arguments: call.args.String(),
+			},
+		})
+	}
+
+	return llm.Result{
+		Text:      text.String(),
+		ToolCalls: toLLMToolCalls(raw),
+		Usage:     usage,
+	}, nil
+}
+
+// buildOpenAIBody renders req as an OpenAI chat completion request, the
+// native counterpart of buildChatOptions.
+func (c *Client) buildOpenAIBody(req llm.Request, p chatParams, format responseFormat, stream bool) (map[string]any, error) {
+	msgs := make([]map[string]any, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		msg := map[string]any{"role": m.Role, "content": m.TextContent()}
+		if len(m.Parts) > 0 && c.supportsVision() {
+			parts, err := openAIContentParts(m)
+			if err != nil {
+				return nil, err
+			}
+			msg["content"] = parts
+		}
+		if id := strings.TrimSpace(m.ToolCallID); id != "" {
+			msg["tool_call_id"] = id
+		}
+		if calls := toUniaiToolCallsFromLLM(m.ToolCalls); len(calls) > 0 {
+			msg["tool_calls"] = calls
+		}
+		msgs = append(msgs, msg)
+	}
+
+	model := p.model
+	if model == "" {
+		model = c.model
+	}
+	body := map[string]any{
+		"model":       model,
+		"messages":    msgs,
+		"temperature": p.temperature,
+	}
+	if stream {
+		body["stream"] = true
+		body["stream_options"] = map[string]any{"include_usage": true}
+	}
+
+	if len(p.tools) > 0 {
+		tools := make([]map[string]any, 0, len(p.tools))
+		for _, t := range p.tools {
+			fn := map[string]any{"name": strings.TrimSpace(t.Name), "description": strings.TrimSpace(t.Description)}
+			if schema := toolSchema(t); schema != nil {
+				fn["parameters"] = schema
+			}
+			tools = append(tools, map[string]any{"type": "function", "function": fn})
+		}
+		body["tools"] = tools
+		body["tool_choice"] = "auto"
+	}
+
+	if p.topP != nil {
+		body["top_p"] = *p.topP
+	}
+	if p.maxTokens > 0 {
+		body["max_tokens"] = p.maxTokens
+	}
+	if len(p.stop) > 0 {
+		body["stop"] = p.stop
+	}
+	if p.presencePenalty != nil {
+		body["presence_penalty"] = *p.presencePenalty
+	}
+	if p.frequencyPenalty != nil {
+		body["frequency_penalty"] = *p.frequencyPenalty
+	}
+	if p.user != "" {
+		body["user"] = p.user
+	}
+
+	switch format {
+	case formatJSONObject:
+		body["response_format"] = map[string]any{"type": "json_object"}
+	case formatJSONSchema:
+		body["response_format"] = jsonSchemaFormat(req.Schema)
+	}
+	if key := c.promptCacheKey(req); key != "" {
+		body["prompt_cache_key"] = key
+	}
+	return body, nil
+}
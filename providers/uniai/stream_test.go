@@ -0,0 +1,104 @@
+package uniai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+func TestChatStreamUsesConfiguredClientAndParams(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n"+
+			"data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n"+
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5}}\n\n"+
+			"data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var used bool
+	hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		used = true
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	c := New(Config{Provider: "openai", Endpoint: srv.URL, APIKey: "k", Model: "m", HTTPClient: hc})
+
+	var deltas []string
+	res, err := c.ChatStream(context.Background(), llm.Request{
+		Messages:   []llm.Message{{Role: "user", Content: "hi"}},
+		Parameters: map[string]any{"max_tokens": 64, "stop": "END"},
+	}, func(ev llm.StreamEvent) { deltas = append(deltas, ev.Text) })
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if !used {
+		t.Fatal("configured HTTP client not used")
+	}
+	if res.Text != "Hello" || strings.Join(deltas, "|") != "Hel|lo" || res.Usage.TotalTokens != 5 {
+		t.Fatalf("result = %+v, deltas = %v", res, deltas)
+	}
+	if got["stream"] != true || got["max_tokens"] != float64(64) || got["model"] != "m" {
+		t.Fatalf("body = %#v", got)
+	}
+}
+
+func TestChatStreamCutOffIsAnError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"c1\",\"function\":{\"name\":\"bash\",\"arguments\":\"{\\\"cmd\"}}]}}]}\n\n")
+	}))
+	defer srv.Close()
+
+	c := New(Config{Provider: "openai", Endpoint: srv.URL, APIKey: "k", Model: "m"})
+	_, err := c.ChatStream(context.Background(), llm.Request{
+		Messages: []llm.Message{{Role: "user", Content: "hi"}},
+	}, func(llm.StreamEvent) {})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("ChatStream() error = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestChatSendsImagesWithoutStreaming(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"content":"a cat","tool_calls":[{"id":"c1","function":{"name":"note","arguments":"{\"x\":1}"}}]}}],"usage":{"prompt_tokens":7,"completion_tokens":2,"total_tokens":9}}`)
+	}))
+	defer srv.Close()
+
+	c := New(Config{Provider: "openai", Endpoint: srv.URL, APIKey: "k", Model: "m"})
+	res, err := c.Chat(context.Background(), llm.Request{Messages: []llm.Message{{
+		Role:    "user",
+		Content: "what is this?",
+		Parts:   []llm.ContentPart{llm.ImageURL("https://example.test/cat.png")},
+	}}})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if res.Text != "a cat" || len(res.ToolCalls) != 1 || res.ToolCalls[0].Name != "note" || res.Usage.InputTokens != 7 {
+		t.Fatalf("result = %+v", res)
+	}
+	if _, ok := got["stream"]; ok {
+		t.Fatalf("vision request was streamed: %#v", got)
+	}
+	msgs, _ := got["messages"].([]any)
+	msg, _ := msgs[0].(map[string]any)
+	if parts, _ := msg["content"].([]any); len(parts) != 2 {
+		t.Fatalf("content = %#v, want text and image parts", msg["content"])
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -107,15 +107,12 @@ func (p *AuthProfile) Validate() error {
 		}
 	}
 
-	b, ok := p.Bindings["url_fetch"]
-	if !ok {
-		return fmt.Errorf("auth_profiles.%s.bindings.url_fetch is required", p.ID)
-	}
-	if err := b.Validate("url_fetch"); err != nil {
-		return fmt.Errorf("auth_profiles.%s.bindings.url_fetch: %w", p.ID, err)
+	_, fetch := p.Bindings["url_fetch"]
+	_, search := p.Bindings["web_search"]
+	if !fetch && !search {
+		return fmt.Errorf("auth_profiles.%s.bindings requires url_fetch or web_search", p.ID)
 	}
 
-	// Optional: validate other bindings if provided.
 	for toolName, binding := range p.Bindings {
 		if strings.TrimSpace(toolName) == "" {
 			continue
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/quailyquaily/mistermorph/llm"
+)
+
+// Attachments collects content parts (images, files) that tools add to the
+// model's context. The engine puts one in the tool context and sends what
+// was attached after the step's tool results.
+type Attachments struct {
+	mu    sync.Mutex
+	parts []llm.ContentPart
+}
+
+func (a *Attachments) Add(parts ...llm.ContentPart) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.parts = append(a.parts, parts...)
+}
+
+// Drain returns the collected parts and resets the collection.
+func (a *Attachments) Drain() []llm.ContentPart {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := a.parts
+	a.parts = nil
+	return out
+}
+
+type attachmentsKey struct{}
+
+func WithAttachments(ctx context.Context, a *Attachments) context.Context {
+	return context.WithValue(ctx, attachmentsKey{}, a)
+}
+
+// Attach adds parts to the caller's context. It reports false when the
+// caller does not accept attachments (e.g. a direct MCP call).
+func Attach(ctx context.Context, parts ...llm.ContentPart) bool {
+	a, _ := ctx.Value(attachmentsKey{}).(*Attachments)
+	if a == nil {
+		return false
+	}
+	a.Add(parts...)
+	return true
+}
@@ -18,6 +18,9 @@ type BashTool struct {
 	MaxOutputBytes int
 	DenyPaths      []string
 	DenyTokens     []string
+	// Sandbox, when set, runs commands in an OS-level sandbox instead of
+	// directly as the agent's user.
+	Sandbox *BashSandbox
 }
 
 func NewBashTool(enabled bool, confirmEachRun bool, defaultTimeout time.Duration, maxOutputBytes int) *BashTool {
@@ -105,9 +108,18 @@ func (t *BashTool) Execute(ctx context.Context, params map[string]any) (string,
 	runCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(runCtx, "bash", "-lc", cmdStr)
-	if cwd != "" {
-		cmd.Dir = cwd
+	var cmd *exec.Cmd
+	if t.Sandbox != nil {
+		var err error
+		cmd, err = t.Sandbox.command(runCtx, cmdStr, cwd)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		cmd = exec.CommandContext(runCtx, "bash", "-lc", cmdStr)
+		if cwd != "" {
+			cmd.Dir = cwd
+		}
 	}
 
 	var stdout limitedBuffer
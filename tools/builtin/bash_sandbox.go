@@ -0,0 +1,172 @@
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/internal/pathutil"
+)
+
+// DefaultSandboxReadOnlyDirs are the system directories bound read-only
+// into the sandbox when none are configured; missing ones are skipped.
+var DefaultSandboxReadOnlyDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib64", "/etc"}
+
+// BashSandbox runs bash commands in Linux namespaces via bubblewrap (bwrap):
+// a fresh user, mount, PID, IPC and UTS namespace (plus network unless
+// Network is set), with only ReadOnlyDirs and WritableDirs visible, a
+// private /tmp, a cleared environment and CPU/memory/process rlimits.
+type BashSandbox struct {
+	// Bwrap is the bubblewrap binary; empty looks up "bwrap" in PATH.
+	Bwrap        string
+	ReadOnlyDirs []string
+	// WritableDirs are bound read-write (usually just file_cache_dir). The
+	// first one is HOME and the default working directory.
+	WritableDirs []string
+	Network      bool
+	CPUSeconds   int
+	MemoryMB     int
+	MaxProcesses int
+}
+
+// command returns the sandboxed exec.Cmd for cmdStr. It fails closed: no
+// bwrap or no Linux means no command.
+func (s *BashSandbox) command(ctx context.Context, cmdStr string, cwd string) (*exec.Cmd, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("bash sandbox requires Linux (tools.bash.sandbox.enabled=true)")
+	}
+	bwrap := strings.TrimSpace(s.Bwrap)
+	if bwrap == "" {
+		bwrap = "bwrap"
+	}
+	path, err := exec.LookPath(bwrap)
+	if err != nil {
+		return nil, fmt.Errorf("bash sandbox needs bubblewrap (%s): %w", bwrap, err)
+	}
+	for _, d := range s.writableDirs() {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return nil, fmt.Errorf("bash sandbox: %w", err)
+		}
+	}
+	args, err := s.args(cmdStr, cwd)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	// The sandbox starts from a clear environment; keep the host's out of
+	// the process table too.
+	cmd.Env = []string{}
+	return cmd, nil
+}
+
+// args builds the bwrap argument list.
+func (s *BashSandbox) args(cmdStr string, cwd string) ([]string, error) {
+	writable := s.writableDirs()
+	if len(writable) == 0 {
+		return nil, fmt.Errorf("bash sandbox: no writable directory (set file_cache_dir or tools.bash.sandbox.writable_dirs)")
+	}
+	readOnly := s.ReadOnlyDirs
+	if len(readOnly) == 0 {
+		readOnly = DefaultSandboxReadOnlyDirs
+	}
+
+	cwd = strings.TrimSpace(cwd)
+	if cwd == "" {
+		cwd = writable[0]
+	} else {
+		cwd = sandboxPath(cwd)
+		if !sandboxContains(cwd, writable) && !sandboxContains(cwd, sandboxDirs(readOnly)) {
+			return nil, fmt.Errorf("bash sandbox: cwd %q is outside the sandbox", cwd)
+		}
+	}
+
+	args := []string{
+		"--unshare-user", "--unshare-pid", "--unshare-ipc", "--unshare-uts", "--unshare-cgroup-try",
+		"--die-with-parent", "--new-session",
+		"--clearenv",
+		"--setenv", "PATH", "/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin",
+		"--setenv", "HOME", writable[0],
+		"--setenv", "TMPDIR", "/tmp",
+		"--setenv", "LANG", "C.UTF-8",
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+	}
+	if !s.Network {
+		args = append(args, "--unshare-net")
+	} else {
+		// DNS needs the host's resolver config, which /etc usually brings.
+		args = append(args, "--share-net")
+	}
+	for _, d := range sandboxDirs(readOnly) {
+		args = append(args, "--ro-bind-try", d, d)
+	}
+	for _, d := range writable {
+		args = append(args, "--bind", d, d)
+	}
+	args = append(args, "--chdir", cwd, "--", "bash", "-c", s.limits()+cmdStr)
+	return args, nil
+}
+
+// limits returns a ulimit prefix for the sandboxed shell. bash's ulimit
+// sets soft and hard limits, so the command cannot raise them again.
+func (s *BashSandbox) limits() string {
+	var parts []string
+	if s.CPUSeconds > 0 {
+		parts = append(parts, "-t "+strconv.Itoa(s.CPUSeconds))
+	}
+	if s.MemoryMB > 0 {
+		parts = append(parts, "-v "+strconv.Itoa(s.MemoryMB*1024))
+	}
+	if s.MaxProcesses > 0 {
+		parts = append(parts, "-u "+strconv.Itoa(s.MaxProcesses))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "ulimit " + strings.Join(parts, " ") + " || exit 126\n"
+}
+
+func (s *BashSandbox) writableDirs() []string {
+	return sandboxDirs(s.WritableDirs)
+}
+
+func sandboxDirs(dirs []string) []string {
+	out := make([]string, 0, len(dirs))
+	seen := make(map[string]bool, len(dirs))
+	for _, d := range dirs {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		d = sandboxPath(d)
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	return out
+}
+
+func sandboxPath(p string) string {
+	p = pathutil.ExpandHomePath(p)
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+func sandboxContains(p string, dirs []string) bool {
+	for _, d := range dirs {
+		if p == d || strings.HasPrefix(p, strings.TrimSuffix(d, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
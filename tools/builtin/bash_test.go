@@ -1,6 +1,10 @@
 package builtin
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"testing"
+)
 
 func TestContainsTokenBoundary(t *testing.T) {
 	cases := []struct {
@@ -66,3 +70,57 @@ func TestBashCommandDeniedTokens_Curl(t *testing.T) {
 		})
 	}
 }
+
+func TestBashSandboxArgs(t *testing.T) {
+	s := &BashSandbox{
+		ReadOnlyDirs: []string{"/usr", "/etc"},
+		WritableDirs: []string{"/var/cache/morph", "/var/cache/morph/"},
+		CPUSeconds:   10,
+		MemoryMB:     64,
+		MaxProcesses: 32,
+	}
+	args, err := s.args("echo hi", "")
+	if err != nil {
+		t.Fatalf("args: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	for _, want := range []string{
+		"--unshare-user", "--unshare-net", "--clearenv",
+		"--ro-bind-try /usr /usr", "--ro-bind-try /etc /etc",
+		"--bind /var/cache/morph /var/cache/morph",
+		"--chdir /var/cache/morph",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("args missing %q: %s", want, joined)
+		}
+	}
+	if strings.Count(joined, "--bind ") != 1 {
+		t.Fatalf("writable dirs not deduplicated: %s", joined)
+	}
+	if got := args[len(args)-1]; got != "ulimit -t 10 -v 65536 -u 32 || exit 126\necho hi" {
+		t.Fatalf("script = %q", got)
+	}
+
+	s.Network = true
+	args, _ = s.args("true", "/usr/share")
+	joined = strings.Join(args, " ")
+	if strings.Contains(joined, "--unshare-net") || !strings.Contains(joined, "--chdir /usr/share") {
+		t.Fatalf("network/cwd args wrong: %s", joined)
+	}
+
+	if _, err := s.args("true", "/home/someone"); err == nil {
+		t.Fatal("expected error for cwd outside the sandbox")
+	}
+	if _, err := (&BashSandbox{}).args("true", ""); err == nil {
+		t.Fatal("expected error without writable dirs")
+	}
+}
+
+func TestBashSandboxFailsClosed(t *testing.T) {
+	bt := NewBashTool(true, false, 0, 0)
+	bt.Sandbox = &BashSandbox{Bwrap: "definitely-not-bwrap", WritableDirs: []string{t.TempDir()}}
+	out, err := bt.Execute(context.Background(), map[string]any{"cmd": "echo hi"})
+	if err == nil || out != "" {
+		t.Fatalf("expected refusal, got out=%q err=%v", out, err)
+	}
+}
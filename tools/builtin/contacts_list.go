@@ -25,6 +25,8 @@ func NewContactsListTool(enabled bool, contactsDir string) *ContactsListTool {
 
 func (t *ContactsListTool) Name() string { return "contacts_list" }
 
+func (t *ContactsListTool) ReadOnly(map[string]any) bool { return true }
+
 func (t *ContactsListTool) Description() string {
 	return "Lists contacts from contacts store. Use this before proactive sharing to inspect active/inactive contacts and profile metadata."
 }
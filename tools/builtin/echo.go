@@ -12,6 +12,8 @@ func NewEchoTool() *EchoTool { return &EchoTool{} }
 
 func (t *EchoTool) Name() string { return "echo" }
 
+func (t *EchoTool) ReadOnly(map[string]any) bool { return true }
+
 func (t *EchoTool) Description() string {
 	return "Echoes the provided value back to the agent. Useful for debugging and string formatting."
 }
@@ -0,0 +1,313 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// editFileMaxDiffBytes caps the diff echoed back to the model.
+const editFileMaxDiffBytes = 32 * 1024
+
+type EditFileTool struct {
+	Enabled  bool
+	MaxBytes int
+	BaseDirs []string
+}
+
+func NewEditFileTool(enabled bool, maxBytes int, baseDirs ...string) *EditFileTool {
+	if maxBytes <= 0 {
+		maxBytes = 1024 * 1024
+	}
+	return &EditFileTool{
+		Enabled:  enabled,
+		MaxBytes: maxBytes,
+		BaseDirs: writeBaseDirs(baseDirs),
+	}
+}
+
+func (t *EditFileTool) Name() string { return "edit_file" }
+
+func (t *EditFileTool) Description() string {
+	return "Edits a local text file in place with exact search/replace blocks or a unified diff, without resending the whole file. All edits apply or none do; conflicts are reported. Use dry_run to preview the resulting diff. Restricted to file_cache_dir or file_state_dir."
+}
+
+// UntrustedOutput: diffs and conflicts quote the file, like read_file.
+func (t *EditFileTool) UntrustedOutput() bool { return true }
+
+func (t *EditFileTool) ParameterSchema() string {
+	s := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "File path to edit. Resolved like write_file: relative paths under file_cache_dir, prefix with file_state_dir/ for the state dir.",
+			},
+			"edits": map[string]any{
+				"type":        "array",
+				"description": "Search/replace blocks applied in order. Each search must match the current text exactly once unless replace_all is set; include enough surrounding lines to make it unique.",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"search": map[string]any{
+							"type":        "string",
+							"description": "Exact text to find (including indentation).",
+						},
+						"replace": map[string]any{
+							"type":        "string",
+							"description": "Replacement text.",
+						},
+						"replace_all": map[string]any{
+							"type":        "boolean",
+							"description": "Replace every occurrence instead of requiring exactly one.",
+						},
+					},
+					"required": []string{"search", "replace"},
+				},
+			},
+			"patch": map[string]any{
+				"type":        "string",
+				"description": "Unified diff for this one file (@@ -a,b +c,d @@ hunks with ' ', '-', '+' lines). Hunks whose line numbers are off are placed by their context. A patch with only -0,0 hunks creates the file. Use instead of edits.",
+			},
+			"dry_run": map[string]any{
+				"type":        "boolean",
+				"description": "If true, return the resulting diff without writing (default: false).",
+			},
+		},
+		"required": []string{"path"},
+	}
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+type searchReplace struct {
+	search     string
+	replace    string
+	replaceAll bool
+}
+
+func (t *EditFileTool) Execute(_ context.Context, params map[string]any) (string, error) {
+	if !t.Enabled {
+		return "", fmt.Errorf("edit_file tool is disabled (enable via config: tools.edit_file.enabled=true)")
+	}
+
+	path, _ := params["path"].(string)
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", fmt.Errorf("missing required param: path")
+	}
+	edits, err := parseSearchReplace(params["edits"])
+	if err != nil {
+		return "", err
+	}
+	patch, _ := params["patch"].(string)
+	if strings.TrimSpace(patch) == "" {
+		patch = ""
+	}
+	switch {
+	case len(edits) == 0 && patch == "":
+		return "", fmt.Errorf("missing required param: edits or patch")
+	case len(edits) > 0 && patch != "":
+		return "", fmt.Errorf("use either edits or patch, not both")
+	}
+	dryRun, _ := params["dry_run"].(bool)
+
+	baseDir, resolvedPath, err := resolveWritePath(t.BaseDirs, path)
+	if err != nil {
+		return "", err
+	}
+	path = resolvedPath
+
+	var hunks []patchHunk
+	if patch != "" {
+		if hunks, err = parsePatch(patch); err != nil {
+			return "", err
+		}
+	}
+
+	perm := fs.FileMode(0o644)
+	exists := true
+	raw, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		exists = false
+		if !createsFile(hunks) {
+			return "", fmt.Errorf("file does not exist: %s (create it with write_file, or send a patch with a single -0,0 hunk)", path)
+		}
+	case err != nil:
+		return "", err
+	default:
+		if fi, err := os.Stat(path); err == nil {
+			perm = fi.Mode().Perm()
+		}
+	}
+	if t.MaxBytes > 0 && len(raw) > t.MaxBytes {
+		return "", fmt.Errorf("file too large to edit (%d bytes > %d max)", len(raw), t.MaxBytes)
+	}
+	before := string(raw)
+
+	var after string
+	var conflicts []editConflict
+	if patch != "" {
+		var res textLines
+		res, conflicts = applyPatch(splitText(before), hunks)
+		after = res.String()
+	} else {
+		after, conflicts = applySearchReplace(before, edits)
+	}
+	if len(conflicts) > 0 {
+		n := len(edits)
+		if patch != "" {
+			n = len(hunks)
+		}
+		out, _ := json.MarshalIndent(map[string]any{
+			"path":      path,
+			"conflicts": conflicts,
+		}, "", "  ")
+		return string(out), fmt.Errorf("%d of %d edits did not apply; file not modified", len(conflicts), n)
+	}
+	if t.MaxBytes > 0 && len(after) > t.MaxBytes {
+		return "", fmt.Errorf("edited file too large (%d bytes > %d max)", len(after), t.MaxBytes)
+	}
+
+	rel := filepath.Base(path)
+	if r, err := filepath.Rel(baseDir, path); err == nil {
+		rel = filepath.ToSlash(r)
+	}
+	diff := unifiedDiff(rel, splitText(before), splitText(after))
+	truncated := false
+	if len(diff) > editFileMaxDiffBytes {
+		diff = diff[:editFileMaxDiffBytes]
+		truncated = true
+	}
+
+	changed := after != before || !exists
+	if !dryRun && changed {
+		if dir := filepath.Dir(path); dir != "" && dir != "." {
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return "", err
+			}
+		}
+		if err := os.WriteFile(path, []byte(after), perm); err != nil {
+			return "", err
+		}
+	}
+
+	abs, _ := filepath.Abs(path)
+	out, _ := json.MarshalIndent(map[string]any{
+		"path":           path,
+		"abs_path":       abs,
+		"base_dir":       baseDir,
+		"dry_run":        dryRun,
+		"changed":        changed,
+		"bytes":          len(after),
+		"diff":           diff,
+		"diff_truncated": truncated,
+	}, "", "  ")
+	return string(out), nil
+}
+
+func parseSearchReplace(v any) ([]searchReplace, error) {
+	if v == nil {
+		return nil, nil
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid param: edits must be an array of {search, replace}")
+	}
+	out := make([]searchReplace, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("invalid param: edits[%d] must be an object", i)
+		}
+		search, _ := m["search"].(string)
+		if search == "" {
+			return nil, fmt.Errorf("invalid param: edits[%d].search is empty", i)
+		}
+		replace, ok := m["replace"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid param: edits[%d].replace is missing", i)
+		}
+		all, _ := m["replace_all"].(bool)
+		out = append(out, searchReplace{search: search, replace: replace, replaceAll: all})
+	}
+	return out, nil
+}
+
+// applySearchReplace applies edits in order, each against the result of
+// the previous one. Models write "\n"; in a CRLF file the blocks are
+// matched and inserted with "\r\n".
+func applySearchReplace(text string, edits []searchReplace) (string, []editConflict) {
+	crlf := strings.Contains(text, "\r\n")
+	out := text
+	var conflicts []editConflict
+	for i, e := range edits {
+		search, replace := e.search, e.replace
+		if crlf && !strings.Contains(out, search) {
+			search = strings.ReplaceAll(search, "\n", "\r\n")
+			replace = strings.ReplaceAll(replace, "\n", "\r\n")
+		}
+		n := strings.Count(out, search)
+		switch {
+		case n == 0:
+			conflicts = append(conflicts, editConflict{
+				Edit:     i + 1,
+				Reason:   "search text not found",
+				Expected: strings.Split(e.search, "\n"),
+				Hint:     searchHint(out, e.search),
+			})
+		case n > 1 && !e.replaceAll:
+			conflicts = append(conflicts, editConflict{
+				Edit:   i + 1,
+				Reason: fmt.Sprintf("search text matches %d times", n),
+				Hint:   "add surrounding lines to make it unique, or set replace_all",
+			})
+		default:
+			out = strings.ReplaceAll(out, search, replace)
+		}
+	}
+	if len(conflicts) > 0 {
+		return text, conflicts
+	}
+	return out, nil
+}
+
+// searchHint points at where a missing block probably lives: the first
+// line whose trimmed text equals the block's first non-blank line.
+func searchHint(text, search string) string {
+	first := ""
+	for _, l := range strings.Split(search, "\n") {
+		if strings.TrimSpace(l) != "" {
+			first = strings.TrimSpace(l)
+			break
+		}
+	}
+	if first == "" {
+		return ""
+	}
+	for n, l := range splitText(text).lines {
+		if strings.TrimSpace(l) == first {
+			return fmt.Sprintf("the first line of search appears at line %d; check whitespace and the following lines against the file", n+1)
+		}
+	}
+	return "re-read the file; it may have changed"
+}
+
+// createsFile reports whether hunks only add lines to an empty file.
+func createsFile(hunks []patchHunk) bool {
+	if len(hunks) == 0 {
+		return false
+	}
+	for _, h := range hunks {
+		if len(h.old) > 0 || h.oldStart != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,424 @@
+package builtin
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// textLines is a file split into lines without terminators. eol is the
+// file's line ending and final reports whether the last line ends with it.
+type textLines struct {
+	lines []string
+	eol   string
+	final bool
+}
+
+func splitText(s string) textLines {
+	t := textLines{eol: "\n"}
+	if strings.Contains(s, "\r\n") {
+		t.eol = "\r\n"
+	}
+	if s == "" {
+		return t
+	}
+	t.final = strings.HasSuffix(s, "\n")
+	s = strings.TrimSuffix(s, "\n")
+	for _, l := range strings.Split(s, "\n") {
+		t.lines = append(t.lines, strings.TrimSuffix(l, "\r"))
+	}
+	return t
+}
+
+func (t textLines) String() string {
+	if len(t.lines) == 0 {
+		return ""
+	}
+	s := strings.Join(t.lines, t.eol)
+	if t.final {
+		s += t.eol
+	}
+	return s
+}
+
+type patchHunk struct {
+	header   string
+	oldStart int
+	old      []string
+	new      []string
+	// noFinalEOL is set when the new side ends with "\ No newline at end of file".
+	noFinalEOL bool
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parsePatch reads the hunks of a single-file unified diff. File headers
+// (---/+++, diff --git, index) are skipped; hunk line counts are not
+// trusted, since models often get them wrong.
+func parsePatch(patch string) ([]patchHunk, error) {
+	var hunks []patchHunk
+	var cur *patchHunk
+	lastSide := byte(0)
+	patch = strings.TrimSuffix(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	prev := ""
+	for _, line := range strings.Split(patch, "\n") {
+		before := prev
+		prev = line
+		if strings.HasPrefix(line, "diff ") || (cur != nil && strings.HasPrefix(line, "+++ ") && strings.HasPrefix(before, "--- ")) {
+			return nil, fmt.Errorf("patch touches more than one file; send one edit_file call per file")
+		}
+		if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.Atoi(m[1])
+			hunks = append(hunks, patchHunk{header: m[0], oldStart: start})
+			cur = &hunks[len(hunks)-1]
+			lastSide = 0
+			continue
+		}
+		if cur == nil {
+			// Preamble: diff/index/---/+++ lines or prose around the patch.
+			continue
+		}
+		if line == "" {
+			// A bare empty line is an empty context line that lost its space.
+			cur.old = append(cur.old, "")
+			cur.new = append(cur.new, "")
+			lastSide = ' '
+			continue
+		}
+		switch line[0] {
+		case ' ':
+			cur.old = append(cur.old, line[1:])
+			cur.new = append(cur.new, line[1:])
+		case '-':
+			cur.old = append(cur.old, line[1:])
+		case '+':
+			cur.new = append(cur.new, line[1:])
+		case '\\':
+			if lastSide == '+' || lastSide == ' ' {
+				cur.noFinalEOL = true
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("invalid patch line in hunk %q: %q", cur.header, line)
+		}
+		lastSide = line[0]
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("patch has no hunks (expected unified diff with @@ -a,b +c,d @@ headers)")
+	}
+	return hunks, nil
+}
+
+// editConflict describes an edit that could not be applied.
+type editConflict struct {
+	Edit     int      `json:"edit"`
+	Hunk     string   `json:"hunk,omitempty"`
+	Reason   string   `json:"reason"`
+	Expected []string `json:"expected,omitempty"`
+	Actual   []string `json:"actual,omitempty"`
+	Hint     string   `json:"hint,omitempty"`
+}
+
+// applyPatch applies hunks in order. A hunk is placed at its stated line
+// when the old side matches there, otherwise at the nearest exact match
+// after the previous hunk, then the nearest match ignoring trailing
+// whitespace. It returns the conflicts instead of a partial result.
+func applyPatch(t textLines, hunks []patchHunk) (textLines, []editConflict) {
+	out := t
+	out.lines = append([]string(nil), t.lines...)
+	var conflicts []editConflict
+	minPos, shift := 0, 0
+	for i, h := range hunks {
+		want := h.oldStart - 1 + shift
+		if len(h.old) == 0 {
+			// Pure insertion: "-N,0" means after line N.
+			want = h.oldStart + shift
+		}
+		pos := findHunk(out.lines, h.old, want, minPos)
+		if pos < 0 {
+			c := editConflict{Edit: i + 1, Hunk: h.header, Reason: "context does not match the file", Expected: h.old}
+			if want < 0 {
+				want = 0
+			}
+			end := want + len(h.old)
+			if want < len(out.lines) {
+				if end > len(out.lines) {
+					end = len(out.lines)
+				}
+				c.Actual = append([]string(nil), out.lines[want:end]...)
+			}
+			conflicts = append(conflicts, c)
+			continue
+		}
+		lines := make([]string, 0, len(out.lines)-len(h.old)+len(h.new))
+		lines = append(lines, out.lines[:pos]...)
+		lines = append(lines, h.new...)
+		lines = append(lines, out.lines[pos+len(h.old):]...)
+		if pos+len(h.old) == len(out.lines) {
+			// The hunk reaches the end of the file: it decides the final newline.
+			out.final = !h.noFinalEOL
+		}
+		out.lines = lines
+		shift += len(h.new) - len(h.old)
+		minPos = pos + len(h.new)
+	}
+	if len(conflicts) > 0 {
+		return t, conflicts
+	}
+	return out, nil
+}
+
+func findHunk(lines, old []string, want, minPos int) int {
+	if len(old) == 0 {
+		if want < minPos {
+			want = minPos
+		}
+		if want > len(lines) {
+			want = len(lines)
+		}
+		return want
+	}
+	for _, eq := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		best := -1
+		for p := minPos; p+len(old) <= len(lines); p++ {
+			if !linesMatch(lines[p:p+len(old)], old, eq) {
+				continue
+			}
+			if best < 0 || absInt(p-want) < absInt(best-want) {
+				best = p
+			}
+			if p >= want {
+				break
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return -1
+}
+
+func linesMatch(a, b []string, eq func(a, b string) bool) bool {
+	for i := range b {
+		if !eq(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// diffMaxEdits bounds the Myers search; beyond it the changed region is
+// shown as one replacement.
+const diffMaxEdits = 2000
+
+type diffOp struct {
+	kind byte // ' ', '-', '+'
+	text string
+}
+
+// unifiedDiff renders the change from a to b as a unified diff with three
+// lines of context, or "" when they are equal.
+func unifiedDiff(name string, a, b textLines) string {
+	ops := diffLines(a.diffLines(), b.diffLines())
+	const ctx = 3
+	var sb strings.Builder
+	oldLine, newLine := 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// Hunk: back up ctx context lines, then run until ctx*2 unchanged lines.
+		start := i
+		for start > 0 && i-start < ctx && ops[start-1].kind == ' ' {
+			start--
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*ctx {
+				end += minInt(ctx, run-end)
+				break
+			}
+			end = run
+		}
+		oStart, nStart := oldLine-(i-start), newLine-(i-start)
+		oCount, nCount := 0, 0
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			body.WriteByte(op.kind)
+			if text, ok := strings.CutSuffix(op.text, noEOLMark); ok {
+				body.WriteString(text + "\n\\ No newline at end of file\n")
+			} else {
+				body.WriteString(op.text + "\n")
+			}
+			if op.kind != '+' {
+				oCount++
+			}
+			if op.kind != '-' {
+				nCount++
+			}
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(oStart, oCount), hunkRange(nStart, nCount))
+		sb.WriteString(body.String())
+		oldLine = oStart + oCount
+		newLine = nStart + nCount
+		i = end
+	}
+	return sb.String()
+}
+
+// noEOLMark tags a last line that lacks a line ending, so diffLines sees
+// adding or dropping the final newline as a change to that line.
+const noEOLMark = "\x00noeol"
+
+func (t textLines) diffLines() []string {
+	if t.final || len(t.lines) == 0 {
+		return t.lines
+	}
+	lines := append([]string(nil), t.lines...)
+	lines[len(lines)-1] += noEOLMark
+	return lines
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return strconv.Itoa(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// diffLines returns a line edit script from a to b (Myers' algorithm on
+// the part between the common prefix and suffix).
+func diffLines(a, b []string) []diffOp {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, myers(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+func myers(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	off := max
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	found := false
+	for d := 0; d <= max && d <= diffMaxEdits && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		ops := make([]diffOp, 0, n+m)
+		for _, l := range a {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range b {
+			ops = append(ops, diffOp{'+', l})
+		}
+		return ops
+	}
+
+	var rev []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			rev = append(rev, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			rev = append(rev, diffOp{'+', b[y-1]})
+			y--
+		} else {
+			rev = append(rev, diffOp{'-', a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, diffOp{' ', a[x-1]})
+		x--
+		y--
+	}
+	ops := make([]diffOp, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
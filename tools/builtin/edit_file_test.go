@@ -0,0 +1,149 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditFileSearchReplace(t *testing.T) {
+	base := t.TempDir()
+	path := filepath.Join(base, "a.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc A() int {\n\treturn 1\n}\n\nfunc B() int {\n\treturn 1\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewEditFileTool(true, 0, base)
+
+	out, err := tool.Execute(context.Background(), map[string]any{
+		"path": "a.go",
+		"edits": []any{
+			map[string]any{"search": "func B() int {\n\treturn 1", "replace": "func B() int {\n\treturn 2"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v (out=%s)", err, out)
+	}
+	var res map[string]any
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	if diff := res["diff"].(string); !strings.Contains(diff, "@@ -5,5 +5,5 @@\n }\n \n func B() int {\n-\treturn 1\n+\treturn 2\n }\n") {
+		t.Fatalf("diff = %q", diff)
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.HasSuffix(string(b), "func B() int {\n\treturn 2\n}\n") || !strings.Contains(string(b), "func A() int {\n\treturn 1\n") {
+		t.Fatalf("content = %q", b)
+	}
+
+	// Ambiguous and missing blocks are conflicts; nothing is written.
+	out, err = tool.Execute(context.Background(), map[string]any{
+		"path": "a.go",
+		"edits": []any{
+			map[string]any{"search": "package a", "replace": "package b"},
+			map[string]any{"search": "\treturn", "replace": "\tr"},
+			map[string]any{"search": "func C() int {", "replace": ""},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "2 of 3 edits") {
+		t.Fatalf("err = %v, want 2 conflicts", err)
+	}
+	if !strings.Contains(out, "matches 2 times") || !strings.Contains(out, "search text not found") {
+		t.Fatalf("conflicts = %s", out)
+	}
+	if b2, _ := os.ReadFile(path); string(b2) != string(b) {
+		t.Fatal("file changed despite conflicts")
+	}
+}
+
+func TestEditFilePatchDryRunAndOffset(t *testing.T) {
+	base := t.TempDir()
+	path := filepath.Join(base, "notes.md")
+	orig := "# Notes\r\n\r\none\r\ntwo\r\nthree\r\n"
+	if err := os.WriteFile(path, []byte(orig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewEditFileTool(true, 0, base)
+
+	// Line numbers are off by two; the context places the hunk.
+	patch := "--- a/notes.md\n+++ b/notes.md\n@@ -5,2 +5,3 @@\n one\n-two\n+2\n+2.5\n"
+	out, err := tool.Execute(context.Background(), map[string]any{"path": "notes.md", "patch": patch, "dry_run": true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !strings.Contains(out, `"changed": true`) || !strings.Contains(out, `-two\n+2\n+2.5`) {
+		t.Fatalf("dry run out = %s", out)
+	}
+	if b, _ := os.ReadFile(path); string(b) != orig {
+		t.Fatal("dry run wrote the file")
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]any{"path": "notes.md", "patch": patch}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	b, _ := os.ReadFile(path)
+	if string(b) != "# Notes\r\n\r\none\r\n2\r\n2.5\r\nthree\r\n" {
+		t.Fatalf("content = %q", b)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o600 {
+		t.Fatalf("mode = %v, want 0600 kept", fi.Mode().Perm())
+	}
+
+	out, err = tool.Execute(context.Background(), map[string]any{"path": "notes.md", "patch": "@@ -1,2 +1,2 @@\n # Notes\n-missing\n+x\n"})
+	if err == nil || !strings.Contains(out, "context does not match") {
+		t.Fatalf("expected conflict, got err=%v out=%s", err, out)
+	}
+}
+
+func TestEditFilePatchCreatesFileAndStaysInBase(t *testing.T) {
+	base := t.TempDir()
+	tool := NewEditFileTool(true, 0, base)
+
+	if _, err := tool.Execute(context.Background(), map[string]any{"path": "sub/new.txt", "patch": "@@ -0,0 +1,2 @@\n+hello\n+world\n"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(base, "sub", "new.txt"))
+	if err != nil || string(b) != "hello\nworld\n" {
+		t.Fatalf("content = %q, err = %v", b, err)
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]any{"path": "missing.txt", "edits": []any{map[string]any{"search": "a", "replace": "b"}}}); err == nil {
+		t.Fatal("expected error editing a missing file")
+	}
+	if _, err := tool.Execute(context.Background(), map[string]any{"path": "../escape.txt", "patch": "@@ -0,0 +1 @@\n+x\n"}); err == nil {
+		t.Fatal("expected error outside base dir")
+	}
+}
+
+func TestUnifiedDiffFinalNewline(t *testing.T) {
+	diff := unifiedDiff("f", splitText("a\nb"), splitText("a\nb\n"))
+	want := "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"
+	if diff != want {
+		t.Fatalf("diff = %q, want %q", diff, want)
+	}
+	if d := unifiedDiff("f", splitText("x\n"), splitText("x\n")); d != "" {
+		t.Fatalf("diff of equal texts = %q", d)
+	}
+}
+
+func TestUnifiedDiffRoundTrip(t *testing.T) {
+	cases := [][2]string{
+		{"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n", "a\nB\nc\nd\ne\nf\ng\nh\ni\nJ\nk\nl\n"},
+		{"", "new\nfile\n"},
+		{"x\ny\nz\n", "z\ny\nx"},
+		{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n1\n2\n4\n5\n6\n7\n8\n9\n10\n11\n"},
+	}
+	for i, c := range cases {
+		from, to := splitText(c[0]), splitText(c[1])
+		hunks, err := parsePatch(unifiedDiff("f", from, to))
+		if err != nil {
+			t.Fatalf("case %d: parse: %v", i, err)
+		}
+		got, conflicts := applyPatch(from, hunks)
+		if len(conflicts) > 0 || got.String() != c[1] {
+			t.Fatalf("case %d: got %q (conflicts %v), want %q", i, got.String(), conflicts, c[1])
+		}
+	}
+}
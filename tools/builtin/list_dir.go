@@ -0,0 +1,215 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ListDirTool struct {
+	MaxEntries int
+	DenyPaths  []string
+	BaseDirs   []string
+}
+
+func NewListDirTool(maxEntries int, denyPaths []string, baseDirs ...string) *ListDirTool {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &ListDirTool{MaxEntries: maxEntries, DenyPaths: denyPaths, BaseDirs: normalizeBaseDirs(baseDirs)}
+}
+
+func (t *ListDirTool) Name() string { return "list_dir" }
+
+func (t *ListDirTool) ReadOnly(map[string]any) bool { return true }
+
+func (t *ListDirTool) Description() string {
+	return "Lists a local directory (optionally recursively, filtered by a glob) with type, size and modification time of each entry. Files denied to read_file are omitted."
+}
+
+func (t *ListDirTool) ParameterSchema() string {
+	s := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to list. Supports aliases `file_cache_dir/<path>` and `file_state_dir/<path>` (or just the alias).",
+			},
+			"recursive": map[string]any{
+				"type":        "boolean",
+				"description": "List subdirectories too (default: false).",
+			},
+			"max_depth": map[string]any{
+				"type":        "integer",
+				"description": "Optional recursion depth limit (1 = direct children only).",
+			},
+			"glob": map[string]any{
+				"type":        "string",
+				"description": "Optional glob (e.g. `*.go`). Matched against the entry name, or against the path relative to `path` when it contains `/`.",
+			},
+			"include_hidden": map[string]any{
+				"type":        "boolean",
+				"description": "Include dot files and descend into dot directories (default: false).",
+			},
+		},
+		"required": []string{"path"},
+	}
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+type dirEntry struct {
+	Path    string `json:"path"`
+	Type    string `json:"type"`
+	Size    int64  `json:"size,omitempty"`
+	ModTime string `json:"mtime"`
+}
+
+func (t *ListDirTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	root, err := resolveReadRoot(t.BaseDirs, t.DenyPaths, params, "list_dir")
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(root); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory (use read_file)", root)
+	}
+	recursive := parseBoolDefault(params["recursive"], false)
+	maxDepth, _ := paramInt(params, "max_depth")
+	if !recursive {
+		maxDepth = 1
+	}
+	glob, _ := params["glob"].(string)
+	glob = strings.TrimSpace(glob)
+	if glob != "" {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return "", fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+	hidden := parseBoolDefault(params["include_hidden"], false)
+
+	entries := []dirEntry{}
+	truncated := false
+	errStop := errors.New("stop")
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if p == root {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		skip, err := skipWalkEntry(p, rel, d, walkErr, hidden, maxDepth, t.DenyPaths)
+		if skip || err != nil {
+			return err
+		}
+		if glob != "" && !matchGlob(glob, rel) {
+			return nil
+		}
+		if len(entries) >= t.MaxEntries {
+			truncated = true
+			return errStop
+		}
+		e := dirEntry{Path: rel, Type: "file"}
+		if info, err := d.Info(); err == nil {
+			e.ModTime = info.ModTime().UTC().Format(time.RFC3339)
+			switch {
+			case d.IsDir():
+				e.Type = "dir"
+			case info.Mode()&fs.ModeSymlink != 0:
+				e.Type = "symlink"
+			case !info.Mode().IsRegular():
+				e.Type = "other"
+			default:
+				e.Size = info.Size()
+			}
+		}
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return "", err
+	}
+
+	out, _ := json.MarshalIndent(map[string]any{
+		"path":      root,
+		"entries":   entries,
+		"count":     len(entries),
+		"truncated": truncated,
+	}, "", "  ")
+	return string(out), nil
+}
+
+// resolveReadRoot resolves params["path"] like read_file and checks it
+// against the deny list; tool names the caller in errors.
+func resolveReadRoot(baseDirs, denyPaths []string, params map[string]any, tool string) (string, error) {
+	path, _ := params["path"].(string)
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", fmt.Errorf("missing required param: path")
+	}
+	var err error
+	if alias, rest := detectWritePathAlias(path); alias != "" && strings.TrimSpace(rest) == "" {
+		// A bare alias names the base dir itself.
+		path = selectBaseForAlias(baseDirs, alias)
+		if strings.TrimSpace(path) == "" {
+			return "", fmt.Errorf("base dir %s is not configured", alias)
+		}
+		path, err = filepath.Abs(path)
+	} else {
+		resolver := &ReadFileTool{BaseDirs: baseDirs}
+		path, err = resolver.resolvePath(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if offending, ok := denyPath(path, denyPaths); ok {
+		return "", fmt.Errorf("%s denied for path %q (matched %q)", tool, path, offending)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// skipWalkEntry applies the walk rules shared by list_dir and search_files:
+// unreadable entries, hidden names, the depth limit and the deny list are
+// skipped (directories with their contents).
+func skipWalkEntry(p, rel string, d fs.DirEntry, walkErr error, hidden bool, maxDepth int, denyPaths []string) (bool, error) {
+	skipDir := func() (bool, error) {
+		if d != nil && d.IsDir() {
+			return true, filepath.SkipDir
+		}
+		return true, nil
+	}
+	if walkErr != nil {
+		return skipDir()
+	}
+	if !hidden && strings.HasPrefix(d.Name(), ".") {
+		return skipDir()
+	}
+	if _, denied := denyPath(p, denyPaths); denied {
+		return skipDir()
+	}
+	if maxDepth > 0 && strings.Count(rel, "/")+1 > maxDepth {
+		return skipDir()
+	}
+	return false, nil
+}
+
+// matchGlob matches the entry name, or the relative path when glob has a
+// separator.
+func matchGlob(glob, rel string) bool {
+	name := rel
+	if !strings.Contains(glob, "/") {
+		name = filepath.Base(filepath.FromSlash(rel))
+	}
+	ok, _ := filepath.Match(glob, name)
+	return ok
+}
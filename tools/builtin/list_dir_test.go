@@ -0,0 +1,69 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestListDirTool(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.go":            "package a",
+		"b.txt":           "hello",
+		"sub/c.go":        "package sub",
+		"sub/config.yaml": "secret: x",
+		"sub/deep/d.go":   "package deep",
+		".git/HEAD":       "ref",
+	})
+	tool := NewListDirTool(0, []string{"config.yaml"}, root)
+
+	list := func(params map[string]any) []string {
+		t.Helper()
+		out, err := tool.Execute(context.Background(), params)
+		if err != nil {
+			t.Fatalf("Execute(%v): %v", params, err)
+		}
+		var res struct {
+			Entries []dirEntry `json:"entries"`
+		}
+		if err := json.Unmarshal([]byte(out), &res); err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, e := range res.Entries {
+			paths = append(paths, e.Path+":"+e.Type)
+		}
+		return paths
+	}
+
+	if got := strings.Join(list(map[string]any{"path": "file_cache_dir"}), ","); got != "a.go:file,b.txt:file,sub:dir" {
+		t.Fatalf("flat = %s", got)
+	}
+	if got := strings.Join(list(map[string]any{"path": root, "recursive": true, "glob": "*.go"}), ","); got != "a.go:file,sub/c.go:file,sub/deep/d.go:file" {
+		t.Fatalf("recursive glob = %s", got)
+	}
+	if got := strings.Join(list(map[string]any{"path": "file_cache_dir/sub", "recursive": true, "max_depth": 1}), ","); got != "c.go:file,deep:dir" {
+		t.Fatalf("max_depth = %s (config.yaml must stay hidden)", got)
+	}
+	if _, err := tool.Execute(context.Background(), map[string]any{"path": "file_cache_dir/../"}); err == nil {
+		t.Fatal("expected alias escape to fail")
+	}
+}
@@ -39,6 +39,8 @@ func NewMemoryRecentlyTool(enabled bool, memoryDir string, defaultDays int, maxI
 
 func (t *MemoryRecentlyTool) Name() string { return "memory_recently" }
 
+func (t *MemoryRecentlyTool) ReadOnly(map[string]any) bool { return true }
+
 func (t *MemoryRecentlyTool) Description() string {
 	return "Reads recent short-term memory items. Returns summary plus metadata (including contact_id and Telegram chat hints when available)."
 }
@@ -197,3 +197,15 @@ func asInt64(v any) (int64, bool) {
 		return 0, false
 	}
 }
+
+// paramInt reads an integer param, reporting whether it was given.
+func paramInt(params map[string]any, key string) (int, bool) {
+	raw, ok := params[key]
+	if !ok || raw == nil {
+		return 0, false
+	}
+	if s, isStr := raw.(string); isStr && strings.TrimSpace(s) == "" {
+		return 0, false
+	}
+	return parseIntDefault(raw, 0), true
+}
@@ -0,0 +1,525 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+const (
+	processMaxWait  = 60 * time.Second
+	processKillWait = 3 * time.Second
+)
+
+var processNameRe = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// ProcessManager owns the background jobs started by process_start. Jobs
+// belong to the run that started them and are killed when it ends, so
+// process_start is refused outside a run (direct tool calls such as MCP
+// serve have no run to end). On Linux jobs are also killed if the agent
+// itself dies.
+type ProcessManager struct {
+	// MaxJobs caps the jobs running at once across all runs.
+	MaxJobs     int
+	BufferBytes int
+	DenyPaths   []string
+	DenyTokens  []string
+	// Sandbox, when set, starts jobs in the same sandbox as bash.
+	Sandbox *BashSandbox
+
+	mu   sync.Mutex
+	jobs map[string]*processJob
+}
+
+func NewProcessManager(maxJobs int, bufferBytes int) *ProcessManager {
+	if maxJobs <= 0 {
+		maxJobs = 4
+	}
+	if bufferBytes <= 0 {
+		bufferBytes = 64 * 1024
+	}
+	return &ProcessManager{
+		MaxJobs:     maxJobs,
+		BufferBytes: bufferBytes,
+		jobs:        map[string]*processJob{},
+	}
+}
+
+// Tools returns process_start, process_status, process_output and
+// process_kill, all backed by m.
+func (m *ProcessManager) Tools() []tools.Tool {
+	return []tools.Tool{
+		&ProcessStartTool{m: m},
+		&ProcessStatusTool{m: m},
+		&ProcessOutputTool{m: m},
+		&ProcessKillTool{m: m},
+	}
+}
+
+// EndRun kills the jobs runID started.
+func (m *ProcessManager) EndRun(runID string) {
+	m.mu.Lock()
+	var jobs []*processJob
+	for key, j := range m.jobs {
+		if j.runID == runID {
+			jobs = append(jobs, j)
+			delete(m.jobs, key)
+		}
+	}
+	m.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func(j *processJob) {
+			defer wg.Done()
+			j.kill()
+		}(j)
+	}
+	wg.Wait()
+}
+
+func (m *ProcessManager) start(runID, name, cmdStr, cwd string) (*processJob, error) {
+	if runID == "" {
+		return nil, fmt.Errorf("process_start is only available inside an agent run (jobs are killed when their run ends)")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := runID + "/" + name
+	running := 0
+	for k, j := range m.jobs {
+		if k == key {
+			if !j.exited() {
+				return nil, fmt.Errorf("process %q is already running (kill it first or pick another name)", name)
+			}
+			delete(m.jobs, k)
+			continue
+		}
+		if !j.exited() {
+			running++
+		}
+	}
+	if running >= m.MaxJobs {
+		return nil, fmt.Errorf("too many running processes (max %d, configure via tools.process.max_jobs)", m.MaxJobs)
+	}
+
+	var cmd *exec.Cmd
+	if m.Sandbox != nil {
+		var err error
+		// Jobs outlive the tool call, so they are not bound to its context.
+		cmd, err = m.Sandbox.command(context.Background(), cmdStr, cwd)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		cmd = exec.Command("bash", "-lc", cmdStr)
+		if cwd != "" {
+			cmd.Dir = cwd
+		}
+	}
+	j := &processJob{
+		runID:   runID,
+		name:    name,
+		cmdStr:  cmdStr,
+		cmd:     cmd,
+		out:     &ringBuffer{size: m.BufferBytes},
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	cmd.Stdout = j.out
+	cmd.Stderr = j.out
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go j.wait()
+	m.jobs[key] = j
+	return j, nil
+}
+
+func (m *ProcessManager) get(runID, name string) (*processJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[runID+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("no process named %q", name)
+	}
+	return j, nil
+}
+
+func (m *ProcessManager) list(runID string) []*processJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*processJob
+	for _, j := range m.jobs {
+		if j.runID == runID {
+			out = append(out, j)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].started.Before(out[b].started) })
+	return out
+}
+
+type processJob struct {
+	runID   string
+	name    string
+	cmdStr  string
+	cmd     *exec.Cmd
+	out     *ringBuffer
+	started time.Time
+	done    chan struct{}
+
+	mu       sync.Mutex
+	ended    time.Time
+	exitCode int
+	killed   bool
+}
+
+func (j *processJob) wait() {
+	err := j.cmd.Wait()
+	j.mu.Lock()
+	j.ended = time.Now()
+	j.exitCode = 0
+	if err != nil {
+		j.exitCode = -1
+		if ee, ok := err.(*exec.ExitError); ok {
+			j.exitCode = ee.ExitCode()
+		}
+	}
+	j.mu.Unlock()
+	close(j.done)
+}
+
+func (j *processJob) exited() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// kill stops the job's process group: SIGTERM first, SIGKILL if it is
+// still around after processKillWait.
+func (j *processJob) kill() {
+	if j.exited() {
+		return
+	}
+	j.mu.Lock()
+	j.killed = true
+	j.mu.Unlock()
+	terminateProcessGroup(j.cmd)
+	select {
+	case <-j.done:
+		return
+	case <-time.After(processKillWait):
+	}
+	killProcessGroup(j.cmd)
+	<-j.done
+}
+
+func (j *processJob) status() map[string]any {
+	s := map[string]any{
+		"name":    j.name,
+		"cmd":     j.cmdStr,
+		"started": j.started.UTC().Format(time.RFC3339),
+		"running": !j.exited(),
+	}
+	if j.cmd.Process != nil {
+		s["pid"] = j.cmd.Process.Pid
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.ended.IsZero() {
+		s["exit_code"] = j.exitCode
+		s["killed"] = j.killed
+		s["duration_seconds"] = j.ended.Sub(j.started).Round(time.Millisecond).Seconds()
+	}
+	s["output_bytes"] = j.out.Total()
+	return s
+}
+
+// ringBuffer keeps the last size bytes written to it. Offsets count every
+// byte ever written, so readers can resume from a cursor and tell when the
+// bytes they missed were dropped.
+type ringBuffer struct {
+	mu    sync.Mutex
+	size  int
+	buf   []byte
+	total int64
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.total += int64(len(p))
+	if len(p) >= r.size {
+		r.buf = append(r.buf[:0], p[len(p)-r.size:]...)
+		return len(p), nil
+	}
+	if over := len(r.buf) + len(p) - r.size; over > 0 {
+		r.buf = append(r.buf[:0], r.buf[over:]...)
+	}
+	r.buf = append(r.buf, p...)
+	return len(p), nil
+}
+
+func (r *ringBuffer) Total() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total
+}
+
+// since returns the bytes from offset on, the offset to continue from and
+// whether bytes before the returned chunk were dropped.
+func (r *ringBuffer) since(offset int64) ([]byte, int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	start := r.total - int64(len(r.buf))
+	dropped := false
+	if offset < start {
+		offset = start
+		dropped = true
+	}
+	if offset > r.total {
+		offset = r.total
+	}
+	return append([]byte(nil), r.buf[offset-start:]...), r.total, dropped
+}
+
+func processName(params map[string]any) (string, error) {
+	name, _ := params["name"].(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("missing required param: name")
+	}
+	if !processNameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid process name %q (use letters, digits, '.', '_' or '-')", name)
+	}
+	return name, nil
+}
+
+func processJSON(v any) string {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return string(b)
+}
+
+func processSchema(properties map[string]any, required ...string) string {
+	s := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return processJSON(s)
+}
+
+var processNameParam = map[string]any{
+	"type":        "string",
+	"description": "Job name, unique within the run (letters, digits, '.', '_', '-').",
+}
+
+type ProcessStartTool struct{ m *ProcessManager }
+
+func (t *ProcessStartTool) Name() string { return "process_start" }
+
+func (t *ProcessStartTool) Description() string {
+	return "Starts a bash command as a named background job and returns immediately. Use it for servers, watchers and long builds; read output with process_output. Jobs are killed when the run ends."
+}
+
+func (t *ProcessStartTool) ParameterSchema() string {
+	return processSchema(map[string]any{
+		"name": processNameParam,
+		"cmd": map[string]any{
+			"type":        "string",
+			"description": "Bash command to run in the background.",
+		},
+		"cwd": map[string]any{
+			"type":        "string",
+			"description": "Optional working directory.",
+		},
+	}, "name", "cmd")
+}
+
+// EndRun makes the engine kill a run's jobs when the run ends.
+func (t *ProcessStartTool) EndRun(runID string) { t.m.EndRun(runID) }
+
+func (t *ProcessStartTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	name, err := processName(params)
+	if err != nil {
+		return "", err
+	}
+	cmdStr, _ := params["cmd"].(string)
+	cmdStr = strings.TrimSpace(cmdStr)
+	if cmdStr == "" {
+		return "", fmt.Errorf("missing required param: cmd")
+	}
+	if offending, ok := bashCommandDenied(cmdStr, t.m.DenyPaths); ok {
+		return "", fmt.Errorf("process command references denied path %q (configure via tools.bash.deny_paths)", offending)
+	}
+	if offending, ok := bashCommandDeniedTokens(cmdStr, t.m.DenyTokens); ok {
+		return "", fmt.Errorf("process command references denied token %q", offending)
+	}
+	cwd, _ := params["cwd"].(string)
+	cwd = strings.TrimSpace(cwd)
+
+	j, err := t.m.start(tools.RunIDFromContext(ctx), name, cmdStr, cwd)
+	if err != nil {
+		return "", err
+	}
+	return processJSON(j.status()), nil
+}
+
+type ProcessStatusTool struct{ m *ProcessManager }
+
+func (t *ProcessStatusTool) Name() string { return "process_status" }
+
+func (t *ProcessStatusTool) Description() string {
+	return "Reports whether background jobs started by process_start are running, with exit codes of finished ones. Omit name to list all jobs of this run."
+}
+
+func (t *ProcessStatusTool) ParameterSchema() string {
+	return processSchema(map[string]any{"name": processNameParam})
+}
+
+func (t *ProcessStatusTool) ReadOnly(map[string]any) bool { return true }
+
+func (t *ProcessStatusTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	runID := tools.RunIDFromContext(ctx)
+	if v, _ := params["name"].(string); strings.TrimSpace(v) != "" {
+		name, err := processName(params)
+		if err != nil {
+			return "", err
+		}
+		j, err := t.m.get(runID, name)
+		if err != nil {
+			return "", err
+		}
+		return processJSON(j.status()), nil
+	}
+	jobs := t.m.list(runID)
+	out := make([]map[string]any, 0, len(jobs))
+	for _, j := range jobs {
+		out = append(out, j.status())
+	}
+	return processJSON(map[string]any{"processes": out}), nil
+}
+
+type ProcessOutputTool struct{ m *ProcessManager }
+
+func (t *ProcessOutputTool) Name() string { return "process_output" }
+
+func (t *ProcessOutputTool) Description() string {
+	return "Reads combined stdout/stderr of a background job. Pass the returned next_offset as since to read only new output; wait_seconds waits for new output or exit first. Only the most recent output is kept."
+}
+
+func (t *ProcessOutputTool) ParameterSchema() string {
+	return processSchema(map[string]any{
+		"name": processNameParam,
+		"since": map[string]any{
+			"type":        "integer",
+			"description": "Optional offset to read from (next_offset of a previous call). Defaults to the oldest buffered byte.",
+		},
+		"tail_bytes": map[string]any{
+			"type":        "integer",
+			"description": "Optional: return at most this many bytes from the end.",
+		},
+		"wait_seconds": map[string]any{
+			"type":        "number",
+			"description": "Optional: wait up to this long (max 60) for output past since or for the job to exit.",
+		},
+	}, "name")
+}
+
+func (t *ProcessOutputTool) ReadOnly(map[string]any) bool { return true }
+
+func (t *ProcessOutputTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	name, err := processName(params)
+	if err != nil {
+		return "", err
+	}
+	j, err := t.m.get(tools.RunIDFromContext(ctx), name)
+	if err != nil {
+		return "", err
+	}
+	var since int64
+	if v, ok := asFloat64(params["since"]); ok && v > 0 {
+		since = int64(v)
+	}
+	if v, ok := asFloat64(params["wait_seconds"]); ok && v > 0 {
+		wait := time.Duration(v * float64(time.Second))
+		if wait > processMaxWait {
+			wait = processMaxWait
+		}
+		j.waitOutput(ctx, since, wait)
+	}
+
+	data, next, dropped := j.out.since(since)
+	if v, ok := asFloat64(params["tail_bytes"]); ok && v > 0 && int(v) < len(data) {
+		data = data[len(data)-int(v):]
+		dropped = true
+	}
+	s := j.status()
+	return processJSON(map[string]any{
+		"name":        name,
+		"running":     s["running"],
+		"exit_code":   s["exit_code"],
+		"next_offset": next,
+		"truncated":   dropped,
+		"output":      string(bytes.ToValidUTF8(data, []byte("\n[non-utf8 output]\n"))),
+	}), nil
+}
+
+// waitOutput returns once output past offset exists, the job exits, ctx
+// ends or wait elapses.
+func (j *processJob) waitOutput(ctx context.Context, offset int64, wait time.Duration) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	tick := time.NewTicker(200 * time.Millisecond)
+	defer tick.Stop()
+	for j.out.Total() <= offset {
+		select {
+		case <-j.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-deadline.C:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+type ProcessKillTool struct{ m *ProcessManager }
+
+func (t *ProcessKillTool) Name() string { return "process_kill" }
+
+func (t *ProcessKillTool) Description() string {
+	return "Stops a background job and everything it spawned (SIGTERM, then SIGKILL after 3 seconds). Its output stays readable."
+}
+
+func (t *ProcessKillTool) ParameterSchema() string {
+	return processSchema(map[string]any{"name": processNameParam}, "name")
+}
+
+func (t *ProcessKillTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	name, err := processName(params)
+	if err != nil {
+		return "", err
+	}
+	j, err := t.m.get(tools.RunIDFromContext(ctx), name)
+	if err != nil {
+		return "", err
+	}
+	j.kill()
+	return processJSON(j.status()), nil
+}
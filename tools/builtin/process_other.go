@@ -0,0 +1,21 @@
+//go:build !linux
+
+package builtin
+
+import "os/exec"
+
+// Outside Linux jobs are plain child processes: kills reach the shell only,
+// and jobs are not killed if the agent dies.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = cmd.Process.Kill()
+	}
+}
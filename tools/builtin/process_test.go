@@ -0,0 +1,141 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+func processTools(t *testing.T, m *ProcessManager) (start, status, output, kill tools.Tool) {
+	t.Helper()
+	ts := m.Tools()
+	return ts[0], ts[1], ts[2], ts[3]
+}
+
+func decodeProcess(t *testing.T, out string) map[string]any {
+	t.Helper()
+	var v map[string]any
+	if err := json.Unmarshal([]byte(out), &v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	return v
+}
+
+func TestProcessStartOutputStatus(t *testing.T) {
+	m := NewProcessManager(2, 0)
+	start, status, output, _ := processTools(t, m)
+	ctx := tools.WithRunID(context.Background(), "run_a")
+
+	if _, err := start.Execute(ctx, map[string]any{"name": "hello", "cmd": "echo one; echo two >&2; sleep 0.2; echo three"}); err != nil {
+		t.Fatalf("process_start: %v", err)
+	}
+	if _, err := start.Execute(ctx, map[string]any{"name": "hello", "cmd": "true"}); err == nil {
+		t.Fatal("expected error starting a running name twice")
+	}
+
+	out, err := output.Execute(ctx, map[string]any{"name": "hello", "wait_seconds": 5})
+	if err != nil {
+		t.Fatalf("process_output: %v", err)
+	}
+	first := decodeProcess(t, out)
+	next := first["next_offset"].(float64)
+
+	// Wait for the job to exit; read only what came after the first chunk.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, err = output.Execute(ctx, map[string]any{"name": "hello", "since": next, "wait_seconds": 1})
+		if err != nil {
+			t.Fatalf("process_output: %v", err)
+		}
+		if v := decodeProcess(t, out); v["running"] == false {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("job did not exit")
+		}
+	}
+	all, _, _ := m.jobs["run_a/hello"].out.since(0)
+	// bash -l may print profile noise first.
+	if got := string(all); !strings.HasSuffix(got, "one\ntwo\nthree\n") {
+		t.Fatalf("combined output = %q", got)
+	}
+
+	st, err := status.Execute(ctx, map[string]any{"name": "hello"})
+	if err != nil {
+		t.Fatalf("process_status: %v", err)
+	}
+	if v := decodeProcess(t, st); v["running"] != false || v["exit_code"] != float64(0) {
+		t.Fatalf("status = %v", v)
+	}
+
+	// Jobs are scoped to their run.
+	other := tools.WithRunID(context.Background(), "run_b")
+	if _, err := status.Execute(other, map[string]any{"name": "hello"}); err == nil {
+		t.Fatal("expected run_b not to see run_a's job")
+	}
+}
+
+func TestProcessKillAndEndRun(t *testing.T) {
+	m := NewProcessManager(2, 0)
+	start, _, _, kill := processTools(t, m)
+	ctx := tools.WithRunID(context.Background(), "run_a")
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := start.Execute(ctx, map[string]any{"name": name, "cmd": "sleep 30"}); err != nil {
+			t.Fatalf("process_start %s: %v", name, err)
+		}
+	}
+	if _, err := start.Execute(ctx, map[string]any{"name": "c", "cmd": "sleep 30"}); err == nil || !strings.Contains(err.Error(), "max_jobs") {
+		t.Fatalf("expected max_jobs error, got %v", err)
+	}
+	// The cap is shared by all runs.
+	other := tools.WithRunID(context.Background(), "run_b")
+	if _, err := start.Execute(other, map[string]any{"name": "c", "cmd": "sleep 30"}); err == nil || !strings.Contains(err.Error(), "max_jobs") {
+		t.Fatalf("expected max_jobs error for another run, got %v", err)
+	}
+	// Without a run nothing would ever end the job.
+	if _, err := start.Execute(context.Background(), map[string]any{"name": "c", "cmd": "true"}); err == nil || !strings.Contains(err.Error(), "inside an agent run") {
+		t.Fatalf("expected a run to be required, got %v", err)
+	}
+
+	out, err := kill.Execute(ctx, map[string]any{"name": "a"})
+	if err != nil {
+		t.Fatalf("process_kill: %v", err)
+	}
+	if v := decodeProcess(t, out); v["running"] != false || v["killed"] != true {
+		t.Fatalf("after kill: %v", v)
+	}
+
+	b := m.jobs["run_a/b"]
+	start.(tools.RunScopedTool).EndRun("run_a")
+	if !b.exited() {
+		t.Fatal("EndRun left a job running")
+	}
+	if len(m.list("run_a")) != 0 {
+		t.Fatal("EndRun left jobs registered")
+	}
+}
+
+func TestRingBufferKeepsTail(t *testing.T) {
+	r := &ringBuffer{size: 8}
+	_, _ = r.Write([]byte("abcdef"))
+	_, _ = r.Write([]byte("ghij"))
+
+	data, next, dropped := r.since(0)
+	if string(data) != "cdefghij" || next != 10 || !dropped {
+		t.Fatalf("since(0) = %q, %d, %v", data, next, dropped)
+	}
+	data, _, dropped = r.since(7)
+	if string(data) != "hij" || dropped {
+		t.Fatalf("since(7) = %q, %v", data, dropped)
+	}
+	_, _ = r.Write([]byte("0123456789"))
+	data, next, _ = r.since(next)
+	if string(data) != "23456789" || next != 20 {
+		t.Fatalf("since(10) = %q, %d", data, next)
+	}
+}
@@ -1,9 +1,12 @@
 package builtin
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -29,8 +32,10 @@ func NewReadFileToolWithDenyPaths(maxBytes int64, denyPaths []string, baseDirs .
 
 func (t *ReadFileTool) Name() string { return "read_file" }
 
+func (t *ReadFileTool) ReadOnly(map[string]any) bool { return true }
+
 func (t *ReadFileTool) Description() string {
-	return "Reads a local text file from disk and returns its content (truncated to a maximum size)."
+	return "Reads a local text file from disk and returns its content (truncated to a maximum size). Use start_line/end_line, tail_lines or offset/length to page through large files; ranged or truncated reads start with a header giving the file's total size."
 }
 
 func (t *ReadFileTool) ParameterSchema() string {
@@ -41,6 +46,26 @@ func (t *ReadFileTool) ParameterSchema() string {
 				"type":        "string",
 				"description": "File path to read. Supports aliases `file_cache_dir/<path>` and `file_state_dir/<path>`.",
 			},
+			"start_line": map[string]any{
+				"type":        "integer",
+				"description": "Optional first line to return (1-based).",
+			},
+			"end_line": map[string]any{
+				"type":        "integer",
+				"description": "Optional last line to return (inclusive).",
+			},
+			"tail_lines": map[string]any{
+				"type":        "integer",
+				"description": "Optional: return the last N lines (e.g. of a log). Cannot be combined with start_line/end_line.",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"description": "Optional byte offset to start reading at. Cannot be combined with line ranges.",
+			},
+			"length": map[string]any{
+				"type":        "integer",
+				"description": "Optional number of bytes to read from offset.",
+			},
 		},
 		"required": []string{"path"},
 	}
@@ -64,14 +89,192 @@ func (t *ReadFileTool) Execute(_ context.Context, params map[string]any) (string
 		return "", fmt.Errorf("read_file denied for path %q (matched %q)", path, offending)
 	}
 
-	data, err := os.ReadFile(path)
+	startLine, hasStart := paramInt(params, "start_line")
+	endLine, hasEnd := paramInt(params, "end_line")
+	tailLines, hasTail := paramInt(params, "tail_lines")
+	offset, hasOffset := paramInt(params, "offset")
+	length, hasLength := paramInt(params, "length")
+	lineRange := hasStart || hasEnd || hasTail
+	byteRange := hasOffset || hasLength
+	switch {
+	case lineRange && byteRange:
+		return "", fmt.Errorf("use either line ranges (start_line/end_line/tail_lines) or a byte range (offset/length), not both")
+	case hasTail && (hasStart || hasEnd):
+		return "", fmt.Errorf("tail_lines cannot be combined with start_line/end_line")
+	case startLine < 0 || endLine < 0 || tailLines < 0 || offset < 0 || length < 0:
+		return "", fmt.Errorf("ranges must not be negative")
+	}
+
+	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
-	if t.MaxBytes > 0 && int64(len(data)) > t.MaxBytes {
-		data = data[:t.MaxBytes]
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory (use list_dir)", path)
+	}
+	size := info.Size()
+
+	if lineRange {
+		if hasTail {
+			total, err := countLines(f)
+			if err != nil {
+				return "", err
+			}
+			startLine, endLine = total-tailLines+1, total
+			if startLine < 1 {
+				startLine = 1
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return "", err
+			}
+		}
+		if startLine < 1 {
+			startLine = 1
+		}
+		r, err := readLines(f, startLine, endLine, t.MaxBytes)
+		if err != nil {
+			return "", err
+		}
+		return readFileHeader(path, size, r.header()) + r.data, nil
+	}
+
+	if !byteRange && (t.MaxBytes <= 0 || size <= t.MaxBytes) {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	off := int64(offset)
+	if off > size {
+		off = size
+	}
+	n := size - off
+	if hasLength && int64(length) < n {
+		n = int64(length)
+	}
+	truncated := false
+	if t.MaxBytes > 0 && n > t.MaxBytes {
+		n = t.MaxBytes
+		truncated = true
+	}
+	data := make([]byte, n)
+	if _, err := f.ReadAt(data, off); err != nil && err != io.EOF {
+		return "", err
+	}
+	detail := fmt.Sprintf("bytes %d-%d", off, off+n)
+	if end := off + n; end < size {
+		detail += fmt.Sprintf(" | next: offset=%d", end)
+		if truncated {
+			detail += " (max_bytes reached)"
+		}
+	}
+	return readFileHeader(path, size, detail) + string(data), nil
+}
+
+// readFileHeader labels a partial read so the model knows what it got and
+// how to get the rest.
+func readFileHeader(path string, size int64, detail string) string {
+	return fmt.Sprintf("[read_file %s | size: %d bytes | %s]\n", path, size, detail)
+}
+
+type lineRead struct {
+	data        string
+	first, last int
+	total       int
+	truncated   bool
+}
+
+func (r lineRead) header() string {
+	if r.last < r.first {
+		return fmt.Sprintf("lines: none (file has %d lines)", r.total)
+	}
+	s := fmt.Sprintf("lines %d-%d of %d", r.first, r.last, r.total)
+	if r.last < r.total {
+		s += fmt.Sprintf(" | next: start_line=%d", r.last+1)
+		if r.truncated {
+			s += " (max_bytes reached)"
+		}
+	}
+	return s
+}
+
+// readLines returns lines start..end (1-based, inclusive; end 0 means to
+// the end) up to maxBytes, and counts every line of the file. A first line
+// longer than maxBytes is returned cut.
+func readLines(f io.Reader, start, end int, maxBytes int64) (lineRead, error) {
+	r := lineRead{first: start, last: start - 1}
+	var out []byte
+	br := bufio.NewReaderSize(f, 64*1024)
+	line := 1
+	partial := false // inside a line longer than the reader's buffer
+	for {
+		seg, err := br.ReadSlice('\n')
+		if len(seg) > 0 {
+			in := line >= start && (end <= 0 || line <= end) && !r.truncated
+			if in {
+				if maxBytes > 0 && int64(len(out)+len(seg)) > maxBytes {
+					if r.last < start && !partial {
+						out = append(out, seg[:maxBytes-int64(len(out))]...)
+						r.last = line
+					}
+					r.truncated = true
+				} else {
+					out = append(out, seg...)
+					r.last = line
+				}
+			}
+			partial = seg[len(seg)-1] != '\n'
+			if !partial {
+				line++
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return lineRead{}, err
+		}
+	}
+	r.total = line - 1
+	if partial {
+		r.total++
+	}
+	if r.last > r.total {
+		r.last = r.total
+	}
+	r.data = string(out)
+	return r, nil
+}
+
+func countLines(f io.Reader) (int, error) {
+	buf := make([]byte, 64*1024)
+	n, last := 0, byte('\n')
+	for {
+		k, err := f.Read(buf)
+		if k > 0 {
+			n += bytes.Count(buf[:k], []byte{'\n'})
+			last = buf[k-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if last != '\n' {
+		n++
 	}
-	return string(data), nil
+	return n, nil
 }
 
 func (t *ReadFileTool) resolvePath(rawPath string) (string, error) {
@@ -99,3 +99,62 @@ func TestReadFileTool_BareAliasRejected(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestReadFileTool_Ranges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	var b strings.Builder
+	for i := 1; i <= 10; i++ {
+		b.WriteString("line " + string(rune('0'+i%10)) + "\n")
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := NewReadFileTool(20)
+
+	out, err := tool.Execute(context.Background(), map[string]any{"path": path, "start_line": 2, "end_line": 3})
+	if err != nil {
+		t.Fatalf("lines: %v", err)
+	}
+	if !strings.HasSuffix(out, "| size: 70 bytes | lines 2-3 of 10 | next: start_line=4]\nline 2\nline 3\n") {
+		t.Fatalf("lines = %q", out)
+	}
+
+	out, err = tool.Execute(context.Background(), map[string]any{"path": path, "tail_lines": 2})
+	if err != nil {
+		t.Fatalf("tail: %v", err)
+	}
+	if !strings.HasSuffix(out, "lines 9-10 of 10]\nline 9\nline 0\n") {
+		t.Fatalf("tail = %q", out)
+	}
+
+	// max_bytes (20) stops a long range at a line boundary.
+	out, err = tool.Execute(context.Background(), map[string]any{"path": path, "start_line": 1})
+	if err != nil {
+		t.Fatalf("capped: %v", err)
+	}
+	if !strings.HasSuffix(out, "lines 1-2 of 10 | next: start_line=3 (max_bytes reached)]\nline 1\nline 2\n") {
+		t.Fatalf("capped = %q", out)
+	}
+
+	out, err = tool.Execute(context.Background(), map[string]any{"path": path, "offset": 63, "length": 100})
+	if err != nil {
+		t.Fatalf("bytes: %v", err)
+	}
+	if !strings.HasSuffix(out, "| bytes 63-70]\nline 0\n") {
+		t.Fatalf("bytes = %q", out)
+	}
+
+	// Unranged reads past max_bytes say how big the file is.
+	out, err = tool.Execute(context.Background(), map[string]any{"path": path})
+	if err != nil {
+		t.Fatalf("truncated: %v", err)
+	}
+	if !strings.Contains(out, "| size: 70 bytes | bytes 0-20 | next: offset=20 (max_bytes reached)]\n") {
+		t.Fatalf("truncated = %q", out)
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]any{"path": path, "tail_lines": 1, "offset": 5}); err == nil {
+		t.Fatal("expected error mixing line and byte ranges")
+	}
+}
@@ -0,0 +1,107 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+// ReadImageTool attaches a local image to the model's context so the model
+// can look at it (e.g. a photo sent to the Telegram bot).
+type ReadImageTool struct {
+	MaxBytes  int64
+	DenyPaths []string
+	BaseDirs  []string
+}
+
+func NewReadImageTool(maxBytes int64, denyPaths []string, baseDirs ...string) *ReadImageTool {
+	return &ReadImageTool{MaxBytes: maxBytes, DenyPaths: denyPaths, BaseDirs: normalizeBaseDirs(baseDirs)}
+}
+
+func (t *ReadImageTool) Name() string { return "read_image" }
+
+func (t *ReadImageTool) ReadOnly(map[string]any) bool { return true }
+
+func (t *ReadImageTool) Description() string {
+	return "Attaches a local image file (png, jpeg, gif, webp) to the conversation so you can see it. The image is shown in the next message."
+}
+
+func (t *ReadImageTool) ParameterSchema() string {
+	s := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Image path. Supports aliases `file_cache_dir/<path>` and `file_state_dir/<path>`.",
+			},
+		},
+		"required": []string{"path"},
+	}
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+func (t *ReadImageTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	path, _ := params["path"].(string)
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", fmt.Errorf("missing required param: path")
+	}
+	resolver := &ReadFileTool{BaseDirs: t.BaseDirs}
+	path, err := resolver.resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if offending, ok := denyPath(path, t.DenyPaths); ok {
+		return "", fmt.Errorf("read_image denied for path %q (matched %q)", path, offending)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	if t.MaxBytes > 0 && info.Size() > t.MaxBytes {
+		return "", fmt.Errorf("image too large: %d bytes (max %d)", info.Size(), t.MaxBytes)
+	}
+	mimeType, err := sniffImageType(path)
+	if err != nil {
+		return "", err
+	}
+
+	part := llm.ContentPart{Type: llm.PartImage, Path: path, MimeType: mimeType, Name: filepath.Base(path)}
+	if !tools.Attach(ctx, part) {
+		return "", fmt.Errorf("read_image is only available inside an agent run")
+	}
+	return fmt.Sprintf("attached image %s (%s, %d bytes); it follows as an attachment", path, mimeType, info.Size()), nil
+}
+
+func sniffImageType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	mimeType := http.DetectContentType(head[:n])
+	if !strings.HasPrefix(mimeType, "image/") {
+		// DetectContentType misses some formats (e.g. svg); trust a known
+		// image extension as a fallback.
+		mimeType = mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("%s is not an image", path)
+	}
+	return strings.SplitN(mimeType, ";", 2)[0], nil
+}
@@ -0,0 +1,64 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/llm"
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestReadImageTool_Attaches(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cat.png")
+	if err := os.WriteFile(path, pngHeader, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var sink tools.Attachments
+	ctx := tools.WithAttachments(context.Background(), &sink)
+	tool := NewReadImageTool(1024, nil)
+	if _, err := tool.Execute(ctx, map[string]any{"path": path}); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	parts := sink.Drain()
+	if len(parts) != 1 || parts[0].Type != llm.PartImage || parts[0].MimeType != "image/png" || parts[0].Path != path {
+		t.Fatalf("parts = %+v", parts)
+	}
+}
+
+func TestReadImageTool_Rejects(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	img := filepath.Join(dir, "cat.png")
+	if err := os.WriteFile(img, pngHeader, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := tools.WithAttachments(context.Background(), &tools.Attachments{})
+
+	cases := []struct {
+		name string
+		tool *ReadImageTool
+		ctx  context.Context
+		path string
+	}{
+		{name: "not_image", tool: NewReadImageTool(1024, nil), ctx: ctx, path: text},
+		{name: "too_large", tool: NewReadImageTool(4, nil), ctx: ctx, path: img},
+		{name: "denied", tool: NewReadImageTool(1024, []string{"cat.png"}), ctx: ctx, path: img},
+		{name: "no_sink", tool: NewReadImageTool(1024, nil), ctx: context.Background(), path: img},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tc.tool.Execute(tc.ctx, map[string]any{"path": tc.path}); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
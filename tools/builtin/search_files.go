@@ -0,0 +1,249 @@
+package builtin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	searchMaxLineChars   = 400
+	searchMaxContext     = 10
+	searchBinarySniffLen = 8 * 1024
+)
+
+type SearchFilesTool struct {
+	MaxMatches   int
+	MaxFileBytes int64
+	DenyPaths    []string
+	BaseDirs     []string
+}
+
+func NewSearchFilesTool(maxMatches int, maxFileBytes int64, denyPaths []string, baseDirs ...string) *SearchFilesTool {
+	if maxMatches <= 0 {
+		maxMatches = 200
+	}
+	if maxFileBytes <= 0 {
+		maxFileBytes = 4 * 1024 * 1024
+	}
+	return &SearchFilesTool{
+		MaxMatches:   maxMatches,
+		MaxFileBytes: maxFileBytes,
+		DenyPaths:    denyPaths,
+		BaseDirs:     normalizeBaseDirs(baseDirs),
+	}
+}
+
+func (t *SearchFilesTool) Name() string { return "search_files" }
+
+func (t *SearchFilesTool) ReadOnly(map[string]any) bool { return true }
+
+// UntrustedOutput: matches quote file contents, like read_file.
+func (t *SearchFilesTool) UntrustedOutput() bool { return true }
+
+func (t *SearchFilesTool) Description() string {
+	return "Searches file contents under a directory (or in one file) for a regular expression and returns matching lines with line numbers and optional context. Binary, oversized, hidden and denied files are skipped."
+}
+
+func (t *SearchFilesTool) ParameterSchema() string {
+	s := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory or file to search. Supports aliases `file_cache_dir/<path>` and `file_state_dir/<path>` (or just the alias).",
+			},
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Regular expression (RE2 syntax) matched against each line.",
+			},
+			"glob": map[string]any{
+				"type":        "string",
+				"description": "Optional file filter (e.g. `*.log`), matched like list_dir's glob.",
+			},
+			"ignore_case": map[string]any{
+				"type":        "boolean",
+				"description": "Case-insensitive match (default: false).",
+			},
+			"context_lines": map[string]any{
+				"type":        "integer",
+				"description": "Lines of context before and after each match (default: 0, max: 10).",
+			},
+			"max_matches": map[string]any{
+				"type":        "integer",
+				"description": "Optional cap on returned matches (cannot exceed the configured maximum).",
+			},
+			"include_hidden": map[string]any{
+				"type":        "boolean",
+				"description": "Search dot files and dot directories (default: false).",
+			},
+		},
+		"required": []string{"path", "pattern"},
+	}
+	b, _ := json.MarshalIndent(s, "", "  ")
+	return string(b)
+}
+
+type searchMatch struct {
+	File   string   `json:"file"`
+	Line   int      `json:"line"`
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+}
+
+func (t *SearchFilesTool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	root, err := resolveReadRoot(t.BaseDirs, t.DenyPaths, params, "search_files")
+	if err != nil {
+		return "", err
+	}
+	pattern, _ := params["pattern"].(string)
+	if strings.TrimSpace(pattern) == "" {
+		return "", fmt.Errorf("missing required param: pattern")
+	}
+	if parseBoolDefault(params["ignore_case"], false) {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	glob, _ := params["glob"].(string)
+	glob = strings.TrimSpace(glob)
+	if glob != "" {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return "", fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+	contextLines := parseIntDefault(params["context_lines"], 0)
+	if contextLines < 0 {
+		contextLines = 0
+	}
+	if contextLines > searchMaxContext {
+		contextLines = searchMaxContext
+	}
+	maxMatches := t.MaxMatches
+	if n := parseIntDefault(params["max_matches"], 0); n > 0 && n < maxMatches {
+		maxMatches = n
+	}
+	hidden := parseBoolDefault(params["include_hidden"], false)
+
+	s := &fileSearch{re: re, context: contextLines, max: maxMatches, maxFileBytes: t.MaxFileBytes, matches: []searchMatch{}}
+	errStop := errors.New("stop")
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, walkErr error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel := filepath.Base(p)
+		if p != root {
+			rel, _ = filepath.Rel(root, p)
+			rel = filepath.ToSlash(rel)
+			skip, err := skipWalkEntry(p, rel, d, walkErr, hidden, 0, t.DenyPaths)
+			if skip || err != nil {
+				return err
+			}
+		} else if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if glob != "" && !matchGlob(glob, rel) {
+			return nil
+		}
+		if err := s.file(p, rel); err != nil {
+			s.skipped++
+		}
+		if s.truncated {
+			return errStop
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return "", err
+	}
+
+	out, _ := json.MarshalIndent(map[string]any{
+		"path":           root,
+		"pattern":        re.String(),
+		"matches":        s.matches,
+		"count":          len(s.matches),
+		"files_searched": s.searched,
+		"files_skipped":  s.skipped,
+		"truncated":      s.truncated,
+	}, "", "  ")
+	return string(out), nil
+}
+
+type fileSearch struct {
+	re           *regexp.Regexp
+	context      int
+	max          int
+	maxFileBytes int64
+
+	matches   []searchMatch
+	searched  int
+	skipped   int
+	truncated bool
+}
+
+// file searches one file. Oversized and binary files count as skipped.
+func (s *fileSearch) file(path, rel string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() > s.maxFileBytes {
+		return fmt.Errorf("too large")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if bytes.IndexByte(data[:min(len(data), searchBinarySniffLen)], 0) >= 0 {
+		return fmt.Errorf("binary")
+	}
+	s.searched++
+
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for sc.Scan() {
+		lines = append(lines, strings.TrimSuffix(sc.Text(), "\r"))
+	}
+	for i, line := range lines {
+		if !s.re.MatchString(line) {
+			continue
+		}
+		if len(s.matches) >= s.max {
+			s.truncated = true
+			return nil
+		}
+		m := searchMatch{File: rel, Line: i + 1, Text: clipLine(line)}
+		if s.context > 0 {
+			for _, l := range lines[max(0, i-s.context):i] {
+				m.Before = append(m.Before, clipLine(l))
+			}
+			for _, l := range lines[i+1 : min(len(lines), i+1+s.context)] {
+				m.After = append(m.After, clipLine(l))
+			}
+		}
+		s.matches = append(s.matches, m)
+	}
+	return nil
+}
+
+func clipLine(s string) string {
+	if len(s) <= searchMaxLineChars {
+		return s
+	}
+	return strings.ToValidUTF8(s[:searchMaxLineChars], "") + "…"
+}
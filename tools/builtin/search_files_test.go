@@ -0,0 +1,61 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestSearchFilesTool(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app.log":         "start\nINFO ready\nERROR disk full\nretrying\nERROR disk full again\n",
+		"sub/notes.md":    "todo: fix error handling\n",
+		"sub/config.yaml": "error: secret\n",
+		"bin.dat":         "ERROR\x00\x01",
+	})
+	tool := NewSearchFilesTool(0, 0, []string{"config.yaml"}, root)
+
+	out, err := tool.Execute(context.Background(), map[string]any{
+		"path":          root,
+		"pattern":       "error",
+		"ignore_case":   true,
+		"context_lines": 1,
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	var res struct {
+		Matches   []searchMatch `json:"matches"`
+		Skipped   int           `json:"files_skipped"`
+		Truncated bool          `json:"truncated"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Matches) != 3 || res.Skipped != 1 {
+		t.Fatalf("matches = %+v, skipped = %d", res.Matches, res.Skipped)
+	}
+	m := res.Matches[0]
+	if m.File != "app.log" || m.Line != 3 || m.Text != "ERROR disk full" || len(m.Before) != 1 || m.Before[0] != "INFO ready" || m.After[0] != "retrying" {
+		t.Fatalf("first match = %+v", m)
+	}
+	if res.Matches[2].File != "sub/notes.md" {
+		t.Fatalf("last match = %+v", res.Matches[2])
+	}
+
+	out, err = tool.Execute(context.Background(), map[string]any{"path": "file_cache_dir/app.log", "pattern": "^ERROR", "max_matches": 1})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	res.Matches = nil
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Matches) != 1 || !res.Truncated {
+		t.Fatalf("single file = %s", out)
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]any{"path": root, "pattern": "("}); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+}
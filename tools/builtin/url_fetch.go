@@ -87,6 +87,16 @@ func NewURLFetchToolWithAuthLimits(enabled bool, timeout time.Duration, maxBytes
 
 func (t *URLFetchTool) Name() string { return "url_fetch" }
 
+// ReadOnly reports whether the call is a plain GET that does not download to disk.
+func (t *URLFetchTool) ReadOnly(params map[string]any) bool {
+	if p, _ := params["download_path"].(string); strings.TrimSpace(p) != "" {
+		return false
+	}
+	method, _ := params["method"].(string)
+	method = strings.ToUpper(strings.TrimSpace(method))
+	return method == "" || method == http.MethodGet
+}
+
 func (t *URLFetchTool) Description() string {
 	return "Fetches an HTTP(S) URL (GET/POST/PUT/PATCH/DELETE) and returns the response body (truncated)."
 }
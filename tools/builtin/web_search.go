@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/quailyquaily/mistermorph/secrets"
 	"golang.org/x/net/html"
 )
 
@@ -22,9 +23,18 @@ type WebSearchTool struct {
 	UserAgent      string
 	MaxBodyBytes   int64
 	AllowRedirects bool
+	// Backends are tried in order until MaxResults distinct results are
+	// collected. Empty means DuckDuckGo HTML at BaseURL.
+	Backends []WebSearchBackendConfig
+	// Auth resolves backend auth_profile keys, under the same rules as url_fetch.
+	Auth *URLFetchAuth
 }
 
 func NewWebSearchTool(enabled bool, baseURL string, timeout time.Duration, maxResults int, userAgent string) *WebSearchTool {
+	return NewWebSearchToolWithBackends(enabled, baseURL, timeout, maxResults, userAgent, nil, nil)
+}
+
+func NewWebSearchToolWithBackends(enabled bool, baseURL string, timeout time.Duration, maxResults int, userAgent string, backends []WebSearchBackendConfig, auth *URLFetchAuth) *WebSearchTool {
 	if strings.TrimSpace(baseURL) == "" {
 		baseURL = "https://duckduckgo.com/html/"
 	}
@@ -46,11 +56,15 @@ func NewWebSearchTool(enabled bool, baseURL string, timeout time.Duration, maxRe
 		UserAgent:      userAgent,
 		MaxBodyBytes:   2 * 1024 * 1024,
 		AllowRedirects: true,
+		Backends:       backends,
+		Auth:           auth,
 	}
 }
 
 func (t *WebSearchTool) Name() string { return "web_search" }
 
+func (t *WebSearchTool) ReadOnly(map[string]any) bool { return true }
+
 func (t *WebSearchTool) Description() string {
 	return "Search the web for a query and return a short list of results (title, url, snippet)."
 }
@@ -80,6 +94,13 @@ type webSearchResult struct {
 	Snippet string `json:"snippet,omitempty"`
 }
 
+// webSearchBackend is one search engine. search returns at most max
+// results; an empty list is not an error.
+type webSearchBackend interface {
+	name() string
+	search(ctx context.Context, q string, max int) ([]webSearchResult, error)
+}
+
 func (t *WebSearchTool) Execute(ctx context.Context, params map[string]any) (string, error) {
 	if !t.Enabled {
 		return "", fmt.Errorf("web_search tool is disabled (enable via config: tools.web_search.enabled=true)")
@@ -101,60 +122,149 @@ func (t *WebSearchTool) Execute(ctx context.Context, params map[string]any) (str
 		maxResults = 20
 	}
 
-	base, err := url.Parse(t.BaseURL)
+	backends, err := t.backends()
+	if err != nil {
+		return "", err
+	}
+
+	// Fall through the backends until enough distinct results are found;
+	// a failing backend only matters when none of them returns anything.
+	results := []webSearchResult{}
+	seen := make(map[string]bool)
+	var engines, failures []string
+	for _, b := range backends {
+		if len(results) >= maxResults {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		found, err := b.search(ctx, q, maxResults)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", b.name(), err))
+			continue
+		}
+		added := 0
+		for _, r := range found {
+			key := webSearchResultKey(r.URL)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			results = append(results, r)
+			added++
+			if len(results) >= maxResults {
+				break
+			}
+		}
+		if added > 0 {
+			engines = append(engines, b.name())
+		}
+	}
+	if len(results) == 0 && len(failures) > 0 {
+		return "", fmt.Errorf("web_search failed: %s", strings.Join(failures, "; "))
+	}
+	if len(engines) == 0 {
+		engines = append(engines, backends[0].name())
+	}
+
+	out := map[string]any{
+		"engine":       strings.Join(engines, "+"),
+		"query":        q,
+		"result_count": len(results),
+		"results":      results,
+	}
+	if len(failures) > 0 {
+		out["errors"] = failures
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	return string(b), nil
+}
+
+// duckDuckGoBackend scrapes the DuckDuckGo HTML endpoint. It needs no key
+// but breaks when the markup changes.
+type duckDuckGoBackend struct {
+	t       *WebSearchTool
+	label   string
+	baseURL string
+}
+
+func (b *duckDuckGoBackend) name() string { return b.label }
+
+func (b *duckDuckGoBackend) search(ctx context.Context, q string, max int) ([]webSearchResult, error) {
+	base, err := url.Parse(b.baseURL)
 	if err != nil {
-		return "", fmt.Errorf("invalid base_url: %w", err)
+		return nil, fmt.Errorf("invalid base_url: %w", err)
 	}
 	u := *base
 	qs := u.Query()
 	qs.Set("q", q)
 	u.RawQuery = qs.Encode()
 
-	httpClient := &http.Client{Timeout: t.Timeout}
-	if !t.AllowRedirects {
-		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		}
+	body, err := b.t.get(ctx, &u, "", "", secrets.Inject{})
+	if err != nil {
+		return nil, err
 	}
+	return parseDuckDuckGoHTML(body, max)
+}
 
+// get fetches u. With an auth profile the key is injected as a header and
+// redirects are not followed, so it never leaves the allowed prefixes.
+func (t *WebSearchTool) get(ctx context.Context, u *url.URL, accept, authProfile string, inject secrets.Inject) ([]byte, error) {
 	reqCtx, cancel := context.WithTimeout(ctx, t.Timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, u.String(), nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("User-Agent", t.UserAgent)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	allowRedirects := t.AllowRedirects
+	if authProfile != "" {
+		name, val, err := t.authHeader(ctx, authProfile, u, inject)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, val)
+		allowRedirects = false
+	}
+
+	httpClient := &http.Client{Timeout: t.Timeout}
+	if !allowRedirects {
+		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 32*1024))
-		return "", fmt.Errorf("web_search non-2xx status=%d body=%s", resp.StatusCode, string(bytes.ToValidUTF8(body, []byte("[non-utf8]"))))
-	}
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, t.MaxBodyBytes))
-	if err != nil {
-		return "", err
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
+		return nil, fmt.Errorf("non-2xx status=%d body=%s", resp.StatusCode, string(bytes.ToValidUTF8(body, []byte("[non-utf8]"))))
 	}
+	return io.ReadAll(io.LimitReader(resp.Body, t.MaxBodyBytes))
+}
 
-	results, err := parseDuckDuckGoHTML(body, maxResults)
-	if err != nil {
-		return "", err
+// webSearchResultKey identifies a result across backends: scheme, "www.",
+// fragment and a trailing slash do not make a page different.
+func webSearchResultKey(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
 	}
-
-	out := map[string]any{
-		"engine":       "duckduckgo_html",
-		"query":        q,
-		"result_count": len(results),
-		"results":      results,
+	key := strings.TrimPrefix(strings.ToLower(u.Host), "www.") + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
 	}
-	b, _ := json.MarshalIndent(out, "", "  ")
-	return string(b), nil
+	return key
 }
 
 func parseDuckDuckGoHTML(htmlBytes []byte, maxResults int) ([]webSearchResult, error) {
@@ -0,0 +1,372 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/secrets"
+	"golang.org/x/net/html"
+)
+
+// WebSearchBackendConfig is one entry of tools.web_search.backends. Fields
+// left empty take the defaults of the backend type.
+type WebSearchBackendConfig struct {
+	// Type is duckduckgo, searxng, brave, bing, google or json.
+	Type string `mapstructure:"type"`
+	// Name labels the backend in output and errors (default: type).
+	Name        string            `mapstructure:"name"`
+	BaseURL     string            `mapstructure:"base_url"`
+	AuthProfile string            `mapstructure:"auth_profile"`
+	Params      map[string]string `mapstructure:"params"`
+
+	// Request and response mapping, used by type json and to override presets.
+	QueryParam string `mapstructure:"query_param"`
+	CountParam string `mapstructure:"count_param"`
+	Results    string `mapstructure:"results"`
+	Title      string `mapstructure:"title"`
+	URL        string `mapstructure:"url"`
+	Snippet    string `mapstructure:"snippet"`
+}
+
+// webSearchPreset describes a JSON search API. Paths are dot-separated keys
+// into the response; results must name an array.
+type webSearchPreset struct {
+	baseURL    string
+	params     map[string]string
+	countParam string
+	maxCount   int
+	results    string
+	title      string
+	url        string
+	snippet    string
+	// inject is the key header used when the profile has no web_search binding.
+	inject   secrets.Inject
+	needAuth bool
+	required []string
+}
+
+var webSearchPresets = map[string]webSearchPreset{
+	"searxng": {
+		params:  map[string]string{"format": "json"},
+		results: "results", title: "title", url: "url", snippet: "content",
+	},
+	"brave": {
+		baseURL:    "https://api.search.brave.com/res/v1/web/search",
+		countParam: "count", maxCount: 20,
+		results: "web.results", title: "title", url: "url", snippet: "description",
+		inject:   secrets.Inject{Location: "header", Name: "X-Subscription-Token"},
+		needAuth: true,
+	},
+	"bing": {
+		baseURL:    "https://api.bing.microsoft.com/v7.0/search",
+		countParam: "count", maxCount: 50,
+		results: "webPages.value", title: "name", url: "url", snippet: "snippet",
+		inject:   secrets.Inject{Location: "header", Name: "Ocp-Apim-Subscription-Key"},
+		needAuth: true,
+	},
+	"google": {
+		baseURL:    "https://www.googleapis.com/customsearch/v1",
+		countParam: "num", maxCount: 10,
+		results: "items", title: "title", url: "link", snippet: "snippet",
+		inject:   secrets.Inject{Location: "header", Name: "X-goog-api-key"},
+		needAuth: true,
+		required: []string{"cx"},
+	},
+	"json": {title: "title"},
+}
+
+func (c WebSearchBackendConfig) Validate() error {
+	typ := strings.ToLower(strings.TrimSpace(c.Type))
+	if typ == "" {
+		return fmt.Errorf("type is required")
+	}
+	if typ == "duckduckgo" {
+		return validateWebSearchBaseURL(c.BaseURL, false)
+	}
+	p, ok := webSearchPresets[typ]
+	if !ok {
+		return fmt.Errorf("unsupported type: %q", c.Type)
+	}
+	if err := validateWebSearchBaseURL(c.BaseURL, p.baseURL == ""); err != nil {
+		return err
+	}
+	if p.needAuth && strings.TrimSpace(c.AuthProfile) == "" {
+		return fmt.Errorf("%s requires auth_profile", typ)
+	}
+	for _, k := range p.required {
+		if strings.TrimSpace(c.Params[k]) == "" {
+			return fmt.Errorf("%s requires params.%s", typ, k)
+		}
+	}
+	if typ == "json" && (strings.TrimSpace(c.Results) == "" || strings.TrimSpace(c.URL) == "") {
+		return fmt.Errorf("json requires results and url field paths")
+	}
+	return nil
+}
+
+func validateWebSearchBaseURL(raw string, required bool) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		if required {
+			return fmt.Errorf("base_url is required")
+		}
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid base_url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("base_url must be an absolute http(s) URL: %q", raw)
+	}
+	return nil
+}
+
+func (t *WebSearchTool) backends() ([]webSearchBackend, error) {
+	if len(t.Backends) == 0 {
+		return []webSearchBackend{&duckDuckGoBackend{t: t, label: "duckduckgo_html", baseURL: t.BaseURL}}, nil
+	}
+	out := make([]webSearchBackend, 0, len(t.Backends))
+	for i, c := range t.Backends {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid tools.web_search.backends[%d]: %w", i, err)
+		}
+		typ := strings.ToLower(strings.TrimSpace(c.Type))
+		label := strings.TrimSpace(c.Name)
+		if typ == "duckduckgo" {
+			if label == "" {
+				label = "duckduckgo_html"
+			}
+			base := strings.TrimSpace(c.BaseURL)
+			if base == "" {
+				base = t.BaseURL
+			}
+			out = append(out, &duckDuckGoBackend{t: t, label: label, baseURL: base})
+			continue
+		}
+		if label == "" {
+			label = typ
+		}
+		out = append(out, newJSONSearchBackend(t, label, typ, c))
+	}
+	return out, nil
+}
+
+// jsonSearchBackend queries a search API that answers GET requests with
+// JSON, mapping fields by path.
+type jsonSearchBackend struct {
+	t           *WebSearchTool
+	label       string
+	baseURL     string
+	params      map[string]string
+	queryParam  string
+	countParam  string
+	maxCount    int
+	results     string
+	title       string
+	url         string
+	snippet     string
+	authProfile string
+	inject      secrets.Inject
+}
+
+func newJSONSearchBackend(t *WebSearchTool, label, typ string, c WebSearchBackendConfig) *jsonSearchBackend {
+	p := webSearchPresets[typ]
+	pick := func(v, def string) string {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+		return def
+	}
+	b := &jsonSearchBackend{
+		t:           t,
+		label:       label,
+		baseURL:     pick(c.BaseURL, p.baseURL),
+		params:      make(map[string]string),
+		queryParam:  pick(c.QueryParam, "q"),
+		countParam:  pick(c.CountParam, p.countParam),
+		maxCount:    p.maxCount,
+		results:     pick(c.Results, p.results),
+		title:       pick(c.Title, p.title),
+		url:         pick(c.URL, p.url),
+		snippet:     pick(c.Snippet, p.snippet),
+		authProfile: strings.TrimSpace(c.AuthProfile),
+		inject:      p.inject,
+	}
+	if typ == "searxng" {
+		// An instance root means its /search endpoint.
+		if u, err := url.Parse(b.baseURL); err == nil && strings.Trim(u.Path, "/") == "" {
+			u.Path = "/search"
+			b.baseURL = u.String()
+		}
+	}
+	for k, v := range p.params {
+		b.params[k] = v
+	}
+	for k, v := range c.Params {
+		b.params[k] = v
+	}
+	return b
+}
+
+func (b *jsonSearchBackend) name() string { return b.label }
+
+func (b *jsonSearchBackend) search(ctx context.Context, q string, max int) ([]webSearchResult, error) {
+	u, err := url.Parse(b.baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base_url: %w", err)
+	}
+	qs := u.Query()
+	for k, v := range b.params {
+		qs.Set(k, v)
+	}
+	qs.Set(b.queryParam, q)
+	if b.countParam != "" {
+		n := max
+		if b.maxCount > 0 && n > b.maxCount {
+			n = b.maxCount
+		}
+		qs.Set(b.countParam, strconv.Itoa(n))
+	}
+	u.RawQuery = qs.Encode()
+
+	body, err := b.t.get(ctx, u, "application/json", b.authProfile, b.inject)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON response: %w", err)
+	}
+	return mapJSONResults(doc, b.results, b.title, b.url, b.snippet, max)
+}
+
+// mapJSONResults extracts results from a decoded response. A missing
+// results path means no results (some APIs omit empty sections).
+func mapJSONResults(doc any, results, title, link, snippet string, max int) ([]webSearchResult, error) {
+	v := jsonPath(doc, results)
+	if v == nil {
+		return nil, nil
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("results path %q is not an array", results)
+	}
+	var out []webSearchResult
+	for _, item := range items {
+		if len(out) >= max {
+			break
+		}
+		u := jsonString(jsonPath(item, link))
+		if u == "" {
+			continue
+		}
+		out = append(out, webSearchResult{
+			Title:   plainText(jsonString(jsonPath(item, title))),
+			URL:     u,
+			Snippet: plainText(jsonString(jsonPath(item, snippet))),
+		})
+	}
+	return out, nil
+}
+
+// jsonPath follows a dot-separated path of object keys and array indexes.
+func jsonPath(v any, path string) any {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return v
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch x := v.(type) {
+		case map[string]any:
+			v = x[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil
+			}
+			v = x[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func jsonString(v any) string {
+	switch x := v.(type) {
+	case string:
+		return strings.TrimSpace(x)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// plainText drops markup such as the <strong> highlights search APIs put
+// in titles and snippets.
+func plainText(s string) string {
+	if !strings.ContainsAny(s, "<&") {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	root, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return textContent(root)
+}
+
+// authHeader resolves a backend's auth_profile to the header carrying its
+// key. The profile's web_search binding wins over the backend's default.
+func (t *WebSearchTool) authHeader(ctx context.Context, profileID string, u *url.URL, def secrets.Inject) (string, string, error) {
+	if pol, ok := secrets.SkillAuthProfilePolicyFromContext(ctx); ok && pol.Enforce {
+		if pol.Allowed == nil || !pol.Allowed[profileID] {
+			return "", "", fmt.Errorf("auth_profile %q is not declared by any loaded skill", profileID)
+		}
+	}
+	if t.Auth == nil || !t.Auth.Enabled {
+		return "", "", fmt.Errorf("auth_profile is not enabled (set secrets.enabled=true)")
+	}
+	if t.Auth.AllowProfiles == nil || !t.Auth.AllowProfiles[profileID] {
+		return "", "", fmt.Errorf("auth_profile %q is not allowed (fail-closed)", profileID)
+	}
+	if t.Auth.Profiles == nil {
+		return "", "", fmt.Errorf("auth_profile is enabled but profile store is not configured")
+	}
+	p, ok := t.Auth.Profiles.Get(profileID)
+	if !ok {
+		return "", "", fmt.Errorf("auth_profile not found: %q", profileID)
+	}
+	if err := p.Validate(); err != nil {
+		return "", "", fmt.Errorf("invalid auth_profile %q: %w", profileID, err)
+	}
+	if err := p.IsURLAllowed(u, "GET"); err != nil {
+		return "", "", err
+	}
+
+	inject := def
+	if b, ok := p.Bindings[t.Name()]; ok {
+		inject = b.Inject
+	}
+	if strings.TrimSpace(inject.Name) == "" {
+		return "", "", fmt.Errorf("auth_profile %q has no binding for tool %q", profileID, t.Name())
+	}
+
+	if t.Auth.Resolver == nil {
+		return "", "", fmt.Errorf("auth_profile is enabled but secret resolver is not configured")
+	}
+	sec, err := t.Auth.Resolver.Resolve(ctx, p.Credential.SecretRef)
+	if err != nil {
+		return "", "", err
+	}
+	val, err := formatInjectedSecret(inject.Format, sec)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(inject.Name), val, nil
+}
@@ -0,0 +1,183 @@
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quailyquaily/mistermorph/secrets"
+)
+
+func TestWebSearchDuckDuckGoDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "golang" {
+			t.Errorf("q = %q", r.URL.Query().Get("q"))
+		}
+		_, _ = w.Write([]byte(`<html><body>
+<a class="result__a" href="/l/?uddg=https%3A%2F%2Fgo.dev%2F">The Go Programming Language</a>
+<a class="result__a" href="https://go.dev">Go again</a>
+</body></html>`))
+	}))
+	defer srv.Close()
+
+	tool := NewWebSearchTool(true, srv.URL+"/html/", 2*time.Second, 5, "test-agent")
+	out, err := tool.Execute(context.Background(), map[string]any{"q": "golang"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	var res struct {
+		Engine  string            `json:"engine"`
+		Results []webSearchResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Engine != "duckduckgo_html" || len(res.Results) != 1 || res.Results[0].URL != "https://go.dev/" {
+		t.Fatalf("out = %s", out)
+	}
+}
+
+func TestWebSearchFallbackAndDedupe(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer down.Close()
+	custom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") != "q1" || r.URL.Query().Get("lang") != "en" {
+			t.Errorf("custom query = %q", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"data":{"hits":[
+			{"t":"A <b>one</b>","link":"https://a.example/x/","desc":"first &amp; best"},
+			{"t":"A dup","link":"https://www.a.example/x"},
+			{"t":"no link"}
+		]}}`))
+	}))
+	defer custom.Close()
+	searx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			t.Errorf("searxng request = %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`{"results":[
+			{"title":"A again","url":"http://a.example/x#top","content":"dup"},
+			{"title":"B","url":"https://b.example/","content":"bee"},
+			{"title":"C","url":"https://c.example/","content":"sea"}
+		]}`))
+	}))
+	defer searx.Close()
+
+	tool := NewWebSearchToolWithBackends(true, "", 2*time.Second, 5, "test-agent", []WebSearchBackendConfig{
+		{Type: "searxng", Name: "down", BaseURL: down.URL},
+		{Type: "json", Name: "custom", BaseURL: custom.URL + "/api", QueryParam: "query", Params: map[string]string{"lang": "en"},
+			Results: "data.hits", Title: "t", URL: "link", Snippet: "desc"},
+		{Type: "searxng", BaseURL: searx.URL},
+	}, nil)
+	out, err := tool.Execute(context.Background(), map[string]any{"q": "q1", "max_results": 2})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	var res struct {
+		Engine  string            `json:"engine"`
+		Results []webSearchResult `json:"results"`
+		Errors  []string          `json:"errors"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Engine != "custom+searxng" || len(res.Results) != 2 {
+		t.Fatalf("out = %s", out)
+	}
+	if r := res.Results[0]; r.Title != "A one" || r.Snippet != "first & best" || r.URL != "https://a.example/x/" {
+		t.Fatalf("first result = %+v", r)
+	}
+	if res.Results[1].URL != "https://b.example/" {
+		t.Fatalf("second result = %+v, want b.example after dedupe", res.Results[1])
+	}
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "down: non-2xx status=429") {
+		t.Fatalf("errors = %v", res.Errors)
+	}
+
+	// Every backend failing is an error.
+	tool.Backends = tool.Backends[:1]
+	if _, err := tool.Execute(context.Background(), map[string]any{"q": "q1"}); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestWebSearchAuthProfile(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "shh_secret")
+	var gotKey, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.Header.Get("X-Subscription-Token")
+		gotAuth = r.Header.Get("Authorization")
+		if r.URL.Query().Get("count") != "3" {
+			t.Errorf("count = %q", r.URL.Query().Get("count"))
+		}
+		_, _ = w.Write([]byte(`{"web":{"results":[{"title":"Go","url":"https://go.dev/","description":"The <strong>Go</strong> language"}]}}`))
+	}))
+	defer srv.Close()
+
+	// The profile only binds url_fetch; brave falls back to its own header.
+	profile := testProfileForURL(t, "brave", srv.URL+"/", secrets.ToolBinding{
+		Inject: secrets.Inject{Location: "header", Name: "Authorization", Format: "bearer"},
+	})
+	denyPrivate := false
+	profile.Allow.DenyPrivateIPs = &denyPrivate
+	auth := &URLFetchAuth{
+		Enabled:       true,
+		AllowProfiles: map[string]bool{"brave": true},
+		Profiles:      secrets.NewProfileStore(map[string]secrets.AuthProfile{"brave": profile}),
+		Resolver:      &secrets.EnvResolver{},
+	}
+	backends := []WebSearchBackendConfig{{Type: "brave", BaseURL: srv.URL + "/res/v1/web/search", AuthProfile: "brave"}}
+	tool := NewWebSearchToolWithBackends(true, "", 2*time.Second, 3, "test-agent", backends, auth)
+
+	out, err := tool.Execute(context.Background(), map[string]any{"q": "go"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if gotKey != "shh_secret" || gotAuth != "" {
+		t.Fatalf("key header = %q, authorization = %q", gotKey, gotAuth)
+	}
+	if strings.Contains(out, "shh_secret") || !strings.Contains(out, `"snippet": "The Go language"`) {
+		t.Fatalf("out = %s", out)
+	}
+
+	auth.AllowProfiles = map[string]bool{}
+	gotKey = ""
+	if _, err := tool.Execute(context.Background(), map[string]any{"q": "go"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("err = %v, want not allowed", err)
+	}
+	if gotKey != "" {
+		t.Fatal("request sent without an allowed profile")
+	}
+}
+
+func TestWebSearchBackendConfigValidate(t *testing.T) {
+	cases := []struct {
+		cfg     WebSearchBackendConfig
+		wantErr string
+	}{
+		{WebSearchBackendConfig{Type: "duckduckgo"}, ""},
+		{WebSearchBackendConfig{Type: "searxng", BaseURL: "https://searx.example"}, ""},
+		{WebSearchBackendConfig{Type: "searxng"}, "base_url is required"},
+		{WebSearchBackendConfig{Type: "brave"}, "requires auth_profile"},
+		{WebSearchBackendConfig{Type: "google", AuthProfile: "g"}, "requires params.cx"},
+		{WebSearchBackendConfig{Type: "google", AuthProfile: "g", Params: map[string]string{"cx": "123"}}, ""},
+		{WebSearchBackendConfig{Type: "json", BaseURL: "https://api.example/search", Results: "items"}, "results and url"},
+		{WebSearchBackendConfig{Type: "json", BaseURL: "ftp://api.example", Results: "items", URL: "link"}, "http(s)"},
+		{WebSearchBackendConfig{Type: "yahoo"}, "unsupported type"},
+	}
+	for _, c := range cases {
+		err := c.cfg.Validate()
+		if c.wantErr == "" && err != nil {
+			t.Errorf("%+v: unexpected error %v", c.cfg, err)
+		}
+		if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+			t.Errorf("%+v: err = %v, want %q", c.cfg, err, c.wantErr)
+		}
+	}
+}
@@ -21,6 +21,16 @@ func NewWriteFileTool(enabled bool, maxBytes int, baseDirs ...string) *WriteFile
 	if maxBytes <= 0 {
 		maxBytes = 512 * 1024
 	}
+	return &WriteFileTool{
+		Enabled:  enabled,
+		MaxBytes: maxBytes,
+		BaseDirs: writeBaseDirs(baseDirs),
+	}
+}
+
+// writeBaseDirs drops empty entries; the first dir is file_cache_dir and the
+// second file_state_dir.
+func writeBaseDirs(baseDirs []string) []string {
 	cleaned := make([]string, 0, len(baseDirs))
 	for _, dir := range baseDirs {
 		dir = strings.TrimSpace(dir)
@@ -32,11 +42,7 @@ func NewWriteFileTool(enabled bool, maxBytes int, baseDirs ...string) *WriteFile
 	if len(cleaned) == 0 {
 		cleaned = []string{"/var/cache/morph"}
 	}
-	return &WriteFileTool{
-		Enabled:  enabled,
-		MaxBytes: maxBytes,
-		BaseDirs: cleaned,
-	}
+	return cleaned
 }
 
 func (t *WriteFileTool) Name() string { return "write_file" }
@@ -0,0 +1,274 @@
+// Package mcp connects to Model Context Protocol servers (stdio, streamable
+// HTTP or legacy HTTP+SSE) and exposes their tools as tools.Tool, so calls go
+// through the same guard, audit and approval path as built-in tools.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ProtocolVersion is the MCP revision requested during initialization.
+const ProtocolVersion = "2025-03-26"
+
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+	TransportSSE   = "sse"
+)
+
+// ServerConfig describes one MCP server (an entry of `mcp.servers`).
+type ServerConfig struct {
+	// Name identifies the server and prefixes its tool names.
+	Name string `mapstructure:"name"`
+	// Transport is stdio|http|sse. Empty means stdio when Command is set,
+	// otherwise http.
+	Transport string `mapstructure:"transport"`
+
+	// Command, Args, Env ("KEY=VALUE") and Dir launch a stdio server.
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	Env     []string `mapstructure:"env"`
+	Dir     string   `mapstructure:"dir"`
+
+	// URL and Headers connect to an http or sse server.
+	URL     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Tools optionally limits which of the server's tools are registered.
+	Tools []string `mapstructure:"tools"`
+	// Timeout bounds each request to the server (default 60s).
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+func (c ServerConfig) transport() string {
+	t := strings.ToLower(strings.TrimSpace(c.Transport))
+	if t != "" {
+		return t
+	}
+	if strings.TrimSpace(c.Command) != "" {
+		return TransportStdio
+	}
+	return TransportHTTP
+}
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+func (m *rpcMessage) isResponse() bool { return len(m.ID) > 0 && m.Method == "" }
+
+// RPCError is a JSON-RPC error returned by a server.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// transport delivers JSON-RPC messages to one server.
+type transport interface {
+	// roundTrip sends a request and waits for its response.
+	roundTrip(ctx context.Context, msg *rpcMessage) (*rpcMessage, error)
+	// notify sends a notification (no response expected).
+	notify(ctx context.Context, msg *rpcMessage) error
+	close() error
+}
+
+// Client is an initialized session with one MCP server.
+type Client struct {
+	name    string
+	t       transport
+	timeout time.Duration
+	nextID  atomic.Int64
+
+	ServerName    string
+	ServerVersion string
+	Instructions  string
+}
+
+// Connect starts (or dials) the server and performs the MCP handshake. The
+// context only bounds the handshake; a stdio server keeps running until
+// Close.
+func Connect(ctx context.Context, cfg ServerConfig, logger *slog.Logger) (*Client, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	cfg.Name = strings.TrimSpace(cfg.Name)
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("mcp server: missing name")
+	}
+	var (
+		t   transport
+		err error
+	)
+	switch cfg.transport() {
+	case TransportStdio:
+		t, err = startStdio(cfg, logger)
+	case TransportHTTP:
+		t, err = newHTTPTransport(cfg)
+	case TransportSSE:
+		t, err = dialSSE(ctx, cfg)
+	default:
+		err = fmt.Errorf("unsupported transport %q (use stdio|http|sse)", cfg.Transport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", cfg.Name, err)
+	}
+	c := newClient(cfg.Name, t, cfg.Timeout)
+	if err := c.initialize(ctx); err != nil {
+		_ = t.close()
+		return nil, fmt.Errorf("mcp server %s: initialize: %w", cfg.Name, err)
+	}
+	return c, nil
+}
+
+func newClient(name string, t transport, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return &Client{name: name, t: t, timeout: timeout}
+}
+
+// Name returns the configured server name.
+func (c *Client) Name() string { return c.name }
+
+func (c *Client) Close() error {
+	if c == nil || c.t == nil {
+		return nil
+	}
+	return c.t.close()
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var res struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ServerInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+		Instructions string `json:"instructions"`
+	}
+	err := c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "mistermorph", "version": "1.0"},
+	}, &res)
+	if err != nil {
+		return err
+	}
+	if ht, ok := c.t.(*httpTransport); ok {
+		ht.setProtocolVersion(res.ProtocolVersion)
+	}
+	c.ServerName = res.ServerInfo.Name
+	c.ServerVersion = res.ServerInfo.Version
+	c.Instructions = res.Instructions
+	return c.t.notify(ctx, &rpcMessage{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// RemoteTool is a tool as listed by tools/list.
+type RemoteTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+	Annotations *struct {
+		ReadOnlyHint *bool `json:"readOnlyHint,omitempty"`
+	} `json:"annotations,omitempty"`
+}
+
+// ListTools returns all tools of the server, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]RemoteTool, error) {
+	var (
+		out    []RemoteTool
+		cursor string
+	)
+	for page := 0; page < 100; page++ {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var res struct {
+			Tools      []RemoteTool `json:"tools"`
+			NextCursor string       `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &res); err != nil {
+			return nil, err
+		}
+		out = append(out, res.Tools...)
+		cursor = res.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	return out, nil
+}
+
+// Content is one item of a tools/call result.
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Data     string `json:"data,omitempty"`
+	Resource *struct {
+		URI      string `json:"uri"`
+		MimeType string `json:"mimeType,omitempty"`
+		Text     string `json:"text,omitempty"`
+	} `json:"resource,omitempty"`
+}
+
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var res CallToolResult
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, out any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	id := c.nextID.Add(1)
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	resp, err := c.t.roundTrip(ctx, &rpcMessage{
+		JSONRPC: "2.0",
+		ID:      json.RawMessage(strconv.FormatInt(id, 10)),
+		Method:  method,
+		Params:  raw,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil || len(resp.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
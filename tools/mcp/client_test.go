@@ -0,0 +1,182 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+// fakeServer answers initialize, tools/list and tools/call for an "add" tool
+// and a "fail" tool.
+func fakeServer(msg *rpcMessage) *rpcMessage {
+	if !msg.isResponse() && len(msg.ID) == 0 {
+		return nil // notification
+	}
+	resp := &rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+	switch msg.Method {
+	case "initialize":
+		resp.Result = json.RawMessage(`{"protocolVersion":"2025-03-26","capabilities":{"tools":{}},"serverInfo":{"name":"fake","version":"0.1"}}`)
+	case "tools/list":
+		resp.Result = json.RawMessage(`{"tools":[
+			{"name":"add","description":"Add two numbers.","inputSchema":{"type":"object","properties":{"a":{"type":"number"},"b":{"type":"number"}}},"annotations":{"readOnlyHint":true}},
+			{"name":"fail","inputSchema":{"type":"object"}}
+		]}`)
+	case "tools/call":
+		var p struct {
+			Name      string             `json:"name"`
+			Arguments map[string]float64 `json:"arguments"`
+		}
+		_ = json.Unmarshal(msg.Params, &p)
+		if p.Name == "fail" {
+			resp.Result = json.RawMessage(`{"content":[{"type":"text","text":"boom"}],"isError":true}`)
+			break
+		}
+		b, _ := json.Marshal(map[string]any{
+			"content": []map[string]any{{"type": "text", "text": fmt.Sprint(p.Arguments["a"] + p.Arguments["b"])}},
+		})
+		resp.Result = b
+	default:
+		resp.Error = &RPCError{Code: -32601, Message: "method not found"}
+	}
+	return resp
+}
+
+func newPipeClient(t *testing.T) *Client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	go func() {
+		sc := bufio.NewScanner(serverR)
+		for sc.Scan() {
+			var msg rpcMessage
+			if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+				continue
+			}
+			if resp := fakeServer(&msg); resp != nil {
+				b, _ := json.Marshal(resp)
+				_, _ = serverW.Write(append(b, '\n'))
+			}
+		}
+		_ = serverW.Close()
+	}()
+	c := newClient("calc", newStreamTransport(clientR, clientW, nil), 0)
+	if err := c.initialize(context.Background()); err != nil {
+		t.Fatalf("initialize() error = %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestRegisterToolsAndExecute(t *testing.T) {
+	c := newPipeClient(t)
+	if c.ServerName != "fake" {
+		t.Fatalf("ServerName = %q", c.ServerName)
+	}
+
+	reg := tools.NewRegistry()
+	n, err := RegisterTools(context.Background(), reg, c, nil, nil)
+	if err != nil || n != 2 {
+		t.Fatalf("RegisterTools() = %d, %v", n, err)
+	}
+	add, ok := reg.Get("calc__add")
+	if !ok {
+		t.Fatalf("calc__add not registered; have %s", reg.ToolNames())
+	}
+	if !tools.IsReadOnlyCall(add, nil) || !tools.HasUntrustedOutput(add) {
+		t.Fatalf("calc__add should be read-only and untrusted")
+	}
+	if !strings.Contains(add.ParameterSchema(), `"a"`) {
+		t.Fatalf("ParameterSchema() = %s", add.ParameterSchema())
+	}
+	out, err := add.Execute(context.Background(), map[string]any{"a": 2, "b": 3})
+	if err != nil || out != "5" {
+		t.Fatalf("Execute() = %q, %v", out, err)
+	}
+
+	fail, _ := reg.Get("calc__fail")
+	if out, err := fail.Execute(context.Background(), nil); err == nil || out != "boom" {
+		t.Fatalf("fail Execute() = %q, %v", out, err)
+	}
+}
+
+func TestRegisterToolsAllowlistAndConflicts(t *testing.T) {
+	c := newPipeClient(t)
+	reg := tools.NewRegistry()
+	reg.Register(NewTool(c, RemoteTool{Name: "fail"}))
+
+	n, err := RegisterTools(context.Background(), reg, c, []string{"add", "fail"}, nil)
+	if err != nil || n != 1 {
+		t.Fatalf("RegisterTools() = %d, %v (want only add; fail conflicts)", n, err)
+	}
+	n, err = RegisterTools(context.Background(), tools.NewRegistry(), c, []string{"add"}, nil)
+	if err != nil || n != 1 {
+		t.Fatalf("RegisterTools(allow add) = %d, %v", n, err)
+	}
+}
+
+func TestStreamableHTTPTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var msg rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		resp := fakeServer(&msg)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", "s1")
+		b, _ := json.Marshal(resp)
+		if msg.Method == "tools/call" {
+			// Answer tool calls as an SSE stream with a progress notification first.
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			_, _ = fmt.Fprintf(w, "event: message\ndata: %s\n\n", b)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}))
+	defer srv.Close()
+
+	t.Setenv("FAKE_MCP_TOKEN", "secret")
+	c, err := Connect(context.Background(), ServerConfig{
+		Name:    "remote",
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer ${FAKE_MCP_TOKEN}"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer c.Close()
+
+	res, err := c.CallTool(context.Background(), "add", map[string]any{"a": 1, "b": 1})
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+	if got := FormatResult(res); got != "2" {
+		t.Fatalf("FormatResult() = %q", got)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	if got := ToolName("my server", "get.file"); got != "my_server__get_file" {
+		t.Fatalf("ToolName() = %q", got)
+	}
+	if got := ToolName("s", strings.Repeat("x", 100)); len(got) != maxToolNameLen {
+		t.Fatalf("ToolName() length = %d", len(got))
+	}
+}
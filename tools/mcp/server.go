@@ -0,0 +1,247 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+// Server exposes a tools.Registry to MCP clients over newline-delimited
+// JSON-RPC (the stdio transport).
+type Server struct {
+	Name     string
+	Version  string
+	Registry *tools.Registry
+	// Call executes a tool. It defaults to calling the registry tool
+	// directly; set it to route calls through the guard.
+	Call   func(ctx context.Context, name string, params map[string]any) (string, error)
+	Logger *slog.Logger
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+// Serve handles requests from r and writes responses to w until r ends or
+// ctx is canceled. Tool calls run concurrently; notifications/cancelled
+// cancels an in-flight call.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	if s.Logger == nil {
+		s.Logger = slog.Default()
+	}
+	if s.Registry == nil {
+		s.Registry = tools.NewRegistry()
+	}
+	// In-flight calls are canceled (then awaited) once the input ends.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var msg rpcMessage
+			if jerr := json.Unmarshal(line, &msg); jerr != nil {
+				s.write(w, &rpcMessage{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: -32700, Message: "parse error"}})
+			} else if msg.Method == "tools/call" && len(msg.ID) > 0 {
+				callCtx, callCancel := context.WithCancel(ctx)
+				s.track(string(msg.ID), callCancel)
+				wg.Add(1)
+				go func(msg rpcMessage) {
+					defer wg.Done()
+					defer s.untrack(string(msg.ID))
+					s.write(w, s.handle(callCtx, &msg))
+				}(msg)
+			} else if resp := s.handle(ctx, &msg); resp != nil {
+				s.write(w, resp)
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Server) track(id string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running == nil {
+		s.running = make(map[string]context.CancelFunc)
+	}
+	s.running[id] = cancel
+}
+
+func (s *Server) untrack(id string) {
+	s.mu.Lock()
+	cancel := s.running[id]
+	delete(s.running, id)
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (s *Server) write(w io.Writer, msg *rpcMessage) {
+	if msg == nil {
+		return
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, _ = w.Write(append(b, '\n'))
+}
+
+// handle answers one message; it returns nil for notifications and stray
+// responses.
+func (s *Server) handle(ctx context.Context, msg *rpcMessage) *rpcMessage {
+	if len(msg.ID) == 0 {
+		if msg.Method == "notifications/cancelled" {
+			var p struct {
+				RequestID json.RawMessage `json:"requestId"`
+			}
+			if json.Unmarshal(msg.Params, &p) == nil {
+				s.untrack(string(p.RequestID))
+			}
+		}
+		return nil
+	}
+	if msg.isResponse() {
+		return nil
+	}
+
+	resp := &rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+	var (
+		result any
+		err    *RPCError
+	)
+	switch msg.Method {
+	case "initialize":
+		result = s.initialize(msg.Params)
+	case "ping":
+		result = map[string]any{}
+	case "tools/list":
+		result = map[string]any{"tools": s.listTools()}
+	case "tools/call":
+		result, err = s.callTool(ctx, msg.Params)
+	default:
+		err = &RPCError{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+	if err != nil {
+		resp.Error = err
+		return resp
+	}
+	b, merr := json.Marshal(result)
+	if merr != nil {
+		resp.Error = &RPCError{Code: -32603, Message: merr.Error()}
+		return resp
+	}
+	resp.Result = b
+	return resp
+}
+
+func (s *Server) initialize(params json.RawMessage) map[string]any {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		ClientInfo      struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"clientInfo"`
+	}
+	_ = json.Unmarshal(params, &p)
+	s.Logger.Info("mcp_client_initialize", "client", p.ClientInfo.Name, "client_version", p.ClientInfo.Version, "protocol_version", p.ProtocolVersion)
+
+	// Older revisions are compatible for the tools-only surface we serve.
+	version := ProtocolVersion
+	switch p.ProtocolVersion {
+	case "2024-11-05", "2025-03-26", "2025-06-18":
+		version = p.ProtocolVersion
+	}
+	name := s.Name
+	if name == "" {
+		name = "mistermorph"
+	}
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities":    map[string]any{"tools": map[string]any{}},
+		"serverInfo":      map[string]any{"name": name, "version": s.Version},
+	}
+}
+
+func (s *Server) listTools() []map[string]any {
+	all := s.Registry.All()
+	out := make([]map[string]any, 0, len(all))
+	for _, t := range all {
+		schema := json.RawMessage(t.ParameterSchema())
+		if !json.Valid(schema) {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		item := map[string]any{
+			"name":        t.Name(),
+			"description": t.Description(),
+			"inputSchema": schema,
+		}
+		if tools.IsReadOnlyCall(t, nil) {
+			item["annotations"] = map[string]any{"readOnlyHint": true}
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func (s *Server) callTool(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	var p struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, &RPCError{Code: -32602, Message: "invalid params: " + err.Error()}
+	}
+	tool, ok := s.Registry.Get(p.Name)
+	if !ok {
+		return nil, &RPCError{Code: -32602, Message: fmt.Sprintf("unknown tool: %s", p.Name)}
+	}
+	if p.Arguments == nil {
+		p.Arguments = map[string]any{}
+	}
+
+	var (
+		out string
+		err error
+	)
+	if s.Call != nil {
+		out, err = s.Call(ctx, p.Name, p.Arguments)
+	} else {
+		out, err = tool.Execute(ctx, p.Arguments)
+		if err != nil && out == "" {
+			out = "error: " + err.Error()
+		}
+	}
+	if err != nil {
+		s.Logger.Warn("mcp_tool_call_error", "tool", p.Name, "error", err.Error())
+	}
+	if out == "" {
+		out = "(no output)"
+	}
+	return CallToolResult{
+		Content: []Content{{Type: "text", Text: out}},
+		IsError: err != nil,
+	}, nil
+}
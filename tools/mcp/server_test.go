@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/quailyquaily/mistermorph/tools"
+	"github.com/quailyquaily/mistermorph/tools/builtin"
+)
+
+func TestServerRoundTrip(t *testing.T) {
+	reg := tools.NewRegistry()
+	reg.Register(builtin.NewEchoTool())
+
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	srv := &Server{Name: "test", Registry: reg}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(context.Background(), serverR, serverW)
+		_ = serverW.Close()
+	}()
+
+	c := newClient("morph", newStreamTransport(clientR, clientW, nil), 0)
+	if err := c.initialize(context.Background()); err != nil {
+		t.Fatalf("initialize() error = %v", err)
+	}
+	if c.ServerName != "test" {
+		t.Fatalf("ServerName = %q", c.ServerName)
+	}
+
+	remote, err := c.ListTools(context.Background())
+	if err != nil || len(remote) != 1 || remote[0].Name != "echo" {
+		t.Fatalf("ListTools() = %+v, %v", remote, err)
+	}
+	if remote[0].Annotations == nil || remote[0].Annotations.ReadOnlyHint == nil || !*remote[0].Annotations.ReadOnlyHint {
+		t.Fatalf("echo should be annotated read-only")
+	}
+
+	res, err := c.CallTool(context.Background(), "echo", map[string]any{"value": "hi"})
+	if err != nil || res.IsError || FormatResult(res) != "hi" {
+		t.Fatalf("CallTool(echo) = %+v, %v", res, err)
+	}
+	res, err = c.CallTool(context.Background(), "echo", nil)
+	if err != nil || !res.IsError {
+		t.Fatalf("CallTool(echo, no value) = %+v, %v; want isError", res, err)
+	}
+	if _, err := c.CallTool(context.Background(), "missing", nil); err == nil {
+		t.Fatalf("CallTool(missing) should return an RPC error")
+	}
+
+	_ = c.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+}
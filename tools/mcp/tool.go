@@ -0,0 +1,175 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/quailyquaily/mistermorph/tools"
+)
+
+const maxToolNameLen = 64
+
+var invalidToolNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// ToolName returns the registry name of a server tool: "<server>__<tool>",
+// restricted to the characters LLM tool APIs accept.
+func ToolName(server, tool string) string {
+	name := invalidToolNameChars.ReplaceAllString(strings.TrimSpace(server), "_") + "__" +
+		invalidToolNameChars.ReplaceAllString(strings.TrimSpace(tool), "_")
+	if len(name) > maxToolNameLen {
+		name = name[:maxToolNameLen]
+	}
+	return name
+}
+
+// Tool adapts one MCP server tool to tools.Tool.
+type Tool struct {
+	client *Client
+	name   string
+	remote RemoteTool
+}
+
+func NewTool(client *Client, remote RemoteTool) *Tool {
+	return &Tool{client: client, name: ToolName(client.Name(), remote.Name), remote: remote}
+}
+
+func (t *Tool) Name() string { return t.name }
+
+func (t *Tool) Description() string {
+	desc := strings.TrimSpace(t.remote.Description)
+	if desc == "" {
+		desc = "No description provided."
+	}
+	return fmt.Sprintf("[MCP server %s, tool %s] %s", t.client.Name(), t.remote.Name, desc)
+}
+
+func (t *Tool) ParameterSchema() string {
+	if len(t.remote.InputSchema) == 0 {
+		return `{"type":"object","properties":{}}`
+	}
+	return string(t.remote.InputSchema)
+}
+
+// ReadOnly trusts the server's readOnlyHint annotation.
+func (t *Tool) ReadOnly(map[string]any) bool {
+	a := t.remote.Annotations
+	return a != nil && a.ReadOnlyHint != nil && *a.ReadOnlyHint
+}
+
+// UntrustedOutput marks results as external content for the engine.
+func (t *Tool) UntrustedOutput() bool { return true }
+
+func (t *Tool) Execute(ctx context.Context, params map[string]any) (string, error) {
+	res, err := t.client.CallTool(ctx, t.remote.Name, params)
+	if err != nil {
+		return "", err
+	}
+	out := FormatResult(res)
+	if res.IsError {
+		msg := out
+		if len(msg) > 500 {
+			msg = msg[:500] + "..."
+		}
+		return out, fmt.Errorf("mcp tool %s failed: %s", t.remote.Name, msg)
+	}
+	return out, nil
+}
+
+// FormatResult renders a tools/call result as text. Binary content is
+// summarized rather than inlined.
+func FormatResult(res *CallToolResult) string {
+	if res == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(res.Content))
+	for _, c := range res.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s content: %s, %d base64 bytes omitted]", c.Type, c.MimeType, len(c.Data)))
+		case "resource":
+			if c.Resource == nil {
+				continue
+			}
+			if c.Resource.Text != "" {
+				parts = append(parts, fmt.Sprintf("[resource %s]\n%s", c.Resource.URI, c.Resource.Text))
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource %s (%s)]", c.Resource.URI, c.Resource.MimeType))
+			}
+		case "resource_link":
+			b, _ := json.Marshal(c)
+			parts = append(parts, string(b))
+		}
+	}
+	if len(parts) == 0 && len(res.StructuredContent) > 0 {
+		return string(res.StructuredContent)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// RegisterServers connects to every configured server and registers its
+// tools in reg. A server that fails to start or list tools is logged and
+// skipped; tool names already in reg are not overridden. The returned clients
+// stay connected for the life of the process.
+func RegisterServers(ctx context.Context, reg *tools.Registry, servers []ServerConfig, logger *slog.Logger) []*Client {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var clients []*Client
+	for _, cfg := range servers {
+		client, err := Connect(ctx, cfg, logger)
+		if err != nil {
+			logger.Warn("mcp_server_connect_error", "server", cfg.Name, "error", err.Error())
+			continue
+		}
+		n, err := RegisterTools(ctx, reg, client, cfg.Tools, logger)
+		if err != nil {
+			logger.Warn("mcp_server_list_tools_error", "server", cfg.Name, "error", err.Error())
+			_ = client.Close()
+			continue
+		}
+		logger.Info("mcp_server_ready", "server", client.Name(), "server_name", client.ServerName, "server_version", client.ServerVersion, "tools", n)
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// RegisterTools lists the client's tools and registers them in reg. When
+// allow is non-empty, only the named (server-side) tools are registered.
+func RegisterTools(ctx context.Context, reg *tools.Registry, client *Client, allow []string, logger *slog.Logger) (int, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	remote, err := client.ListTools(ctx)
+	if err != nil {
+		return 0, err
+	}
+	allowed := make(map[string]bool, len(allow))
+	for _, name := range allow {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+	n := 0
+	for _, rt := range remote {
+		if strings.TrimSpace(rt.Name) == "" {
+			continue
+		}
+		if len(allowed) > 0 && !allowed[rt.Name] {
+			continue
+		}
+		t := NewTool(client, rt)
+		if _, exists := reg.Get(t.Name()); exists {
+			logger.Warn("mcp_tool_name_conflict", "server", client.Name(), "tool", rt.Name, "name", t.Name())
+			continue
+		}
+		reg.Register(t)
+		n++
+	}
+	return n, nil
+}
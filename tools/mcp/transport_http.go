@@ -0,0 +1,316 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// httpTransport implements the streamable HTTP transport: every message is a
+// POST, and the response is either plain JSON or an SSE stream that carries
+// the response.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+}
+
+func newHTTPTransport(cfg ServerConfig) (*httpTransport, error) {
+	u := strings.TrimSpace(cfg.URL)
+	if u == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	return &httpTransport{url: u, headers: expandHeaders(cfg.Headers), client: &http.Client{}}, nil
+}
+
+func (t *httpTransport) setProtocolVersion(v string) {
+	t.mu.Lock()
+	t.protocolVersion = strings.TrimSpace(v)
+	t.mu.Unlock()
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("Mcp-Protocol-Version", t.protocolVersion)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+func (t *httpTransport) post(ctx context.Context, msg *rpcMessage) (*http.Response, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, b)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if sid := strings.TrimSpace(resp.Header.Get("Mcp-Session-Id")); sid != "" {
+		t.mu.Lock()
+		t.sessionID = sid
+		t.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, msg *rpcMessage) (*rpcMessage, error) {
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var out rpcMessage
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, fmt.Errorf("decode response: %w", err)
+		}
+		return &out, nil
+	}
+
+	var out *rpcMessage
+	err = readSSE(resp.Body, func(_ string, data string) bool {
+		var m rpcMessage
+		if json.Unmarshal([]byte(data), &m) != nil {
+			return true
+		}
+		if m.isResponse() && string(m.ID) == string(msg.ID) {
+			out = &m
+			return false
+		}
+		return true
+	})
+	if out != nil {
+		return out, nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, fmt.Errorf("no response in event stream: %w", err)
+}
+
+func (t *httpTransport) notify(ctx context.Context, msg *rpcMessage) error {
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// close ends the session on the server, if it issued one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sid := t.sessionID
+	t.mu.Unlock()
+	if sid == "" {
+		return nil
+	}
+	req, err := t.newRequest(context.Background(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// sseTransport implements the legacy HTTP+SSE transport: a long-lived GET
+// stream announces a POST endpoint and carries all responses.
+type sseTransport struct {
+	d        *dispatcher
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	cancel   context.CancelFunc
+}
+
+func dialSSE(ctx context.Context, cfg ServerConfig) (transport, error) {
+	u := strings.TrimSpace(cfg.URL)
+	if u == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, u, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	headers := expandHeaders(cfg.Headers)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("http %d", resp.StatusCode)
+	}
+
+	t := &sseTransport{headers: headers, client: client, cancel: cancel}
+	t.d = newDispatcher(func(msg *rpcMessage) { _ = t.send(context.Background(), msg) })
+	endpoint := make(chan string, 1)
+	go func() {
+		defer resp.Body.Close()
+		err := readSSE(resp.Body, func(event string, data string) bool {
+			switch event {
+			case "endpoint":
+				select {
+				case endpoint <- strings.TrimSpace(data):
+				default:
+				}
+			case "", "message":
+				var m rpcMessage
+				if json.Unmarshal([]byte(data), &m) == nil {
+					t.d.deliver(&m)
+				}
+			}
+			return true
+		})
+		t.d.fail(err)
+	}()
+
+	select {
+	case ep := <-endpoint:
+		base, _ := url.Parse(u)
+		ref, err := url.Parse(ep)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid endpoint %q: %w", ep, err)
+		}
+		t.endpoint = base.ResolveReference(ref).String()
+		return t, nil
+	case <-t.d.done:
+		cancel()
+		return nil, fmt.Errorf("stream closed before endpoint event: %w", t.d.err)
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+}
+
+func (t *sseTransport) send(ctx context.Context, msg *rpcMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("http %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (t *sseTransport) roundTrip(ctx context.Context, msg *rpcMessage) (*rpcMessage, error) {
+	ch, unregister := t.d.register(msg.ID)
+	defer unregister()
+	if err := t.send(ctx, msg); err != nil {
+		return nil, err
+	}
+	return t.d.wait(ctx, ch)
+}
+
+func (t *sseTransport) notify(ctx context.Context, msg *rpcMessage) error {
+	return t.send(ctx, msg)
+}
+
+func (t *sseTransport) close() error {
+	t.cancel()
+	return nil
+}
+
+// readSSE calls fn for each event until fn returns false or the stream ends.
+func readSSE(r io.Reader, fn func(event string, data string) bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var (
+		event string
+		data  []string
+	)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if !fn(event, strings.Join(data, "\n")) {
+					return nil
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return sc.Err()
+}
+
+// expandHeaders resolves ${VAR} references so tokens can stay in the
+// environment instead of the config file.
+func expandHeaders(h map[string]string) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		out[k] = os.ExpandEnv(v)
+	}
+	return out
+}
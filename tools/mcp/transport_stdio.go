@@ -0,0 +1,223 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dispatcher matches responses arriving on a shared stream to the requests
+// waiting for them, and answers requests the server sends to the client.
+type dispatcher struct {
+	mu      sync.Mutex
+	pending map[string]chan *rpcMessage
+	done    chan struct{}
+	err     error
+	// reply sends a response to a server-initiated request.
+	reply func(msg *rpcMessage)
+}
+
+func newDispatcher(reply func(msg *rpcMessage)) *dispatcher {
+	return &dispatcher{
+		pending: make(map[string]chan *rpcMessage),
+		done:    make(chan struct{}),
+		reply:   reply,
+	}
+}
+
+func (d *dispatcher) register(id json.RawMessage) (chan *rpcMessage, func()) {
+	ch := make(chan *rpcMessage, 1)
+	key := string(id)
+	d.mu.Lock()
+	d.pending[key] = ch
+	d.mu.Unlock()
+	return ch, func() {
+		d.mu.Lock()
+		delete(d.pending, key)
+		d.mu.Unlock()
+	}
+}
+
+func (d *dispatcher) deliver(msg *rpcMessage) {
+	switch {
+	case msg.isResponse():
+		d.mu.Lock()
+		ch := d.pending[string(msg.ID)]
+		delete(d.pending, string(msg.ID))
+		d.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	case len(msg.ID) > 0 && d.reply != nil:
+		// Server-initiated request: answer pings, reject everything else
+		// (sampling, roots, elicitation are not supported).
+		resp := &rpcMessage{JSONRPC: "2.0", ID: msg.ID}
+		if msg.Method == "ping" {
+			resp.Result = json.RawMessage(`{}`)
+		} else {
+			resp.Error = &RPCError{Code: -32601, Message: "method not supported by client: " + msg.Method}
+		}
+		d.reply(resp)
+	}
+}
+
+// fail stops the dispatcher; waiting and future requests get err.
+func (d *dispatcher) fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	select {
+	case <-d.done:
+		return
+	default:
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	d.err = err
+	close(d.done)
+}
+
+func (d *dispatcher) wait(ctx context.Context, ch chan *rpcMessage) (*rpcMessage, error) {
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-d.done:
+		return nil, fmt.Errorf("connection closed: %w", d.err)
+	}
+}
+
+// streamTransport speaks newline-delimited JSON-RPC over a reader/writer
+// pair, as used by stdio servers.
+type streamTransport struct {
+	d       *dispatcher
+	writeMu sync.Mutex
+	w       io.WriteCloser
+	closeFn func() error
+	once    sync.Once
+}
+
+func newStreamTransport(r io.Reader, w io.WriteCloser, closeFn func() error) *streamTransport {
+	t := &streamTransport{w: w, closeFn: closeFn}
+	t.d = newDispatcher(func(msg *rpcMessage) { _ = t.write(msg) })
+	go t.readLoop(r)
+	return t
+}
+
+func (t *streamTransport) readLoop(r io.Reader) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var msg rpcMessage
+			if json.Unmarshal(line, &msg) == nil {
+				t.d.deliver(&msg)
+			}
+		}
+		if err != nil {
+			t.d.fail(err)
+			return
+		}
+	}
+}
+
+func (t *streamTransport) write(msg *rpcMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.w.Write(append(b, '\n'))
+	return err
+}
+
+func (t *streamTransport) roundTrip(ctx context.Context, msg *rpcMessage) (*rpcMessage, error) {
+	ch, unregister := t.d.register(msg.ID)
+	defer unregister()
+	if err := t.write(msg); err != nil {
+		return nil, err
+	}
+	return t.d.wait(ctx, ch)
+}
+
+func (t *streamTransport) notify(_ context.Context, msg *rpcMessage) error {
+	return t.write(msg)
+}
+
+func (t *streamTransport) close() error {
+	var err error
+	t.once.Do(func() {
+		err = t.w.Close()
+		if t.closeFn != nil {
+			if cerr := t.closeFn(); err == nil {
+				err = cerr
+			}
+		}
+		t.d.fail(io.EOF)
+	})
+	return err
+}
+
+// startStdio launches the server process. Closing its stdin asks it to exit;
+// it is killed if it is still running a few seconds later.
+func startStdio(cfg ServerConfig, logger *slog.Logger) (transport, error) {
+	command := strings.TrimSpace(cfg.Command)
+	if command == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+	cmd := exec.Command(command, cfg.Args...)
+	cmd.Dir = strings.TrimSpace(cfg.Dir)
+	cmd.Env = os.Environ()
+	for _, kv := range cfg.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.TrimSpace(k) != "" {
+			cmd.Env = append(cmd.Env, strings.TrimSpace(k)+"="+os.ExpandEnv(v))
+		}
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			logger.Debug("mcp_server_stderr", "server", cfg.Name, "line", sc.Text())
+		}
+	}()
+
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+	return newStreamTransport(stdout, stdin, func() error {
+		select {
+		case <-exited:
+		case <-time.After(3 * time.Second):
+			_ = cmd.Process.Kill()
+			<-exited
+		}
+		return nil
+	}), nil
+}
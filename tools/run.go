@@ -0,0 +1,25 @@
+package tools
+
+import "context"
+
+// RunScopedTool is optionally implemented by tools that keep state for the
+// run that created it, such as background processes. The engine calls
+// EndRun when a run finishes (other than pausing for an approval), so the
+// tool can release what the run left behind.
+type RunScopedTool interface {
+	EndRun(runID string)
+}
+
+type runIDKey struct{}
+
+// WithRunID records the current run's id for tools.
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// RunIDFromContext returns the run id set by WithRunID, or "" outside a
+// run (e.g. a direct MCP call).
+func RunIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
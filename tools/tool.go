@@ -8,3 +8,27 @@ type Tool interface {
 	ParameterSchema() string
 	Execute(ctx context.Context, params map[string]any) (string, error)
 }
+
+// ReadOnlyTool is optionally implemented by tools whose calls (for the given
+// params) do not change local or remote state. The engine may run such calls
+// concurrently when several are requested in one step.
+type ReadOnlyTool interface {
+	ReadOnly(params map[string]any) bool
+}
+
+// UntrustedOutputTool is optionally implemented by tools whose output comes
+// from outside the agent's control (web pages, external servers). The engine
+// wraps such observations so the model treats them as data, not instructions.
+type UntrustedOutputTool interface {
+	UntrustedOutput() bool
+}
+
+func HasUntrustedOutput(t Tool) bool {
+	u, ok := t.(UntrustedOutputTool)
+	return ok && u.UntrustedOutput()
+}
+
+func IsReadOnlyCall(t Tool, params map[string]any) bool {
+	ro, ok := t.(ReadOnlyTool)
+	return ok && ro.ReadOnly(params)
+}